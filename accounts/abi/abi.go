@@ -21,6 +21,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+
+	"github.com/vntchain/go-vnt/common"
 )
 
 // The ABI holds information about a contract's context and available
@@ -30,7 +32,7 @@ type ABI struct {
 	Constructor Method
 	Methods     map[string]Method
 	Events      map[string]Event
-	Calls       map[string]Method
+	Calls       map[string]Method // Methods of other contracts called by the contract
 	// Keys        map[string]Key
 }
 
@@ -183,3 +185,14 @@ func (abi *ABI) MethodById(sigdata []byte) (*Method, error) {
 	}
 	return nil, fmt.Errorf("no method with id: %#x", sigdata[:4])
 }
+
+// EventById looks up an event by the signature hash found in the first topic
+// of its logs, returns nil if none found
+func (abi *ABI) EventById(topic common.Hash) (*Event, error) {
+	for _, event := range abi.Events {
+		if !event.Anonymous && event.Id() == topic {
+			return &event, nil
+		}
+	}
+	return nil, fmt.Errorf("no event with id: %#x", topic)
+}
@@ -19,8 +19,11 @@ package abi
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"reflect"
 	"strings"
+
+	"github.com/vntchain/go-vnt/common/hexutil"
 )
 
 // Argument holds the name of the argument and the corresponding type.
@@ -276,6 +279,57 @@ func (arguments Arguments) Pack(args ...interface{}) ([]byte, error) {
 	return ret, nil
 }
 
+// NamedValue is a decoded argument value labelled with the name and type of
+// the argument it was unpacked from.
+type NamedValue struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON implements json.Marshaler, rendering integers of any width and
+// byte slices in the hex encoding used throughout the RPC API.
+func (v NamedValue) MarshalJSON() ([]byte, error) {
+	type namedValue NamedValue
+	enc := namedValue(v)
+	switch value := v.Value.(type) {
+	case *big.Int:
+		enc.Value = (*hexutil.Big)(value)
+	case uint8:
+		enc.Value = hexutil.Uint64(value)
+	case uint16:
+		enc.Value = hexutil.Uint64(value)
+	case uint32:
+		enc.Value = hexutil.Uint64(value)
+	case uint64:
+		enc.Value = hexutil.Uint64(value)
+	case int8:
+		enc.Value = (*hexutil.Big)(big.NewInt(int64(value)))
+	case int16:
+		enc.Value = (*hexutil.Big)(big.NewInt(int64(value)))
+	case int32:
+		enc.Value = (*hexutil.Big)(big.NewInt(int64(value)))
+	case int64:
+		enc.Value = (*hexutil.Big)(big.NewInt(value))
+	case []byte:
+		enc.Value = hexutil.Bytes(value)
+	}
+	return json.Marshal(enc)
+}
+
+// NamedValues pairs the given values, as returned by UnpackValues or
+// Event.UnpackLog, with the arguments they were decoded from.
+func (arguments Arguments) NamedValues(values []interface{}) ([]NamedValue, error) {
+	if len(arguments) != len(values) {
+		return nil, fmt.Errorf("abi: argument count mismatch: %d for %d", len(values), len(arguments))
+	}
+	named := make([]NamedValue, len(arguments))
+	for i, arg := range arguments {
+		named[i] = NamedValue{Name: arg.Name, Type: arg.Type.String(), Value: values[i]}
+	}
+	return named, nil
+}
+
 // capitalise makes the first character of a string upper case, also removing any
 // prefixing underscores from the variable names.
 func capitalise(input string) string {
@@ -18,6 +18,7 @@ package backends
 
 import (
 	"context"
+	"crypto/ecdsa"
 	"errors"
 	"fmt"
 	"math/big"
@@ -25,9 +26,12 @@ import (
 	"time"
 
 	hubble "github.com/vntchain/go-vnt"
+	"github.com/vntchain/go-vnt/accounts"
 	"github.com/vntchain/go-vnt/accounts/abi/bind"
 	"github.com/vntchain/go-vnt/common"
 	"github.com/vntchain/go-vnt/common/math"
+	"github.com/vntchain/go-vnt/consensus"
+	"github.com/vntchain/go-vnt/consensus/dpos"
 	"github.com/vntchain/go-vnt/consensus/mock"
 	"github.com/vntchain/go-vnt/core"
 	"github.com/vntchain/go-vnt/core/bloombits"
@@ -35,6 +39,7 @@ import (
 	"github.com/vntchain/go-vnt/core/state"
 	"github.com/vntchain/go-vnt/core/types"
 	"github.com/vntchain/go-vnt/core/vm"
+	"github.com/vntchain/go-vnt/crypto"
 	"github.com/vntchain/go-vnt/event"
 	"github.com/vntchain/go-vnt/params"
 	"github.com/vntchain/go-vnt/rpc"
@@ -47,6 +52,7 @@ var _ bind.ContractBackend = (*SimulatedBackend)(nil)
 
 var errBlockNumberUnsupported = errors.New("SimulatedBackend cannot access blocks other than the latest block")
 var errGasEstimationFailed = errors.New("gas required exceeds allowance or always failing transaction")
+var errNoLocalWitness = errors.New("no local witness in turn to produce the block")
 
 // SimulatedBackend implements bind.ContractBackend, simulating a blockchain in
 // the background. Its main purpose is to allow easily testing contract bindings.
@@ -60,7 +66,12 @@ type SimulatedBackend struct {
 
 	events *filters.EventSystem // Event system for filtering log events live
 
-	config *params.ChainConfig
+	config     *params.ChainConfig
+	engine     consensus.Engine // Consensus engine producing the pending block
+	timeOffset int64            // Shift of the pending block time set by AdjustTime
+
+	dpos      *dpos.Dpos                           // DPoS engine sealing the blocks, nil with the mock engine
+	witnesses map[common.Address]*ecdsa.PrivateKey // Keys of the local witnesses in DPoS mode
 }
 
 // NewSimulatedBackend creates a new binding backend using a simulated blockchain
@@ -75,6 +86,53 @@ func NewSimulatedBackend(alloc core.GenesisAlloc) *SimulatedBackend {
 		database:   database,
 		blockchain: blockchain,
 		config:     genesis.Config,
+		engine:     mock.NewMock(),
+		events:     filters.NewEventSystem(new(event.TypeMux), &filterBackend{database, blockchain}, false),
+	}
+	backend.rollback()
+	return backend
+}
+
+// NewDposSimulatedBackend creates a new binding backend using a simulated
+// blockchain, which follows the rules of the DPoS consensus engine. The first
+// witnessesNum keys are the witnesses of the genesis block, and all the keys are
+// local witnesses: each block is produced by the local witness in turn and
+// finalized by the commit messages of all the local witnesses in its witness
+// list, so the keys must hold a quorum of the witnesses.
+//
+// Producing rewards are granted to the candidates registered in the election
+// contract, and the witness list is updated from its candidates as on a real
+// chain. AdjustTime moves the pending block to the following witness-update
+// epochs.
+func NewDposSimulatedBackend(alloc core.GenesisAlloc, witnessesNum int, keys []*ecdsa.PrivateKey) *SimulatedBackend {
+	if len(keys) < witnessesNum {
+		panic(fmt.Errorf("too few witness keys: have %d, want %d", len(keys), witnessesNum))
+	}
+	config, dposConfig := *params.TestChainConfig, *params.TestChainConfig.Dpos
+	dposConfig.WitnessesNum = witnessesNum
+	config.Dpos = &dposConfig
+
+	witnesses := make(map[common.Address]*ecdsa.PrivateKey, len(keys))
+	genesis := core.Genesis{Config: &config, Alloc: alloc, Timestamp: 1546272000 + 10000}
+	for i, key := range keys {
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		witnesses[addr] = key
+		if i < witnessesNum {
+			genesis.Witnesses = append(genesis.Witnesses, addr)
+		}
+	}
+	database := vntdb.NewMemDatabase()
+	genesis.MustCommit(database)
+	engine := dpos.New(config.Dpos, database)
+	blockchain, _ := core.NewBlockChain(database, nil, genesis.Config, engine, vm.Config{})
+
+	backend := &SimulatedBackend{
+		database:   database,
+		blockchain: blockchain,
+		config:     genesis.Config,
+		engine:     engine,
+		dpos:       engine,
+		witnesses:  witnesses,
 		events:     filters.NewEventSystem(new(event.TypeMux), &filterBackend{database, blockchain}, false),
 	}
 	backend.rollback()
@@ -102,13 +160,113 @@ func (b *SimulatedBackend) Rollback() {
 }
 
 func (b *SimulatedBackend) rollback() {
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), mock.NewMock(), b.database, 1, func(int, *core.BlockGen) {})
+	b.timeOffset = 0
+	b.generate(nil)
+}
+
+// generate rebuilds the pending block and state on top of the current head,
+// with the given transactions and the time shift set by AdjustTime.
+func (b *SimulatedBackend) generate(txs []*types.Transaction) {
+	parent := b.blockchain.CurrentBlock()
+
+	var prepared *types.Header
+	if b.dpos != nil {
+		var err error
+		if prepared, err = b.prepareDpos(parent); err != nil {
+			panic(err)
+		}
+	}
+	blocks, _ := core.GenerateChain(b.config, parent, b.engine, b.database, 1, func(number int, block *core.BlockGen) {
+		if prepared != nil {
+			block.SetCoinbase(prepared.Coinbase)
+			block.SetWitnesses(prepared.Witnesses)
+			block.SetExtra(prepared.Extra)
+			// The generated block is timed two seconds after its parent
+			block.OffsetTime(new(big.Int).Sub(prepared.Time, parent.Time()).Int64() - 2)
+		} else if b.timeOffset != 0 {
+			block.OffsetTime(b.timeOffset)
+		}
+		for _, tx := range txs {
+			block.AddTxWithChain(b.blockchain, tx)
+		}
+	})
 	statedb, _ := b.blockchain.State()
 
 	b.pendingBlock = blocks[0]
+	if b.dpos != nil {
+		b.pendingBlock = b.sealDpos(parent, b.pendingBlock)
+	}
 	b.pendingState, _ = state.New(b.pendingBlock.Root(), statedb.Database())
 }
 
+// prepareDpos returns the header fields of the block following parent in DPoS
+// mode. The block is timed at the first slot, not earlier than the time shift
+// set by AdjustTime, in which a local witness is in turn to produce it.
+func (b *SimulatedBackend) prepareDpos(parent *types.Block) (*types.Header, error) {
+	statedb, err := b.blockchain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	period := int64(b.config.Dpos.Period)
+	slot := (b.timeOffset + 2*period - 1) / period
+	if slot < 1 {
+		slot = 1
+	}
+	for i := int64(0); i < int64(b.config.Dpos.WitnessesNum); i++ {
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			Number:     new(big.Int).Add(parent.Number(), common.Big1),
+			Time:       new(big.Int).Add(parent.Time(), big.NewInt((slot+i)*period)),
+		}
+		producer, err := b.dpos.PrepareWitnesses(b.blockchain, header, statedb)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := b.witnesses[producer]; ok {
+			header.Coinbase = producer
+			return header, nil
+		}
+	}
+	return nil, errNoLocalWitness
+}
+
+// sealDpos signs block by its producer and finalizes it with the commit
+// messages of the local witnesses in its witness list.
+func (b *SimulatedBackend) sealDpos(parent *types.Block, block *types.Block) *types.Block {
+	header := block.Header()
+	if err := dpos.SignHeader(header, b.signWitness); err != nil {
+		panic(err)
+	}
+	block = block.WithSeal(header)
+
+	// The bft round starts at zero in the first slot after the parent
+	elapsed := new(big.Int).Sub(header.Time, parent.Time()).Uint64()
+	round := uint32(elapsed/b.config.Dpos.Period) - 1
+
+	var msgs []*types.CommitMsg
+	for _, witness := range header.Witnesses {
+		if _, ok := b.witnesses[witness]; !ok {
+			continue
+		}
+		msg, err := dpos.NewCommitMsg(block, round, witness, b.signWitness)
+		if err != nil {
+			panic(err)
+		}
+		msgs = append(msgs, msg)
+	}
+	block.FillBftMsg(msgs)
+	return block
+}
+
+// signWitness signs hash with the key of a local witness.
+func (b *SimulatedBackend) signWitness(account accounts.Account, hash []byte) ([]byte, error) {
+	key, ok := b.witnesses[account.Address]
+	if !ok {
+		return nil, fmt.Errorf("unknown witness %s", account.Address.Hex())
+	}
+	return crypto.Sign(hash, key)
+}
+
 // CodeAt returns the code associated with a certain account in the blockchain.
 func (b *SimulatedBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
 	b.mu.Lock()
@@ -306,16 +464,7 @@ func (b *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transa
 		panic(fmt.Errorf("invalid transaction nonce: got %d, want %d", tx.Nonce(), nonce))
 	}
 
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), mock.NewMock(), b.database, 1, func(number int, block *core.BlockGen) {
-		for _, tx := range b.pendingBlock.Transactions() {
-			block.AddTxWithChain(b.blockchain, tx)
-		}
-		block.AddTxWithChain(b.blockchain, tx)
-	})
-	statedb, _ := b.blockchain.State()
-
-	b.pendingBlock = blocks[0]
-	b.pendingState, _ = state.New(b.pendingBlock.Root(), statedb.Database())
+	b.generate(append(b.pendingBlock.Transactions(), tx))
 	return nil
 }
 
@@ -381,21 +530,15 @@ func (b *SimulatedBackend) SubscribeFilterLogs(ctx context.Context, query hubble
 	}), nil
 }
 
-// AdjustTime adds a time shift to the simulated clock.
+// AdjustTime adds a time shift to the simulated clock, which applies to the
+// pending block until it's committed or rolled back. In DPoS mode the block is
+// moved to the first slot of a local witness after the shift.
 func (b *SimulatedBackend) AdjustTime(adjustment time.Duration) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), mock.NewMock(), b.database, 1, func(number int, block *core.BlockGen) {
-		for _, tx := range b.pendingBlock.Transactions() {
-			block.AddTx(tx)
-		}
-		block.OffsetTime(int64(adjustment.Seconds()))
-	})
-	statedb, _ := b.blockchain.State()
-
-	b.pendingBlock = blocks[0]
-	b.pendingState, _ = state.New(b.pendingBlock.Root(), statedb.Database())
 
+	b.timeOffset += int64(adjustment.Seconds())
+	b.generate(b.pendingBlock.Transactions())
 	return nil
 }
 
@@ -430,6 +573,15 @@ func (fb *filterBackend) HeaderByNumber(ctx context.Context, block rpc.BlockNumb
 	return fb.bc.GetHeaderByNumber(uint64(block.Int64())), nil
 }
 
+func (fb *filterBackend) StateAndHeaderByNumber(ctx context.Context, block rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	header, err := fb.HeaderByNumber(ctx, block)
+	if header == nil || err != nil {
+		return nil, nil, err
+	}
+	statedb, err := fb.bc.StateAt(header.Root)
+	return statedb, header, err
+}
+
 func (fb *filterBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
 	number := rawdb.ReadHeaderNumber(fb.db, hash)
 	if number == nil {
@@ -0,0 +1,148 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vntchain/go-vnt/accounts/abi"
+	"github.com/vntchain/go-vnt/accounts/abi/bind"
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/consensus/dpos"
+	"github.com/vntchain/go-vnt/core"
+	"github.com/vntchain/go-vnt/core/types"
+	"github.com/vntchain/go-vnt/core/vm/election"
+	"github.com/vntchain/go-vnt/crypto"
+	"github.com/vntchain/go-vnt/params"
+)
+
+func vnt(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(params.Vnt))
+}
+
+// Tests that the DPoS simulated backend produces blocks accepted by the DPoS
+// rules, and that election calls, rewards and witness updates take effect.
+func TestDposSimulatedBackend(t *testing.T) {
+	var keys []*ecdsa.PrivateKey
+	for i := 0; i < 4; i++ {
+		key, _ := crypto.GenerateKey()
+		keys = append(keys, key)
+	}
+	binderKey, _ := crypto.GenerateKey()
+	voterKey, _ := crypto.GenerateKey()
+	binder := crypto.PubkeyToAddress(binderKey.PublicKey)
+	voter := crypto.PubkeyToAddress(voterKey.PublicKey)
+	candidates := []common.Address{crypto.PubkeyToAddress(keys[2].PublicKey), crypto.PubkeyToAddress(keys[3].PublicKey)}
+
+	sim := NewDposSimulatedBackend(core.GenesisAlloc{
+		binder:        {Balance: vnt(30000000)},
+		voter:         {Balance: vnt(100)},
+		candidates[0]: {Balance: vnt(1)},
+		candidates[1]: {Balance: vnt(1)},
+	}, 2, keys)
+	sim.Commit()
+
+	genesis := sim.blockchain.GetBlockByNumber(0)
+	head := sim.blockchain.CurrentBlock()
+	if head.NumberU64() != 1 || head.Coinbase() != genesis.Witnesses()[0] || len(head.CmtMsges()) != 2 {
+		t.Fatalf("block 1 mismatch: number %d, coinbase %x, commits %d", head.NumberU64(), head.Coinbase(), len(head.CmtMsges()))
+	}
+
+	// Register, bind and vote the candidates, and deposit the rewards, all in
+	// the same block to stay in the genesis witnesses' epoch
+	parsed, _ := abi.JSON(strings.NewReader(election.ElectionAbiJSON))
+	contract := bind.NewBoundContract(common.HexToAddress(election.ContractAddr), parsed, sim, sim, sim)
+	var txs []*types.Transaction
+	transact := func(key *ecdsa.PrivateKey, value *big.Int, method string, params ...interface{}) {
+		opts := bind.NewKeyedTransactor(key, sim.config.ChainID)
+		opts.GasLimit, opts.Value = 100000, value
+		tx, err := contract.Transact(opts, method, params...)
+		if err != nil {
+			t.Fatalf("failed to send %s: %v", method, err)
+		}
+		txs = append(txs, tx)
+	}
+	nodes := []string{"nodea", "nodeb"}
+	urls := []string{
+		"/ip4/127.0.0.1/tcp/30303/ipfs/1kHNAAfnqXNsxMwJf6QjJFRmVK7iB32U9owwK9KfeLFxEA7",
+		"/ip4/127.0.0.1/tcp/30303/ipfs/1kHcch6yuBCgC5nPPSK3Yp7Es4c4eenxAeK167pYwUvNjRo",
+	}
+	for i, candidate := range candidates {
+		transact(keys[2+i], nil, "registerWitness", []byte(urls[i]), []byte("www."+nodes[i]+".com"), []byte(nodes[i]), binder, candidate)
+		transact(binderKey, vnt(10000000), "$bindCandidate", candidate, candidate)
+	}
+	transact(voterKey, vnt(10), "$stake")
+	transact(voterKey, nil, "voteWitnesses", candidates)
+	transact(binderKey, vnt(100), "$depositReward")
+	sim.Commit()
+
+	for i, tx := range txs {
+		if receipt, _ := sim.TransactionReceipt(context.Background(), tx.Hash()); receipt == nil || receipt.Status != types.ReceiptStatusSuccessful {
+			t.Fatalf("election transaction %d failed: %+v", i, receipt)
+		}
+	}
+	statedb, _ := sim.blockchain.State()
+	for _, candidate := range candidates {
+		if c := election.GetCandidate(statedb, candidate); c == nil || !c.Active() || c.VoteCount.Sign() <= 0 {
+			t.Fatalf("candidate %x not elected: %v", candidate, c)
+		}
+	}
+	if head := sim.blockchain.CurrentHeader(); head.Witnesses[0] != genesis.Witnesses()[0] {
+		t.Fatalf("witnesses updated before the epoch: %x", head.Witnesses)
+	}
+
+	// Move to the next witness-update epoch, the candidates become witnesses
+	sim.AdjustTime(time.Duration(3*2*params.TestChainConfig.Dpos.Period) * time.Second)
+	sim.Commit()
+
+	head = sim.blockchain.CurrentBlock()
+	if len(head.Witnesses()) != 2 || !containsAddr(candidates, head.Witnesses()[0]) || !containsAddr(candidates, head.Witnesses()[1]) {
+		t.Fatalf("witnesses not updated: %x", head.Witnesses())
+	}
+
+	// The producer of the next block is rewarded
+	statedb, _ = sim.blockchain.State()
+	balances := map[common.Address]*big.Int{}
+	for _, candidate := range candidates {
+		balances[candidate] = statedb.GetBalance(candidate)
+	}
+	sim.Commit()
+
+	head = sim.blockchain.CurrentBlock()
+	if !containsAddr(candidates, head.Coinbase()) {
+		t.Fatalf("block produced by %x, not by a candidate", head.Coinbase())
+	}
+	statedb, _ = sim.blockchain.State()
+	reward := new(big.Int).Sub(statedb.GetBalance(head.Coinbase()), balances[head.Coinbase()])
+	if reward.Cmp(dpos.VortexBlockReward) != 0 {
+		t.Errorf("producer reward mismatch: have %v, want %v", reward, dpos.VortexBlockReward)
+	}
+}
+
+func containsAddr(addrs []common.Address, addr common.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
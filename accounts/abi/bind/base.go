@@ -26,6 +26,7 @@ import (
 	"github.com/vntchain/go-vnt/accounts/abi"
 	"github.com/vntchain/go-vnt/common"
 	"github.com/vntchain/go-vnt/core/types"
+	"github.com/vntchain/go-vnt/core/wavm/utils"
 	"github.com/vntchain/go-vnt/crypto"
 	"github.com/vntchain/go-vnt/event"
 )
@@ -114,6 +115,13 @@ func DeployContract(opts *TransactOpts, abi abi.ABI, bytecode []byte, backend Co
 	return c.address, tx, c, nil
 }
 
+// DeployWasmContract deploys a WASM contract onto the VNT blockchain, packing
+// its code along with its ABI into the deployment code, and binds the deployment
+// address with a Go wrapper.
+func DeployWasmContract(opts *TransactOpts, abi abi.ABI, abiJSON string, wasm []byte, backend ContractBackend, params ...interface{}) (common.Address, *types.Transaction, *BoundContract, error) {
+	return DeployContract(opts, abi, utils.CompressWasmAndAbi([]byte(abiJSON), wasm, nil), backend, params...)
+}
+
 // Call invokes the (constant) contract method with params as input values and
 // sets the output to result. The result type might be a single field for simple
 // returns, a slice of interfaces for anonymous returns and a struct for named
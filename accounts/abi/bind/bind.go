@@ -63,7 +63,9 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string, lang La
 			return r
 		}, abis[i])
 
-		// Extract the call and transact methods; events; and sort them alphabetically
+		// Extract the call and transact methods; events; and sort them alphabetically.
+		// The calls of the ABI declare the methods of other contracts invoked by
+		// this one, so they aren't bound.
 		var (
 			calls     = make(map[string]*tmplMethod)
 			transacts = make(map[string]*tmplMethod)
@@ -381,8 +383,8 @@ func namedTypeJava(javaKind string, solKind abi.Type) string {
 // methodNormalizer is a name transformer that modifies Solidity method names to
 // conform to target language naming concentions.
 var methodNormalizer = map[Lang]func(string) string{
-	LangGo:   capitalise,
-	LangJava: decapitalise,
+	LangGo:   func(name string) string { return capitalise(strings.TrimPrefix(name, abi.PayablePrefix)) },
+	LangJava: func(name string) string { return decapitalise(strings.TrimPrefix(name, abi.PayablePrefix)) },
 }
 
 // capitalise makes a camel-case string which starts with an upper case character.
@@ -0,0 +1,146 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vntchain/go-vnt/common/hexutil"
+	"github.com/vntchain/go-vnt/core/wavm/utils"
+)
+
+// wasmTokenTester exercises the binding of the ERC20 token of the WAVM tests
+// against a simulated backend.
+const wasmTokenTester = `
+package bindtest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/vntchain/go-vnt/accounts/abi/bind"
+	"github.com/vntchain/go-vnt/accounts/abi/bind/backends"
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/core"
+	"github.com/vntchain/go-vnt/crypto"
+	"github.com/vntchain/go-vnt/params"
+)
+
+func TestToken(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	auth := bind.NewKeyedTransactor(key, params.TestChainConfig.ChainID)
+	sim := backends.NewSimulatedBackend(core.GenesisAlloc{auth.From: {Balance: big.NewInt(params.Vnt)}})
+
+	_, _, token, err := DeployToken(auth, sim, big.NewInt(1000), "bitcoin", "BTC")
+	if err != nil {
+		t.Fatalf("failed to deploy token: %v", err)
+	}
+	sim.Commit()
+
+	if symbol, err := token.GetSymbol(nil); err != nil || symbol != "BTC" {
+		t.Fatalf("symbol mismatch: have %q (%v), want %q", symbol, err, "BTC")
+	}
+	recipient := common.HexToAddress("0x02")
+	if _, err := token.Transfer(auth, recipient, big.NewInt(100)); err != nil {
+		t.Fatalf("failed to transfer tokens: %v", err)
+	}
+	sim.Commit()
+
+	if amount, err := token.GetAmount(nil, recipient); err != nil || amount.Int64() != 100 {
+		t.Fatalf("amount mismatch: have %v (%v), want %d", amount, err, 100)
+	}
+	it, err := token.FilterTransfer(nil, []common.Address{auth.From}, []common.Address{recipient})
+	if err != nil {
+		t.Fatalf("failed to filter transfers: %v", err)
+	}
+	if !it.Next() || it.Event.To != recipient || it.Event.Value.Int64() != 100 {
+		t.Fatalf("transfer event mismatch: have %+v (%v)", it.Event, it.Error())
+	}
+}
+`
+
+// Tests that the binding of a WASM contract deploys and operates it on a
+// simulated backend.
+func TestBindWasm(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping binding compilation in short mode")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	code, err := ioutil.ReadFile("../../../core/wavm/tests/erc20/TokenERC20.compress")
+	if err != nil {
+		t.Fatalf("failed to read contract: %v", err)
+	}
+	wasm, _, err := utils.DecodeContractCode(code)
+	if err != nil {
+		t.Fatalf("failed to decode contract: %v", err)
+	}
+	binding, err := Bind([]string{"Token"}, []string{string(wasm.Abi)}, []string{hexutil.Encode(wasm.Code)}, "bindtest", LangGo)
+	if err != nil {
+		t.Fatalf("failed to generate binding: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "bind-wasm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "token.go"), []byte(binding), 0600); err != nil {
+		t.Fatalf("failed to write binding: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "token_test.go"), []byte(wasmTokenTester), 0600); err != nil {
+		t.Fatalf("failed to write tester: %v", err)
+	}
+	cmd := exec.Command("go", "test", "-v", "-gcflags=all=-d=checkptr=0", ".")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to run binding test: %v\n%s", err, out)
+	}
+}
+
+// Tests that the payable methods of WASM contracts are bound without their
+// marking prefix, and that the calls of other contracts aren't bound.
+func TestBindWasmMethodNames(t *testing.T) {
+	abi := `[
+		{"name":"$Deposit","constant":false,"inputs":[],"outputs":[],"type":"function"},
+		{"name":"balance","constant":true,"inputs":[],"outputs":[{"name":"output","type":"uint256"}],"type":"function"},
+		{"name":"GetTokenName","constant":false,"inputs":[],"outputs":[{"name":"output","type":"string"}],"type":"call"}
+	]`
+	binding, err := Bind([]string{"Bank"}, []string{abi}, []string{""}, "bindtest", LangGo)
+	if err != nil {
+		t.Fatalf("failed to generate binding: %v", err)
+	}
+	for _, want := range []string{
+		"func (_Bank *BankTransactor) Deposit(opts *bind.TransactOpts)",
+		"function $Deposit() payable returns()",
+		`Transact(opts, "$Deposit")`,
+		"func (_Bank *BankCaller) Balance(opts *bind.CallOpts)",
+	} {
+		if !strings.Contains(binding, want) {
+			t.Errorf("binding misses %q", want)
+		}
+	}
+	if strings.Contains(binding, ") GetTokenName(") {
+		t.Errorf("call of another contract bound")
+	}
+}
@@ -55,3 +55,45 @@ func (e Event) Id() common.Hash {
 	}
 	return common.BytesToHash(crypto.Keccak256([]byte(fmt.Sprintf("%v(%v)", e.Name, strings.Join(types, ",")))))
 }
+
+// UnpackLog decodes the arguments of a log emitted by this event. Indexed
+// arguments are recovered from the topics, non-indexed ones from the data, and
+// the values are returned in declaration order.
+//
+// Note, dynamic indexed arguments cannot be reconstructed since they get mapped
+// to Keccak256 hashes as the topic value, so their hash is returned instead.
+func (e Event) UnpackLog(topics []common.Hash, data []byte) ([]interface{}, error) {
+	if !e.Anonymous {
+		if len(topics) == 0 || topics[0] != e.Id() {
+			return nil, fmt.Errorf("abi: log signature mismatch for event %s", e.Name)
+		}
+		topics = topics[1:]
+	}
+	nonIndexed, err := e.Inputs.UnpackValues(data)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, 0, len(e.Inputs))
+	for _, input := range e.Inputs {
+		if !input.Indexed {
+			values = append(values, nonIndexed[0])
+			nonIndexed = nonIndexed[1:]
+			continue
+		}
+		if len(topics) == 0 {
+			return nil, fmt.Errorf("abi: topic/field count mismatch for event %s", e.Name)
+		}
+		var value interface{}
+		switch input.Type.T {
+		case StringTy, BytesTy, SliceTy, ArrayTy:
+			value = topics[0]
+		default:
+			if value, err = toGoType(0, input.Type, topics[0][:]); err != nil {
+				return nil, err
+			}
+		}
+		values = append(values, value)
+		topics = topics[1:]
+	}
+	return values, nil
+}
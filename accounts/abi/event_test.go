@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"math/big"
 	"reflect"
 	"strings"
@@ -393,3 +394,62 @@ func TestEventIndexedWithArrayUnpack(t *testing.T) {
 	require.Equal(t, [2]uint8{0, 0}, rst.Value1)
 	require.Equal(t, stringOut, rst.Value2)
 }
+
+// TestEventUnpackLog verifies that indexed and non-indexed arguments are
+// recovered from a log in declaration order.
+func TestEventUnpackLog(t *testing.T) {
+	definition := fmt.Sprintf(`[%s]`, jsonEventTransfer)
+	abi, err := JSON(strings.NewReader(definition))
+	require.NoError(t, err)
+	event := abi.Events["Transfer"]
+
+	from := common.HexToAddress("0x00Ce0d46d924CC8437c806721496599FC3FFA268")
+	to := common.HexToAddress("0x376c47978271565f56DEB45495afa69E59c16Ab2")
+	data, err := hex.DecodeString(transferData1)
+	require.NoError(t, err)
+	topics := []common.Hash{event.Id(), common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())}
+
+	found, err := abi.EventById(topics[0])
+	require.NoError(t, err)
+	require.Equal(t, "Transfer", found.Name)
+
+	values, err := event.UnpackLog(topics, data)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{from, to, big.NewInt(1000000)}, values)
+
+	named, err := event.Inputs.NamedValues(values)
+	require.NoError(t, err)
+	require.Equal(t, "to", named[1].Name)
+	require.Equal(t, "uint256", named[2].Type)
+
+	_, err = event.UnpackLog(topics[:2], data)
+	require.Error(t, err, "missing indexed topic should fail")
+	_, err = event.UnpackLog([]common.Hash{{}}, data)
+	require.Error(t, err, "mismatching signature should fail")
+}
+
+// TestNamedValueMarshalJSON verifies that integers of all widths and byte
+// slices are hex encoded.
+func TestNamedValueMarshalJSON(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{uint8(10), `"0xa"`},
+		{uint16(10), `"0xa"`},
+		{uint32(10), `"0xa"`},
+		{uint64(10), `"0xa"`},
+		{int8(-10), `"-0xa"`},
+		{int16(10), `"0xa"`},
+		{int32(10), `"0xa"`},
+		{int64(-10), `"-0xa"`},
+		{big.NewInt(10), `"0xa"`},
+		{[]byte{0x0a}, `"0x0a"`},
+		{"ten", `"ten"`},
+	}
+	for i, tt := range tests {
+		enc, err := json.Marshal(NamedValue{Name: "v", Type: "t", Value: tt.value})
+		require.NoError(t, err)
+		require.Equal(t, `{"name":"v","type":"t","value":`+tt.want+`}`, string(enc), "test %d", i)
+	}
+}
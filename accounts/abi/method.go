@@ -23,6 +23,10 @@ import (
 	"github.com/vntchain/go-vnt/crypto"
 )
 
+// PayablePrefix is the prefix of the names of the WASM contract methods which
+// accept funds.
+const PayablePrefix = "$"
+
 // Method represents a callable given a `Name` and whether the method is a constant.
 // If the method is `Const` no transaction needs to be created for this
 // particular Method call. It can easily be simulated using a local VM.
@@ -67,11 +71,20 @@ func (method Method) String() string {
 		}
 		outputs[i] += output.Type.String()
 	}
-	constant := ""
+	modifiers := ""
 	if method.Const {
-		constant = "constant "
+		modifiers += "constant "
+	}
+	if method.Payable() {
+		modifiers += "payable "
 	}
-	return fmt.Sprintf("function %v(%v) %sreturns(%v)", method.Name, strings.Join(inputs, ", "), constant, strings.Join(outputs, ", "))
+	return fmt.Sprintf("function %v(%v) %sreturns(%v)", method.Name, strings.Join(inputs, ", "), modifiers, strings.Join(outputs, ", "))
+}
+
+// Payable returns whether the method accepts funds, which WASM contracts mark by
+// prefixing the method name with PayablePrefix.
+func (method Method) Payable() bool {
+	return strings.HasPrefix(method.Name, PayablePrefix)
 }
 
 func (method Method) Id() []byte {
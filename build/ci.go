@@ -77,6 +77,7 @@ var (
 		executablePath("puppeth"),
 		executablePath("rlpdump"),
 		executablePath("swarm"),
+		executablePath("wavm"),
 		executablePath("wnode"),
 	}
 
@@ -106,6 +107,10 @@ var (
 			Name:        "swarm",
 			Description: "VNT Swarm daemon and tools",
 		},
+		{
+			Name:        "wavm",
+			Description: "Developer utility that runs WASM contracts outside of a chain.",
+		},
 		{
 			Name:        "wnode",
 			Description: "VNT Whisper diagnostic tool",
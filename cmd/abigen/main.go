@@ -26,12 +26,15 @@ import (
 
 	"github.com/vntchain/go-vnt/accounts/abi/bind"
 	"github.com/vntchain/go-vnt/common/compiler"
+	"github.com/vntchain/go-vnt/common/hexutil"
+	"github.com/vntchain/go-vnt/core/wavm/utils"
 )
 
 var (
-	abiFlag = flag.String("abi", "", "Path to the VNT contract ABI json to bind, - for STDIN")
-	binFlag = flag.String("bin", "", "Path to the VNT contract bytecode (generate deploy method)")
-	typFlag = flag.String("type", "", "Struct name for the binding (default = package name)")
+	abiFlag  = flag.String("abi", "", "Path to the VNT contract ABI json to bind, - for STDIN")
+	binFlag  = flag.String("bin", "", "Path to the VNT contract hex encoded WASM code (generate deploy method)")
+	wasmFlag = flag.String("wasm", "", "Path to the VNT contract .wasm or .compress code (generate deploy method, ABI embedded in .compress)")
+	typFlag  = flag.String("type", "", "Struct name for the binding (default = package name)")
 
 	solFlag  = flag.String("sol", "", "Path to the VNT contract Solidity source to build and bind")
 	solcFlag = flag.String("solc", "solc", "Solidity compiler to use if source builds are requested")
@@ -46,11 +49,14 @@ func main() {
 	// Parse and ensure all needed inputs are specified
 	flag.Parse()
 
-	if *abiFlag == "" && *solFlag == "" {
-		fmt.Printf("No contract ABI (--abi) or Solidity source (--sol) specified\n")
+	if *abiFlag == "" && *solFlag == "" && *wasmFlag == "" {
+		fmt.Printf("No contract ABI (--abi), WASM code (--wasm) or Solidity source (--sol) specified\n")
 		os.Exit(-1)
-	} else if (*abiFlag != "" || *binFlag != "" || *typFlag != "") && *solFlag != "" {
-		fmt.Printf("Contract ABI (--abi), bytecode (--bin) and type (--type) flags are mutually exclusive with the Solidity source (--sol) flag\n")
+	} else if (*abiFlag != "" || *binFlag != "" || *wasmFlag != "" || *typFlag != "") && *solFlag != "" {
+		fmt.Printf("Contract ABI (--abi), bytecode (--bin, --wasm) and type (--type) flags are mutually exclusive with the Solidity source (--sol) flag\n")
+		os.Exit(-1)
+	} else if *binFlag != "" && *wasmFlag != "" {
+		fmt.Printf("Contract bytecode flags (--bin, --wasm) are mutually exclusive\n")
 		os.Exit(-1)
 	}
 	if *pkgFlag == "" {
@@ -111,14 +117,28 @@ func main() {
 		}
 	} else {
 		// Otherwise load up the ABI, optional bytecode and type name from the parameters
-		abi, err := ioutil.ReadFile(*abiFlag)
-		if err != nil {
-			fmt.Printf("Failed to read input ABI: %v\n", err)
+		var (
+			abi []byte
+			bin = []byte{}
+			err error
+		)
+		if *wasmFlag != "" {
+			if abi, bin, err = wasmFromFile(*wasmFlag); err != nil {
+				fmt.Printf("Failed to read input WASM code: %v\n", err)
+				os.Exit(-1)
+			}
+		}
+		if *abiFlag != "" {
+			if abi, err = ioutil.ReadFile(*abiFlag); err != nil {
+				fmt.Printf("Failed to read input ABI: %v\n", err)
+				os.Exit(-1)
+			}
+		} else if abi == nil {
+			fmt.Printf("No contract ABI (--abi) specified along with the .wasm code\n")
 			os.Exit(-1)
 		}
 		abis = append(abis, string(abi))
 
-		bin := []byte{}
 		if *binFlag != "" {
 			if bin, err = ioutil.ReadFile(*binFlag); err != nil {
 				fmt.Printf("Failed to read input bytecode: %v\n", err)
@@ -150,6 +170,23 @@ func main() {
 	}
 }
 
+// wasmFromFile reads the hex encoded WASM code of a .wasm or .compress file, and
+// the ABI embedded in the latter.
+func wasmFromFile(path string) ([]byte, []byte, error) {
+	code, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if magic, _ := utils.ReadMagic(code); magic != utils.MAGIC {
+		return nil, []byte(hexutil.Encode(code)), nil
+	}
+	wasm, _, err := utils.DecodeContractCode(code)
+	if err != nil {
+		return nil, nil, err
+	}
+	return wasm.Abi, []byte(hexutil.Encode(wasm.Code)), nil
+}
+
 func contractsFromStdin() (map[string]*compiler.Contract, error) {
 	bytes, err := ioutil.ReadAll(os.Stdin)
 	if err != nil {
@@ -20,22 +20,25 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
-	"github.com/syndtr/goleveldb/leveldb/util"
 	"github.com/vntchain/go-vnt/cmd/utils"
 	"github.com/vntchain/go-vnt/common"
 	"github.com/vntchain/go-vnt/console"
 	"github.com/vntchain/go-vnt/core"
+	"github.com/vntchain/go-vnt/core/rawdb"
 	"github.com/vntchain/go-vnt/core/state"
+	"github.com/vntchain/go-vnt/core/state/pruner"
 	"github.com/vntchain/go-vnt/core/types"
 	"github.com/vntchain/go-vnt/event"
 	"github.com/vntchain/go-vnt/log"
 	"github.com/vntchain/go-vnt/trie"
+	"github.com/vntchain/go-vnt/vnt"
 	"github.com/vntchain/go-vnt/vnt/downloader"
 	"github.com/vntchain/go-vnt/vntdb"
 	cli "gopkg.in/urfave/cli.v1"
@@ -65,6 +68,8 @@ It expects the genesis file as argument.`,
 		ArgsUsage: "<filename> (<filename 2> ... <filename N>) ",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBEngineFlag,
 			utils.CacheFlag,
 			utils.GCModeFlag,
 			utils.CacheDatabaseFlag,
@@ -85,6 +90,8 @@ processing will proceed even if an individual RLP-file import failure occurs.`,
 		ArgsUsage: "<filename> [<blockNumFirst> <blockNumLast>]",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBEngineFlag,
 			utils.CacheFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
@@ -119,6 +126,26 @@ if already existing.`,
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
 The export-preimages command export hash preimages to an RLP encoded stream`,
+	}
+	exportStateCommand = cli.Command{
+		Action:    utils.MigrateFlags(exportState),
+		Name:      "export-state",
+		Usage:     "Export the state of a block as a genesis specification",
+		ArgsUsage: "<blockHash | blockNum> <filename>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBEngineFlag,
+			utils.CacheFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The export-state command writes the state of a block into a genesis JSON file
+which "gvnt init" can load, to start a new chain such as a testnet from it. The
+accounts are exported with their balances, nonces, codes and storage, and the
+election contract storage as candidate, voter, stake and reward records. The
+header fields and the witnesses of the genesis are taken from the block. If the
+file ends with .gz, the output is gzipped.`,
 	}
 	copydbCommand = cli.Command{
 		Action:    utils.MigrateFlags(copyDb),
@@ -127,6 +154,8 @@ The export-preimages command export hash preimages to an RLP encoded stream`,
 		ArgsUsage: "<sourceChaindataDir>",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBEngineFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 		},
@@ -141,10 +170,70 @@ The first argument must be the directory containing the blockchain to download f
 		ArgsUsage: " ",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBEngineFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
 Remove blockchain and state databases`,
+	}
+	freezeCommand = cli.Command{
+		Action:    utils.MigrateFlags(freeze),
+		Name:      "freeze",
+		Usage:     "Move the immutable chain segments into the ancient database",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBEngineFlag,
+			utils.CacheFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The freeze command moves all the blocks old enough to be immutable out of the
+chain database into the flat files of the ancient database, at once rather than
+gradually as a running node does. It migrates the chain databases created before
+the ancient database existed.`,
+	}
+	pruneStateCommand = cli.Command{
+		Action:    utils.MigrateFlags(pruneState),
+		Name:      "prune-state",
+		Usage:     "Delete the state which isn't reachable from the recent blocks",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBEngineFlag,
+			utils.CacheFlag,
+			utils.PruneRecentFlag,
+			utils.BloomFilterSizeFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The prune-state command deletes from the chain database all the trie nodes and
+contract codes which aren't part of the states of the recent blocks or of the
+genesis block, then compacts the database. The node must not be running. The
+live state is marked in a bloom filter, whose size trades memory for the number
+of dead entries left over.`,
+	}
+	rewindCommand = cli.Command{
+		Action:    utils.MigrateFlags(rewind),
+		Name:      "rewind",
+		Usage:     "Rewind the chain to a previous block",
+		ArgsUsage: "<blockHash | blockNum>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBEngineFlag,
+			utils.CacheFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The rewind command sets the head of the chain back to a canonical block whose
+state is available, like debug_setHead on a running node. The headers, bodies,
+receipts, state diffs and transaction lookups of the blocks above it are deleted,
+along with the bloombits sections not entirely below it. The node must not be
+running. The consensus state is rebuilt from the new head when the node starts.`,
 	}
 	dumpCommand = cli.Command{
 		Action:    utils.MigrateFlags(dump),
@@ -153,6 +242,8 @@ Remove blockchain and state databases`,
 		ArgsUsage: "[<blockHash> | <blockNum>]...",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBEngineFlag,
 			utils.CacheFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
@@ -248,19 +339,8 @@ func importChain(ctx *cli.Context) error {
 	fmt.Printf("Import done in %v.\n\n", time.Since(start))
 
 	// Output pre-compaction stats mostly to see the import trashing
-	db := chainDb.(*vntdb.LDBDatabase)
-
-	stats, err := db.LDB().GetProperty("leveldb.stats")
-	if err != nil {
-		utils.Fatalf("Failed to read database stats: %v", err)
-	}
-	fmt.Println(stats)
-
-	ioStats, err := db.LDB().GetProperty("leveldb.iostats")
-	if err != nil {
-		utils.Fatalf("Failed to read database iostats: %v", err)
-	}
-	fmt.Println(ioStats)
+	db := rawdb.KeyValueStore(chainDb)
+	showLeveldbStats(db)
 
 	fmt.Printf("Trie cache misses:  %d\n", trie.CacheMisses())
 	fmt.Printf("Trie cache unloads: %d\n\n", trie.CacheUnloads())
@@ -279,26 +359,39 @@ func importChain(ctx *cli.Context) error {
 	}
 
 	// Compact the entire database to more accurately measure disk io and print the stats
+	compacter, ok := db.(vntdb.Compacter)
+	if !ok {
+		return nil
+	}
 	start = time.Now()
 	fmt.Println("Compacting entire database...")
-	if err = db.LDB().CompactRange(util.Range{}); err != nil {
+	if err := compacter.Compact(nil, nil); err != nil {
 		utils.Fatalf("Compaction failed: %v", err)
 	}
 	fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
 
-	stats, err = db.LDB().GetProperty("leveldb.stats")
+	showLeveldbStats(db)
+	return nil
+}
+
+// showLeveldbStats prints the internal stats of a leveldb database, nothing for
+// the other engines.
+func showLeveldbStats(db vntdb.Database) {
+	ldb, ok := db.(*vntdb.LDBDatabase)
+	if !ok {
+		return
+	}
+	stats, err := ldb.LDB().GetProperty("leveldb.stats")
 	if err != nil {
 		utils.Fatalf("Failed to read database stats: %v", err)
 	}
 	fmt.Println(stats)
 
-	ioStats, err = db.LDB().GetProperty("leveldb.iostats")
+	ioStats, err := ldb.LDB().GetProperty("leveldb.iostats")
 	if err != nil {
 		utils.Fatalf("Failed to read database iostats: %v", err)
 	}
 	fmt.Println(ioStats)
-
-	return nil
 }
 
 func exportChain(ctx *cli.Context) error {
@@ -339,7 +432,7 @@ func importPreimages(ctx *cli.Context) error {
 		utils.Fatalf("This command requires an argument.")
 	}
 	stack := makeFullNode(ctx)
-	diskdb := utils.MakeChainDatabase(ctx, stack).(*vntdb.LDBDatabase)
+	diskdb := rawdb.KeyValueStore(utils.MakeChainDatabase(ctx, stack))
 
 	start := time.Now()
 	if err := utils.ImportPreimages(diskdb, ctx.Args().First()); err != nil {
@@ -355,7 +448,7 @@ func exportPreimages(ctx *cli.Context) error {
 		utils.Fatalf("This command requires an argument.")
 	}
 	stack := makeFullNode(ctx)
-	diskdb := utils.MakeChainDatabase(ctx, stack).(*vntdb.LDBDatabase)
+	diskdb := rawdb.KeyValueStore(utils.MakeChainDatabase(ctx, stack))
 
 	start := time.Now()
 	if err := utils.ExportPreimages(diskdb, ctx.Args().First()); err != nil {
@@ -365,6 +458,32 @@ func exportPreimages(ctx *cli.Context) error {
 	return nil
 }
 
+// exportState exports the state of a block as a genesis specification.
+func exportState(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 {
+		utils.Fatalf("This command requires block and file arguments.")
+	}
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	var header *types.Header
+	if arg := ctx.Args().First(); hashish(arg) {
+		header = chain.GetHeaderByHash(common.HexToHash(arg))
+	} else if number, err := strconv.ParseUint(arg, 10, 64); err == nil {
+		header = chain.GetHeaderByNumber(number)
+	}
+	if header == nil {
+		utils.Fatalf("Block %s not found", ctx.Args().First())
+	}
+	start := time.Now()
+	if err := utils.ExportState(chainDb, chain.Config(), header, ctx.Args().Get(1)); err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
 func copyDb(ctx *cli.Context) error {
 	// Ensure we have a source chain directory to copy
 	if len(ctx.Args()) != 1 {
@@ -378,7 +497,7 @@ func copyDb(ctx *cli.Context) error {
 	dl := downloader.New(syncmode, chainDb, new(event.TypeMux), chain, nil, nil)
 
 	// Create a source peer to satisfy downloader requests from
-	db, err := vntdb.NewLDBDatabase(ctx.Args().First(), ctx.GlobalInt(utils.CacheFlag.Name), 256)
+	db, err := vntdb.Open("", ctx.Args().First(), ctx.GlobalInt(utils.CacheFlag.Name), 256)
 	if err != nil {
 		return err
 	}
@@ -403,12 +522,14 @@ func copyDb(ctx *cli.Context) error {
 	fmt.Printf("Database copy done in %v\n", time.Since(start))
 
 	// Compact the entire database to remove any sync overhead
-	start = time.Now()
-	fmt.Println("Compacting entire database...")
-	if err = chainDb.(*vntdb.LDBDatabase).LDB().CompactRange(util.Range{}); err != nil {
-		utils.Fatalf("Compaction failed: %v", err)
+	if compacter, ok := rawdb.KeyValueStore(chainDb).(vntdb.Compacter); ok {
+		start = time.Now()
+		fmt.Println("Compacting entire database...")
+		if err = compacter.Compact(nil, nil); err != nil {
+			utils.Fatalf("Compaction failed: %v", err)
+		}
+		fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
 	}
-	fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
 
 	return nil
 }
@@ -416,11 +537,25 @@ func copyDb(ctx *cli.Context) error {
 func removeDB(ctx *cli.Context) error {
 	stack, _ := makeConfigNode(ctx)
 
-	for _, name := range []string{"chaindata", "lightchaindata"} {
+	dbdirs := map[string]string{
+		"chaindata":      stack.ResolvePath("chaindata"),
+		"lightchaindata": stack.ResolvePath("lightchaindata"),
+	}
+	// An ancient database outside of the chain database is removed with it
+	if ancient := ctx.GlobalString(utils.AncientFlag.Name); ancient != "" {
+		if !filepath.IsAbs(ancient) {
+			ancient = filepath.Join(dbdirs["chaindata"], ancient)
+		}
+		dbdirs["ancient"] = ancient
+	}
+	for _, name := range []string{"chaindata", "ancient", "lightchaindata"} {
+		dbdir, ok := dbdirs[name]
+		if !ok {
+			continue
+		}
 		// Ensure the database exists in the first place
 		logger := log.New("database", name)
 
-		dbdir := stack.ResolvePath(name)
 		if !common.FileExist(dbdir) {
 			logger.Info("Database doesn't exist, skipping", "path", dbdir)
 			continue
@@ -468,6 +603,89 @@ func dump(ctx *cli.Context) error {
 	return nil
 }
 
+// freeze moves the immutable chain segments of the chain database into the
+// ancient database.
+func freeze(ctx *cli.Context) error {
+	stack := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	start := time.Now()
+	frozen, err := rawdb.FreezeAncients(chainDb)
+	if err != nil {
+		utils.Fatalf("Freezing failed: %v", err)
+	}
+	fmt.Printf("Moved %d blocks into the ancient database in %v\n", frozen, time.Since(start))
+	return nil
+}
+
+func pruneState(ctx *cli.Context) error {
+	stack := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	pruner, err := pruner.NewPruner(chainDb, ctx.GlobalUint64(utils.BloomFilterSizeFlag.Name))
+	if err != nil {
+		utils.Fatalf("Failed to create pruner: %v", err)
+	}
+	start := time.Now()
+	if err := pruner.Prune(ctx.GlobalUint64(utils.PruneRecentFlag.Name)); err != nil {
+		utils.Fatalf("Pruning failed: %v", err)
+	}
+	fmt.Printf("Pruning done in %v\n", time.Since(start))
+
+	if db, ok := rawdb.KeyValueStore(chainDb).(vntdb.Compacter); ok {
+		start = time.Now()
+		fmt.Println("Compacting entire database...")
+		if err := db.Compact(nil, nil); err != nil {
+			utils.Fatalf("Compaction failed: %v", err)
+		}
+		fmt.Printf("Compaction done in %v\n", time.Since(start))
+	}
+	return nil
+}
+
+func rewind(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires a block argument.")
+	}
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	var header *types.Header
+	if arg := ctx.Args().First(); hashish(arg) {
+		header = chain.GetHeaderByHash(common.HexToHash(arg))
+	} else if number, err := strconv.ParseUint(arg, 10, 64); err == nil {
+		header = chain.GetHeaderByNumber(number)
+	}
+	if header == nil {
+		utils.Fatalf("Block %s not found", ctx.Args().First())
+	}
+	number := header.Number.Uint64()
+	if rawdb.ReadCanonicalHash(chainDb, number) != header.Hash() {
+		utils.Fatalf("Block %d [%x…] is not canonical", number, header.Hash().Bytes()[:4])
+	}
+	if current := chain.CurrentBlock().NumberU64(); number > current {
+		utils.Fatalf("Block %d is above the current head %d", number, current)
+	}
+	// Rewinding to a block without state would fall back to the genesis
+	if _, err := chain.StateAt(header.Root); err != nil {
+		utils.Fatalf("State of block %d missing: %v", number, err)
+	}
+	start := time.Now()
+	if err := chain.SetHead(number); err != nil {
+		utils.Fatalf("Rewind failed: %v", err)
+	}
+	sections, err := vnt.RewindBloomBits(chainDb, number)
+	if err != nil {
+		utils.Fatalf("Bloombits rewind failed: %v", err)
+	}
+	head := chain.CurrentBlock()
+	fmt.Printf("Rewound to block %d [%x…], dropped %d bloombits sections in %v\n", head.NumberU64(), head.Hash().Bytes()[:4], sections, time.Since(start))
+	return nil
+}
+
 // hashish returns true for strings that look like hashes.
 func hashish(x string) bool {
 	_, err := strconv.Atoi(x)
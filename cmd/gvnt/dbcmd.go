@@ -0,0 +1,370 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of go-vnt.
+//
+// go-vnt is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-vnt is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-vnt. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/vntchain/go-vnt/cmd/utils"
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/common/hexutil"
+	"github.com/vntchain/go-vnt/core/rawdb"
+	"github.com/vntchain/go-vnt/core/state"
+	"github.com/vntchain/go-vnt/core/vm/election"
+	"github.com/vntchain/go-vnt/vntdb"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dbCommand = cli.Command{
+		Name:      "db",
+		Usage:     "Low level database operations",
+		ArgsUsage: "",
+		Category:  "DATABASE COMMANDS",
+		Subcommands: []cli.Command{
+			dbInspectCommand,
+			dbGetCommand,
+			dbPutCommand,
+			dbDeleteCommand,
+			dbCompactCommand,
+			dbConvertCommand,
+		},
+	}
+	dbInspectCommand = cli.Command{
+		Action:    utils.MigrateFlags(inspectDB),
+		Name:      "inspect",
+		Usage:     "Report the number and size of the database entries by category",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBEngineFlag,
+			utils.CacheFlag,
+		},
+		Description: `
+The inspect command walks the entire chain database, classifying its entries by
+their key prefix: headers, bodies, receipts, transaction lookups, trie nodes,
+preimages, bloombits and so on. The items of the ancient database are reported
+per table, and the nodes of the election contract storage at the head block,
+which are also accounted as trie nodes, are reported apart.`,
+	}
+	dbGetCommand = cli.Command{
+		Action:    utils.MigrateFlags(dbGet),
+		Name:      "get",
+		Usage:     "Print the value of a raw database key",
+		ArgsUsage: "<hex-key>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBEngineFlag,
+		},
+		Description: `
+The get command prints the hex encoded value stored under a hex encoded key of
+the chain database.`,
+	}
+	dbPutCommand = cli.Command{
+		Action:    utils.MigrateFlags(dbPut),
+		Name:      "put",
+		Usage:     "Store a value under a raw database key",
+		ArgsUsage: "<hex-key> <hex-value>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBEngineFlag,
+		},
+		Description: `
+The put command stores a hex encoded value under a hex encoded key of the chain
+database, overwriting the previous value. The node must not be running. This is
+a low level operation which may corrupt the database.`,
+	}
+	dbDeleteCommand = cli.Command{
+		Action:    utils.MigrateFlags(dbDelete),
+		Name:      "delete",
+		Usage:     "Delete a raw database key",
+		ArgsUsage: "<hex-key>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBEngineFlag,
+		},
+		Description: `
+The delete command deletes a hex encoded key from the chain database, printing
+the value it held. The node must not be running. This is a low level operation
+which may corrupt the database.`,
+	}
+	dbCompactCommand = cli.Command{
+		Action:    utils.MigrateFlags(dbCompact),
+		Name:      "compact",
+		Usage:     "Compact the entire database",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBEngineFlag,
+			utils.CacheFlag,
+		},
+		Description: `
+The compact command compacts the entire chain database, reclaiming the disk
+space of the deleted and overwritten entries.`,
+	}
+	dbConvertCommand = cli.Command{
+		Action:    utils.MigrateFlags(dbConvert),
+		Name:      "convert",
+		Usage:     "Migrate the chain database to another storage engine",
+		ArgsUsage: "<engine>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+		},
+		Description: `
+The convert command copies all the entries of the chain database into a new
+database of the given storage engine ("leveldb" or "badger"), which then takes
+its place. The ancient database kept inside the chain database is moved along,
+and the old database is left aside for removal. The node must not be running.`,
+	}
+)
+
+// inspectDB prints the statistics of the chain database entries by category.
+func inspectDB(ctx *cli.Context) error {
+	stack := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	start := time.Now()
+	stats, err := rawdb.InspectDatabase(chainDb)
+	if err != nil {
+		utils.Fatalf("Inspection failed: %v", err)
+	}
+	if stat, err := inspectElection(chainDb); err != nil {
+		utils.Fatalf("Election storage inspection failed: %v", err)
+	} else if stat != nil {
+		stats = append(stats, *stat)
+	}
+	var (
+		table = tablewriter.NewWriter(os.Stdout)
+		count uint64
+		size  common.StorageSize
+	)
+	table.SetAutoFormatHeaders(false)
+	table.SetHeader([]string{"Category", "Items", "Size"})
+	for _, stat := range stats {
+		table.Append([]string{stat.Category, fmt.Sprintf("%d", stat.Count), stat.Size.String()})
+		if stat.Category != electionCategory {
+			count, size = count+stat.Count, size+stat.Size
+		}
+	}
+	table.SetFooter([]string{"Total", fmt.Sprintf("%d", count), size.String()})
+	table.Render()
+
+	fmt.Printf("Inspection done in %v\n", time.Since(start))
+	return nil
+}
+
+// electionCategory is the category of the election contract storage nodes, a
+// subset of the trie nodes.
+const electionCategory = "Election storage (in trie nodes)"
+
+// inspectElection measures the storage trie of the election contract at the
+// head block, nil if the head state isn't available.
+func inspectElection(db vntdb.Database) (*rawdb.DatabaseStat, error) {
+	hash := rawdb.ReadHeadBlockHash(db)
+	number := rawdb.ReadHeaderNumber(db, hash)
+	if number == nil {
+		return nil, nil
+	}
+	header := rawdb.ReadHeader(db, hash, *number)
+	if header == nil {
+		return nil, nil
+	}
+	statedb, err := state.New(header.Root, state.NewDatabase(db))
+	if err != nil {
+		return nil, nil
+	}
+	tr := statedb.StorageTrie(common.HexToAddress(election.ContractAddr))
+	if tr == nil {
+		return nil, nil
+	}
+	stat := &rawdb.DatabaseStat{Category: electionCategory}
+	it := tr.NodeIterator(nil)
+	for it.Next(true) {
+		if node := it.Hash(); node != (common.Hash{}) {
+			blob, _ := db.Get(node[:])
+			stat.Count++
+			stat.Size += common.StorageSize(common.HashLength + len(blob))
+		}
+	}
+	return stat, it.Error()
+}
+
+// parseHexArg decodes a hex command line argument, with or without 0x prefix.
+func parseHexArg(arg string) []byte {
+	if !strings.HasPrefix(arg, "0x") && !strings.HasPrefix(arg, "0X") {
+		arg = "0x" + arg
+	}
+	data, err := hexutil.Decode(arg)
+	if err != nil {
+		utils.Fatalf("Invalid hex argument %q: %v", arg, err)
+	}
+	return data
+}
+
+// dbGet prints the value of a raw key of the chain database.
+func dbGet(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires a key argument.")
+	}
+	key := parseHexArg(ctx.Args().First())
+
+	stack := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	value, err := chainDb.Get(key)
+	if err != nil {
+		utils.Fatalf("Failed to retrieve key %#x: %v", key, err)
+	}
+	fmt.Printf("%#x\n", value)
+	return nil
+}
+
+// dbPut stores a value under a raw key of the chain database.
+func dbPut(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 {
+		utils.Fatalf("This command requires key and value arguments.")
+	}
+	key, value := parseHexArg(ctx.Args().Get(0)), parseHexArg(ctx.Args().Get(1))
+
+	stack := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	if prev, err := chainDb.Get(key); err == nil {
+		fmt.Printf("Previous value: %#x\n", prev)
+	}
+	if err := chainDb.Put(key, value); err != nil {
+		utils.Fatalf("Failed to store key %#x: %v", key, err)
+	}
+	return nil
+}
+
+// dbDelete deletes a raw key of the chain database.
+func dbDelete(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires a key argument.")
+	}
+	key := parseHexArg(ctx.Args().First())
+
+	stack := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	prev, err := chainDb.Get(key)
+	if err != nil {
+		utils.Fatalf("Failed to retrieve key %#x: %v", key, err)
+	}
+	fmt.Printf("Previous value: %#x\n", prev)
+	if err := chainDb.Delete(key); err != nil {
+		utils.Fatalf("Failed to delete key %#x: %v", key, err)
+	}
+	return nil
+}
+
+// dbCompact compacts the entire key-value store of the chain database.
+func dbCompact(ctx *cli.Context) error {
+	stack := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	db, ok := rawdb.KeyValueStore(chainDb).(vntdb.Compacter)
+	if !ok {
+		utils.Fatalf("Database doesn't support compaction")
+	}
+	start := time.Now()
+	fmt.Println("Compacting entire database...")
+	if err := db.Compact(nil, nil); err != nil {
+		utils.Fatalf("Compaction failed: %v", err)
+	}
+	fmt.Printf("Compaction done in %v\n", time.Since(start))
+	return nil
+}
+
+// dbConvert migrates the chain database to another storage engine.
+func dbConvert(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires an engine argument.")
+	}
+	engine := ctx.Args().First()
+	if engine != vntdb.EngineLevelDB && engine != vntdb.EngineBadger {
+		utils.Fatalf("Unknown database engine %q, want one of %v", engine, vntdb.Engines)
+	}
+	stack, _ := makeConfigNode(ctx)
+	var (
+		path    = stack.ResolvePath("chaindata")
+		cache   = ctx.GlobalInt(utils.CacheFlag.Name) * ctx.GlobalInt(utils.CacheDatabaseFlag.Name) / 100
+		handles = 256
+	)
+	existing := vntdb.Engine(path)
+	switch {
+	case existing == "":
+		utils.Fatalf("No chain database found in %s", path)
+	case existing == engine:
+		utils.Fatalf("Chain database already uses the %s engine", engine)
+	}
+	target, backup := path+"."+engine, path+"."+existing
+	for _, dir := range []string{target, backup} {
+		if _, err := os.Stat(dir); err == nil {
+			utils.Fatalf("Directory %s is in the way, remove it first", dir)
+		}
+	}
+	src, err := vntdb.Open(existing, path, cache, handles)
+	if err != nil {
+		utils.Fatalf("Failed to open chain database: %v", err)
+	}
+	dst, err := vntdb.Open(engine, target, cache, handles)
+	if err != nil {
+		src.Close()
+		utils.Fatalf("Failed to create %s database: %v", engine, err)
+	}
+	start := time.Now()
+	count, err := vntdb.Copy(dst, src.(vntdb.Iteratee))
+	dst.Close()
+	src.Close()
+	if err != nil {
+		os.RemoveAll(target)
+		utils.Fatalf("Conversion failed: %v", err)
+	}
+	// Move the ancient database along if it's kept inside, then swap the databases
+	if ancient := filepath.Join(path, "ancient"); common.FileExist(ancient) {
+		if err := os.Rename(ancient, filepath.Join(target, "ancient")); err != nil {
+			utils.Fatalf("Failed to move ancient database: %v", err)
+		}
+	}
+	if err := os.Rename(path, backup); err != nil {
+		utils.Fatalf("Failed to move old database aside: %v", err)
+	}
+	if err := os.Rename(target, path); err != nil {
+		utils.Fatalf("Failed to move new database in place: %v", err)
+	}
+	fmt.Printf("Converted %d entries to %s in %v\n", count, engine, time.Since(start))
+	fmt.Printf("The old database is kept in %s, remove it once the node runs fine\n", backup)
+	return nil
+}
@@ -62,6 +62,8 @@ var (
 		utils.BootnodesV4Flag,
 		utils.BootnodesV5Flag,
 		utils.DataDirFlag,
+		utils.AncientFlag,
+		utils.DBEngineFlag,
 		utils.KeyStoreDirFlag,
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
@@ -74,7 +76,11 @@ var (
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
 		utils.SyncModeFlag,
+		utils.CheckpointFlag,
 		utils.GCModeFlag,
+		utils.NoSnapshotFlag,
+		utils.HistoryRetainFlag,
+		utils.StateDiffFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
 		utils.LightKDFFlag,
@@ -91,6 +97,10 @@ var (
 		utils.TargetGasLimitFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
+		utils.NoCompressionFlag,
+		utils.BanThresholdFlag,
+		utils.BanDurationFlag,
+		utils.GossipFlag,
 		utils.DiscoveryV5Flag,
 		utils.NetrestrictFlag,
 		utils.NodeKeyFileFlag,
@@ -118,6 +128,16 @@ var (
 		utils.WSPortFlag,
 		utils.WSApiFlag,
 		utils.WSAllowedOriginsFlag,
+		utils.RPCRateLimitFlag,
+		utils.RPCKeyRateLimitFlag,
+		utils.RPCAPIKeysFlag,
+		utils.RPCAllowMethodsFlag,
+		utils.RPCDenyMethodsFlag,
+		utils.RPCMaxRequestSizeFlag,
+		utils.RPCMaxBatchFlag,
+		utils.RPCMaxBatchResponseFlag,
+		utils.RPCBatchWorkersFlag,
+		utils.RPCBatchConcurrentFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
 	}
@@ -141,9 +161,15 @@ func init() {
 		exportCommand,
 		importPreimagesCommand,
 		exportPreimagesCommand,
+		exportStateCommand,
 		copydbCommand,
 		removedbCommand,
+		freezeCommand,
+		pruneStateCommand,
+		rewindCommand,
 		dumpCommand,
+		// See dbcmd.go:
+		dbCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
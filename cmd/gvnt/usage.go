@@ -74,10 +74,18 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			configFileFlag,
 			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBEngineFlag,
 			utils.KeyStoreDirFlag,
 			utils.NetworkIdFlag,
 			utils.SyncModeFlag,
+			utils.CheckpointFlag,
 			utils.GCModeFlag,
+			utils.NoSnapshotFlag,
+			utils.HistoryRetainFlag,
+			utils.StateDiffFlag,
+			utils.PruneRecentFlag,
+			utils.BloomFilterSizeFlag,
 			utils.VntStatsURLFlag,
 			utils.IdentityFlag,
 			utils.LightServFlag,
@@ -132,6 +140,16 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.IPCPathFlag,
 			utils.RPCCORSDomainFlag,
 			utils.RPCVirtualHostsFlag,
+			utils.RPCRateLimitFlag,
+			utils.RPCKeyRateLimitFlag,
+			utils.RPCAPIKeysFlag,
+			utils.RPCAllowMethodsFlag,
+			utils.RPCDenyMethodsFlag,
+			utils.RPCMaxRequestSizeFlag,
+			utils.RPCMaxBatchFlag,
+			utils.RPCMaxBatchResponseFlag,
+			utils.RPCBatchWorkersFlag,
+			utils.RPCBatchConcurrentFlag,
 			utils.JSpathFlag,
 			utils.ExecFlag,
 			utils.PreloadJSFlag,
@@ -148,6 +166,10 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.MaxPendingPeersFlag,
 			utils.NATFlag,
 			utils.NoDiscoverFlag,
+			utils.NoCompressionFlag,
+			utils.BanThresholdFlag,
+			utils.BanDurationFlag,
+			utils.GossipFlag,
 			utils.DiscoveryV5Flag,
 			utils.NetrestrictFlag,
 			utils.NodeKeyFileFlag,
@@ -18,23 +18,31 @@
 package utils
 
 import (
+	"bytes"
 	"compress/gzip"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"os"
 	"os/signal"
 	"runtime"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/vntchain/go-vnt/common"
 	"github.com/vntchain/go-vnt/core"
 	"github.com/vntchain/go-vnt/core/rawdb"
+	"github.com/vntchain/go-vnt/core/state"
 	"github.com/vntchain/go-vnt/core/types"
+	"github.com/vntchain/go-vnt/core/vm/election"
 	"github.com/vntchain/go-vnt/crypto"
 	"github.com/vntchain/go-vnt/internal/debug"
 	"github.com/vntchain/go-vnt/log"
 	"github.com/vntchain/go-vnt/node"
+	"github.com/vntchain/go-vnt/params"
 	"github.com/vntchain/go-vnt/rlp"
 	"github.com/vntchain/go-vnt/vntdb"
 )
@@ -238,7 +246,7 @@ func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, las
 }
 
 // ImportPreimages imports a batch of exported hash preimages into the database.
-func ImportPreimages(db *vntdb.LDBDatabase, fn string) error {
+func ImportPreimages(db vntdb.Database, fn string) error {
 	log.Info("Importing preimages", "file", fn)
 
 	// Open the file handle and potentially unwrap the gzip stream
@@ -285,7 +293,11 @@ func ImportPreimages(db *vntdb.LDBDatabase, fn string) error {
 
 // ExportPreimages exports all known hash preimages into the specified file,
 // truncating any data already present in the file.
-func ExportPreimages(db *vntdb.LDBDatabase, fn string) error {
+func ExportPreimages(db vntdb.Database, fn string) error {
+	iteratee, ok := db.(vntdb.Iteratee)
+	if !ok {
+		return errors.New("database not iterable")
+	}
 	log.Info("Exporting preimages", "file", fn)
 
 	// Open the file handle and potentially wrap with a gzip stream
@@ -301,7 +313,7 @@ func ExportPreimages(db *vntdb.LDBDatabase, fn string) error {
 		defer writer.(*gzip.Writer).Close()
 	}
 	// Iterate over the preimages and export them
-	it := db.NewIteratorWithPrefix([]byte("secure-key-"))
+	it := iteratee.NewIteratorWithPrefix([]byte("secure-key-"))
 	for it.Next() {
 		if err := rlp.Encode(writer, it.Value()); err != nil {
 			return err
@@ -310,3 +322,92 @@ func ExportPreimages(db *vntdb.LDBDatabase, fn string) error {
 	log.Info("Exported preimages", "file", fn)
 	return nil
 }
+
+// ExportState exports the state of a block into the specified file as a genesis
+// specification which "gvnt init" can load, truncating any data already present
+// in the file. The storage of the election contract is exported decoded into
+// its records, while the accounts are streamed into the file one by one.
+func ExportState(db vntdb.Database, config *params.ChainConfig, header *types.Header, fn string) error {
+	log.Info("Exporting state", "number", header.Number, "hash", header.Hash(), "file", fn)
+
+	statedb, err := state.New(header.Root, state.NewDatabase(db))
+	if err != nil {
+		return err
+	}
+	records, err := election.ReadState(statedb)
+	if err != nil {
+		return fmt.Errorf("election state: %v", err)
+	}
+	genesis := &core.Genesis{
+		Config:     config,
+		Timestamp:  header.Time.Uint64(),
+		GasLimit:   header.GasLimit,
+		Difficulty: header.Difficulty,
+		Coinbase:   header.Coinbase,
+		Alloc:      core.GenesisAlloc{},
+		Witnesses:  header.Witnesses,
+		Election:   records,
+	}
+	// The accounts are streamed in place of the empty allocation
+	spec, err := json.MarshalIndent(genesis, "", "  ")
+	if err != nil {
+		return err
+	}
+	placeholder := []byte(`"alloc": {}`)
+	pos := bytes.Index(spec, placeholder)
+	if pos < 0 {
+		return errors.New("allocation missing from genesis specification")
+	}
+	// Open the file handle and potentially wrap with a gzip stream
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var writer io.Writer = fh
+	if strings.HasSuffix(fn, ".gz") {
+		writer = gzip.NewWriter(writer)
+		defer writer.(*gzip.Writer).Close()
+	}
+	if _, err := fmt.Fprintf(writer, "%s\"alloc\": {", spec[:pos]); err != nil {
+		return err
+	}
+	var (
+		electionAddr = common.HexToAddress(election.ContractAddr)
+		start        = time.Now()
+		logged       = time.Now()
+		count        int
+	)
+	err = statedb.DumpAccounts(func(addr common.Address, balance *big.Int, nonce uint64, code []byte, storage map[common.Hash]common.Hash) error {
+		account := core.GenesisAccount{Balance: balance, Nonce: nonce, Code: code}
+		if len(storage) > 0 && addr != electionAddr {
+			account.Storage = storage
+		}
+		blob, err := json.MarshalIndent(account, "    ", "  ")
+		if err != nil {
+			return err
+		}
+		separator := ","
+		if count == 0 {
+			separator = ""
+		}
+		if _, err := fmt.Fprintf(writer, "%s\n    \"%#x\": %s", separator, addr, blob); err != nil {
+			return err
+		}
+		if count++; time.Since(logged) > 8*time.Second {
+			log.Info("Exporting state", "accounts", count, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(writer, "\n  }%s\n", spec[pos+len(placeholder):]); err != nil {
+		return err
+	}
+	log.Info("Exported state", "accounts", count, "candidates", len(records.Candidates), "voters", len(records.Voters),
+		"stakes", len(records.Stakes), "file", fn, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
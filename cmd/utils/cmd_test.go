@@ -0,0 +1,122 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of go-vnt.
+//
+// go-vnt is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-vnt is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-vnt. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/core"
+	"github.com/vntchain/go-vnt/core/vm/election"
+	"github.com/vntchain/go-vnt/params"
+	"github.com/vntchain/go-vnt/vntdb"
+)
+
+// Tests that an exported state loads back as a genesis with the same state root,
+// the election contract storage included.
+func TestExportState(t *testing.T) {
+	var (
+		owner        = common.HexToAddress("0x0100000000000000000000000000000000000001")
+		electionAddr = common.HexToAddress(election.ContractAddr)
+	)
+	genesis := &core.Genesis{
+		Config:    params.TestChainConfig,
+		Timestamp: 1546272000,
+		GasLimit:  params.GenesisGasLimit,
+		Alloc: core.GenesisAlloc{
+			owner: {Balance: big.NewInt(1000), Nonce: 3},
+			common.HexToAddress("0x02"): {
+				Balance: big.NewInt(1),
+				Code:    []byte{0x60, 0x00},
+				Storage: map[common.Hash]common.Hash{{0x01}: {0x02}, {0x03}: common.BigToHash(big.NewInt(4))},
+			},
+			electionAddr: {Balance: big.NewInt(500)},
+		},
+		Witnesses: []common.Address{owner},
+		Election: &election.State{
+			Candidates: []election.Candidate{{
+				Owner:       owner,
+				Binder:      common.HexToAddress("0x03"),
+				Beneficiary: common.HexToAddress("0x04"),
+				VoteCount:   big.NewInt(10),
+				Registered:  true,
+				Bind:        true,
+				Url:         []byte("/ip4/127.0.0.1/tcp/5210/ipfs/1kHcch6yuBCsC5nPPSK3Yp7Es4c4eenxAeK167pYwUvNjRo"),
+				Website:     []byte("www.example.com"),
+				Name:        []byte("node"),
+			}},
+			Voters: []election.Voter{{
+				Owner:          owner,
+				ProxyVoteCount: big.NewInt(0),
+				LastStakeCount: big.NewInt(200),
+				LastVoteCount:  big.NewInt(10),
+				TimeStamp:      big.NewInt(1546272000),
+				VoteCandidates: []common.Address{owner},
+			}},
+			Stakes: []election.Stake{{
+				Owner:      owner,
+				StakeCount: big.NewInt(200),
+				Vnt:        new(big.Int).Mul(big.NewInt(200), big.NewInt(1e18)),
+				TimeStamp:  big.NewInt(1546272000),
+			}},
+			Reward: big.NewInt(300),
+			Lock:   big.NewInt(200),
+		},
+	}
+	db := vntdb.NewMemDatabase()
+	block := genesis.MustCommit(db)
+
+	dir, err := ioutil.TempDir("", "export-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fn := filepath.Join(dir, "genesis.json")
+	if err := ExportState(db, genesis.Config, block.Header(), fn); err != nil {
+		t.Fatalf("failed to export state: %v", err)
+	}
+	blob, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported := new(core.Genesis)
+	if err := json.Unmarshal(blob, exported); err != nil {
+		t.Fatalf("failed to load exported state: %v", err)
+	}
+	if len(exported.Alloc) != len(genesis.Alloc) {
+		t.Errorf("account count mismatch: have %d, want %d", len(exported.Alloc), len(genesis.Alloc))
+	}
+	if storage := exported.Alloc[electionAddr].Storage; storage != nil {
+		t.Errorf("election storage exported raw: %v", storage)
+	}
+	if election := exported.Election; election == nil || len(election.Candidates) != 1 || len(election.Voters) != 1 || len(election.Stakes) != 1 {
+		t.Fatalf("election records mismatch: %+v", election)
+	}
+	imported, err := exported.ToBlock(nil)
+	if err != nil {
+		t.Fatalf("failed to import exported state: %v", err)
+	}
+	if root := imported.Root(); root != block.Root() {
+		t.Errorf("state root mismatch: have %x, want %x", root, block.Root())
+	}
+}
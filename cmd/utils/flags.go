@@ -25,6 +25,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/vntchain/go-vnt/accounts"
 	"github.com/vntchain/go-vnt/accounts/keystore"
@@ -34,6 +35,7 @@ import (
 	"github.com/vntchain/go-vnt/consensus/dpos"
 	"github.com/vntchain/go-vnt/core"
 	"github.com/vntchain/go-vnt/core/state"
+	"github.com/vntchain/go-vnt/core/state/pruner"
 	"github.com/vntchain/go-vnt/core/vm"
 	"github.com/vntchain/go-vnt/crypto"
 	"github.com/vntchain/go-vnt/les"
@@ -122,6 +124,14 @@ var (
 		Usage: "Data directory for the databases and keystore",
 		Value: DirectoryString{node.DefaultDataDir()},
 	}
+	AncientFlag = DirectoryFlag{
+		Name:  "datadir.ancient",
+		Usage: "Data directory for the ancient chain segments (default = inside chaindata)",
+	}
+	DBEngineFlag = cli.StringFlag{
+		Name:  "db.engine",
+		Usage: `Storage engine of the new databases ("leveldb" or "badger", default = engine of the existing ones, else leveldb)`,
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -146,11 +156,37 @@ var (
 		Usage: `Blockchain sync mode ("fast", "full", or "light")`,
 		Value: &defaultSyncMode,
 	}
+	CheckpointFlag = cli.StringFlag{
+		Name:  "checkpoint",
+		Usage: "Trusted finalized block to fast sync from instead of the genesis (<number>:<hash>)",
+	}
 	GCModeFlag = cli.StringFlag{
 		Name:  "gcmode",
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	NoSnapshotFlag = cli.BoolFlag{
+		Name:  "nosnapshot",
+		Usage: "Disables the flat state snapshot and the snap sync relying on it",
+	}
+	HistoryRetainFlag = cli.Uint64Flag{
+		Name:  "history.retain",
+		Usage: "Number of recent blocks whose bodies and receipts are kept (0 = entire history, frees disk space only below 90000 blocks, as older blocks are frozen)",
+	}
+	StateDiffFlag = cli.BoolFlag{
+		Name:  "statediff",
+		Usage: "Record the state changes made by each imported block (debug_getStateDiff)",
+	}
+	PruneRecentFlag = cli.Uint64Flag{
+		Name:  "prune.recent",
+		Usage: "Number of recent blocks whose states are kept by state pruning",
+		Value: pruner.DefaultRecent,
+	}
+	BloomFilterSizeFlag = cli.Uint64Flag{
+		Name:  "bloomfilter.size",
+		Usage: "Megabytes of memory allocated to the bloom filter of state pruning",
+		Value: pruner.DefaultBloomSize,
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -351,6 +387,50 @@ var (
 		Usage: "Origins from which to accept websockets requests",
 		Value: "",
 	}
+	RPCRateLimitFlag = cli.Float64Flag{
+		Name:  "rpcratelimit",
+		Usage: "Maximum HTTP/WS-RPC calls per second accepted from a single IP, counting every call of a batch (0 = unlimited)",
+	}
+	RPCKeyRateLimitFlag = cli.Float64Flag{
+		Name:  "rpckeyratelimit",
+		Usage: "Maximum HTTP/WS-RPC calls per second accepted with a single API key, counting every call of a batch (0 = unlimited)",
+	}
+	RPCAPIKeysFlag = cli.StringFlag{
+		Name:  "rpcapikeys",
+		Usage: "Comma separated list of API keys required in the X-API-Key header of HTTP/WS-RPC requests",
+		Value: "",
+	}
+	RPCAllowMethodsFlag = cli.StringFlag{
+		Name:  "rpcallowmethods",
+		Usage: "Comma separated list of methods callable over HTTP/WS-RPC. Accepts 'namespace_*' wildcards.",
+		Value: "",
+	}
+	RPCDenyMethodsFlag = cli.StringFlag{
+		Name:  "rpcdenymethods",
+		Usage: "Comma separated list of methods not callable over HTTP/WS-RPC. Accepts 'namespace_*' wildcards.",
+		Value: "",
+	}
+	RPCMaxRequestSizeFlag = cli.Int64Flag{
+		Name:  "rpcmaxrequestsize",
+		Usage: "Maximum size in bytes of a HTTP/WS-RPC request (0 = 128KB)",
+	}
+	RPCMaxBatchFlag = cli.IntFlag{
+		Name:  "rpcmaxbatch",
+		Usage: "Maximum number of calls in a HTTP/WS-RPC batch request (0 = unlimited)",
+	}
+	RPCMaxBatchResponseFlag = cli.IntFlag{
+		Name:  "rpcmaxbatchresponse",
+		Usage: "Maximum total size in bytes of the responses to a HTTP/WS-RPC batch request (0 = unlimited)",
+	}
+	RPCBatchWorkersFlag = cli.IntFlag{
+		Name:  "rpcbatchworkers",
+		Usage: "Number of calls of a HTTP/WS-RPC batch request to the --rpcbatchconcurrent methods executed concurrently (0 = sequential)",
+	}
+	RPCBatchConcurrentFlag = cli.StringFlag{
+		Name:  "rpcbatchconcurrent",
+		Usage: "Comma separated list of order independent HTTP/WS-RPC methods whose batched calls may run concurrently, namespace wildcards allowed (e.g. core_getBalance,core_call)",
+		Value: "",
+	}
 	ExecFlag = cli.StringFlag{
 		Name:  "exec",
 		Usage: "Execute JavaScript statement",
@@ -408,6 +488,24 @@ var (
 		Name:  "nodiscover",
 		Usage: "Disables the peer discovery mechanism (manual peer addition)",
 	}
+	NoCompressionFlag = cli.BoolFlag{
+		Name:  "nocompression",
+		Usage: "Disables the snappy compression of messages exchanged with peers",
+	}
+	BanThresholdFlag = cli.IntFlag{
+		Name:  "banthreshold",
+		Usage: "Misbehaviour score at which a peer gets banned",
+		Value: 100,
+	}
+	BanDurationFlag = cli.DurationFlag{
+		Name:  "banduration",
+		Usage: "Time a misbehaving peer stays banned",
+		Value: time.Hour,
+	}
+	GossipFlag = cli.BoolFlag{
+		Name:  "gossip",
+		Usage: "Propagates transactions and BFT messages through gossip topics to peers supporting them",
+	}
 	DiscoveryV5Flag = cli.BoolFlag{
 		Name:  "v5disc",
 		Usage: "Enables the experimental RLPx V5 (Topic Discovery) mechanism",
@@ -650,6 +748,41 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 	}
 }
 
+// setRPCAccess applies the access policy flags of the HTTP and WebSocket RPC
+// interfaces to the config.
+func setRPCAccess(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(RPCRateLimitFlag.Name) {
+		cfg.RPCAccess.RateLimit = ctx.GlobalFloat64(RPCRateLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCKeyRateLimitFlag.Name) {
+		cfg.RPCAccess.KeyRateLimit = ctx.GlobalFloat64(RPCKeyRateLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCAPIKeysFlag.Name) {
+		cfg.RPCAccess.APIKeys = splitAndTrim(ctx.GlobalString(RPCAPIKeysFlag.Name))
+	}
+	if ctx.GlobalIsSet(RPCAllowMethodsFlag.Name) {
+		cfg.RPCAccess.AllowedMethods = splitAndTrim(ctx.GlobalString(RPCAllowMethodsFlag.Name))
+	}
+	if ctx.GlobalIsSet(RPCDenyMethodsFlag.Name) {
+		cfg.RPCAccess.DeniedMethods = splitAndTrim(ctx.GlobalString(RPCDenyMethodsFlag.Name))
+	}
+	if ctx.GlobalIsSet(RPCMaxRequestSizeFlag.Name) {
+		cfg.RPCAccess.MaxRequestSize = ctx.GlobalInt64(RPCMaxRequestSizeFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCMaxBatchFlag.Name) {
+		cfg.RPCAccess.MaxBatchLength = ctx.GlobalInt(RPCMaxBatchFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCMaxBatchResponseFlag.Name) {
+		cfg.RPCAccess.MaxBatchResponseSize = ctx.GlobalInt(RPCMaxBatchResponseFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCBatchWorkersFlag.Name) {
+		cfg.RPCAccess.BatchWorkers = ctx.GlobalInt(RPCBatchWorkersFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCBatchConcurrentFlag.Name) {
+		cfg.RPCAccess.ConcurrentMethods = splitAndTrim(ctx.GlobalString(RPCBatchConcurrentFlag.Name))
+	}
+}
+
 // setIPC creates an IPC path configuration from the set command line flags,
 // returning an empty string if IPC was explicitly disabled, or the set path.
 func setIPC(ctx *cli.Context, cfg *node.Config) {
@@ -776,6 +909,15 @@ func SetP2PConfig(ctx *cli.Context, cfg *vntp2p.Config) {
 	if ctx.GlobalIsSet(NoDiscoverFlag.Name) || lightClient {
 		cfg.NoDiscovery = true
 	}
+	if ctx.GlobalIsSet(NoCompressionFlag.Name) {
+		cfg.NoCompression = true
+	}
+	if ctx.GlobalIsSet(BanThresholdFlag.Name) {
+		cfg.BanThreshold = ctx.GlobalInt(BanThresholdFlag.Name)
+	}
+	if ctx.GlobalIsSet(BanDurationFlag.Name) {
+		cfg.BanDuration = ctx.GlobalDuration(BanDurationFlag.Name)
+	}
 
 	// if we're running a light client or server, force enable the v5 peer discovery
 	// unless it is explicitly disabled with --nodiscover note that explicitly specifying
@@ -803,12 +945,16 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setIPC(ctx, cfg)
 	setHTTP(ctx, cfg)
 	setWS(ctx, cfg)
+	setRPCAccess(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
 
 	switch {
 	case ctx.GlobalIsSet(DataDirFlag.Name):
 		cfg.DataDir = ctx.GlobalString(DataDirFlag.Name)
 	}
+	if ctx.GlobalIsSet(DBEngineFlag.Name) {
+		cfg.DBEngine = ctx.GlobalString(DBEngineFlag.Name)
+	}
 
 	if ctx.GlobalIsSet(KeyStoreDirFlag.Name) {
 		cfg.KeyStoreDir = ctx.GlobalString(KeyStoreDirFlag.Name)
@@ -922,6 +1068,17 @@ func SetVntConfig(ctx *cli.Context, stack *node.Node, cfg *vnt.Config) {
 	case ctx.GlobalIsSet(SyncModeFlag.Name):
 		cfg.SyncMode = *GlobalTextMarshaler(ctx, SyncModeFlag.Name).(*downloader.SyncMode)
 	}
+	if ctx.GlobalIsSet(CheckpointFlag.Name) {
+		cp, err := downloader.ParseCheckpoint(ctx.GlobalString(CheckpointFlag.Name))
+		if err != nil {
+			Fatalf("Option %q: %v", CheckpointFlag.Name, err)
+		}
+		if ctx.GlobalIsSet(SyncModeFlag.Name) && cfg.SyncMode != downloader.FastSync {
+			Fatalf("Option %q requires the fast sync mode", CheckpointFlag.Name)
+		}
+		cfg.SyncMode = downloader.FastSync
+		cfg.Checkpoint = cp
+	}
 	if ctx.GlobalIsSet(LightServFlag.Name) {
 		cfg.LightServ = ctx.GlobalInt(LightServFlag.Name)
 	}
@@ -931,11 +1088,35 @@ func SetVntConfig(ctx *cli.Context, stack *node.Node, cfg *vnt.Config) {
 	if ctx.GlobalIsSet(NetworkIdFlag.Name) {
 		cfg.NetworkId = ctx.GlobalUint64(NetworkIdFlag.Name)
 	}
+	if ctx.GlobalIsSet(GossipFlag.Name) {
+		cfg.Gossip = true
+	}
+	if ctx.GlobalIsSet(NoSnapshotFlag.Name) {
+		cfg.NoSnapshot = true
+	}
+	if ctx.GlobalIsSet(HistoryRetainFlag.Name) {
+		cfg.HistoryRetention = ctx.GlobalUint64(HistoryRetainFlag.Name)
+		if cfg.HistoryRetention > 0 && cfg.HistoryRetention < params.MinHistoryRetention {
+			log.Warn("Sanitizing history retention", "provided", cfg.HistoryRetention, "updated", params.MinHistoryRetention)
+			cfg.HistoryRetention = params.MinHistoryRetention
+		}
+		// The freezer being append-only, the blocks expiring once frozen are only
+		// hidden, their disk space isn't reclaimed
+		if cfg.HistoryRetention >= params.ImmutabilityThreshold {
+			log.Warn("History retention above the immutability threshold, expired blocks stay on disk", "retention", cfg.HistoryRetention, "threshold", params.ImmutabilityThreshold)
+		}
+	}
+	if ctx.GlobalIsSet(StateDiffFlag.Name) {
+		cfg.StateDiffs = ctx.GlobalBool(StateDiffFlag.Name)
+	}
 
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheDatabaseFlag.Name) {
 		cfg.DatabaseCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheDatabaseFlag.Name) / 100
 	}
 	cfg.DatabaseHandles = makeDatabaseHandles()
+	if ctx.GlobalIsSet(AncientFlag.Name) {
+		cfg.DatabaseFreezer = ctx.GlobalString(AncientFlag.Name)
+	}
 
 	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
@@ -1026,7 +1207,7 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node) vntdb.Database {
 		handles = makeDatabaseHandles()
 	)
 	name := "chaindata"
-	chainDb, err := stack.OpenDatabase(name, cache, handles)
+	chainDb, err := stack.OpenDatabaseWithFreezer(name, cache, handles, ctx.GlobalString(AncientFlag.Name))
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
 	}
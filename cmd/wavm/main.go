@@ -0,0 +1,130 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of go-vnt.
+//
+// go-vnt is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-vnt is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-vnt. If not, see <http://www.gnu.org/licenses/>.
+
+// wavm executes WASM contracts on an in-memory state, outside of any chain.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/vntchain/go-vnt/cmd/utils"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+
+var app *cli.App
+
+// Command line flags of the run command.
+var (
+	codeFlag = cli.StringFlag{
+		Name:  "code",
+		Usage: "contract to deploy, a .wasm file along with --abi or a .compress file",
+	}
+	abiFlag = cli.StringFlag{
+		Name:  "abi",
+		Usage: "ABI JSON file of the contract, embedded in .compress files and deployed contracts",
+	}
+	initFlag = cli.StringFlag{
+		Name:  "init",
+		Usage: "JSON array of the constructor arguments",
+		Value: "[]",
+	}
+	receiverFlag = cli.StringFlag{
+		Name:  "receiver",
+		Usage: "address of a contract of the prestate to call, instead of deploying one",
+	}
+	prestateFlag = cli.StringFlag{
+		Name:  "prestate",
+		Usage: "genesis JSON file holding the initial state and block context",
+	}
+	senderFlag = cli.StringFlag{
+		Name:  "sender",
+		Usage: "address of the transaction sender",
+		Value: "0x0000000000000000000000000000000000000001",
+	}
+	gasFlag = cli.Uint64Flag{
+		Name:  "gas",
+		Usage: "gas limit of each execution",
+		Value: 10000000,
+	}
+	priceFlag = cli.Uint64Flag{
+		Name:  "price",
+		Usage: "gas price of each execution",
+	}
+	valueFlag = cli.Uint64Flag{
+		Name:  "value",
+		Usage: "value sent along with each execution",
+	}
+	debugFlag = cli.BoolFlag{
+		Name:  "debug",
+		Usage: "print the execution trace and the debug messages of the contract",
+	}
+	dumpFlag = cli.BoolFlag{
+		Name:  "dump",
+		Usage: "dump the state after the executions",
+	}
+	jsonFlag = cli.BoolFlag{
+		Name:  "json",
+		Usage: "output JSON instead of human-readable format",
+	}
+)
+
+var runCommand = cli.Command{
+	Action:    runCmd,
+	Name:      "run",
+	Usage:     "Deploy a contract and run calls against it",
+	ArgsUsage: "[<method> [<json args>]]...",
+	Flags: []cli.Flag{
+		codeFlag,
+		abiFlag,
+		initFlag,
+		receiverFlag,
+		prestateFlag,
+		senderFlag,
+		gasFlag,
+		priceFlag,
+		valueFlag,
+		debugFlag,
+		dumpFlag,
+		jsonFlag,
+	},
+	Description: `
+The run command deploys the contract given by --code into an in-memory state,
+then calls its methods in order. Each method name is optionally followed by the
+JSON array of its arguments, e.g.
+
+    wavm run --code token.compress --init '[1000, "bitcoin", "BTC"]' \
+        transfer '["0x02", 100]' GetAmount '["0x02"]'
+
+The return values, the logs and the gas used are printed for each execution.`,
+}
+
+func init() {
+	app = utils.NewApp(gitCommit, "the WASM contract runner")
+	app.Commands = []cli.Command{
+		runCommand,
+	}
+}
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
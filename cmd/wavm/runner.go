@@ -0,0 +1,473 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of go-vnt.
+//
+// go-vnt is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-vnt is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-vnt. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/vntchain/go-vnt/accounts/abi"
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/common/hexutil"
+	"github.com/vntchain/go-vnt/common/math"
+	"github.com/vntchain/go-vnt/core"
+	"github.com/vntchain/go-vnt/core/state"
+	"github.com/vntchain/go-vnt/core/types"
+	"github.com/vntchain/go-vnt/core/vm"
+	inter "github.com/vntchain/go-vnt/core/vm/interface"
+	"github.com/vntchain/go-vnt/core/wavm"
+	"github.com/vntchain/go-vnt/core/wavm/contract"
+	"github.com/vntchain/go-vnt/core/wavm/utils"
+	"github.com/vntchain/go-vnt/crypto"
+	"github.com/vntchain/go-vnt/params"
+	"github.com/vntchain/go-vnt/vntdb"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// runner executes contract deployments and calls on an in-memory state.
+type runner struct {
+	statedb *state.StateDB
+	header  *types.Header
+	config  *params.ChainConfig
+
+	sender common.Address
+	gas    uint64
+	price  *big.Int
+	value  *big.Int
+	debug  io.Writer // Destination of the execution traces, if any
+
+	txs int // Number of executions, tagging their logs
+}
+
+// newRunner creates a runner over the state of a genesis.
+func newRunner(genesis *core.Genesis, sender common.Address) (*runner, error) {
+	db := vntdb.NewMemDatabase()
+	block, err := genesis.ToBlock(db)
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := state.New(block.Root(), state.NewDatabase(db))
+	if err != nil {
+		return nil, err
+	}
+	config := genesis.Config
+	if config == nil {
+		config = params.TestChainConfig
+	}
+	return &runner{
+		statedb: statedb,
+		header:  block.Header(),
+		config:  config,
+		sender:  sender,
+		gas:     10000000,
+		price:   new(big.Int),
+		value:   new(big.Int),
+	}, nil
+}
+
+// execution is the outcome of a contract deployment or call.
+type execution struct {
+	Method  string        `json:"method"`
+	Output  []interface{} `json:"output,omitempty"`
+	GasUsed uint64        `json:"gasUsed"`
+	Logs    []*logEntry   `json:"logs,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// logEntry is a log emitted by an execution, decoded when the event is known.
+type logEntry struct {
+	Address common.Address `json:"address"`
+	Event   string         `json:"event,omitempty"`
+	Args    []interface{}  `json:"args,omitempty"`
+	Topics  []common.Hash  `json:"topics"`
+	Data    hexutil.Bytes  `json:"data"`
+}
+
+func (r *runner) newWAVM(tracer vm.Tracer) *wavm.WAVM {
+	context := vm.Context{
+		CanTransfer: func(db inter.StateDB, address common.Address, amount *big.Int) bool {
+			return core.CanTransfer(db, address, amount)
+		},
+		Transfer: func(db inter.StateDB, sender, recipient common.Address, amount *big.Int) {
+			core.Transfer(db, sender, recipient, amount)
+		},
+		GetHash: func(n uint64) common.Hash {
+			return crypto.Keccak256Hash(new(big.Int).SetUint64(n).Bytes())
+		},
+		Origin:      r.sender,
+		Coinbase:    r.header.Coinbase,
+		BlockNumber: new(big.Int).Set(r.header.Number),
+		Time:        new(big.Int).Set(r.header.Time),
+		GasLimit:    r.header.GasLimit,
+		Difficulty:  new(big.Int).Set(r.header.Difficulty),
+		GasPrice:    r.price,
+	}
+	vmconfig := vm.Config{}
+	if tracer != nil {
+		vmconfig.Debug, vmconfig.Tracer = true, tracer
+	}
+	return wavm.NewWAVM(context, r.statedb, r.config, vmconfig)
+}
+
+// execute runs a deployment or call, tracing it if requested, and collects its
+// outcome and logs.
+func (r *runner) execute(method string, contract *abi.ABI, run func(*wavm.WAVM) ([]byte, uint64, error)) ([]byte, *execution) {
+	r.txs++
+	txhash := common.BigToHash(big.NewInt(int64(r.txs)))
+	r.statedb.Prepare(txhash, common.Hash{}, r.txs)
+
+	var (
+		logger *wavm.WasmLogger
+		tracer vm.Tracer
+	)
+	if r.debug != nil {
+		logger = wavm.NewWasmLogger(&vm.LogConfig{Debug: true})
+		tracer = logger
+	}
+	ret, leftOverGas, err := run(r.newWAVM(tracer))
+
+	exec := &execution{Method: method, GasUsed: r.gas - leftOverGas}
+	if err != nil {
+		exec.Error = err.Error()
+	}
+	for _, log := range r.statedb.GetLogs(txhash) {
+		exec.Logs = append(exec.Logs, decodeLog(contract, log))
+	}
+	if logger != nil {
+		fmt.Fprintf(r.debug, "Trace of %s:\n", method)
+		wavm.WriteTrace(r.debug, logger.StructLogs())
+		for _, msg := range logger.DebugLogs() {
+			fmt.Fprintln(r.debug, msg.PrintMsg)
+		}
+	}
+	return ret, exec
+}
+
+// deploy creates a contract from its deployment code and constructor arguments.
+func (r *runner) deploy(code []byte, contract *abi.ABI, args []interface{}) (common.Address, *execution) {
+	input, err := contract.Pack("", args...)
+	if err != nil {
+		return common.Address{}, &execution{Method: "constructor", Error: err.Error()}
+	}
+	var addr common.Address
+	_, exec := r.execute("constructor", contract, func(evm *wavm.WAVM) ([]byte, uint64, error) {
+		ret, created, leftOverGas, err := evm.Create(vm.AccountRef(r.sender), append(code, input...), r.gas, r.value)
+		addr = created
+		return ret, leftOverGas, err
+	})
+	return addr, exec
+}
+
+// call runs a method of a contract.
+func (r *runner) call(addr common.Address, contract *abi.ABI, method string, args []interface{}) *execution {
+	input, err := contract.Pack(method, args...)
+	if err != nil {
+		return &execution{Method: method, Error: err.Error()}
+	}
+	ret, exec := r.execute(method, contract, func(evm *wavm.WAVM) ([]byte, uint64, error) {
+		return evm.Call(vm.AccountRef(r.sender), addr, input, r.gas, r.value)
+	})
+	if exec.Error == "" && len(contract.Methods[method].Outputs) > 0 {
+		output, err := contract.Methods[method].Outputs.UnpackValues(ret)
+		if err != nil {
+			exec.Error = fmt.Sprintf("failed to decode output %x: %v", ret, err)
+		}
+		exec.Output = output
+	}
+	return exec
+}
+
+// decodeLog decodes a log with the events of the contract ABI.
+func decodeLog(contract *abi.ABI, log *types.Log) *logEntry {
+	entry := &logEntry{Address: log.Address, Topics: log.Topics, Data: log.Data}
+	if len(log.Topics) > 0 {
+		if event, err := contract.EventById(log.Topics[0]); err == nil {
+			if args, err := event.UnpackLog(log.Topics, log.Data); err == nil {
+				entry.Event, entry.Args = event.Name, args
+			}
+		}
+	}
+	return entry
+}
+
+// loadCode reads the deployment code of a contract along with its ABI, either
+// from a .compress file or from a .wasm file and a separate ABI.
+func loadCode(codeFile, abiFile string) ([]byte, *abi.ABI, error) {
+	code, err := ioutil.ReadFile(codeFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if magic, _ := utils.ReadMagic(code); magic != utils.MAGIC {
+		if abiFile == "" {
+			return nil, nil, errors.New("--abi is required along with a .wasm file")
+		}
+		abijson, err := ioutil.ReadFile(abiFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		code = utils.CompressWasmAndAbi(abijson, code, nil)
+	}
+	contract, err := loadABI(code, abiFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return code, contract, nil
+}
+
+// loadABI reads the ABI file if given, or the ABI embedded in the contract code.
+func loadABI(code []byte, abiFile string) (*abi.ABI, error) {
+	var (
+		abijson []byte
+		err     error
+	)
+	if abiFile != "" {
+		abijson, err = ioutil.ReadFile(abiFile)
+	} else {
+		var decoded contract.WasmCode
+		decoded, _, err = utils.DecodeContractCode(code)
+		abijson = decoded.Abi
+	}
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := wavm.GetAbi(abijson)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ABI: %v", err)
+	}
+	return &parsed, nil
+}
+
+// parseArgs converts the JSON array of the arguments of a method to their ABI
+// types.
+func parseArgs(args abi.Arguments, input string) ([]interface{}, error) {
+	var raws []json.RawMessage
+	if strings.TrimSpace(input) != "" {
+		if err := json.Unmarshal([]byte(input), &raws); err != nil {
+			return nil, fmt.Errorf("arguments %s not a JSON array: %v", input, err)
+		}
+	}
+	if len(raws) != len(args) {
+		return nil, fmt.Errorf("argument count mismatch: have %d, want %d", len(raws), len(args))
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		value, err := parseArg(arg.Type, raws[i])
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %v", arg.Name, err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// parseArg converts a JSON value to an ABI type. Integers are given as JSON
+// numbers or as decimal or hex strings.
+func parseArg(typ abi.Type, raw json.RawMessage) (interface{}, error) {
+	switch typ.T {
+	case abi.StringTy:
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+
+	case abi.BoolTy:
+		var b bool
+		err := json.Unmarshal(raw, &b)
+		return b, err
+
+	case abi.AddressTy:
+		var addr common.Address
+		err := json.Unmarshal(raw, &addr)
+		return addr, err
+
+	case abi.IntTy, abi.UintTy:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			s = string(raw)
+		}
+		n, ok := math.ParseBig256(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %s", raw)
+		}
+		if typ.T == abi.UintTy && n.Sign() < 0 {
+			return nil, fmt.Errorf("negative integer %v for %v", n, typ)
+		}
+		if typ.Kind == reflect.Ptr {
+			return n, nil
+		}
+		value := reflect.New(typ.Type).Elem()
+		if typ.T == abi.UintTy {
+			if n.BitLen() > typ.Size {
+				return nil, fmt.Errorf("integer %v overflows %v", n, typ)
+			}
+			value.SetUint(n.Uint64())
+		} else {
+			if !n.IsInt64() || value.OverflowInt(n.Int64()) {
+				return nil, fmt.Errorf("integer %v overflows %v", n, typ)
+			}
+			value.SetInt(n.Int64())
+		}
+		return value.Interface(), nil
+	}
+	return nil, fmt.Errorf("unsupported type %v", typ)
+}
+
+// parseCalls splits the command line arguments into the methods to call and the
+// JSON arrays of their arguments.
+func parseCalls(contract *abi.ABI, args []string) ([]string, [][]interface{}, error) {
+	var (
+		methods []string
+		inputs  [][]interface{}
+	)
+	for i := 0; i < len(args); i++ {
+		method, ok := contract.Methods[args[i]]
+		if !ok {
+			return nil, nil, fmt.Errorf("method %q not found in the ABI", args[i])
+		}
+		input := ""
+		if i+1 < len(args) && strings.HasPrefix(strings.TrimSpace(args[i+1]), "[") {
+			input, i = args[i+1], i+1
+		}
+		values, err := parseArgs(method.Inputs, input)
+		if err != nil {
+			return nil, nil, fmt.Errorf("method %q: %v", method.Name, err)
+		}
+		methods, inputs = append(methods, method.Name), append(inputs, values)
+	}
+	return methods, inputs, nil
+}
+
+// printExecution writes the outcome of an execution in human-readable format.
+func printExecution(w io.Writer, exec *execution) {
+	fmt.Fprintf(w, "%s:\n", exec.Method)
+	if exec.Error != "" {
+		fmt.Fprintf(w, "  error:    %s\n", exec.Error)
+	}
+	for _, output := range exec.Output {
+		fmt.Fprintf(w, "  output:   %s\n", formatValues(output))
+	}
+	fmt.Fprintf(w, "  gas used: %d\n", exec.GasUsed)
+	for _, log := range exec.Logs {
+		if log.Event != "" {
+			fmt.Fprintf(w, "  event:    %s(%s) (%s)\n", log.Event, formatValues(log.Args...), log.Address.Hex())
+		} else {
+			fmt.Fprintf(w, "  log:      topics %x data %x (%s)\n", log.Topics, log.Data, log.Address.Hex())
+		}
+	}
+}
+
+// formatValues formats ABI values, with the addresses in hex.
+func formatValues(values ...interface{}) string {
+	strs := make([]string, len(values))
+	for i, value := range values {
+		if addr, ok := value.(common.Address); ok {
+			strs[i] = addr.Hex()
+		} else {
+			strs[i] = fmt.Sprintf("%v", value)
+		}
+	}
+	return strings.Join(strs, ", ")
+}
+
+func runCmd(ctx *cli.Context) error {
+	genesis := &core.Genesis{Config: params.TestChainConfig, Alloc: make(core.GenesisAlloc)}
+	if file := ctx.String(prestateFlag.Name); file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, genesis); err != nil {
+			return fmt.Errorf("invalid prestate %s: %v", file, err)
+		}
+	}
+	var sender common.Address
+	if err := sender.UnmarshalText([]byte(ctx.String(senderFlag.Name))); err != nil {
+		return fmt.Errorf("invalid sender: %v", err)
+	}
+	if _, ok := genesis.Alloc[sender]; !ok {
+		genesis.Alloc[sender] = core.GenesisAccount{Balance: new(big.Int).Mul(big.NewInt(1e9), big.NewInt(params.Vnt))}
+	}
+	r, err := newRunner(genesis, sender)
+	if err != nil {
+		return err
+	}
+	r.gas = ctx.Uint64(gasFlag.Name)
+	r.price.SetUint64(ctx.Uint64(priceFlag.Name))
+	r.value.SetUint64(ctx.Uint64(valueFlag.Name))
+	if ctx.Bool(debugFlag.Name) {
+		r.debug = os.Stderr
+	}
+	report := func(exec *execution) {
+		if ctx.Bool(jsonFlag.Name) {
+			out, _ := json.Marshal(exec)
+			fmt.Println(string(out))
+		} else {
+			printExecution(os.Stdout, exec)
+		}
+	}
+	// Deploy the contract, or load it from the prestate
+	var (
+		addr     common.Address
+		contract *abi.ABI
+	)
+	switch {
+	case ctx.IsSet(receiverFlag.Name):
+		if err := addr.UnmarshalText([]byte(ctx.String(receiverFlag.Name))); err != nil {
+			return fmt.Errorf("invalid receiver: %v", err)
+		}
+		if contract, err = loadABI(r.statedb.GetCode(addr), ctx.String(abiFlag.Name)); err != nil {
+			return fmt.Errorf("failed to load ABI of %x: %v", addr, err)
+		}
+	case ctx.IsSet(codeFlag.Name):
+		code, parsed, err := loadCode(ctx.String(codeFlag.Name), ctx.String(abiFlag.Name))
+		if err != nil {
+			return err
+		}
+		args, err := parseArgs(parsed.Constructor.Inputs, ctx.String(initFlag.Name))
+		if err != nil {
+			return fmt.Errorf("constructor: %v", err)
+		}
+		contract = parsed
+
+		var exec *execution
+		if addr, exec = r.deploy(code, contract, args); exec.Error != "" {
+			report(exec)
+			return errors.New("contract deployment failed")
+		}
+		report(exec)
+	default:
+		return errors.New("either --code or --receiver is required")
+	}
+	methods, inputs, err := parseCalls(contract, ctx.Args())
+	if err != nil {
+		return err
+	}
+	for i, method := range methods {
+		report(r.call(addr, contract, method, inputs[i]))
+	}
+	if ctx.Bool(dumpFlag.Name) {
+		r.statedb.IntermediateRoot(true)
+		fmt.Println(string(r.statedb.Dump()))
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of go-vnt.
+//
+// go-vnt is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-vnt is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-vnt. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/core"
+	"github.com/vntchain/go-vnt/params"
+)
+
+const (
+	testCompress = "../../core/wavm/tests/erc20/TokenERC20.compress"
+	testWasm     = "../../core/wavm/tests/erc20/TokenERC20.wasm"
+	testABI      = "../../core/wavm/tests/erc20/abi.json"
+)
+
+func newTestRunner(t *testing.T) *runner {
+	sender := common.HexToAddress("0x01")
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{sender: {Balance: big.NewInt(params.Vnt)}},
+	}
+	r, err := newRunner(genesis, sender)
+	if err != nil {
+		t.Fatalf("failed to create runner: %v", err)
+	}
+	return r
+}
+
+// Tests that a token contract is deployed and called, with its outputs and
+// events decoded.
+func TestRunToken(t *testing.T) {
+	for _, files := range [][2]string{{testCompress, ""}, {testWasm, testABI}} {
+		r := newTestRunner(t)
+
+		code, contract, err := loadCode(files[0], files[1])
+		if err != nil {
+			t.Fatalf("%s: failed to load code: %v", files[0], err)
+		}
+		args, err := parseArgs(contract.Constructor.Inputs, `[1000, "bitcoin", "BTC"]`)
+		if err != nil {
+			t.Fatalf("%s: failed to parse constructor arguments: %v", files[0], err)
+		}
+		addr, exec := r.deploy(code, contract, args)
+		if exec.Error != "" || exec.GasUsed == 0 {
+			t.Fatalf("%s: deployment failed: %+v", files[0], exec)
+		}
+		methods, inputs, err := parseCalls(contract, []string{
+			"transfer", `["0x0000000000000000000000000000000000000002", "0x64"]`,
+			"GetAmount", `["0x0000000000000000000000000000000000000002"]`,
+			"GetSymbol",
+		})
+		if err != nil {
+			t.Fatalf("%s: failed to parse calls: %v", files[0], err)
+		}
+		var execs []*execution
+		for i, method := range methods {
+			execs = append(execs, r.call(addr, contract, method, inputs[i]))
+		}
+		if len(execs[0].Logs) != 1 || execs[0].Logs[0].Event != "Transfer" {
+			t.Errorf("%s: transfer logs mismatch: have %+v", files[0], execs[0].Logs)
+		}
+		if out := formatValues(execs[1].Output...); out != "100" {
+			t.Errorf("%s: amount mismatch: have %s, want %s", files[0], out, "100")
+		}
+		if out := formatValues(execs[2].Output...); out != "BTC" {
+			t.Errorf("%s: symbol mismatch: have %s, want %s", files[0], out, "BTC")
+		}
+		// The deployed contract is called with its embedded ABI
+		embedded, err := loadABI(r.statedb.GetCode(addr), "")
+		if err != nil {
+			t.Fatalf("%s: failed to load the embedded ABI: %v", files[0], err)
+		}
+		if _, ok := embedded.Methods["GetTokenName"]; !ok {
+			t.Errorf("%s: embedded ABI misses GetTokenName", files[0])
+		}
+	}
+}
+
+// Tests that the execution trace is written when debugging.
+func TestRunTrace(t *testing.T) {
+	r := newTestRunner(t)
+	code, contract, err := loadCode(testCompress, "")
+	if err != nil {
+		t.Fatalf("failed to load code: %v", err)
+	}
+	args, _ := parseArgs(contract.Constructor.Inputs, `[1000, "bitcoin", "BTC"]`)
+	addr, _ := r.deploy(code, contract, args)
+
+	trace := new(bytes.Buffer)
+	r.debug = trace
+	if exec := r.call(addr, contract, "GetSymbol", nil); exec.Error != "" {
+		t.Fatalf("call failed: %v", exec.Error)
+	}
+	if !bytes.Contains(trace.Bytes(), []byte("Trace of GetSymbol")) || !bytes.Contains(trace.Bytes(), []byte("gas=")) {
+		t.Errorf("trace missing: have %q", trace.String())
+	}
+}
+
+// Tests that malformed call arguments are rejected.
+func TestParseCallsErrors(t *testing.T) {
+	_, contract, err := loadCode(testCompress, "")
+	if err != nil {
+		t.Fatalf("failed to load code: %v", err)
+	}
+	tests := [][]string{
+		{"NoSuchMethod"},
+		{"GetAmount"},
+		{"GetAmount", `["0x02", "0x03"]`},
+		{"transfer", `["0x0000000000000000000000000000000000000002", "lots"]`},
+		{"transfer", `["0x0000000000000000000000000000000000000002", -1]`},
+	}
+	for i, args := range tests {
+		if _, _, err := parseCalls(contract, args); err == nil {
+			t.Errorf("test %d: no error for %q", i, args)
+		}
+	}
+}
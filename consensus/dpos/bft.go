@@ -51,7 +51,7 @@ type BftManager struct {
 	h           *big.Int                    // local block chain height, protect by newRoundRWLock
 	r           uint32                      // local BFT round, protect by newRoundRWLock
 	step        uint32                      // local BFT round, protect by atomic operation
-	witnessList map[common.Address]struct{} // current witness list, rely on producing
+	witnessList map[common.Address]struct{} // current witness list, rely on producing, nil until the next round after a reset
 
 	newRoundRWLock sync.RWMutex // RW lock for switch to new round
 
@@ -130,6 +130,10 @@ func (b *BftManager) handleBftMsg(msg types.ConsensusMsg) error {
 			go b.startSync(prePrepareMsg.Block)
 		}
 		return nil
+	} else if blkNumCmp == 0 && b.witnessList == nil {
+		// The round of this height is not started after a reset, the message
+		// is imported at the round switch
+		return b.mp.addMsg(msg)
 	} else if blkNumCmp < 0 {
 		// 比当前高度消息的msg，暂时先直接舍弃掉
 		return fmt.Errorf("the height of msg is lower than current height, msg height :%d, current height : %d", msgBlkNum, b.h)
@@ -383,8 +387,8 @@ func (b *BftManager) newRound(h *big.Int, r uint32, witList []common.Address) {
 	log.Trace("New round switch start")
 	b.newRoundRWLock.Lock()
 
-	// Update witness list
-	if b.h.Cmp(h) != 0 {
+	// Update witness list, which is dropped by a reset
+	if b.h.Cmp(h) != 0 || b.witnessList == nil {
 		b.witnessList = make(map[common.Address]struct{})
 		for _, wit := range witList {
 			b.witnessList[wit] = struct{}{}
@@ -408,6 +412,23 @@ func (b *BftManager) newRound(h *big.Int, r uint32, witList []common.Address) {
 	go b.importCurRoundMsg()
 }
 
+// reset drops all the bft messages and goes back to the height following the
+// given chain head, as the chain was rewound below the rounds in progress. The
+// next round switch reloads the witness list.
+func (b *BftManager) reset(head *big.Int) {
+	b.newRoundRWLock.Lock()
+	defer b.newRoundRWLock.Unlock()
+
+	b.h = new(big.Int).Add(head, common.Big1)
+	b.r = 0
+	b.step = newRound
+	b.witnessList = nil
+
+	b.mp.cleanAllMessage()
+	b.roundMp.cleanAllMessage()
+	log.Debug("BFT state reset", "h", b.h.String())
+}
+
 // importCurRoundMsg import consensus messages, but can not directly import to round msg pool
 func (b *BftManager) importCurRoundMsg() {
 	b.newRoundRWLock.RLock()
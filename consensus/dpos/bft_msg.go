@@ -19,8 +19,11 @@ package dpos
 import (
 	"errors"
 	"fmt"
+	"math/big"
+
 	"github.com/vntchain/go-vnt/accounts"
 	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/consensus"
 	"github.com/vntchain/go-vnt/core/types"
 	"github.com/vntchain/go-vnt/crypto"
 	"github.com/vntchain/go-vnt/log"
@@ -54,22 +57,32 @@ func (bft *BftManager) makePrepareMsg(prePreMsg *types.PreprepareMsg) (*types.Pr
 }
 
 func (bft *BftManager) makeCommitMsg(prePreMsg *types.PreprepareMsg) (*types.CommitMsg, error) {
+	msg, err := NewCommitMsg(prePreMsg.Block, prePreMsg.Round, bft.coinBase, bft.dp.signFn)
+	if err != nil {
+		log.Error("Make commit msg failed", "error", err)
+		return nil, fmt.Errorf("makeCommitMsg, error: %s", err)
+	}
+	return msg, nil
+}
+
+// NewCommitMsg makes the commit message of commiter for block at round, which
+// is signed with signFn.
+func NewCommitMsg(block *types.Block, round uint32, commiter common.Address, signFn SignerFn) (*types.CommitMsg, error) {
 	msg := &types.CommitMsg{
-		Round:       prePreMsg.Round,
-		Commiter:    bft.coinBase,
-		BlockNumber: prePreMsg.Block.Number(),
-		BlockHash:   prePreMsg.Block.Hash(),
+		Round:       round,
+		Commiter:    commiter,
+		BlockNumber: block.Number(),
+		BlockHash:   block.Hash(),
 		CommitSig:   nil,
 	}
 
-	if sig, err := bft.dp.signFn(accounts.Account{Address: bft.coinBase}, msg.Hash().Bytes()); err != nil {
-		log.Error("Make commit msg failed", "error", err)
-		return nil, fmt.Errorf("makeCommitMsg, error: %s", err)
-	} else {
-		msg.CommitSig = make([]byte, len(sig))
-		copy(msg.CommitSig, sig)
-		return msg, nil
+	sig, err := signFn(accounts.Account{Address: commiter}, msg.Hash().Bytes())
+	if err != nil {
+		return nil, err
 	}
+	msg.CommitSig = make([]byte, len(sig))
+	copy(msg.CommitSig, sig)
+	return msg, nil
 }
 
 func (bft *BftManager) verifyPrePrepareMsg(msg *types.PreprepareMsg) error {
@@ -118,6 +131,84 @@ func (bft *BftManager) verifyCommitMsg(msg *types.CommitMsg) error {
 	return nil
 }
 
+// maxBftMsgDistance is the number of blocks around the local head the bft
+// messages checked and relayed may be for.
+const maxBftMsgDistance = 16
+
+// validateMsg performs the checks of a bft message which do not depend on the
+// local round: the message is complete, for a height near the local head, and
+// signed by its claimed sender, a witness of that height.
+func (bft *BftManager) validateMsg(chain consensus.ChainReader, msg types.ConsensusMsg) error {
+	var (
+		sender common.Address
+		sig    []byte
+	)
+	switch m := msg.(type) {
+	case *types.PreprepareMsg:
+		if m.Block == nil {
+			return fmt.Errorf("pre-prepare msg's block is empty")
+		}
+		if err := bft.validateSender(chain, m.Block.Number(), m.Block.Coinbase()); err != nil {
+			return err
+		}
+		producer, err := bft.dp.Author(m.Block.Header())
+		if err != nil {
+			return err
+		}
+		if producer != m.Block.Coinbase() {
+			return fmt.Errorf("pre-prepare block not signed by its producer: %s", m.Block.Coinbase().String())
+		}
+		return nil
+	case *types.PrepareMsg:
+		sender, sig = m.PrepareAddr, m.PrepareSig
+	case *types.CommitMsg:
+		sender, sig = m.Commiter, m.CommitSig
+	default:
+		return fmt.Errorf("unknown bft message type: %s", msg.Type().String())
+	}
+	if msg.GetBlockNum() == nil {
+		return fmt.Errorf("bft msg's height is empty")
+	}
+	if err := bft.validateSender(chain, msg.GetBlockNum(), sender); err != nil {
+		return err
+	}
+	if !bft.verifySig(sender, msg.Hash().Bytes(), sig) {
+		return fmt.Errorf("%s signature is invalid", msg.Type().String())
+	}
+	return nil
+}
+
+// validateSender checks that the sender of a bft message is a witness of its
+// height. The heights up to the local head are checked against the witnesses of
+// their block, the next ones against the witnesses of the head, which may still
+// be updated, so they can only be told unverifiable. The heights too far from
+// the head are unverifiable.
+func (bft *BftManager) validateSender(chain consensus.ChainReader, number *big.Int, sender common.Address) error {
+	head := chain.CurrentHeader()
+	if !number.IsUint64() {
+		return consensus.ErrUnverifiableMsg
+	}
+	n, headNum := number.Uint64(), head.Number.Uint64()
+	if n > headNum+maxBftMsgDistance || n+maxBftMsgDistance < headNum {
+		return consensus.ErrUnverifiableMsg
+	}
+	header := head
+	if n < headNum {
+		if header = chain.GetHeaderByNumber(n); header == nil {
+			return consensus.ErrUnverifiableMsg
+		}
+	}
+	for _, wit := range header.Witnesses {
+		if wit == sender {
+			return nil
+		}
+	}
+	if n > headNum {
+		return consensus.ErrUnverifiableMsg
+	}
+	return fmt.Errorf("bft msg sender is not witness of block %d: %s", n, sender.String())
+}
+
 func (bft *BftManager) VerifyCmtMsgOf(block *types.Block) error {
 	cmtMsges := block.CmtMsges()
 	if len(cmtMsges) < bft.quorum {
@@ -19,10 +19,18 @@ package dpos
 import (
 	"math/big"
 	"testing"
+	"time"
 
+	"github.com/vntchain/go-vnt/accounts"
 	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/consensus"
+	"github.com/vntchain/go-vnt/consensus/mock"
+	"github.com/vntchain/go-vnt/core"
 	"github.com/vntchain/go-vnt/core/types"
+	"github.com/vntchain/go-vnt/core/vm"
+	"github.com/vntchain/go-vnt/crypto"
 	"github.com/vntchain/go-vnt/params"
+	"github.com/vntchain/go-vnt/vntdb"
 )
 
 func newDefaultBft() *BftManager {
@@ -182,3 +190,237 @@ func Test_ValidWitness(t *testing.T) {
 		}
 	}
 }
+
+// testChainReader is a chain of headers, the last one being the head.
+type testChainReader []*types.Header
+
+func (c testChainReader) Config() *params.ChainConfig  { return params.TestChainConfig }
+func (c testChainReader) CurrentHeader() *types.Header { return c[len(c)-1] }
+
+func (c testChainReader) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := c.GetHeaderByNumber(number); header != nil && header.Hash() == hash {
+		return header
+	}
+	return nil
+}
+
+func (c testChainReader) GetHeaderByNumber(number uint64) *types.Header {
+	if number < uint64(len(c)) {
+		return c[number]
+	}
+	return nil
+}
+
+func (c testChainReader) GetHeaderByHash(hash common.Hash) *types.Header {
+	for _, header := range c {
+		if header.Hash() == hash {
+			return header
+		}
+	}
+	return nil
+}
+
+func (c testChainReader) GetBlock(hash common.Hash, number uint64) *types.Block { return nil }
+
+// newTestChainReader creates a chain up to the given head, produced by the
+// given witnesses.
+func newTestChainReader(head int, witnesses []common.Address) testChainReader {
+	chain := make(testChainReader, head+1)
+	for i := range chain {
+		chain[i] = &types.Header{Number: big.NewInt(int64(i)), Witnesses: witnesses}
+	}
+	return chain
+}
+
+func TestValidateMsg(t *testing.T) {
+	bft := newDefaultBft()
+	key, _ := crypto.GenerateKey()
+	bft.coinBase = crypto.PubkeyToAddress(key.PublicKey)
+	bft.dp.signFn = func(account accounts.Account, hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, key)
+	}
+	chain := newTestChainReader(9, []common.Address{bft.coinBase})
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(10)})
+	prepre := bft.makePrePrepareMsg(block, 0)
+	prepare, err := bft.makePrepareMsg(prepre)
+	if err != nil {
+		t.Fatalf("make prepare msg error: %s", err)
+	}
+	commit, err := bft.makeCommitMsg(prepre)
+	if err != nil {
+		t.Fatalf("make commit msg error: %s", err)
+	}
+	if err := bft.validateMsg(chain, prepare); err != nil {
+		t.Errorf("valid prepare msg rejected: %s", err)
+	}
+	if err := bft.validateMsg(chain, commit); err != nil {
+		t.Errorf("valid commit msg rejected: %s", err)
+	}
+
+	// Messages claiming another sender, or without a block, are rejected
+	prepare.PrepareAddr = common.HexToAddress("0x122369f04f32269598789998de33e3d56e2c507a")
+	if err := bft.validateMsg(newTestChainReader(9, []common.Address{bft.coinBase, prepare.PrepareAddr}), prepare); err == nil {
+		t.Errorf("forged prepare msg accepted")
+	}
+	commit.BlockNumber = nil
+	if err := bft.validateMsg(chain, commit); err == nil {
+		t.Errorf("commit msg without height accepted")
+	}
+	if err := bft.validateMsg(chain, &types.PreprepareMsg{}); err == nil {
+		t.Errorf("pre-prepare msg without block accepted")
+	}
+	if err := bft.validateMsg(chain, prepre); err == nil {
+		t.Errorf("unsigned pre-prepare msg accepted")
+	}
+}
+
+// Tests that bft messages are only accepted from the witnesses of their height,
+// and that the ones which can't be checked are told apart.
+func TestValidateMsgSender(t *testing.T) {
+	bft := newDefaultBft()
+	key, _ := crypto.GenerateKey()
+	bft.coinBase = crypto.PubkeyToAddress(key.PublicKey)
+	bft.dp.signFn = func(account accounts.Account, hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, key)
+	}
+	witnesses := []common.Address{bft.coinBase}
+	others := []common.Address{common.HexToAddress("0x122369f04f32269598789998de33e3d56e2c507a")}
+
+	tests := []struct {
+		chain   testChainReader
+		number  int64
+		invalid bool  // whether the message is rejected as invalid
+		err     error // error expected otherwise
+	}{
+		{newTestChainReader(20, witnesses), 21, false, nil},                                              // next block
+		{newTestChainReader(20, witnesses), 20, false, nil},                                              // head block
+		{newTestChainReader(20, witnesses), 20 + maxBftMsgDistance, false, nil},                          // near future
+		{newTestChainReader(20, witnesses), 20 - maxBftMsgDistance, false, nil},                          // near past
+		{newTestChainReader(20, others), 15, true, nil},                                                  // past block of other witnesses
+		{newTestChainReader(20, others), 21, false, consensus.ErrUnverifiableMsg},                        // witnesses may be updated
+		{newTestChainReader(20, witnesses), 21 + maxBftMsgDistance, false, consensus.ErrUnverifiableMsg}, // far future
+		{newTestChainReader(20, witnesses), 19 - maxBftMsgDistance, false, consensus.ErrUnverifiableMsg}, // far past
+	}
+	for i, tt := range tests {
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(tt.number)})
+		prepare, err := bft.makePrepareMsg(bft.makePrePrepareMsg(block, 0))
+		if err != nil {
+			t.Fatalf("make prepare msg error: %s", err)
+		}
+		err = bft.validateMsg(tt.chain, prepare)
+		switch {
+		case tt.invalid && (err == nil || err == consensus.ErrUnverifiableMsg):
+			t.Errorf("test %d: invalid msg not rejected: %v", i, err)
+		case !tt.invalid && err != tt.err:
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+func TestResetHead(t *testing.T) {
+	dp := New(&params.DposConfig{WitnessesNum: 4, Period: 2}, nil)
+	bft := dp.bft
+
+	// set state above the new head
+	bft.h = big.NewInt(10)
+	bft.r = 2
+	bft.producing = 1
+	bft.witnessList[common.HexToAddress("0x01")] = struct{}{}
+	dp.lastBounty.bountyHeight.SetUint64(8)
+	dp.lastBounty.updateHeight.SetUint64(10)
+
+	for _, h := range []int64{10, 12} {
+		prepre := &types.PreprepareMsg{Block: types.NewBlockWithHeader(&types.Header{Number: big.NewInt(h)})}
+		if err := bft.mp.addMsg(prepre); err != nil {
+			t.Fatalf("add msg error: %s", err)
+		}
+	}
+	dp.ResetHead(&types.Header{Number: big.NewInt(5)})
+
+	// The next round produces the block following the new head
+	if bft.h.Int64() != 6 || bft.r != 0 || bft.step != newRound || len(bft.witnessList) != 0 {
+		t.Errorf("bft state not reset, h: %d, r: %d, step: %d, witnesses: %d", bft.h, bft.r, bft.step, len(bft.witnessList))
+	}
+	if len(bft.mp.pool) != 0 || len(bft.mp.msgHashSet) != 0 {
+		t.Errorf("msg pool not cleaned, heights: %d", len(bft.mp.pool))
+	}
+	if dp.lastBounty.updateHeight.Sign() != 0 {
+		t.Errorf("last bounty not reset, update height: %d", dp.lastBounty.updateHeight)
+	}
+	// Messages of the next block are kept until its round starts
+	prepre := &types.PreprepareMsg{Block: types.NewBlockWithHeader(&types.Header{Number: big.NewInt(6)})}
+	if err := bft.handleBftMsg(prepre); err != nil {
+		t.Errorf("handle msg error: %s", err)
+	}
+	if _, err := bft.mp.getPrePrepareMsg(big.NewInt(6), 0); err != nil {
+		t.Errorf("Pre-prepare msg should in msg pool, but not find")
+	}
+}
+
+// resetNotifier is a dpos engine signalling the resets of its state.
+type resetNotifier struct {
+	*Dpos
+	reset chan struct{}
+}
+
+func (e *resetNotifier) ResetHead(head *types.Header) {
+	close(e.reset)
+	e.Dpos.ResetHead(head)
+}
+
+// Tests that rewinding the chain while a bft commit writes its block doesn't
+// deadlock, the commit holding the round lock while waiting on the chain lock.
+func TestSetHeadDuringCommit(t *testing.T) {
+	var (
+		db      = vntdb.NewMemDatabase()
+		gspec   = &core.Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+		dp      = New(&params.DposConfig{WitnessesNum: 4, Period: 2}, db)
+		engine  = &resetNotifier{Dpos: dp, reset: make(chan struct{})}
+	)
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	gendb := vntdb.NewMemDatabase()
+	gspec.MustCommit(gendb)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, mock.NewMock(), gendb, 1, nil)
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatalf("failed to get state: %v", err)
+	}
+	// Write the block from a bft commit, which holds the round lock
+	dp.bft.writeBlock = func(block *types.Block) error {
+		_, err := chain.WriteBlockWithState(block, nil, statedb)
+		return err
+	}
+	dp.bft.newRoundRWLock.RLock()
+
+	setHead := make(chan error, 1)
+	go func() { setHead <- chain.SetHead(0) }()
+
+	// Commit once the rewind reaches the bft reset
+	commit := make(chan error, 1)
+	go func() {
+		<-engine.reset
+		commit <- dp.bft.writeBlock(blocks[0])
+		dp.bft.newRoundRWLock.RUnlock()
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-commit:
+			if err != nil {
+				t.Errorf("failed to write block: %v", err)
+			}
+		case err := <-setHead:
+			if err != nil {
+				t.Errorf("failed to rewind chain: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			// Stopping the chain would wait for the blocked write
+			t.Fatalf("deadlock between the bft commit and the chain rewind")
+		}
+	}
+	chain.Stop()
+}
@@ -565,6 +565,55 @@ func (d *Dpos) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan
 	return nil, nil
 }
 
+// SignHeader signs all the fields of header but the signature with the key of
+// its coinbase, and fills the signature in.
+func SignHeader(header *types.Header, signFn SignerFn) error {
+	sh, err := sigHash(header)
+	if err != nil {
+		return err
+	}
+	sighash, err := signFn(accounts.Account{Address: header.Coinbase}, sh.Bytes())
+	if err != nil {
+		return err
+	}
+	header.Signature = make([]byte, len(sighash))
+	copy(header.Signature[:], sighash)
+	return nil
+}
+
+// PrepareWitnesses fills the witness list and the update time of header the
+// way Prepare does, taking the candidates from the state of the parent block,
+// and returns the witness in turn to produce the block at the header's time.
+// Unlike Prepare, it keeps the header's time and starts no bft round, so blocks
+// can be produced without the network, e.g. by a simulated chain.
+func (d *Dpos) PrepareWitnesses(chain consensus.ChainReader, header *types.Header, parentState *state.StateDB) (common.Address, error) {
+	number := header.Number.Uint64()
+	if number == 0 {
+		return common.Address{}, errUnknownBlock
+	}
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return common.Address{}, consensus.ErrUnknownAncestor
+	}
+	header.Difficulty = big.NewInt(1)
+
+	updated, witnesses := d.getWitnesses(header, parentState, parent)
+	header.Witnesses = witnesses
+	header.Extra = make([]byte, updateTimeLen)
+	if needSetUpdateTime(updated, number) {
+		copy(header.Extra, encodeUpdateTime(header.Time))
+	} else {
+		copy(header.Extra, parent.Extra)
+	}
+
+	for _, witness := range witnesses {
+		if d.inTurn(header, witness, chain, nil) {
+			return witness, nil
+		}
+	}
+	return common.Address{}, errOutTurn
+}
+
 // CalcDifficulty is the difficulty adjustment algorithm. It returns the difficulty
 // that a new block should have based on the previous blocks in the chain and the
 // current signer.
@@ -786,10 +835,33 @@ func (d *Dpos) HandleBftMsg(chain consensus.ChainReader, msg types.ConsensusMsg)
 	go d.bft.handleBftMsg(msg)
 }
 
+// ValidateBftMsg checks that a bft message received from the network is well
+// formed and signed by its sender, a witness of its height, before relaying it
+// to other peers. It returns consensus.ErrUnverifiableMsg for the messages too
+// far from the local chain to be checked.
+func (d *Dpos) ValidateBftMsg(chain consensus.ChainReader, msg types.ConsensusMsg) error {
+	return d.bft.validateMsg(chain, msg)
+}
+
 func (d *Dpos) CleanOldMsg(h *big.Int) {
 	d.bft.cleanOldMsg(h)
 }
 
+// ResetHead drops the cached signatures, the bounty bookkeeping and the bft
+// messages and round, which may refer to the blocks above the given head the
+// chain was rewound to.
+func (d *Dpos) ResetHead(head *types.Header) {
+	d.signatures.Purge()
+
+	d.lastBounty.Lock()
+	d.lastBounty.bountyHeight.SetUint64(0)
+	d.lastBounty.updateHeight.SetUint64(0)
+	d.lastBounty.Unlock()
+
+	d.bft.reset(head.Number)
+	log.Info("Reset dpos state to new head", "number", head.Number, "hash", head.Hash())
+}
+
 func (d *Dpos) VerifyCommitMsg(block *types.Block) error {
 	return d.bft.VerifyCmtMsgOf(block)
 }
@@ -34,4 +34,8 @@ var (
 	// ErrInvalidNumber is returned if a block's number doesn't equal it's parent's
 	// plus one.
 	ErrInvalidNumber = errors.New("invalid block number")
+
+	// ErrUnverifiableMsg is returned when a consensus message is too far from the
+	// local chain to check that its sender takes part in the consensus.
+	ErrUnverifiableMsg = errors.New("unverifiable consensus message")
 )
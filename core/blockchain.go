@@ -32,6 +32,7 @@ import (
 	"github.com/vntchain/go-vnt/consensus"
 	"github.com/vntchain/go-vnt/core/rawdb"
 	"github.com/vntchain/go-vnt/core/state"
+	"github.com/vntchain/go-vnt/core/state/snapshot"
 	"github.com/vntchain/go-vnt/core/types"
 	"github.com/vntchain/go-vnt/core/vm"
 	"github.com/vntchain/go-vnt/crypto"
@@ -62,6 +63,14 @@ const (
 	badBlockLimit       = 10
 	triesInMemory       = 128
 
+	// historyExpiryInterval is the frequency to check whether the chain progressed
+	// enough to expire more of the block history.
+	historyExpiryInterval = time.Minute
+
+	// historyExpiryBatch is the maximum number of blocks to expire the history of
+	// in one go, before checking for the blockchain to stop.
+	historyExpiryBatch = 10000
+
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
 	BlockChainVersion = 3
 )
@@ -72,6 +81,10 @@ type CacheConfig struct {
 	Disabled      bool          // Whether to disable trie write caching (archive node)
 	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
 	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+	Snapshot      bool          // Whether to maintain a flat snapshot of the head state
+
+	HistoryRetention uint64 // Number of recent blocks to keep the bodies and receipts of (0 = all)
+	StateDiffs       bool   // Whether to record the state changes made by each block
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -114,6 +127,7 @@ type BlockChain struct {
 	currentFastBlock atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
 
 	stateCache   state.Database // State database to reuse between imports (contains state cache)
+	snaps        *snapshot.Tree // Flat snapshot of the head state, nil if not maintained
 	bodyCache    *lru.Cache     // Cache for the most recent block bodies
 	bodyRLPCache *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
 	blockCache   *lru.Cache     // Cache for the most recent entire blocks
@@ -179,6 +193,11 @@ func NewBlockChain(db vntdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
 	}
+	if cacheConfig.Snapshot {
+		if bc.snaps, err = snapshot.New(db, bc.stateCache.TrieDB(), bc.CurrentBlock().Root()); err != nil {
+			log.Warn("State snapshot unavailable", "err", err)
+		}
+	}
 	// Check the current state of the block hashes and make sure that we do not have any of the bad blocks in our chain
 	for hash := range BadHashes {
 		if header := bc.GetHeaderByHash(hash); header != nil {
@@ -194,6 +213,11 @@ func NewBlockChain(db vntdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	}
 	// Take ownership of this particular state
 	go bc.update()
+
+	if cacheConfig.HistoryRetention > 0 {
+		bc.wg.Add(1)
+		go bc.expireHistory()
+	}
 	return bc, nil
 }
 
@@ -260,19 +284,49 @@ func (bc *BlockChain) loadLastState() error {
 	return nil
 }
 
+// headResetter is implemented by the consensus engines keeping state about the
+// recent blocks, which must be reset when the chain is rewound.
+type headResetter interface {
+	ResetHead(head *types.Header)
+}
+
 // SetHead rewinds the local chain to a new head. In the case of headers, everything
 // above the new head will be deleted and the new one set. In the case of blocks
 // though, the head may be further rewound if block bodies are missing (non-archive
 // nodes after a fast sync).
 func (bc *BlockChain) SetHead(head uint64) error {
+	if err := bc.setHead(head); err != nil {
+		return err
+	}
+	// Let the consensus engine drop its state about the discarded blocks. This
+	// happens outside of the chain lock, the engine may be waiting on it while
+	// holding its own locks, such as when writing a block after a bft commit.
+	if engine, ok := bc.engine.(headResetter); ok {
+		engine.ResetHead(bc.CurrentHeader())
+	}
+	return nil
+}
+
+// setHead rewinds the chain data to a new head, under the chain lock.
+func (bc *BlockChain) setHead(head uint64) error {
 	log.Warn("Rewinding blockchain", "target", head)
 
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
 
-	// Rewind the header chain, deleting all block bodies until then
+	// Rewind the header chain, deleting all block bodies, receipts, state diffs
+	// and transaction lookups until then
 	delFn := func(hash common.Hash, num uint64) {
+		if body := rawdb.ReadBody(bc.db, hash, num); body != nil {
+			for _, tx := range body.Transactions {
+				if blockHash, _, _ := rawdb.ReadTxLookupEntry(bc.db, tx.Hash()); blockHash == hash {
+					rawdb.DeleteTxLookupEntry(bc.db, tx.Hash())
+				}
+			}
+		}
 		rawdb.DeleteBody(bc.db, hash, num)
+		rawdb.DeleteReceipts(bc.db, hash, num)
+		rawdb.DeleteStateDiff(bc.db, hash, num)
 	}
 	bc.hc.SetHead(head, delFn)
 	currentHeader := bc.hc.CurrentHeader()
@@ -333,6 +387,42 @@ func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	return nil
 }
 
+// InsertCheckpoint anchors the header chain at a trusted header, letting the
+// headers following it be inserted without the history before it. Blocks all
+// having a difficulty of one, the total difficulty of the checkpoint follows
+// from its number.
+func (bc *BlockChain) InsertCheckpoint(header *types.Header) error {
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	number, hash := header.Number.Uint64(), header.Hash()
+	if bc.HasHeader(hash, number) {
+		return nil
+	}
+	if head := bc.CurrentHeader().Number.Uint64(); head >= number {
+		return fmt.Errorf("checkpoint #%d not above the head header #%d", number, head)
+	}
+	td := new(big.Int).Add(bc.genesisBlock.Difficulty(), header.Number)
+
+	batch := bc.db.NewBatch()
+	rawdb.WriteTd(batch, hash, number, td)
+	rawdb.WriteHeader(batch, header)
+	rawdb.WriteCanonicalHash(batch, hash, number)
+
+	// Neither the blocks before the checkpoint, nor its body and receipts, are
+	// retrieved. The history starts after it, like after a history expiry.
+	if rawdb.ReadHistoryTail(bc.db) <= number {
+		rawdb.WriteHistoryTail(batch, number+1)
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	bc.hc.SetCurrentHeader(header)
+
+	log.Info("Inserted trusted checkpoint", "number", number, "hash", hash)
+	return nil
+}
+
 // GasLimit returns the gas limit of the current HEAD block.
 func (bc *BlockChain) GasLimit() uint64 {
 	return bc.CurrentBlock().GasLimit()
@@ -388,6 +478,29 @@ func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
 	return state.New(root, bc.stateCache)
 }
 
+// importState returns a new mutable state to import a block on top of the given
+// root, tracking its flat changes if the state snapshot is maintained.
+func (bc *BlockChain) importState(root common.Hash) (*state.StateDB, error) {
+	statedb, err := state.New(root, bc.stateCache)
+	if err != nil {
+		return nil, err
+	}
+	if bc.snaps != nil {
+		statedb.TrackSnapshotDiff()
+	}
+	return statedb, nil
+}
+
+// StateCache returns the caching database underpinning the chain states.
+func (bc *BlockChain) StateCache() state.Database {
+	return bc.stateCache
+}
+
+// Snapshots returns the flat snapshot of the head state, nil if not maintained.
+func (bc *BlockChain) Snapshots() *snapshot.Tree {
+	return bc.snaps
+}
+
 // Reset purges the entire blockchain, restoring it to its genesis state.
 func (bc *BlockChain) Reset() error {
 	return bc.ResetWithGenesisBlock(bc.genesisBlock)
@@ -645,6 +758,9 @@ func (bc *BlockChain) Stop() {
 
 	bc.wg.Wait()
 
+	if bc.snaps != nil {
+		bc.snaps.Stop()
+	}
 	// Ensure the state of a recent block is also stored to disk before exiting.
 	// We're writing three different states to catch different restart scenarios:
 	//  - HEAD:     So we don't need to reprocess any blocks in the general case
@@ -891,6 +1007,13 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	batch := bc.db.NewBatch()
 	rawdb.WriteBlock(batch, block)
 
+	diff, err := state.StateDiff()
+	if err != nil {
+		return NonStatTy, err
+	}
+	if diff != nil {
+		rawdb.WriteStateDiff(batch, block.Hash(), block.NumberU64(), diff)
+	}
 	root, err := state.Commit(true)
 	if err != nil {
 		return NonStatTy, err
@@ -994,11 +1117,32 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	// Set new head.
 	if status == CanonStatTy {
 		bc.insert(block)
+		bc.updateSnapshot(block, root, state)
 	}
 	bc.futureBlocks.Remove(block.Hash())
 	return status, nil
 }
 
+// updateSnapshot moves the state snapshot to the state of the new head block.
+func (bc *BlockChain) updateSnapshot(block *types.Block, root common.Hash, state *state.StateDB) {
+	if bc.snaps == nil {
+		return
+	}
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return
+	}
+	diff := state.SnapshotDiff()
+	if diff == nil {
+		bc.snaps.Rebuild(root)
+		return
+	}
+	if err := bc.snaps.Update(parent.Root, root, diff); err != nil {
+		log.Error("Failed to update state snapshot", "number", block.Number(), "hash", block.Hash(), "err", err)
+		bc.snaps.Rebuild(root)
+	}
+}
+
 // InsertChain attempts to insert the given batch of blocks in to the canonical
 // chain or, otherwise, create a fork. If an error is returned it will return
 // the index number of the failing block as well an error describing what went
@@ -1169,7 +1313,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		} else {
 			parent = chain[i-1]
 		}
-		stateDb, err := state.New(parent.Root(), bc.stateCache)
+		stateDb, err := bc.importState(parent.Root())
 		if err != nil {
 			return i, events, coalescedLogs, err
 		}
@@ -1447,6 +1591,56 @@ func (bc *BlockChain) update() {
 	}
 }
 
+// expireHistory periodically prunes the bodies and receipts of the blocks older
+// than the history retention, until the blockchain is stopped.
+func (bc *BlockChain) expireHistory() {
+	defer bc.wg.Done()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-bc.quit:
+			return
+		}
+		head := bc.CurrentBlock().NumberU64()
+		for head >= bc.cacheConfig.HistoryRetention {
+			tail, limit := bc.HistoryTail(), head-bc.cacheConfig.HistoryRetention+1
+			if tail == 0 {
+				tail = 1 // The genesis is never expired
+			}
+			if limit <= tail {
+				break
+			}
+			if limit-tail > historyExpiryBatch {
+				limit = tail + historyExpiryBatch
+			}
+			if err := rawdb.PruneHistory(bc.db, limit); err != nil {
+				log.Error("Failed to expire block history", "err", err)
+				break
+			}
+			log.Info("Expired block history", "tail", limit)
+			bc.bodyCache.Purge()
+			bc.bodyRLPCache.Purge()
+			bc.blockCache.Purge()
+
+			select {
+			case <-bc.quit:
+				return
+			default:
+			}
+		}
+		timer.Reset(historyExpiryInterval)
+	}
+}
+
+// HistoryTail retrieves the number of the first block whose body and receipts
+// are retained, zero if the whole history is.
+func (bc *BlockChain) HistoryTail() uint64 {
+	return rawdb.ReadHistoryTail(bc.db)
+}
+
 // BadBlocks returns a list of the last 'bad blocks' that the client has seen on the network
 func (bc *BlockChain) BadBlocks() []*types.Block {
 	blocks := make([]*types.Block, 0, bc.badBlocks.Len())
@@ -1694,7 +1888,7 @@ func (bc *BlockChain) WriteBlock(block *types.Block) error {
 	if parent == nil {
 		return ErrParentIsNil
 	}
-	stateDb, err := bc.StateAt(parent.Root())
+	stateDb, err := bc.importState(parent.Root())
 	if err != nil {
 		return err
 	}
@@ -862,6 +862,59 @@ func TestFastVsFullChains(t *testing.T) {
 	}
 }
 
+// Tests that a chain anchored at a checkpoint accepts the headers and the fast
+// sync data following it, without the history before it.
+func TestInsertCheckpoint(t *testing.T) {
+	var (
+		gendb   = vntdb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(gendb)
+	)
+	blocks, receipts := GenerateChain(gspec.Config, genesis, mock.NewMock(), gendb, 64, nil)
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	db := vntdb.NewMemDatabase()
+	gspec.MustCommit(db)
+	chain, _ := NewBlockChain(db, nil, gspec.Config, mock.NewMock(), vm.Config{})
+	defer chain.Stop()
+
+	checkpoint := headers[31]
+	if err := chain.InsertCheckpoint(checkpoint); err != nil {
+		t.Fatalf("failed to insert checkpoint: %v", err)
+	}
+	if head := chain.CurrentHeader(); head.Hash() != checkpoint.Hash() {
+		t.Fatalf("head header mismatch: have #%d, want #%d", head.Number, checkpoint.Number)
+	}
+	want := new(big.Int).Add(genesis.Difficulty(), checkpoint.Number)
+	if td := chain.GetTd(checkpoint.Hash(), checkpoint.Number.Uint64()); td == nil || td.Cmp(want) != 0 {
+		t.Fatalf("checkpoint td mismatch: have %v, want %v", td, want)
+	}
+	if tail := rawdb.ReadHistoryTail(db); tail != checkpoint.Number.Uint64()+1 {
+		t.Fatalf("history tail mismatch: have %d, want %d", tail, checkpoint.Number.Uint64()+1)
+	}
+	if n, err := chain.InsertHeaderChain(headers[32:], 1); err != nil {
+		t.Fatalf("failed to insert header %d: %v", n, err)
+	}
+	if n, err := chain.InsertReceiptChain(blocks[32:], receipts[32:]); err != nil {
+		t.Fatalf("failed to insert receipt %d: %v", n, err)
+	}
+	if head := chain.CurrentFastBlock(); head.Hash() != blocks[63].Hash() {
+		t.Fatalf("head fast block mismatch: have #%d, want #%d", head.Number(), blocks[63].Number())
+	}
+	if chain.GetHeaderByNumber(1) != nil {
+		t.Fatalf("history before the checkpoint unexpectedly present")
+	}
+	// A checkpoint below the head is rejected
+	fork, _ := GenerateChain(gspec.Config, genesis, mock.NewMock(), gendb, 40, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	if err := chain.InsertCheckpoint(fork[39].Header()); err == nil {
+		t.Fatalf("checkpoint below the head accepted")
+	}
+}
+
 // Tests that various import methods move the chain head pointers to the correct
 // positions.
 func TestLightVsFastVsFullChainHeads(t *testing.T) {
@@ -1124,6 +1177,175 @@ func TestCanonicalBlockRetrieval(t *testing.T) {
 	pend.Wait()
 }
 
+// Tests that the bodies, receipts and transaction lookups of the blocks older
+// than the history retention are expired, while their headers are kept.
+func TestHistoryExpiry(t *testing.T) {
+	var (
+		gendb   = vntdb.NewMemDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: funds}}}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.NewHubbleSigner(gspec.Config.ChainID)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, mock.NewMock(), gendb, 10, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	db := vntdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	chain, _ := NewBlockChain(db, nil, gspec.Config, mock.NewMock(), vm.Config{})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to process block %d: %v", n, err)
+	}
+	chain.Stop()
+
+	// Reopen the chain keeping the history of the last 4 blocks
+	chain, _ = NewBlockChain(db, &CacheConfig{TrieNodeLimit: 256 * 1024 * 1024, TrieTimeLimit: 5 * time.Minute, HistoryRetention: 4}, gspec.Config, mock.NewMock(), vm.Config{})
+	defer chain.Stop()
+
+	for start := time.Now(); chain.HistoryTail() != 7; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("history tail mismatch: have %d, want %d", chain.HistoryTail(), 7)
+		}
+	}
+	for _, block := range blocks {
+		hash, number := block.Hash(), block.NumberU64()
+		expired := number < 7
+
+		if chain.GetHeaderByNumber(number) == nil {
+			t.Errorf("block %d: header missing", number)
+		}
+		if have := chain.GetBlockByNumber(number); (have == nil) != expired {
+			t.Errorf("block %d: block presence mismatch: have %v, expired %v", number, have != nil, expired)
+		}
+		if have := chain.GetReceiptsByHash(hash); (have == nil) != expired {
+			t.Errorf("block %d: receipts presence mismatch: have %v, expired %v", number, have != nil, expired)
+		}
+		if tx, _, _, _ := rawdb.ReadTransaction(db, block.Transactions()[0].Hash()); (tx == nil) != expired {
+			t.Errorf("block %d: transaction lookup mismatch: have %v, expired %v", number, tx != nil, expired)
+		}
+	}
+	if chain.GetBlockByNumber(0) == nil {
+		t.Errorf("genesis expired")
+	}
+}
+
+// Tests that the state changes made by the imported blocks are recorded when
+// requested.
+func TestStateDiffs(t *testing.T) {
+	var (
+		gendb     = vntdb.NewMemDatabase()
+		key, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address   = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.Address{0x01}
+		funds     = big.NewInt(1000000000)
+		gspec     = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: funds}}}
+		genesis   = gspec.MustCommit(gendb)
+		signer    = types.NewHubbleSigner(gspec.Config.ChainID)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, mock.NewMock(), gendb, 3, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), recipient, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	db := vntdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	chain, _ := NewBlockChain(db, &CacheConfig{TrieNodeLimit: 256 * 1024 * 1024, TrieTimeLimit: 5 * time.Minute, StateDiffs: true}, gspec.Config, mock.NewMock(), vm.Config{})
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to process block %d: %v", n, err)
+	}
+	for i, block := range blocks {
+		diff := rawdb.ReadStateDiff(db, block.Hash(), block.NumberU64())
+		if diff == nil {
+			t.Fatalf("block %d: state diff missing", block.NumberU64())
+		}
+		changes := make(map[common.Address]*types.AccountDiff)
+		for _, account := range diff {
+			changes[account.Address] = account
+		}
+		if sender := changes[address]; sender == nil || sender.Nonce == nil || sender.Nonce.From != uint64(i) || sender.Nonce.To != uint64(i+1) {
+			t.Errorf("block %d: sender nonce change mismatch: %+v", block.NumberU64(), sender)
+		}
+		want := &types.BalanceDiff{From: big.NewInt(int64(i * 1000)), To: big.NewInt(int64((i + 1) * 1000))}
+		if account := changes[recipient]; account == nil || account.Balance == nil || account.Balance.From.Cmp(want.From) != 0 || account.Balance.To.Cmp(want.To) != 0 {
+			t.Errorf("block %d: recipient balance change mismatch: have %+v, want %+v", block.NumberU64(), account, want)
+		}
+	}
+}
+
+// headResetMock is a mock consensus engine recording the head it was reset to.
+type headResetMock struct {
+	*mock.Mock
+	head *types.Header
+}
+
+func (m *headResetMock) ResetHead(head *types.Header) { m.head = head }
+
+// Tests that rewinding the chain deletes the receipts, state diffs and
+// transaction lookups of the discarded blocks, and resets the consensus engine.
+func TestSetHeadCleanup(t *testing.T) {
+	var (
+		gendb   = vntdb.NewMemDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: funds}}}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.NewHubbleSigner(gspec.Config.ChainID)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, mock.NewMock(), gendb, 8, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	db := vntdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	engine := &headResetMock{Mock: mock.NewMock()}
+	chain, _ := NewBlockChain(db, &CacheConfig{TrieNodeLimit: 256 * 1024 * 1024, TrieTimeLimit: 5 * time.Minute, StateDiffs: true}, gspec.Config, engine, vm.Config{})
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to process block %d: %v", n, err)
+	}
+	if err := chain.SetHead(4); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if head := chain.CurrentBlock().NumberU64(); head != 4 {
+		t.Fatalf("head mismatch: have %d, want %d", head, 4)
+	}
+	if engine.head == nil || engine.head.Number.Uint64() != 4 {
+		t.Errorf("engine reset head mismatch: have %v, want %d", engine.head, 4)
+	}
+	for _, block := range blocks {
+		hash, number := block.Hash(), block.NumberU64()
+		kept := number <= 4
+
+		if have := rawdb.ReadReceipts(db, hash, number); (have != nil) != kept {
+			t.Errorf("block %d: receipts presence mismatch: have %v, kept %v", number, have != nil, kept)
+		}
+		if have := rawdb.ReadStateDiff(db, hash, number); (have != nil) != kept {
+			t.Errorf("block %d: state diff presence mismatch: have %v, kept %v", number, have != nil, kept)
+		}
+		if have, _, _ := rawdb.ReadTxLookupEntry(db, block.Transactions()[0].Hash()); (have != common.Hash{}) != kept {
+			t.Errorf("block %d: transaction lookup presence mismatch: have %v, kept %v", number, have != common.Hash{}, kept)
+		}
+	}
+}
+
 func TestEIP155Transition(t *testing.T) {
 	// Configure and generate a sample block chain
 	var (
@@ -394,6 +394,34 @@ func (c *ChainIndexer) AddChildIndexer(indexer *ChainIndexer) {
 	}
 }
 
+// Rewind discards the sections not entirely below the given head block, which
+// the chain was rewound to, returning the heads of the discarded sections by
+// section number.
+func (c *ChainIndexer) Rewind(head uint64) map[uint64]common.Hash {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	sections := (head + 1) / c.sectionSize
+	if sections >= c.storedSections {
+		return nil
+	}
+	heads := make(map[uint64]common.Hash, c.storedSections-sections)
+	for section := sections; section < c.storedSections; section++ {
+		heads[section] = c.SectionHead(section)
+	}
+	c.setValidSections(sections)
+	if c.knownSections > sections {
+		c.knownSections = sections
+	}
+	if head = sections * c.sectionSize; head < c.cascadedHead {
+		c.cascadedHead = head
+		for _, child := range c.children {
+			child.newHead(c.cascadedHead, true)
+		}
+	}
+	return heads
+}
+
 // loadValidSections reads the number of valid sections from the index database
 // and caches is into the local state.
 func (c *ChainIndexer) loadValidSections() {
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"math/big"
 	"math/rand"
+	"reflect"
 	"testing"
 	"time"
 
@@ -44,6 +45,35 @@ func TestChainIndexerWithChildren(t *testing.T) {
 	}
 }
 
+// Tests that rewinding an indexer discards the sections not entirely below the
+// new head, reporting their heads.
+func TestChainIndexerRewind(t *testing.T) {
+	db := vntdb.NewMemDatabase()
+	defer db.Close()
+
+	backend := &testChainIndexBackend{t: t, processCh: make(chan uint64)}
+	indexer := NewChainIndexer(db, vntdb.NewTable(db, "i"), backend, 10, 0, 0, "indexer")
+	defer indexer.Close()
+
+	for section := uint64(0); section < 5; section++ {
+		indexer.AddKnownSectionHead(section, common.Hash{byte(section + 1)})
+	}
+	heads := indexer.Rewind(25)
+	if sections, _, _ := indexer.Sections(); sections != 2 {
+		t.Fatalf("section count mismatch: have %d, want %d", sections, 2)
+	}
+	want := map[uint64]common.Hash{2: {0x03}, 3: {0x04}, 4: {0x05}}
+	if !reflect.DeepEqual(heads, want) {
+		t.Errorf("discarded heads mismatch: have %v, want %v", heads, want)
+	}
+	if head := indexer.SectionHead(2); head != (common.Hash{}) {
+		t.Errorf("discarded section head kept: %x", head)
+	}
+	if heads := indexer.Rewind(100); heads != nil {
+		t.Errorf("sections discarded above the head: %v", heads)
+	}
+}
+
 // testChainIndexer runs a test with either a single chain indexer or a chain of
 // multiple backends. The section size and required confirmation count parameters
 // are randomized.
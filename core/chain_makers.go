@@ -71,6 +71,11 @@ func (b *BlockGen) SetExtra(data []byte) {
 	b.header.Extra = data
 }
 
+// SetWitnesses sets the witness list of the generated block.
+func (b *BlockGen) SetWitnesses(witnesses []common.Address) {
+	b.header.Witnesses = witnesses
+}
+
 // AddTx adds a transaction to the generated block. If no coinbase has
 // been set, the block's coinbase is set to the zero address.
 //
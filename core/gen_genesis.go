@@ -10,6 +10,7 @@ import (
 	"github.com/vntchain/go-vnt/common"
 	"github.com/vntchain/go-vnt/common/hexutil"
 	"github.com/vntchain/go-vnt/common/math"
+	"github.com/vntchain/go-vnt/core/vm/election"
 	"github.com/vntchain/go-vnt/params"
 )
 
@@ -26,6 +27,7 @@ func (g Genesis) MarshalJSON() ([]byte, error) {
 		Coinbase   common.Address                              `json:"coinbase"`
 		Alloc      map[common.UnprefixedAddress]GenesisAccount `json:"alloc"      gencodec:"required"`
 		Witnesses  []common.Address                            `json:"witnesses"`
+		Election   *election.State                             `json:"election,omitempty"`
 		Number     math.HexOrDecimal64                         `json:"number"`
 		GasUsed    math.HexOrDecimal64                         `json:"gasUsed"`
 		ParentHash common.Hash                                 `json:"parentHash"`
@@ -44,6 +46,7 @@ func (g Genesis) MarshalJSON() ([]byte, error) {
 		}
 	}
 	enc.Witnesses = g.Witnesses
+	enc.Election = g.Election
 	enc.Number = math.HexOrDecimal64(g.Number)
 	enc.GasUsed = math.HexOrDecimal64(g.GasUsed)
 	enc.ParentHash = g.ParentHash
@@ -61,6 +64,7 @@ func (g *Genesis) UnmarshalJSON(input []byte) error {
 		Coinbase   *common.Address                             `json:"coinbase"`
 		Alloc      map[common.UnprefixedAddress]GenesisAccount `json:"alloc"      gencodec:"required"`
 		Witnesses  []common.Address                            `json:"witnesses"`
+		Election   *election.State                             `json:"election,omitempty"`
 		Number     *math.HexOrDecimal64                        `json:"number"`
 		GasUsed    *math.HexOrDecimal64                        `json:"gasUsed"`
 		ParentHash *common.Hash                                `json:"parentHash"`
@@ -99,6 +103,9 @@ func (g *Genesis) UnmarshalJSON(input []byte) error {
 	if dec.Witnesses != nil {
 		g.Witnesses = dec.Witnesses
 	}
+	if dec.Election != nil {
+		g.Election = dec.Election
+	}
 	if dec.Number != nil {
 		g.Number = uint64(*dec.Number)
 	}
@@ -31,6 +31,7 @@ import (
 	"github.com/vntchain/go-vnt/core/rawdb"
 	"github.com/vntchain/go-vnt/core/state"
 	"github.com/vntchain/go-vnt/core/types"
+	"github.com/vntchain/go-vnt/core/vm/election"
 	"github.com/vntchain/go-vnt/log"
 	"github.com/vntchain/go-vnt/params"
 	"github.com/vntchain/go-vnt/rlp"
@@ -53,6 +54,7 @@ type Genesis struct {
 	Coinbase   common.Address      `json:"coinbase"`
 	Alloc      GenesisAlloc        `json:"alloc"      gencodec:"required"`
 	Witnesses  []common.Address    `json:"witnesses"`
+	Election   *election.State     `json:"election,omitempty"`
 
 	// These fields are used for consensus tests. Please don't use them
 	// in actual genesis blocks.
@@ -163,12 +165,19 @@ func SetupGenesisBlock(db vntdb.Database, genesis *Genesis) (*params.ChainConfig
 			log.Info("Writing custom genesis block")
 		}
 		block, err := genesis.Commit(db)
-		return genesis.Config, block.Hash(), err
+		if err != nil {
+			return genesis.Config, common.Hash{}, err
+		}
+		return genesis.Config, block.Hash(), nil
 	}
 
 	// Check whether the genesis block is already written.
 	if genesis != nil {
-		hash := genesis.ToBlock(nil).Hash()
+		block, err := genesis.ToBlock(nil)
+		if err != nil {
+			return genesis.Config, common.Hash{}, err
+		}
+		hash := block.Hash()
 		if hash != stored {
 			return genesis.Config, hash, &GenesisMismatchError{stored, hash}
 		}
@@ -216,7 +225,7 @@ func (g *Genesis) configOrDefault(ghash common.Hash) *params.ChainConfig {
 
 // ToBlock creates the genesis block and writes state of a genesis specification
 // to the given database (or discards it if nil).
-func (g *Genesis) ToBlock(db vntdb.Database) *types.Block {
+func (g *Genesis) ToBlock(db vntdb.Database) (*types.Block, error) {
 	if db == nil {
 		db = vntdb.NewMemDatabase()
 	}
@@ -229,6 +238,11 @@ func (g *Genesis) ToBlock(db vntdb.Database) *types.Block {
 			statedb.SetState(addr, key, value)
 		}
 	}
+	if g.Election != nil {
+		if err := election.WriteState(statedb, g.Election); err != nil {
+			return nil, fmt.Errorf("invalid genesis election state: %v", err)
+		}
+	}
 	root := statedb.IntermediateRoot(false)
 	head := &types.Header{
 		Number:     new(big.Int).SetUint64(g.Number),
@@ -251,13 +265,16 @@ func (g *Genesis) ToBlock(db vntdb.Database) *types.Block {
 	statedb.Commit(false)
 	statedb.Database().TrieDB().Commit(root, true)
 
-	return types.NewBlock(head, nil, nil)
+	return types.NewBlock(head, nil, nil), nil
 }
 
 // Commit writes the block and state of a genesis specification to the database.
 // The block is committed as the canonical head block.
 func (g *Genesis) Commit(db vntdb.Database) (*types.Block, error) {
-	block := g.ToBlock(db)
+	block, err := g.ToBlock(db)
+	if err != nil {
+		return nil, err
+	}
 	if block.Number().Sign() != 0 {
 		return nil, fmt.Errorf("can't commit genesis block with number > 0")
 	}
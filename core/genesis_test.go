@@ -26,17 +26,39 @@ import (
 	"github.com/vntchain/go-vnt/consensus/mock"
 	"github.com/vntchain/go-vnt/core/rawdb"
 	"github.com/vntchain/go-vnt/core/vm"
+	"github.com/vntchain/go-vnt/core/vm/election"
 	"github.com/vntchain/go-vnt/params"
 	"github.com/vntchain/go-vnt/vntdb"
 )
 
 func TestDefaultGenesisBlock(t *testing.T) {
-	block := DefaultGenesisBlock().ToBlock(nil)
+	block, err := DefaultGenesisBlock().ToBlock(nil)
+	if err != nil {
+		t.Fatalf("failed to create genesis block: %v", err)
+	}
 	if block.Hash() != params.MainnetGenesisHash {
 		t.Errorf("wrong mainnet genesis hash, got %v, want %v", block.Hash(), params.MainnetGenesisHash)
 	}
 }
 
+// Tests that a genesis with an election state that can't be written is refused
+// instead of committed without it.
+func TestSetupGenesisInvalidElection(t *testing.T) {
+	db := vntdb.NewMemDatabase()
+	genesis := &Genesis{
+		Config: params.TestChainConfig,
+		Election: &election.State{
+			Stakes: []election.Stake{{Owner: common.Address{1}, StakeCount: big.NewInt(-1), Vnt: new(big.Int), TimeStamp: new(big.Int)}},
+		},
+	}
+	if _, _, err := SetupGenesisBlock(db, genesis); err == nil {
+		t.Fatal("genesis with invalid election state accepted")
+	}
+	if hash := rawdb.ReadCanonicalHash(db, 0); hash != (common.Hash{}) {
+		t.Fatalf("genesis block committed: %x", hash)
+	}
+}
+
 func TestSetupGenesis(t *testing.T) {
 	var (
 		customghash = common.HexToHash("0x5ebf429a94e504ecb570a2c0591ef098ffd1307d35152f0572c503ec442db4a3")
@@ -471,6 +471,12 @@ func (hc *HeaderChain) SetHead(head uint64, delFn DeleteCallback) {
 	for i := height; i > head; i-- {
 		rawdb.DeleteCanonicalHash(hc.chainDb, i)
 	}
+	// Discard the frozen blocks past the new head
+	if frdb, ok := hc.chainDb.(rawdb.AncientWriter); ok {
+		if err := frdb.TruncateAncients(head + 1); err != nil {
+			log.Crit("Failed to truncate ancient blocks", "err", err)
+		}
+	}
 	// Clear out any stale content from the caches
 	hc.headerCache.Purge()
 	hc.tdCache.Purge()
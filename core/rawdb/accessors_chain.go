@@ -30,6 +30,9 @@ import (
 // ReadCanonicalHash retrieves the hash assigned to a canonical block number.
 func ReadCanonicalHash(db DatabaseReader, number uint64) common.Hash {
 	data, _ := db.Get(headerHashKey(number))
+	if len(data) == 0 {
+		data = readAncient(db, freezerHashTable, number)
+	}
 	if len(data) == 0 {
 		return common.Hash{}
 	}
@@ -126,18 +129,39 @@ func WriteFastTrieProgress(db DatabaseWriter, count uint64) {
 	}
 }
 
+// ReadHistoryTail retrieves the number of the first block whose body and
+// receipts are retained, zero if the whole history is.
+func ReadHistoryTail(db DatabaseReader) uint64 {
+	data, _ := db.Get(historyTailKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteHistoryTail stores the number of the first block whose body and receipts
+// are retained.
+func WriteHistoryTail(db DatabaseWriter, number uint64) {
+	if err := db.Put(historyTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store history tail", "err", err)
+	}
+}
+
 // ReadHeaderRLP retrieves a block header in its raw RLP database encoding.
 func ReadHeaderRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(headerKey(number, hash))
+	if len(data) == 0 {
+		data = readAncientOf(db, freezerHeaderTable, hash, number)
+	}
 	return data
 }
 
 // HasHeader verifies the existence of a block header corresponding to the hash.
 func HasHeader(db DatabaseReader, hash common.Hash, number uint64) bool {
-	if has, err := db.Has(headerKey(number, hash)); !has || err != nil {
-		return false
+	if has, err := db.Has(headerKey(number, hash)); has && err == nil {
+		return true
 	}
-	return true
+	return isAncient(db, hash, number)
 }
 
 // ReadHeader retrieves the block header corresponding to the hash.
@@ -191,6 +215,9 @@ func DeleteHeader(db DatabaseDeleter, hash common.Hash, number uint64) {
 // ReadBodyRLP retrieves the block body (transactions) in RLP encoding.
 func ReadBodyRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(blockBodyKey(number, hash))
+	if len(data) == 0 && !isExpired(db, number) {
+		data = readAncientOf(db, freezerBodiesTable, hash, number)
+	}
 	return data
 }
 
@@ -203,10 +230,10 @@ func WriteBodyRLP(db DatabaseWriter, hash common.Hash, number uint64, rlp rlp.Ra
 
 // HasBody verifies the existence of a block body corresponding to the hash.
 func HasBody(db DatabaseReader, hash common.Hash, number uint64) bool {
-	if has, err := db.Has(blockBodyKey(number, hash)); !has || err != nil {
-		return false
+	if has, err := db.Has(blockBodyKey(number, hash)); has && err == nil {
+		return true
 	}
-	return true
+	return !isExpired(db, number) && isAncient(db, hash, number)
 }
 
 // ReadBody retrieves the block body corresponding to the hash.
@@ -242,6 +269,9 @@ func DeleteBody(db DatabaseDeleter, hash common.Hash, number uint64) {
 // ReadTd retrieves a block's total difficulty corresponding to the hash.
 func ReadTd(db DatabaseReader, hash common.Hash, number uint64) *big.Int {
 	data, _ := db.Get(headerTDKey(number, hash))
+	if len(data) == 0 {
+		data = readAncientOf(db, freezerDifficultyTable, hash, number)
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -275,6 +305,9 @@ func DeleteTd(db DatabaseDeleter, hash common.Hash, number uint64) {
 func ReadReceipts(db DatabaseReader, hash common.Hash, number uint64) types.Receipts {
 	// Retrieve the flattened receipt slice
 	data, _ := db.Get(blockReceiptsKey(number, hash))
+	if len(data) == 0 && !isExpired(db, number) {
+		data = readAncientOf(db, freezerReceiptTable, hash, number)
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -315,6 +348,39 @@ func DeleteReceipts(db DatabaseDeleter, hash common.Hash, number uint64) {
 	}
 }
 
+// ReadStateDiff retrieves the state changes made by a block, if they were
+// recorded.
+func ReadStateDiff(db DatabaseReader, hash common.Hash, number uint64) types.StateDiff {
+	data, _ := db.Get(stateDiffKey(number, hash))
+	if len(data) == 0 {
+		return nil
+	}
+	diff := types.StateDiff{}
+	if err := rlp.DecodeBytes(data, &diff); err != nil {
+		log.Error("Invalid state diff RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return diff
+}
+
+// WriteStateDiff stores the state changes made by a block.
+func WriteStateDiff(db DatabaseWriter, hash common.Hash, number uint64, diff types.StateDiff) {
+	data, err := rlp.EncodeToBytes(diff)
+	if err != nil {
+		log.Crit("Failed to encode state diff", "err", err)
+	}
+	if err := db.Put(stateDiffKey(number, hash), data); err != nil {
+		log.Crit("Failed to store state diff", "err", err)
+	}
+}
+
+// DeleteStateDiff removes the state changes made by a block.
+func DeleteStateDiff(db DatabaseDeleter, hash common.Hash, number uint64) {
+	if err := db.Delete(stateDiffKey(number, hash)); err != nil {
+		log.Crit("Failed to delete state diff", "err", err)
+	}
+}
+
 // ReadBlock retrieves an entire block corresponding to the hash, assembling it
 // back from the stored header and body. If either the header or body could not
 // be retrieved nil is returned.
@@ -342,6 +408,7 @@ func WriteBlock(db DatabaseWriter, block *types.Block) {
 // DeleteBlock removes all block data associated with a hash.
 func DeleteBlock(db DatabaseDeleter, hash common.Hash, number uint64) {
 	DeleteReceipts(db, hash, number)
+	DeleteStateDiff(db, hash, number)
 	DeleteHeader(db, hash, number)
 	DeleteBody(db, hash, number)
 	DeleteTd(db, hash, number)
@@ -373,3 +440,28 @@ func FindCommonAncestor(db DatabaseReader, a, b *types.Header) *types.Header {
 	}
 	return a
 }
+
+// readAncient retrieves an item of a frozen block from the freezer of the
+// database, if it has one.
+func readAncient(db DatabaseReader, kind string, number uint64) []byte {
+	if frdb, ok := db.(AncientReader); ok && number < frdb.Ancients() {
+		data, _ := frdb.Ancient(kind, number)
+		return data
+	}
+	return nil
+}
+
+// isAncient reports whether the block of the given hash is frozen.
+func isAncient(db DatabaseReader, hash common.Hash, number uint64) bool {
+	data := readAncient(db, freezerHashTable, number)
+	return len(data) == common.HashLength && common.BytesToHash(data) == hash
+}
+
+// readAncientOf retrieves an item of a frozen block, provided the block frozen
+// at that number is the one of the given hash.
+func readAncientOf(db DatabaseReader, kind string, hash common.Hash, number uint64) []byte {
+	if !isAncient(db, hash, number) {
+		return nil
+	}
+	return readAncient(db, kind, number)
+}
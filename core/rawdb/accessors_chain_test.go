@@ -310,3 +310,39 @@ func TestBlockReceiptStorage(t *testing.T) {
 		t.Fatalf("deleted receipts returned: %v", rs)
 	}
 }
+
+// Tests that state diffs can be stored, retrieved and deleted.
+func TestStateDiffStorage(t *testing.T) {
+	db := vntdb.NewMemDatabase()
+
+	diff := types.StateDiff{
+		{
+			Address: common.BytesToAddress([]byte{0x01}),
+			Balance: &types.BalanceDiff{From: big.NewInt(1), To: big.NewInt(2)},
+			Nonce:   &types.NonceDiff{From: 0, To: 1},
+		},
+		{
+			Address: common.BytesToAddress([]byte{0x02}),
+			Code:    &types.CodeDiff{From: nil, To: []byte{0x60, 0x00}},
+			Storage: []types.StorageDiff{{Key: common.Hash{0x01}, From: common.Hash{}, To: common.Hash{0x02}}},
+		},
+	}
+	hash := common.BytesToHash([]byte{0x03, 0x14})
+	if d := ReadStateDiff(db, hash, 1); d != nil {
+		t.Fatalf("non existent state diff returned: %v", d)
+	}
+	WriteStateDiff(db, hash, 1, diff)
+	if d := ReadStateDiff(db, hash, 1); d == nil {
+		t.Fatalf("no state diff returned")
+	} else {
+		rlpHave, _ := rlp.EncodeToBytes(d)
+		rlpWant, _ := rlp.EncodeToBytes(diff)
+		if !bytes.Equal(rlpHave, rlpWant) {
+			t.Fatalf("state diff mismatch: have %x, want %x", rlpHave, rlpWant)
+		}
+	}
+	DeleteStateDiff(db, hash, 1)
+	if d := ReadStateDiff(db, hash, 1); d != nil {
+		t.Fatalf("deleted state diff returned: %v", d)
+	}
+}
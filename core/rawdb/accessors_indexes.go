@@ -105,3 +105,11 @@ func WriteBloomBits(db DatabaseWriter, bit uint, section uint64, head common.Has
 		log.Crit("Failed to store bloom bits", "err", err)
 	}
 }
+
+// DeleteBloomBits removes the compressed bloom bit vector belonging to the given
+// section and bit index.
+func DeleteBloomBits(db DatabaseDeleter, bit uint, section uint64, head common.Hash) {
+	if err := db.Delete(bloomBitsKey(bit, section, head)); err != nil {
+		log.Crit("Failed to delete bloom bits", "err", err)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/log"
+)
+
+// ReadSnapshotRoot retrieves the root of the state the snapshot represents.
+func ReadSnapshotRoot(db DatabaseReader) common.Hash {
+	data, _ := db.Get(snapshotRootKey)
+	if len(data) != common.HashLength {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteSnapshotRoot stores the root of the state the snapshot represents.
+func WriteSnapshotRoot(db DatabaseWriter, root common.Hash) {
+	if err := db.Put(snapshotRootKey, root.Bytes()); err != nil {
+		log.Crit("Failed to store snapshot root", "err", err)
+	}
+}
+
+// ReadSnapshotGenerator retrieves the account hash up to which the snapshot is
+// generated, followed by the storage slot hash if the storage of that account
+// is partially generated, and whether a generation is in progress at all.
+func ReadSnapshotGenerator(db DatabaseReader) ([]byte, bool) {
+	data, err := db.Get(snapshotGeneratorKey)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// WriteSnapshotGenerator stores the progress of the snapshot generation.
+func WriteSnapshotGenerator(db DatabaseWriter, marker []byte) {
+	if err := db.Put(snapshotGeneratorKey, marker); err != nil {
+		log.Crit("Failed to store snapshot generator", "err", err)
+	}
+}
+
+// DeleteSnapshotGenerator marks the snapshot generation done.
+func DeleteSnapshotGenerator(db DatabaseDeleter) {
+	if err := db.Delete(snapshotGeneratorKey); err != nil {
+		log.Crit("Failed to remove snapshot generator", "err", err)
+	}
+}
+
+// ReadSnapSyncStatus retrieves the serialized progress of an interrupted snap
+// sync, nil if there is none.
+func ReadSnapSyncStatus(db DatabaseReader) []byte {
+	data, _ := db.Get(snapSyncStatusKey)
+	return data
+}
+
+// WriteSnapSyncStatus stores the serialized progress of a snap sync.
+func WriteSnapSyncStatus(db DatabaseWriter, status []byte) {
+	if err := db.Put(snapSyncStatusKey, status); err != nil {
+		log.Crit("Failed to store snap sync status", "err", err)
+	}
+}
+
+// DeleteSnapSyncStatus removes the progress of a completed snap sync.
+func DeleteSnapSyncStatus(db DatabaseDeleter) {
+	if err := db.Delete(snapSyncStatusKey); err != nil {
+		log.Crit("Failed to remove snap sync status", "err", err)
+	}
+}
+
+// ReadAccountSnapshot retrieves the snapshot entry of an account.
+func ReadAccountSnapshot(db DatabaseReader, hash common.Hash) []byte {
+	data, _ := db.Get(accountSnapshotKey(hash))
+	return data
+}
+
+// WriteAccountSnapshot stores the snapshot entry of an account.
+func WriteAccountSnapshot(db DatabaseWriter, hash common.Hash, entry []byte) {
+	if err := db.Put(accountSnapshotKey(hash), entry); err != nil {
+		log.Crit("Failed to store account snapshot", "err", err)
+	}
+}
+
+// DeleteAccountSnapshot removes the snapshot entry of an account.
+func DeleteAccountSnapshot(db DatabaseDeleter, hash common.Hash) {
+	if err := db.Delete(accountSnapshotKey(hash)); err != nil {
+		log.Crit("Failed to delete account snapshot", "err", err)
+	}
+}
+
+// ReadStorageSnapshot retrieves the snapshot entry of a storage slot.
+func ReadStorageSnapshot(db DatabaseReader, accountHash, storageHash common.Hash) []byte {
+	data, _ := db.Get(storageSnapshotKey(accountHash, storageHash))
+	return data
+}
+
+// WriteStorageSnapshot stores the snapshot entry of a storage slot.
+func WriteStorageSnapshot(db DatabaseWriter, accountHash, storageHash common.Hash, entry []byte) {
+	if err := db.Put(storageSnapshotKey(accountHash, storageHash), entry); err != nil {
+		log.Crit("Failed to store storage snapshot", "err", err)
+	}
+}
+
+// DeleteStorageSnapshot removes the snapshot entry of a storage slot.
+func DeleteStorageSnapshot(db DatabaseDeleter, accountHash, storageHash common.Hash) {
+	if err := db.Delete(storageSnapshotKey(accountHash, storageHash)); err != nil {
+		log.Crit("Failed to delete storage snapshot", "err", err)
+	}
+}
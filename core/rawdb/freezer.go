@@ -0,0 +1,420 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/log"
+	"github.com/vntchain/go-vnt/params"
+	"github.com/vntchain/go-vnt/vntdb"
+)
+
+const (
+	// freezerRecheckInterval is the frequency to check the key-value database for
+	// chain progression that might permit new blocks to be frozen.
+	freezerRecheckInterval = time.Minute
+
+	// freezerBatchLimit is the maximum number of blocks to freeze in one batch
+	// before doing an fsync and deleting them from the key-value store.
+	freezerBatchLimit = 30000
+)
+
+var (
+	// errUnknownTable is returned if an item of an unknown kind is requested.
+	errUnknownTable = errors.New("unknown table")
+
+	// errNoFreezer is returned if a database has no freezer to move blocks into.
+	errNoFreezer = errors.New("database has no freezer")
+)
+
+// freezerTables are the kinds of items stored for each frozen block, and
+// whether they are compressed.
+var freezerTables = map[string]bool{
+	freezerHashTable:       false,
+	freezerHeaderTable:     true,
+	freezerBodiesTable:     true,
+	freezerReceiptTable:    true,
+	freezerDifficultyTable: false,
+}
+
+// freezer is an append-only store of the canonical blocks old enough to be
+// immutable, kept in flat files rather than in the key-value store.
+type freezer struct {
+	frozen    uint64 // Number of blocks frozen (atomic)
+	threshold uint64 // Number of recent blocks kept in the key-value store
+
+	tables map[string]*freezerTable
+	lock   sync.Mutex // Serializes the freezing of blocks
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newFreezer opens the freezer tables in the directory, and makes them agree
+// on the number of blocks frozen.
+func newFreezer(dir string) (*freezer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f := &freezer{
+		threshold: params.ImmutabilityThreshold,
+		tables:    make(map[string]*freezerTable),
+		quit:      make(chan struct{}),
+	}
+	for name, compress := range freezerTables {
+		table, err := newFreezerTable(dir, name, compress)
+		if err != nil {
+			f.closeTables()
+			return nil, err
+		}
+		f.tables[name] = table
+	}
+	if err := f.repair(); err != nil {
+		f.closeTables()
+		return nil, err
+	}
+	log.Info("Opened ancient database", "dir", dir, "frozen", f.frozen)
+	return f, nil
+}
+
+// repair truncates the tables to the number of blocks all of them hold.
+func (f *freezer) repair() error {
+	min := uint64(1<<64 - 1)
+	for _, table := range f.tables {
+		if items := table.Items(); items < min {
+			min = items
+		}
+	}
+	for _, table := range f.tables {
+		if err := table.Truncate(min); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, min)
+	return nil
+}
+
+// Ancients returns the number of blocks frozen.
+func (f *freezer) Ancients() uint64 {
+	return atomic.LoadUint64(&f.frozen)
+}
+
+// Ancient retrieves an item of a frozen block.
+func (f *freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	table := f.tables[kind]
+	if table == nil {
+		return nil, errUnknownTable
+	}
+	return table.Retrieve(number)
+}
+
+// AppendAncient appends the next block to the freezer. Either all the items
+// of the block are appended or none of them.
+func (f *freezer) AppendAncient(number uint64, hash, header, body, receipts, td []byte) (err error) {
+	if frozen := atomic.LoadUint64(&f.frozen); number != frozen {
+		return errOutOrder
+	}
+	defer func() {
+		if err != nil {
+			for _, table := range f.tables {
+				table.Truncate(number)
+			}
+		}
+	}()
+	items := map[string][]byte{
+		freezerHashTable:       hash,
+		freezerHeaderTable:     header,
+		freezerBodiesTable:     body,
+		freezerReceiptTable:    receipts,
+		freezerDifficultyTable: td,
+	}
+	for kind, blob := range items {
+		if err := f.tables[kind].Append(number, blob); err != nil {
+			return fmt.Errorf("failed to append %s #%d: %v", kind, number, err)
+		}
+	}
+	atomic.AddUint64(&f.frozen, 1)
+	return nil
+}
+
+// TruncateAncients discards the blocks past the given number of them.
+func (f *freezer) TruncateAncients(items uint64) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if atomic.LoadUint64(&f.frozen) <= items {
+		return nil
+	}
+	for _, table := range f.tables {
+		if err := table.Truncate(items); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, items)
+	return nil
+}
+
+// Sync flushes the freezer to disk.
+func (f *freezer) Sync() error {
+	for _, table := range f.tables {
+		if err := table.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops the freezing and closes the tables.
+func (f *freezer) Close() error {
+	select {
+	case <-f.quit:
+	default:
+		close(f.quit)
+	}
+	f.wg.Wait()
+	return f.closeTables()
+}
+
+func (f *freezer) closeTables() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// freeze moves the blocks old enough to be immutable from the key-value store
+// into the freezer, periodically, until the freezer is closed.
+func (f *freezer) freeze(db vntdb.Database) {
+	defer f.wg.Done()
+
+	for {
+		frozen, err := f.freezeBatch(db)
+		if err != nil {
+			log.Error("Failed to freeze blocks", "err", err)
+		}
+		if err != nil || frozen == 0 {
+			select {
+			case <-time.After(freezerRecheckInterval):
+			case <-f.quit:
+				return
+			}
+			continue
+		}
+		select {
+		case <-f.quit:
+			return
+		default:
+		}
+	}
+}
+
+// freezeBatch moves the next batch of immutable canonical blocks from the
+// key-value store into the freezer, returning the number of blocks moved.
+func (f *freezer) freezeBatch(db vntdb.Database) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	// Only the blocks deep enough below the head block are immutable
+	number := ReadHeaderNumber(db, ReadHeadBlockHash(db))
+	if number == nil || *number < f.threshold {
+		return 0, nil
+	}
+	var (
+		first = f.Ancients()
+		limit = *number - f.threshold + 1
+	)
+	if limit <= first {
+		return 0, nil
+	}
+	if limit-first > freezerBatchLimit {
+		limit = first + freezerBatchLimit
+	}
+	start := time.Now()
+
+	var (
+		hashes = make([]common.Hash, 0, limit-first)
+		err    error
+	)
+	for n := first; n < limit; n++ {
+		hash := ReadCanonicalHash(db, n)
+		if hash == (common.Hash{}) {
+			if !isExpired(db, n) {
+				err = fmt.Errorf("canonical hash missing for block #%d", n)
+				break
+			}
+			// The blocks below the checkpoint the chain was synced from were never
+			// retrieved, they are frozen as empty items
+			if err = f.AppendAncient(n, nil, nil, nil, nil, nil); err != nil {
+				break
+			}
+			hashes = append(hashes, hash)
+			continue
+		}
+		var (
+			header, _   = db.Get(headerKey(n, hash))
+			body, _     = db.Get(blockBodyKey(n, hash))
+			receipts, _ = db.Get(blockReceiptsKey(n, hash))
+			td, _       = db.Get(headerTDKey(n, hash))
+		)
+		expired := isExpired(db, n)
+		if expired {
+			body, receipts = nil, nil // The expired history is frozen as empty items
+		}
+		if len(header) == 0 || len(td) == 0 || (!expired && (len(body) == 0 || len(receipts) == 0)) {
+			err = fmt.Errorf("block #%d [%x] incomplete", n, hash[:4])
+			break
+		}
+		if err = f.AppendAncient(n, hash[:], header, body, receipts, td); err != nil {
+			break
+		}
+		hashes = append(hashes, hash)
+	}
+	if len(hashes) == 0 {
+		return 0, err
+	}
+	// Only wipe the blocks from the key-value store once safely on disk
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	batch := db.NewBatch()
+	for i, hash := range hashes {
+		n := first + uint64(i)
+		if n == 0 || hash == (common.Hash{}) {
+			continue // The genesis is kept for the chain setup, the gaps have nothing to delete
+		}
+		deleteFrozenBlock(batch, hash, n)
+		for _, side := range readAllHashes(db, n) {
+			if side != hash {
+				DeleteBlock(batch, side, n)
+			}
+		}
+		if batch.ValueSize() > vntdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return len(hashes), err
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return len(hashes), err
+	}
+	log.Info("Moved blocks into the ancient database", "blocks", len(hashes), "frozen", f.Ancients(), "elapsed", common.PrettyDuration(time.Since(start)))
+	return len(hashes), err
+}
+
+// deleteFrozenBlock removes a frozen block from the key-value store, keeping its
+// hash to number mapping to look it up by hash.
+func deleteFrozenBlock(db DatabaseDeleter, hash common.Hash, number uint64) {
+	for _, key := range [][]byte{headerKey(number, hash), blockBodyKey(number, hash), blockReceiptsKey(number, hash), headerTDKey(number, hash), headerHashKey(number)} {
+		if err := db.Delete(key); err != nil {
+			log.Crit("Failed to delete frozen block", "err", err)
+		}
+	}
+}
+
+// readAllHashes retrieves the hashes of all the headers stored for a block
+// number, if the database is iterable.
+func readAllHashes(db vntdb.Database, number uint64) []common.Hash {
+	it, ok := db.(vntdb.Iteratee)
+	if !ok {
+		return nil
+	}
+	prefix := append(append([]byte{}, headerPrefix...), encodeBlockNumber(number)...)
+	iter := it.NewIteratorWithPrefix(prefix)
+	defer iter.Release()
+
+	var hashes []common.Hash
+	for iter.Next() {
+		if key := iter.Key(); len(key) == len(prefix)+common.HashLength {
+			hashes = append(hashes, common.BytesToHash(key[len(prefix):]))
+		}
+	}
+	return hashes
+}
+
+// freezerdb is a key-value database moving the immutable chain segments into a
+// freezer, from which the chain accessors read them transparently.
+type freezerdb struct {
+	vntdb.Database
+	*freezer
+}
+
+// NewDatabaseWithFreezer wraps a key-value database with a freezer in the given
+// directory, into which the immutable blocks are moved in the background.
+func NewDatabaseWithFreezer(db vntdb.Database, dir string) (vntdb.Database, error) {
+	frdb, err := newFreezer(dir)
+	if err != nil {
+		return nil, err
+	}
+	frdb.wg.Add(1)
+	go frdb.freeze(db)
+
+	return &freezerdb{Database: db, freezer: frdb}, nil
+}
+
+// NewIteratorWithPrefix implements vntdb.Iteratee, iterating the key-value store.
+// It panics if the key-value store is not iterable.
+func (db *freezerdb) NewIteratorWithPrefix(prefix []byte) iterator.Iterator {
+	return db.Database.(vntdb.Iteratee).NewIteratorWithPrefix(prefix)
+}
+
+// Close stops the freezing, then closes both the freezer and the key-value store.
+func (db *freezerdb) Close() {
+	if err := db.freezer.Close(); err != nil {
+		log.Error("Failed to close ancient database", "err", err)
+	}
+	db.Database.Close()
+}
+
+// KeyValueStore returns the key-value store of a database, without the freezer.
+func KeyValueStore(db vntdb.Database) vntdb.Database {
+	if frdb, ok := db.(*freezerdb); ok {
+		return frdb.Database
+	}
+	return db
+}
+
+// FreezeAncients moves all the immutable blocks of a database into its freezer
+// at once, returning the number of blocks moved.
+func FreezeAncients(db vntdb.Database) (uint64, error) {
+	frdb, ok := db.(*freezerdb)
+	if !ok {
+		return 0, errNoFreezer
+	}
+	var total uint64
+	for {
+		frozen, err := frdb.freezeBatch(frdb.Database)
+		total += uint64(frozen)
+		if err != nil || frozen == 0 {
+			return total, err
+		}
+	}
+}
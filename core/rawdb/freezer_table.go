@@ -0,0 +1,276 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/vntchain/go-vnt/log"
+)
+
+// indexEntrySize is the size of an index entry, the end offset of an item in
+// the data file.
+const indexEntrySize = 8
+
+var (
+	// errOutOfBounds is returned if the item requested is not in the table.
+	errOutOfBounds = errors.New("out of bounds")
+
+	// errOutOrder is returned if an item is not appended right after the last one.
+	errOutOrder = errors.New("the append operation is out-order")
+
+	// errClosed is returned if the table is used after being closed.
+	errClosed = errors.New("closed")
+)
+
+// freezerTable is an append-only flat file storing one kind of item of the
+// frozen blocks, numbered from zero. The items are concatenated in a data file,
+// while an index file records the end offset of each of them.
+type freezerTable struct {
+	name     string
+	compress bool // Whether the items are stored snappy compressed
+
+	index *os.File // File holding the end offsets of the items
+	data  *os.File // File holding the items
+
+	items uint64 // Number of items stored
+	size  uint64 // Size of the data file, the end offset of the last item
+
+	lock sync.RWMutex
+}
+
+// newFreezerTable opens the table of the given name in the directory, creating
+// it if needed, and repairs the trail of an interrupted append.
+func newFreezerTable(dir, name string, compress bool) (*freezerTable, error) {
+	ext := "r"
+	if compress {
+		ext = "c"
+	}
+	index, err := os.OpenFile(filepath.Join(dir, fmt.Sprintf("%s.%sidx", name, ext)), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.OpenFile(filepath.Join(dir, fmt.Sprintf("%s.%sdat", name, ext)), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		index.Close()
+		return nil, err
+	}
+	t := &freezerTable{
+		name:     name,
+		compress: compress,
+		index:    index,
+		data:     data,
+	}
+	if err := t.repair(); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// repair drops the partially written index entry and the items missing from the
+// data file, then cuts the data file after the last item indexed.
+func (t *freezerTable) repair() error {
+	stat, err := t.index.Stat()
+	if err != nil {
+		return err
+	}
+	items := uint64(stat.Size()) / indexEntrySize
+
+	if stat, err = t.data.Stat(); err != nil {
+		return err
+	}
+	dataSize := uint64(stat.Size())
+
+	var size uint64
+	for ; items > 0; items-- {
+		if size, err = t.offset(items - 1); err != nil {
+			return err
+		}
+		if size <= dataSize {
+			break
+		}
+	}
+	if items == 0 {
+		size = 0
+	}
+	if err := t.index.Truncate(int64(items * indexEntrySize)); err != nil {
+		return err
+	}
+	if err := t.data.Truncate(int64(size)); err != nil {
+		return err
+	}
+	if uint64(stat.Size()) != size {
+		log.Warn("Repaired freezer table", "name", t.name, "items", items, "dropped", dataSize-size)
+	}
+	t.items, t.size = items, size
+	return nil
+}
+
+// offset returns the end offset in the data file of an item.
+func (t *freezerTable) offset(item uint64) (uint64, error) {
+	entry := make([]byte, indexEntrySize)
+	if _, err := t.index.ReadAt(entry, int64(item*indexEntrySize)); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(entry), nil
+}
+
+// Items returns the number of items stored.
+func (t *freezerTable) Items() uint64 {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.items
+}
+
+// Size returns the size of the table on disk, its index and data files.
+func (t *freezerTable) Size() uint64 {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.items*indexEntrySize + t.size
+}
+
+// Retrieve returns an item of the table.
+func (t *freezerTable) Retrieve(item uint64) ([]byte, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.index == nil {
+		return nil, errClosed
+	}
+	if item >= t.items {
+		return nil, errOutOfBounds
+	}
+	var start uint64
+	if item > 0 {
+		var err error
+		if start, err = t.offset(item - 1); err != nil {
+			return nil, err
+		}
+	}
+	end, err := t.offset(item)
+	if err != nil {
+		return nil, err
+	}
+	blob := make([]byte, end-start)
+	if _, err := t.data.ReadAt(blob, int64(start)); err != nil {
+		return nil, err
+	}
+	if t.compress {
+		return snappy.Decode(nil, blob)
+	}
+	return blob, nil
+}
+
+// Append adds the next item to the table. The data is only durable once the
+// table is synced.
+func (t *freezerTable) Append(item uint64, blob []byte) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return errClosed
+	}
+	if item != t.items {
+		return errOutOrder
+	}
+	if t.compress {
+		blob = snappy.Encode(nil, blob)
+	}
+	if _, err := t.data.WriteAt(blob, int64(t.size)); err != nil {
+		return err
+	}
+	entry := make([]byte, indexEntrySize)
+	binary.BigEndian.PutUint64(entry, t.size+uint64(len(blob)))
+	if _, err := t.index.WriteAt(entry, int64(t.items*indexEntrySize)); err != nil {
+		return err
+	}
+	t.items, t.size = t.items+1, t.size+uint64(len(blob))
+	return nil
+}
+
+// Truncate discards the items past the given number of them.
+func (t *freezerTable) Truncate(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return errClosed
+	}
+	if items >= t.items {
+		return nil
+	}
+	var size uint64
+	if items > 0 {
+		var err error
+		if size, err = t.offset(items - 1); err != nil {
+			return err
+		}
+	}
+	if err := t.index.Truncate(int64(items * indexEntrySize)); err != nil {
+		return err
+	}
+	if err := t.data.Truncate(int64(size)); err != nil {
+		return err
+	}
+	t.items, t.size = items, size
+	return nil
+}
+
+// Sync flushes the table to disk, data first so that no index entry ever points
+// past the data file.
+func (t *freezerTable) Sync() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return errClosed
+	}
+	if err := t.data.Sync(); err != nil {
+		return err
+	}
+	return t.index.Sync()
+}
+
+// Close closes the files of the table.
+func (t *freezerTable) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return nil
+	}
+	var errs []error
+	for _, f := range []*os.File{t.index, t.data} {
+		if err := f.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	t.index, t.data = nil, nil
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testItem returns the deterministic content of a test item.
+func testItem(i int) []byte {
+	return bytes.Repeat([]byte{byte(i)}, i%37+1)
+}
+
+// Tests that items appended to a table are retrieved, also after reopening it.
+func TestFreezerTableAppendRetrieve(t *testing.T) {
+	t.Run("raw", func(t *testing.T) { testFreezerTableAppendRetrieve(t, false) })
+	t.Run("compressed", func(t *testing.T) { testFreezerTableAppendRetrieve(t, true) })
+}
+
+func testFreezerTableAppendRetrieve(t *testing.T, compress bool) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	table, err := newFreezerTable(dir, "test", compress)
+	if err != nil {
+		t.Fatalf("failed to open table: %v", err)
+	}
+	for i := 0; i < 255; i++ {
+		if err := table.Append(uint64(i), testItem(i)); err != nil {
+			t.Fatalf("failed to append item %d: %v", i, err)
+		}
+	}
+	if err := table.Append(300, testItem(300)); err != errOutOrder {
+		t.Fatalf("out of order append error mismatch: have %v, want %v", err, errOutOrder)
+	}
+	table.Close()
+
+	if table, err = newFreezerTable(dir, "test", compress); err != nil {
+		t.Fatalf("failed to reopen table: %v", err)
+	}
+	defer table.Close()
+
+	if items := table.Items(); items != 255 {
+		t.Fatalf("item count mismatch: have %d, want %d", items, 255)
+	}
+	for i := 0; i < 255; i++ {
+		blob, err := table.Retrieve(uint64(i))
+		if err != nil {
+			t.Fatalf("failed to retrieve item %d: %v", i, err)
+		}
+		if !bytes.Equal(blob, testItem(i)) {
+			t.Fatalf("item %d mismatch: have %x, want %x", i, blob, testItem(i))
+		}
+	}
+	if _, err := table.Retrieve(255); err != errOutOfBounds {
+		t.Fatalf("out of bounds retrieval error mismatch: have %v, want %v", err, errOutOfBounds)
+	}
+}
+
+// Tests that the trail of an interrupted append is dropped when a table is
+// reopened.
+func TestFreezerTableRepair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	table, err := newFreezerTable(dir, "test", false)
+	if err != nil {
+		t.Fatalf("failed to open table: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := table.Append(uint64(i), testItem(i)); err != nil {
+			t.Fatalf("failed to append item %d: %v", i, err)
+		}
+	}
+	size := table.size
+	table.Close()
+
+	// Cut the data of the last item, and leave a partial index entry behind
+	if err := os.Truncate(filepath.Join(dir, "test.rdat"), int64(size-1)); err != nil {
+		t.Fatal(err)
+	}
+	index, err := os.OpenFile(filepath.Join(dir, "test.ridx"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index.Write([]byte{0x01, 0x02, 0x03})
+	index.Close()
+
+	if table, err = newFreezerTable(dir, "test", false); err != nil {
+		t.Fatalf("failed to reopen table: %v", err)
+	}
+	defer table.Close()
+
+	if items := table.Items(); items != 9 {
+		t.Fatalf("item count mismatch: have %d, want %d", items, 9)
+	}
+	if blob, err := table.Retrieve(8); err != nil || !bytes.Equal(blob, testItem(8)) {
+		t.Fatalf("item 8 mismatch: have %x (%v), want %x", blob, err, testItem(8))
+	}
+	// The table accepts the dropped item again
+	if err := table.Append(9, testItem(9)); err != nil {
+		t.Fatalf("failed to append item 9: %v", err)
+	}
+	if blob, err := table.Retrieve(9); err != nil || !bytes.Equal(blob, testItem(9)) {
+		t.Fatalf("item 9 mismatch: have %x (%v), want %x", blob, err, testItem(9))
+	}
+}
+
+// Tests that a truncated table drops its last items and appends after them.
+func TestFreezerTableTruncate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	table, err := newFreezerTable(dir, "test", true)
+	if err != nil {
+		t.Fatalf("failed to open table: %v", err)
+	}
+	defer table.Close()
+
+	for i := 0; i < 10; i++ {
+		if err := table.Append(uint64(i), testItem(i)); err != nil {
+			t.Fatalf("failed to append item %d: %v", i, err)
+		}
+	}
+	if err := table.Truncate(5); err != nil {
+		t.Fatalf("failed to truncate table: %v", err)
+	}
+	if _, err := table.Retrieve(5); err != errOutOfBounds {
+		t.Fatalf("truncated item retrieval error mismatch: have %v, want %v", err, errOutOfBounds)
+	}
+	if err := table.Append(5, []byte("replaced")); err != nil {
+		t.Fatalf("failed to append item 5: %v", err)
+	}
+	if blob, err := table.Retrieve(5); err != nil || string(blob) != "replaced" {
+		t.Fatalf("item 5 mismatch: have %x (%v), want %x", blob, err, "replaced")
+	}
+	if blob, err := table.Retrieve(4); err != nil || !bytes.Equal(blob, testItem(4)) {
+		t.Fatalf("item 4 mismatch: have %x (%v), want %x", blob, err, testItem(4))
+	}
+}
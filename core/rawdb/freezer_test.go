@@ -0,0 +1,189 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/core/types"
+	"github.com/vntchain/go-vnt/vntdb"
+)
+
+// writeTestChain writes a canonical chain of blocks into the database, each of
+// them with a receipt, along with a side block at number 3.
+func writeTestChain(db vntdb.Database, n int) ([]*types.Block, *types.Block) {
+	var (
+		blocks []*types.Block
+		parent common.Hash
+	)
+	for i := 0; i < n; i++ {
+		header := &types.Header{Number: big.NewInt(int64(i)), ParentHash: parent, Extra: []byte("test block")}
+		block := types.NewBlockWithHeader(header)
+		receipt := &types.Receipt{CumulativeGasUsed: uint64(i), Logs: []*types.Log{}, TxHash: common.Hash{byte(i)}}
+
+		WriteBlock(db, block)
+		WriteReceipts(db, block.Hash(), block.NumberU64(), types.Receipts{receipt})
+		WriteTd(db, block.Hash(), block.NumberU64(), big.NewInt(int64(i+1)))
+		WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+
+		blocks, parent = append(blocks, block), block.Hash()
+	}
+	WriteHeadBlockHash(db, parent)
+
+	side := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(3), ParentHash: blocks[2].Hash(), Extra: []byte("side block")})
+	WriteBlock(db, side)
+	WriteReceipts(db, side.Hash(), side.NumberU64(), nil)
+	WriteTd(db, side.Hash(), side.NumberU64(), big.NewInt(4))
+
+	return blocks, side
+}
+
+// Tests that the immutable blocks are moved into the freezer, and transparently
+// retrieved from it.
+func TestFreezeBlocks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	kvdb := vntdb.NewMemDatabase()
+	blocks, side := writeTestChain(kvdb, 10)
+
+	frdb, err := newFreezer(dir)
+	if err != nil {
+		t.Fatalf("failed to open freezer: %v", err)
+	}
+	frdb.threshold = 4
+	db := &freezerdb{Database: kvdb, freezer: frdb}
+
+	if frozen, err := FreezeAncients(db); err != nil || frozen != 6 {
+		t.Fatalf("frozen blocks mismatch: have %d (%v), want %d", frozen, err, 6)
+	}
+	if frozen := db.Ancients(); frozen != 6 {
+		t.Fatalf("ancients mismatch: have %d, want %d", frozen, 6)
+	}
+	for i, block := range blocks {
+		hash, number := block.Hash(), block.NumberU64()
+
+		// The frozen blocks but the genesis are gone from the key-value store
+		if has, _ := kvdb.Has(headerKey(number, hash)); has != (i == 0 || i >= 6) {
+			t.Errorf("block %d: key-value store presence mismatch: have %v", i, has)
+		}
+		if have := ReadCanonicalHash(db, number); have != hash {
+			t.Errorf("block %d: canonical hash mismatch: have %x, want %x", i, have, hash)
+		}
+		if have := ReadHeaderNumber(db, hash); have == nil || *have != number {
+			t.Errorf("block %d: number mismatch: have %v, want %d", i, have, number)
+		}
+		if have := ReadBlock(db, hash, number); have == nil || have.Hash() != hash {
+			t.Errorf("block %d: block mismatch: have %v", i, have)
+		}
+		if !HasHeader(db, hash, number) || !HasBody(db, hash, number) {
+			t.Errorf("block %d: header or body reported missing", i)
+		}
+		if have := ReadReceipts(db, hash, number); len(have) != 1 || have[0].CumulativeGasUsed != uint64(i) {
+			t.Errorf("block %d: receipts mismatch: have %v", i, have)
+		}
+		if have := ReadTd(db, hash, number); have == nil || have.Int64() != int64(i+1) {
+			t.Errorf("block %d: td mismatch: have %v, want %d", i, have, i+1)
+		}
+	}
+	// The frozen blocks are only retrieved by their own hash
+	if header := ReadHeader(db, common.Hash{0x01}, 3); header != nil {
+		t.Errorf("frozen header retrieved by unknown hash")
+	}
+	// The side blocks of the frozen numbers are discarded
+	if HasHeader(db, side.Hash(), 3) || ReadHeaderNumber(db, side.Hash()) != nil {
+		t.Errorf("side block not discarded")
+	}
+	// The freezer is restored from disk, and truncated on request
+	db.freezer.Close()
+	if frdb, err = newFreezer(dir); err != nil {
+		t.Fatalf("failed to reopen freezer: %v", err)
+	}
+	defer frdb.Close()
+	db = &freezerdb{Database: kvdb, freezer: frdb}
+
+	if frozen := db.Ancients(); frozen != 6 {
+		t.Fatalf("reopened ancients mismatch: have %d, want %d", frozen, 6)
+	}
+	if err := db.TruncateAncients(4); err != nil {
+		t.Fatalf("failed to truncate freezer: %v", err)
+	}
+	if ReadBlock(db, blocks[4].Hash(), 4) != nil || ReadCanonicalHash(db, 4) != (common.Hash{}) {
+		t.Errorf("truncated block still retrieved")
+	}
+	if ReadBlock(db, blocks[3].Hash(), 3) == nil {
+		t.Errorf("remaining frozen block not retrieved")
+	}
+}
+
+// Tests that a chain synced from a checkpoint, without the blocks before it nor
+// the body and receipts of the checkpoint, is frozen across the gap.
+func TestFreezeCheckpointGap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Strip the chain down to what a checkpoint sync at block 4 stores
+	kvdb := vntdb.NewMemDatabase()
+	blocks, side := writeTestChain(kvdb, 10)
+	DeleteBlock(kvdb, side.Hash(), 3)
+	for _, block := range blocks[1:4] {
+		DeleteBlock(kvdb, block.Hash(), block.NumberU64())
+		DeleteCanonicalHash(kvdb, block.NumberU64())
+	}
+	DeleteBody(kvdb, blocks[4].Hash(), 4)
+	DeleteReceipts(kvdb, blocks[4].Hash(), 4)
+	WriteHistoryTail(kvdb, 5)
+
+	frdb, err := newFreezer(dir)
+	if err != nil {
+		t.Fatalf("failed to open freezer: %v", err)
+	}
+	defer frdb.Close()
+	frdb.threshold = 3
+	db := &freezerdb{Database: kvdb, freezer: frdb}
+
+	if frozen, err := FreezeAncients(db); err != nil || frozen != 7 {
+		t.Fatalf("frozen blocks mismatch: have %d (%v), want %d", frozen, err, 7)
+	}
+	for i, block := range blocks[:7] {
+		hash, number := block.Hash(), block.NumberU64()
+
+		stored := i == 0 || i >= 4
+		if have := ReadCanonicalHash(db, number); (have == hash) != stored || (!stored && have != (common.Hash{})) {
+			t.Errorf("block %d: canonical hash mismatch: have %x", i, have)
+		}
+		if HasHeader(db, hash, number) != stored {
+			t.Errorf("block %d: header presence mismatch: want %v", i, stored)
+		}
+		if have := ReadTd(db, hash, number); (have != nil) != stored {
+			t.Errorf("block %d: td presence mismatch: have %v", i, have)
+		}
+		if have := ReadBlock(db, hash, number); (have != nil) != (i == 0 || i >= 5) {
+			t.Errorf("block %d: block presence mismatch: have %v", i, have)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/log"
+	"github.com/vntchain/go-vnt/vntdb"
+)
+
+// isExpired reports whether the body and receipts of a block were pruned by the
+// history expiry. The genesis is never pruned.
+func isExpired(db DatabaseReader, number uint64) bool {
+	return number > 0 && number < ReadHistoryTail(db)
+}
+
+// PruneHistory deletes the bodies, receipts and state diffs of the blocks below
+// the given number, along with the transaction lookup entries of the canonical
+// ones, and moves the history tail up to it. Headers and total difficulties are kept.
+//
+// The items of the blocks already moved into the freezer can't be deleted from
+// its append-only tables, they are only hidden from the chain accessors. Only
+// a history retention below params.ImmutabilityThreshold reclaims the disk
+// space of the expired blocks, pruned before they get frozen, as empty items.
+func PruneHistory(db vntdb.Database, limit uint64) error {
+	tail := ReadHistoryTail(db)
+	if tail == 0 {
+		tail = 1 // The genesis is kept for the chain setup
+	}
+	if limit <= tail {
+		return nil
+	}
+	batch := db.NewBatch()
+	for n := tail; n < limit; n++ {
+		canonical := ReadCanonicalHash(db, n)
+		if canonical != (common.Hash{}) {
+			if body := ReadBody(db, canonical, n); body != nil {
+				for _, tx := range body.Transactions {
+					DeleteTxLookupEntry(batch, tx.Hash())
+				}
+			}
+		}
+		for _, hash := range append(readAllHashes(db, n), canonical) {
+			DeleteBody(batch, hash, n)
+			DeleteReceipts(batch, hash, n)
+			DeleteStateDiff(batch, hash, n)
+		}
+		// Flush the progress along the deletions, the tail never points above
+		// blocks still stored
+		if batch.ValueSize() > vntdb.IdealBatchSize {
+			WriteHistoryTail(batch, n+1)
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	WriteHistoryTail(batch, limit)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Debug("Pruned block history", "from", tail, "to", limit)
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/vntchain/go-vnt/vntdb"
+)
+
+// Tests that the expired bodies and receipts are gone both from the key-value
+// store and the freezer, while the headers and the recent history are kept.
+func TestPruneHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	kvdb := vntdb.NewMemDatabase()
+	blocks, side := writeTestChain(kvdb, 10)
+
+	frdb, err := newFreezer(dir)
+	if err != nil {
+		t.Fatalf("failed to open freezer: %v", err)
+	}
+	defer frdb.Close()
+	frdb.threshold = 7
+	db := &freezerdb{Database: kvdb, freezer: frdb}
+
+	if _, err := FreezeAncients(db); err != nil {
+		t.Fatalf("failed to freeze blocks: %v", err)
+	}
+	if err := PruneHistory(db, 7); err != nil {
+		t.Fatalf("failed to prune history: %v", err)
+	}
+	if tail := ReadHistoryTail(db); tail != 7 {
+		t.Fatalf("history tail mismatch: have %d, want %d", tail, 7)
+	}
+	if HasBody(kvdb, side.Hash(), side.NumberU64()) || ReadReceipts(kvdb, side.Hash(), side.NumberU64()) != nil {
+		t.Errorf("side block history not expired")
+	}
+	// Blocks from the tail onward can still be frozen, the expired ones as empty items
+	frdb.threshold = 1
+	if frozen, err := FreezeAncients(db); err != nil || frozen != 6 {
+		t.Fatalf("frozen blocks mismatch: have %d (%v), want %d", frozen, err, 6)
+	}
+	for i, block := range blocks {
+		hash, number := block.Hash(), block.NumberU64()
+		expired := i > 0 && i < 7
+
+		if !HasHeader(db, hash, number) || ReadTd(db, hash, number) == nil {
+			t.Errorf("block %d: header or td missing", i)
+		}
+		if have := ReadBodyRLP(db, hash, number); (len(have) == 0) != expired {
+			t.Errorf("block %d: body presence mismatch: have %x, expired %v", i, have, expired)
+		}
+		if HasBody(db, hash, number) == expired {
+			t.Errorf("block %d: body reported %v, expired %v", i, !expired, expired)
+		}
+		if have := ReadReceipts(db, hash, number); (have == nil) != expired {
+			t.Errorf("block %d: receipts presence mismatch: have %v, expired %v", i, have, expired)
+		}
+	}
+	// Pruning is idempotent below the tail
+	if err := PruneHistory(db, 3); err != nil || ReadHistoryTail(db) != 7 {
+		t.Errorf("history tail moved back: have %d (%v)", ReadHistoryTail(db), err)
+	}
+}
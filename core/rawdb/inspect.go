@@ -0,0 +1,182 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/log"
+	"github.com/vntchain/go-vnt/vntdb"
+)
+
+// errNotIterable is returned if the database to inspect can't be iterated.
+var errNotIterable = errors.New("database not iterable")
+
+// DatabaseStat is the number of entries of one category stored in a database,
+// and their total size, keys included.
+type DatabaseStat struct {
+	Category string
+	Count    uint64
+	Size     common.StorageSize
+}
+
+// add accounts an entry of the given size to the stat.
+func (s *DatabaseStat) add(size int) {
+	s.Count++
+	s.Size += common.StorageSize(size)
+}
+
+// metadataKeys are the singleton keys tracking the database and chain progress.
+var metadataKeys = [][]byte{
+	databaseVerisionKey, headHeaderKey, headBlockKey, headFastBlockKey, fastTrieProgressKey,
+	snapshotRootKey, snapshotGeneratorKey, snapSyncStatusKey, historyTailKey,
+}
+
+// The categories of the entries of the key-value store, in reporting order.
+const (
+	statHeaders = iota
+	statTds
+	statCanonicalHashes
+	statHeaderNumbers
+	statBodies
+	statReceipts
+	statStateDiffs
+	statTxLookups
+	statBloomBits
+	statTrieNodes
+	statSnapshotAccounts
+	statSnapshotStorage
+	statPreimages
+	statConfigs
+	statChainIndexes
+	statMetadata
+	statUnaccounted
+)
+
+var statCategories = []string{
+	statHeaders:          "Headers",
+	statTds:              "Total difficulties",
+	statCanonicalHashes:  "Canonical hashes",
+	statHeaderNumbers:    "Block number lookups",
+	statBodies:           "Bodies",
+	statReceipts:         "Receipts",
+	statStateDiffs:       "State diffs",
+	statTxLookups:        "Transaction lookups",
+	statBloomBits:        "Bloombits",
+	statTrieNodes:        "Trie nodes and codes",
+	statSnapshotAccounts: "Snapshot accounts",
+	statSnapshotStorage:  "Snapshot storage",
+	statPreimages:        "Trie preimages",
+	statConfigs:          "Chain configs",
+	statChainIndexes:     "Chain indexes",
+	statMetadata:         "Metadata",
+	statUnaccounted:      "Unaccounted",
+}
+
+// categorize returns the category of an entry of the key-value store by its key.
+func categorize(key []byte) int {
+	for _, meta := range metadataKeys {
+		if bytes.Equal(key, meta) {
+			return statMetadata
+		}
+	}
+	switch {
+	case bytes.HasPrefix(key, headerPrefix) && len(key) == len(headerPrefix)+8+common.HashLength:
+		return statHeaders
+	case bytes.HasPrefix(key, headerPrefix) && len(key) == len(headerPrefix)+8+common.HashLength+len(headerTDSuffix) && bytes.HasSuffix(key, headerTDSuffix):
+		return statTds
+	case bytes.HasPrefix(key, headerPrefix) && len(key) == len(headerPrefix)+8+len(headerHashSuffix) && bytes.HasSuffix(key, headerHashSuffix):
+		return statCanonicalHashes
+	case bytes.HasPrefix(key, headerNumberPrefix) && len(key) == len(headerNumberPrefix)+common.HashLength:
+		return statHeaderNumbers
+	case bytes.HasPrefix(key, blockBodyPrefix) && len(key) == len(blockBodyPrefix)+8+common.HashLength:
+		return statBodies
+	case bytes.HasPrefix(key, blockReceiptsPrefix) && len(key) == len(blockReceiptsPrefix)+8+common.HashLength:
+		return statReceipts
+	case bytes.HasPrefix(key, stateDiffPrefix) && len(key) == len(stateDiffPrefix)+8+common.HashLength:
+		return statStateDiffs
+	case bytes.HasPrefix(key, txLookupPrefix) && len(key) == len(txLookupPrefix)+common.HashLength:
+		return statTxLookups
+	case bytes.HasPrefix(key, bloomBitsPrefix) && len(key) == len(bloomBitsPrefix)+10+common.HashLength:
+		return statBloomBits
+	case len(key) == common.HashLength:
+		return statTrieNodes
+	case bytes.HasPrefix(key, SnapshotAccountPrefix) && len(key) == len(SnapshotAccountPrefix)+common.HashLength:
+		return statSnapshotAccounts
+	case bytes.HasPrefix(key, SnapshotStoragePrefix) && len(key) == len(SnapshotStoragePrefix)+2*common.HashLength:
+		return statSnapshotStorage
+	case bytes.HasPrefix(key, preimagePrefix) && len(key) == len(preimagePrefix)+common.HashLength:
+		return statPreimages
+	case bytes.HasPrefix(key, configPrefix) && len(key) == len(configPrefix)+common.HashLength:
+		return statConfigs
+	case bytes.HasPrefix(key, []byte("i")):
+		return statChainIndexes
+	}
+	return statUnaccounted
+}
+
+// InspectDatabase walks the entire key-value store of a database, reporting the
+// number and size of its entries by category, followed by the items of each
+// table of the freezer, if the database has one.
+func InspectDatabase(db vntdb.Database) ([]DatabaseStat, error) {
+	it, ok := KeyValueStore(db).(vntdb.Iteratee)
+	if !ok {
+		return nil, errNotIterable
+	}
+	stats := make([]DatabaseStat, len(statCategories))
+	for i, category := range statCategories {
+		stats[i].Category = category
+	}
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		count  uint64
+	)
+	iter := it.NewIteratorWithPrefix(nil)
+	for iter.Next() {
+		key, value := iter.Key(), iter.Value()
+		stats[categorize(key)].add(len(key) + len(value))
+
+		if count++; time.Since(logged) > 8*time.Second {
+			log.Info("Inspecting database", "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	if frdb, ok := db.(*freezerdb); ok {
+		names := make([]string, 0, len(frdb.tables))
+		for name := range frdb.tables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			stats = append(stats, DatabaseStat{
+				Category: "Ancient " + name,
+				Count:    frdb.Ancients(),
+				Size:     common.StorageSize(frdb.tables[name].Size()),
+			})
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"testing"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/vntdb"
+)
+
+// Tests that the database entries are counted in the category of their key.
+func TestInspectDatabase(t *testing.T) {
+	db := vntdb.NewMemDatabase()
+	writeTestChain(db, 10)
+
+	db.Put(common.Hash{0x01}.Bytes(), []byte{0x01, 0x02})
+	WritePreimages(db, 0, map[common.Hash][]byte{{0x02}: {0x03}})
+	db.Put([]byte("unknown"), []byte{0x04})
+
+	stats, err := InspectDatabase(db)
+	if err != nil {
+		t.Fatalf("failed to inspect database: %v", err)
+	}
+	want := map[string]uint64{
+		"Headers":              11,
+		"Total difficulties":   11,
+		"Canonical hashes":     10,
+		"Block number lookups": 11,
+		"Bodies":               11,
+		"Receipts":             11,
+		"Trie nodes and codes": 1,
+		"Trie preimages":       1,
+		"Metadata":             1,
+		"Unaccounted":          1,
+	}
+	for _, stat := range stats {
+		if stat.Count != want[stat.Category] {
+			t.Errorf("%s: count mismatch: have %d, want %d", stat.Category, stat.Count, want[stat.Category])
+		}
+		if (stat.Count == 0) != (stat.Size == 0) {
+			t.Errorf("%s: size %v mismatches count %d", stat.Category, stat.Size, stat.Count)
+		}
+	}
+	if size := stats[statTrieNodes].Size; size != common.StorageSize(common.HashLength+2) {
+		t.Errorf("trie nodes size mismatch: have %v, want %d", size, common.HashLength+2)
+	}
+}
@@ -31,3 +31,25 @@ type DatabaseWriter interface {
 type DatabaseDeleter interface {
 	Delete(key []byte) error
 }
+
+// AncientReader wraps the retrieval of the blocks moved into a freezer, numbered
+// from zero.
+type AncientReader interface {
+	// Ancients returns the number of blocks frozen.
+	Ancients() uint64
+
+	// Ancient retrieves an item of a frozen block.
+	Ancient(kind string, number uint64) ([]byte, error)
+}
+
+// AncientWriter wraps the appending of blocks to a freezer and their truncation.
+type AncientWriter interface {
+	// AppendAncient appends the next block to the freezer.
+	AppendAncient(number uint64, hash, header, body, receipts, td []byte) error
+
+	// TruncateAncients discards the blocks past the given number of them.
+	TruncateAncients(items uint64) error
+
+	// Sync flushes the freezer to disk.
+	Sync() error
+}
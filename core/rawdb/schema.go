@@ -41,6 +41,19 @@ var (
 	// fastTrieProgressKey tracks the number of trie entries imported during fast sync.
 	fastTrieProgressKey = []byte("TrieSync")
 
+	// snapshotRootKey tracks the state root the flat state snapshot represents.
+	snapshotRootKey = []byte("SnapshotRoot")
+
+	// snapshotGeneratorKey tracks the account hash up to which the snapshot is generated,
+	// followed by the storage slot hash of a partially generated account storage.
+	snapshotGeneratorKey = []byte("SnapshotGenerator")
+
+	// snapSyncStatusKey tracks the progress of an interrupted snap sync.
+	snapSyncStatusKey = []byte("SnapSyncStatus")
+
+	// historyTailKey tracks the first block whose body and receipts are retained.
+	historyTailKey = []byte("HistoryTail")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -49,6 +62,10 @@ var (
 
 	blockBodyPrefix     = []byte("b") // blockBodyPrefix + num (uint64 big endian) + hash -> block body
 	blockReceiptsPrefix = []byte("r") // blockReceiptsPrefix + num (uint64 big endian) + hash -> block receipts
+	stateDiffPrefix     = []byte("d") // stateDiffPrefix + num (uint64 big endian) + hash -> block state diff
+
+	SnapshotAccountPrefix = []byte("a") // SnapshotAccountPrefix + account hash -> account trie value
+	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
 
 	txLookupPrefix  = []byte("l") // txLookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
@@ -63,6 +80,15 @@ var (
 	preimageHitCounter = metrics.NewRegisteredCounter("db/preimage/hits", nil)
 )
 
+// The kinds of items stored for each block of the freezer.
+const (
+	freezerHashTable       = "hashes"   // Canonical hash of the block
+	freezerHeaderTable     = "headers"  // Header RLP
+	freezerBodiesTable     = "bodies"   // Body RLP
+	freezerReceiptTable    = "receipts" // Receipts RLP, in their storage form
+	freezerDifficultyTable = "diffs"    // Total difficulty RLP
+)
+
 // TxLookupEntry is a positional metadata to help looking up the data content of
 // a transaction or receipt given only its hash.
 type TxLookupEntry struct {
@@ -108,6 +134,26 @@ func blockReceiptsKey(number uint64, hash common.Hash) []byte {
 	return append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
 
+// stateDiffKey = stateDiffPrefix + num (uint64 big endian) + hash
+func stateDiffKey(number uint64, hash common.Hash) []byte {
+	return append(append(stateDiffPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+// accountSnapshotKey = SnapshotAccountPrefix + hash
+func accountSnapshotKey(hash common.Hash) []byte {
+	return append(append([]byte{}, SnapshotAccountPrefix...), hash.Bytes()...)
+}
+
+// storageSnapshotKey = SnapshotStoragePrefix + account hash + storage hash
+func storageSnapshotKey(accountHash, storageHash common.Hash) []byte {
+	return append(StorageSnapshotsKey(accountHash), storageHash.Bytes()...)
+}
+
+// StorageSnapshotsKey = SnapshotStoragePrefix + account hash
+func StorageSnapshotsKey(accountHash common.Hash) []byte {
+	return append(append([]byte{}, SnapshotStoragePrefix...), accountHash.Bytes()...)
+}
+
 // txLookupKey = txLookupPrefix + hash
 func txLookupKey(hash common.Hash) []byte {
 	return append(txLookupPrefix, hash.Bytes()...)
@@ -0,0 +1,146 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/core/types"
+)
+
+// diffRecorder tracks the accounts and storage slots touched since the state
+// was opened, gathered from the journal before each of its resets.
+type diffRecorder struct {
+	root     common.Hash                                 // Root of the state before the changes
+	accounts map[common.Address]map[common.Hash]struct{} // Touched accounts and their written slots
+}
+
+// touch marks an account as touched, returning its set of written slots.
+func (r *diffRecorder) touch(addr common.Address) map[common.Hash]struct{} {
+	slots, ok := r.accounts[addr]
+	if !ok {
+		slots = make(map[common.Hash]struct{})
+		r.accounts[addr] = slots
+	}
+	return slots
+}
+
+// copy returns a deep copy of the recorder.
+func (r *diffRecorder) copy() *diffRecorder {
+	cpy := &diffRecorder{
+		root:     r.root,
+		accounts: make(map[common.Address]map[common.Hash]struct{}, len(r.accounts)),
+	}
+	for addr, slots := range r.accounts {
+		cpy.accounts[addr] = make(map[common.Hash]struct{}, len(slots))
+		for key := range slots {
+			cpy.accounts[addr][key] = struct{}{}
+		}
+	}
+	return cpy
+}
+
+// RecordDiff starts recording the changes made to the state, which must not be
+// modified yet, for StateDiff to report them.
+func (self *StateDB) RecordDiff() {
+	self.diff = &diffRecorder{
+		root:     self.trie.Hash(),
+		accounts: make(map[common.Address]map[common.Hash]struct{}),
+	}
+}
+
+// recordJournal gathers the accounts and slots touched by the journal entries,
+// if the changes are recorded.
+func (self *StateDB) recordJournal() {
+	if self.diff == nil {
+		return
+	}
+	for _, entry := range self.journal.entries {
+		switch change := entry.(type) {
+		case storageChange:
+			self.diff.touch(*change.account)[change.key] = struct{}{}
+		case resetObjectChange:
+			self.diff.touch(change.prev.address)
+		default:
+			if addr := entry.dirtied(); addr != nil {
+				self.diff.touch(*addr)
+			}
+		}
+	}
+}
+
+// StateDiff returns the changes made to the state since RecordDiff, comparing
+// the values of the touched accounts and slots with the ones in the original
+// state. It returns nil if the changes aren't recorded.
+func (self *StateDB) StateDiff() (types.StateDiff, error) {
+	if self.diff == nil {
+		return nil, nil
+	}
+	self.recordJournal()
+
+	prev, err := New(self.diff.root, self.db)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]common.Address, 0, len(self.diff.accounts))
+	for addr := range self.diff.accounts {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+
+	diff := types.StateDiff{}
+	for _, addr := range addrs {
+		account := &types.AccountDiff{Address: addr}
+		if from, to := prev.GetBalance(addr), self.GetBalance(addr); from.Cmp(to) != 0 {
+			account.Balance = &types.BalanceDiff{From: from, To: to}
+		}
+		if from, to := prev.GetNonce(addr), self.GetNonce(addr); from != to {
+			account.Nonce = &types.NonceDiff{From: from, To: to}
+		}
+		if codeHash(prev, addr) != codeHash(self, addr) {
+			account.Code = &types.CodeDiff{From: prev.GetCode(addr), To: self.GetCode(addr)}
+		}
+		keys := make([]common.Hash, 0, len(self.diff.accounts[addr]))
+		for key := range self.diff.accounts[addr] {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i][:], keys[j][:]) < 0 })
+		for _, key := range keys {
+			if from, to := prev.GetState(addr, key), self.GetState(addr, key); from != to {
+				account.Storage = append(account.Storage, types.StorageDiff{Key: key, From: from, To: to})
+			}
+		}
+		if account.Balance != nil || account.Nonce != nil || account.Code != nil || len(account.Storage) > 0 {
+			diff = append(diff, account)
+		}
+	}
+	if err := prev.Error(); err != nil {
+		return nil, err
+	}
+	return diff, self.Error()
+}
+
+// codeHash returns the code hash of an account, the one of the empty code for
+// the missing accounts.
+func codeHash(s *StateDB, addr common.Address) common.Hash {
+	if hash := s.GetCodeHash(addr); hash != (common.Hash{}) {
+		return hash
+	}
+	return common.BytesToHash(emptyCodeHash)
+}
@@ -17,8 +17,10 @@
 package state
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"math/big"
 
 	"github.com/vntchain/go-vnt/common"
 	"github.com/vntchain/go-vnt/rlp"
@@ -71,6 +73,57 @@ func (self *StateDB) RawDump() Dump {
 	return dump
 }
 
+// DumpAccounts walks the accounts of the state in the order of their hashes,
+// passing each of them to the callback along with its code and decoded storage,
+// so that a whole state can be exported without holding it in memory. The walk
+// fails on the accounts and storage slots whose preimages are missing.
+func (self *StateDB) DumpAccounts(cb func(addr common.Address, balance *big.Int, nonce uint64, code []byte, storage map[common.Hash]common.Hash) error) error {
+	it := trie.NewIterator(self.trie.NodeIterator(nil))
+	for it.Next() {
+		addr := self.trie.GetKey(it.Key)
+		if addr == nil {
+			return fmt.Errorf("missing preimage of account %x", it.Key)
+		}
+		var data Account
+		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
+			return fmt.Errorf("account %x: %v", addr, err)
+		}
+		addrHash := common.BytesToHash(it.Key)
+
+		var code []byte
+		if !bytes.Equal(data.CodeHash, emptyCodeHash) {
+			var err error
+			if code, err = self.db.ContractCode(addrHash, common.BytesToHash(data.CodeHash)); err != nil {
+				return fmt.Errorf("account %x: %v", addr, err)
+			}
+		}
+		tr, err := self.db.OpenStorageTrie(addrHash, data.Root)
+		if err != nil {
+			return fmt.Errorf("account %x: %v", addr, err)
+		}
+		storage := make(map[common.Hash]common.Hash)
+		storageIt := trie.NewIterator(tr.NodeIterator(nil))
+		for storageIt.Next() {
+			key := self.trie.GetKey(storageIt.Key)
+			if key == nil {
+				return fmt.Errorf("account %x: missing preimage of storage slot %x", addr, storageIt.Key)
+			}
+			_, content, _, err := rlp.Split(storageIt.Value)
+			if err != nil {
+				return fmt.Errorf("account %x: storage slot %x: %v", addr, key, err)
+			}
+			storage[common.BytesToHash(key)] = common.BytesToHash(content)
+		}
+		if storageIt.Err != nil {
+			return storageIt.Err
+		}
+		if err := cb(common.BytesToAddress(addr), data.Balance, data.Nonce, code, storage); err != nil {
+			return err
+		}
+	}
+	return it.Err
+}
+
 func (self *StateDB) Dump() []byte {
 	json, err := json.MarshalIndent(self.RawDump(), "", "    ")
 	if err != nil {
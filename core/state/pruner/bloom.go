@@ -0,0 +1,63 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"encoding/binary"
+
+	"github.com/vntchain/go-vnt/common"
+)
+
+// stateBloom is a bloom filter of the hashes of the live state entries, which
+// are the trie nodes and the contract codes. The hashes are uniformly random,
+// so the bit positions are taken from the hash itself instead of rehashing it.
+type stateBloom struct {
+	bits []uint64
+	size uint64 // Number of bits in the filter
+}
+
+// newStateBloom creates a bloom filter taking up the given number of megabytes.
+func newStateBloom(megabytes uint64) *stateBloom {
+	if megabytes == 0 {
+		megabytes = 1
+	}
+	words := megabytes * 1024 * 1024 / 8
+	return &stateBloom{
+		bits: make([]uint64, words),
+		size: words * 64,
+	}
+}
+
+// Put adds a hash to the filter.
+func (b *stateBloom) Put(hash common.Hash) {
+	for i := 0; i < common.HashLength; i += 8 {
+		bit := binary.BigEndian.Uint64(hash[i:]) % b.size
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Contain reports whether a hash may have been added to the filter. False
+// positives are possible, false negatives aren't.
+func (b *stateBloom) Contain(hash common.Hash) bool {
+	for i := 0; i < common.HashLength; i += 8 {
+		bit := binary.BigEndian.Uint64(hash[i:]) % b.size
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
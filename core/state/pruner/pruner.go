@@ -0,0 +1,201 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pruner implements the offline pruning of the state, deleting from the
+// chain database the trie nodes and contract codes which aren't reachable from
+// the recent states any more.
+package pruner
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/core/rawdb"
+	"github.com/vntchain/go-vnt/core/state"
+	"github.com/vntchain/go-vnt/log"
+	"github.com/vntchain/go-vnt/vntdb"
+)
+
+const (
+	// DefaultRecent is the default number of recent blocks whose states are
+	// kept, the same as the number of states a full node keeps in memory.
+	DefaultRecent = 128
+
+	// DefaultBloomSize is the default size of the bloom filter in megabytes.
+	DefaultBloomSize = 2048
+
+	// logInterval is the interval of the progress logs.
+	logInterval = 8 * time.Second
+)
+
+var (
+	// errNoIteration is returned when the database can't iterate its content.
+	errNoIteration = errors.New("database does not support iteration")
+
+	// errNoHead is returned when the database has no head block.
+	errNoHead = errors.New("head block missing")
+)
+
+// Pruner deletes the state entries of a chain database, that is the trie nodes
+// and the contract codes, which aren't part of the states of the recent blocks
+// or of the genesis block. The live entries are marked in a bloom filter, so a
+// few dead ones may be kept, but none of the live ones is deleted.
+type Pruner struct {
+	db    vntdb.Database
+	it    vntdb.Iteratee
+	bloom *stateBloom
+}
+
+// NewPruner creates a pruner of the database, with a bloom filter of the given
+// size in megabytes. The larger the filter, the fewer dead entries are kept.
+func NewPruner(db vntdb.Database, bloomSize uint64) (*Pruner, error) {
+	it, ok := db.(vntdb.Iteratee)
+	if !ok {
+		return nil, errNoIteration
+	}
+	return &Pruner{
+		db:    db,
+		it:    it,
+		bloom: newStateBloom(bloomSize),
+	}, nil
+}
+
+// Prune keeps the states of the last recent blocks which are stored, along with
+// the genesis state, and deletes all the other state entries. If none of the
+// recent states is stored, the newest stored one is kept, which a node restarts
+// from. It's safe to interrupt the pruning, as only dead entries are deleted.
+func (p *Pruner) Prune(recent uint64) error {
+	roots, err := p.liveRoots(recent)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	for _, root := range roots {
+		if err := p.mark(root); err != nil {
+			return fmt.Errorf("failed to mark state %x: %v", root, err)
+		}
+	}
+	log.Info("Marked live states", "roots", len(roots), "elapsed", common.PrettyDuration(time.Since(start)))
+
+	return p.sweep()
+}
+
+// liveRoots returns the roots of the states to keep, newest first.
+func (p *Pruner) liveRoots(recent uint64) ([]common.Hash, error) {
+	headHash := rawdb.ReadHeadBlockHash(p.db)
+	head := rawdb.ReadHeaderNumber(p.db, headHash)
+	if head == nil {
+		return nil, errNoHead
+	}
+	var roots []common.Hash
+	for number := *head; ; number-- {
+		header := rawdb.ReadHeader(p.db, rawdb.ReadCanonicalHash(p.db, number), number)
+		if header == nil {
+			return nil, fmt.Errorf("canonical header #%d missing", number)
+		}
+		if stored, _ := p.db.Has(header.Root.Bytes()); stored {
+			roots = append(roots, header.Root)
+		}
+		if number == 0 {
+			break
+		}
+		// Past the recent blocks, only the genesis state is needed
+		if *head-number+1 >= recent && len(roots) > 0 {
+			genesis := rawdb.ReadHeader(p.db, rawdb.ReadCanonicalHash(p.db, 0), 0)
+			if genesis == nil {
+				return nil, errors.New("genesis header missing")
+			}
+			if stored, _ := p.db.Has(genesis.Root.Bytes()); stored {
+				roots = append(roots, genesis.Root)
+			}
+			break
+		}
+	}
+	if len(roots) == 0 {
+		return nil, errors.New("no state stored")
+	}
+	return roots, nil
+}
+
+// mark adds all the trie nodes and contract codes of a state to the filter.
+func (p *Pruner) mark(root common.Hash) error {
+	statedb, err := state.New(root, state.NewDatabase(p.db))
+	if err != nil {
+		return err
+	}
+	var (
+		nodes  int
+		logged = time.Now()
+	)
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+		// Nodes embedded in their parents have no hash of their own
+		if it.Hash == (common.Hash{}) {
+			continue
+		}
+		p.bloom.Put(it.Hash)
+		nodes++
+
+		if time.Since(logged) > logInterval {
+			log.Info("Marking live state", "root", root, "nodes", nodes)
+			logged = time.Now()
+		}
+	}
+	return it.Error
+}
+
+// sweep deletes the state entries which aren't in the filter. Trie nodes and
+// contract codes are the only entries of the database keyed by a bare hash.
+func (p *Pruner) sweep() error {
+	var (
+		start   = time.Now()
+		logged  = time.Now()
+		deleted int
+		batch   = p.db.NewBatch()
+	)
+	it := p.it.NewIteratorWithPrefix(nil)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != common.HashLength || p.bloom.Contain(common.BytesToHash(key)) {
+			continue
+		}
+		batch.Delete(common.CopyBytes(key))
+		deleted++
+
+		if batch.ValueSize() >= vntdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > logInterval {
+			log.Info("Pruning state", "deleted", deleted, "at", common.BytesToHash(key))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Pruned state", "deleted", deleted, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/consensus/mock"
+	"github.com/vntchain/go-vnt/core"
+	"github.com/vntchain/go-vnt/core/state"
+	"github.com/vntchain/go-vnt/core/types"
+	"github.com/vntchain/go-vnt/core/vm"
+	"github.com/vntchain/go-vnt/crypto"
+	"github.com/vntchain/go-vnt/params"
+	"github.com/vntchain/go-vnt/vntdb"
+)
+
+// Tests that pruning keeps the recent and genesis states of an archive chain
+// intact, and deletes the older ones.
+func TestPrune(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		signer  = types.NewHubbleSigner(params.TestChainConfig.ChainID)
+		gspec   = &core.Genesis{Config: params.TestChainConfig, Alloc: core.GenesisAlloc{addr: {Balance: big.NewInt(params.Vnt)}}}
+		db      = vntdb.NewMemDatabase()
+		genesis = gspec.MustCommit(db)
+		gendb   = vntdb.NewMemDatabase()
+	)
+	gspec.MustCommit(gendb)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, mock.NewMock(), gendb, 10, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(addr), common.Address{byte(i + 1)}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		b.AddTx(tx)
+	})
+	chain, _ := core.NewBlockChain(db, &core.CacheConfig{Disabled: true}, gspec.Config, mock.NewMock(), vm.Config{})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	chain.Stop()
+
+	before := db.Len()
+	pruner, err := NewPruner(db, 1)
+	if err != nil {
+		t.Fatalf("failed to create pruner: %v", err)
+	}
+	if err := pruner.Prune(2); err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	if db.Len() >= before {
+		t.Fatalf("nothing pruned: %d entries before, %d after", before, db.Len())
+	}
+	// The recent and genesis states are complete
+	for _, root := range []common.Hash{blocks[9].Root(), blocks[8].Root(), genesis.Root()} {
+		statedb, err := state.New(root, state.NewDatabase(db))
+		if err != nil {
+			t.Fatalf("state %x missing: %v", root, err)
+		}
+		it := state.NewNodeIterator(statedb)
+		for it.Next() {
+		}
+		if it.Error != nil {
+			t.Fatalf("state %x incomplete: %v", root, it.Error)
+		}
+	}
+	// The older states are gone, while the chain is intact
+	for _, block := range blocks[:8] {
+		if has, _ := db.Has(block.Root().Bytes()); has {
+			t.Errorf("state of block #%d not pruned", block.NumberU64())
+		}
+	}
+	chain, _ = core.NewBlockChain(db, nil, gspec.Config, mock.NewMock(), vm.Config{})
+	defer chain.Stop()
+	if head := chain.CurrentBlock(); head.Hash() != blocks[9].Hash() {
+		t.Errorf("head mismatch after pruning: have #%d, want #%d", head.NumberU64(), blocks[9].NumberU64())
+	}
+}
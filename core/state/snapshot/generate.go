@@ -0,0 +1,218 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/core/rawdb"
+	"github.com/vntchain/go-vnt/log"
+	"github.com/vntchain/go-vnt/rlp"
+	"github.com/vntchain/go-vnt/trie"
+	"github.com/vntchain/go-vnt/vntdb"
+)
+
+// wipeBatchSize is the number of snapshot entries deleted at once while wiping.
+const wipeBatchSize = 10000
+
+// generate fills the snapshot from the state trie in the background, batch by
+// batch. Every batch is generated from the root the snapshot represents at the
+// time, so that the blocks imported meanwhile only need to update the part of
+// the snapshot already generated.
+func (t *Tree) generate(wipe bool, stop chan struct{}) {
+	start := time.Now()
+	if wipe {
+		if !t.wipe(rawdb.SnapshotAccountPrefix, common.HashLength, stop) {
+			return
+		}
+		if !t.wipe(rawdb.SnapshotStoragePrefix, 2*common.HashLength, stop) {
+			return
+		}
+	}
+	for {
+		t.lock.Lock()
+		select {
+		case <-stop:
+			t.lock.Unlock()
+			return
+		default:
+		}
+		root := t.root
+		done, err := t.generateBatch()
+		t.lock.Unlock()
+
+		if err != nil {
+			log.Warn("State snapshot generation failed", "root", root, "err", err)
+			return
+		}
+		if done {
+			log.Info("Generated state snapshot", "root", root, "elapsed", common.PrettyDuration(time.Since(start)))
+			return
+		}
+	}
+}
+
+// wipe deletes all the snapshot entries with the given prefix and key length
+// after it, reporting false if stopped meanwhile.
+func (t *Tree) wipe(prefix []byte, keyLen int, stop chan struct{}) bool {
+	it := t.diskdb.NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	for more := true; more; {
+		t.lock.Lock()
+		select {
+		case <-stop:
+			t.lock.Unlock()
+			return false
+		default:
+		}
+		batch := t.diskdb.NewBatch()
+		for i := 0; i < wipeBatchSize; i++ {
+			if more = it.Next(); !more {
+				break
+			}
+			if key := it.Key(); len(key) == len(prefix)+keyLen {
+				batch.Delete(common.CopyBytes(key))
+			}
+		}
+		err := batch.Write()
+		t.lock.Unlock()
+
+		if err != nil {
+			log.Warn("Failed to wipe state snapshot", "err", err)
+			return false
+		}
+	}
+	return true
+}
+
+// generateBatch generates the snapshot of the accounts following the marker,
+// along with their storage, until a database batch worth of data. The storage
+// of an account may be split across batches, so that the lock is released
+// regularly however large the storage is. It reports whether the snapshot is
+// complete. The lock must be held.
+func (t *Tree) generateBatch() (bool, error) {
+	accTrie, err := trie.New(t.root, t.triedb)
+	if err != nil {
+		return false, err
+	}
+	// Resume the storage of the account of the marker if it was interrupted
+	accMarker, storeMarker := splitMarker(t.marker)
+	var storeOrigin []byte
+	if storeMarker != nil {
+		if next, ok := nextHash(storeMarker); ok {
+			storeOrigin = next
+		} else {
+			storeMarker = nil // Storage complete up to the last slot
+		}
+	}
+	origin := accMarker
+	if storeMarker == nil {
+		var ok bool
+		if origin, ok = nextHash(accMarker); !ok {
+			return true, t.finishGeneration(t.diskdb.NewBatch())
+		}
+	}
+	batch := t.diskdb.NewBatch()
+
+	it := trie.NewIterator(accTrie.NodeIterator(origin))
+	for it.Next() {
+		hash := common.BytesToHash(it.Key)
+		rawdb.WriteAccountSnapshot(batch, hash, it.Value)
+
+		var account Account
+		if err := rlp.DecodeBytes(it.Value, &account); err != nil {
+			return false, err
+		}
+		if account.Root != emptyRoot && account.Root != (common.Hash{}) {
+			storeTrie, err := trie.New(account.Root, t.triedb)
+			if err != nil {
+				return false, err
+			}
+			var from []byte
+			if storeMarker != nil && bytes.Equal(hash[:], accMarker) {
+				from = storeOrigin
+			}
+			storeIt := trie.NewIterator(storeTrie.NodeIterator(from))
+			for storeIt.Next() {
+				rawdb.WriteStorageSnapshot(batch, hash, common.BytesToHash(storeIt.Key), storeIt.Value)
+				if batch.ValueSize() > vntdb.IdealBatchSize {
+					return false, t.commitBatch(batch, append(hash.Bytes(), storeIt.Key...))
+				}
+			}
+			if storeIt.Err != nil {
+				return false, storeIt.Err
+			}
+		}
+		if batch.ValueSize() > vntdb.IdealBatchSize {
+			return false, t.commitBatch(batch, hash.Bytes())
+		}
+	}
+	if it.Err != nil {
+		return false, it.Err
+	}
+	return true, t.finishGeneration(batch)
+}
+
+// commitBatch writes a batch of generated snapshot entries, along with the
+// marker up to which they cover the snapshot. The lock must be held.
+func (t *Tree) commitBatch(batch vntdb.Batch, marker []byte) error {
+	rawdb.WriteSnapshotGenerator(batch, marker)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	t.marker = marker
+	return nil
+}
+
+// finishGeneration marks the snapshot as generated. The lock must be held.
+func (t *Tree) finishGeneration(batch vntdb.Batch) error {
+	rawdb.DeleteSnapshotGenerator(batch)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	t.marker = nil
+	return nil
+}
+
+// splitMarker splits a generation marker into the hash of the last account
+// generated and, if its storage is only partially generated, the hash of the
+// last storage slot generated.
+func splitMarker(marker []byte) ([]byte, []byte) {
+	if len(marker) <= common.HashLength {
+		return marker, nil
+	}
+	return marker[:common.HashLength], marker[common.HashLength:]
+}
+
+// nextHash returns the hash following the given marker, or false if there is
+// none. An empty marker is followed by the zero hash.
+func nextHash(marker []byte) ([]byte, bool) {
+	if len(marker) == 0 {
+		return nil, true
+	}
+	next := common.CopyBytes(marker)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			return next, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/core/rawdb"
+	"github.com/vntchain/go-vnt/crypto"
+	"github.com/vntchain/go-vnt/rlp"
+	"github.com/vntchain/go-vnt/trie"
+	"github.com/vntchain/go-vnt/vntdb"
+)
+
+// Tests that the storage of an account too large for a single batch is split
+// across batches, and that the blocks imported meanwhile update the slots
+// already generated while leaving the others to the generator.
+func TestGenerateSplitStorage(t *testing.T) {
+	var (
+		db      = vntdb.NewMemDatabase()
+		triedb  = trie.NewDatabase(db)
+		account = crypto.Keccak256Hash([]byte("account"))
+		slots   = make(map[common.Hash][]byte)
+	)
+	// Create an account whose storage spans a few batches, among small ones
+	for i := 0; i < 2*vntdb.IdealBatchSize/common.HashLength; i++ {
+		value, _ := rlp.EncodeToBytes(crypto.Keccak256(big.NewInt(int64(i)).Bytes()))
+		slots[crypto.Keccak256Hash(big.NewInt(int64(i)).Bytes())] = value
+	}
+	commit := func(slots map[common.Hash][]byte) common.Hash {
+		storeTrie, _ := trie.New(common.Hash{}, triedb)
+		for slot, value := range slots {
+			storeTrie.Update(slot[:], value)
+		}
+		storeRoot, _ := storeTrie.Commit(nil)
+
+		accTrie, _ := trie.New(common.Hash{}, triedb)
+		for i := byte(0); i < 10; i++ {
+			data, _ := rlp.EncodeToBytes(&Account{Nonce: uint64(i), Balance: big.NewInt(1), Root: emptyRoot, CodeHash: crypto.Keccak256(nil)})
+			accTrie.Update(crypto.Keccak256([]byte{i}), data)
+		}
+		data, _ := rlp.EncodeToBytes(&Account{Balance: big.NewInt(1), Root: storeRoot, CodeHash: crypto.Keccak256(nil)})
+		accTrie.Update(account[:], data)
+		root, _ := accTrie.Commit(nil)
+		return root
+	}
+	root := commit(slots)
+	snaps := &Tree{diskdb: db, triedb: triedb, root: root, marker: []byte{}}
+
+	// Generate until the storage of the account is partially generated
+	for {
+		snaps.lock.Lock()
+		done, err := snaps.generateBatch()
+		snaps.lock.Unlock()
+		if err != nil {
+			t.Fatalf("failed to generate batch: %v", err)
+		}
+		if done {
+			t.Fatalf("storage not split across batches")
+		}
+		if len(snaps.marker) == 2*common.HashLength {
+			break
+		}
+	}
+	if marker, _ := rawdb.ReadSnapshotGenerator(db); !bytes.Equal(marker, snaps.marker) {
+		t.Fatalf("stored marker mismatch: have %x, want %x", marker, snaps.marker)
+	}
+	if !bytes.Equal(snaps.marker[:common.HashLength], account[:]) {
+		t.Fatalf("marker account mismatch: have %x, want %x", snaps.marker[:common.HashLength], account)
+	}
+	// Update a slot on each side of the marker
+	var (
+		storeMarker = common.BytesToHash(snaps.marker[common.HashLength:])
+		below       common.Hash
+		above       common.Hash
+	)
+	for slot := range slots {
+		switch cmp := bytes.Compare(slot[:], storeMarker[:]); {
+		case cmp < 0 && below == (common.Hash{}):
+			below = slot
+		case cmp > 0 && above == (common.Hash{}):
+			above = slot
+		}
+	}
+	if _, err := snaps.Storage(account, below); err != nil {
+		t.Fatalf("generated slot not readable: %v", err)
+	}
+	if _, err := snaps.Storage(account, above); err != ErrNotCoveredYet {
+		t.Fatalf("slot beyond the marker error mismatch: have %v, want %v", err, ErrNotCoveredYet)
+	}
+	diff := NewDiff()
+	diff.Storage[account] = map[common.Hash][]byte{below: {0x01}, above: {0x02}}
+	slots[below], slots[above] = []byte{0x01}, []byte{0x02}
+
+	child := commit(slots)
+	if err := snaps.Update(root, child, diff); err != nil {
+		t.Fatalf("failed to update snapshot: %v", err)
+	}
+	if flat := rawdb.ReadStorageSnapshot(db, account, above); flat != nil {
+		t.Fatalf("slot beyond the marker updated: %x", flat)
+	}
+	// Finish the generation and check the storage against the new state
+	for done := false; !done; {
+		snaps.lock.Lock()
+		var err error
+		done, err = snaps.generateBatch()
+		snaps.lock.Unlock()
+		if err != nil {
+			t.Fatalf("failed to generate batch: %v", err)
+		}
+	}
+	for slot, value := range slots {
+		if flat := rawdb.ReadStorageSnapshot(db, account, slot); !bytes.Equal(flat, value) {
+			t.Fatalf("slot %x mismatch: have %x, want %x", slot, flat, value)
+		}
+	}
+	it := db.NewIteratorWithPrefix(rawdb.StorageSnapshotsKey(account))
+	defer it.Release()
+	count := 0
+	for it.Next() {
+		count++
+	}
+	if count != len(slots) {
+		t.Fatalf("flat slot count mismatch: have %d, want %d", count, len(slots))
+	}
+}
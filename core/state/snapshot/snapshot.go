@@ -0,0 +1,387 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snapshot implements a flat snapshot of the state, keeping the accounts
+// and storage slots keyed by the hash of their trie keys alongside the tries.
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/core/rawdb"
+	"github.com/vntchain/go-vnt/log"
+	"github.com/vntchain/go-vnt/trie"
+	"github.com/vntchain/go-vnt/vntdb"
+)
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// ErrNotCoveredYet is returned when the snapshot is still being generated
+	// and the requested data is not yet part of it.
+	ErrNotCoveredYet = errors.New("not covered yet")
+
+	// ErrStaleRoot is returned when the snapshot is requested for another state
+	// root than the one it represents.
+	ErrStaleRoot = errors.New("snapshot of another state root")
+
+	// errNoIteration is returned when the database can't iterate its content.
+	errNoIteration = errors.New("database does not support iteration")
+)
+
+// Account is the consensus representation of accounts, as stored in the trie.
+type Account struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+// Entry is a snapshot entry, keyed by the hash of its trie key and holding the
+// value stored in the trie.
+type Entry struct {
+	Hash common.Hash
+	Body []byte
+}
+
+// Diff is the set of flat state changes committed by a block. The destructed
+// accounts lose their whole storage before the updates are applied. Deleted
+// storage slots have nil values.
+type Diff struct {
+	Destructs map[common.Hash]struct{}
+	Accounts  map[common.Hash][]byte
+	Storage   map[common.Hash]map[common.Hash][]byte
+}
+
+// NewDiff creates an empty set of flat state changes.
+func NewDiff() *Diff {
+	return &Diff{
+		Destructs: make(map[common.Hash]struct{}),
+		Accounts:  make(map[common.Hash][]byte),
+		Storage:   make(map[common.Hash]map[common.Hash][]byte),
+	}
+}
+
+// Database is the backing store of the snapshot.
+type Database interface {
+	vntdb.Database
+	vntdb.Iteratee
+}
+
+// Tree is the flat snapshot of a single state root, kept up to date with the
+// chain head as blocks are imported. When the snapshot can't follow the head,
+// as on reorgs, it is regenerated in the background from the state trie.
+type Tree struct {
+	diskdb Database
+	triedb *trie.Database
+
+	root common.Hash // State root the snapshot represents
+
+	// marker is the account hash up to which the snapshot is generated, followed
+	// by the hash of the last slot generated while the storage of that account
+	// is partial. It is nil once the snapshot is complete.
+	marker []byte
+	lock   sync.RWMutex
+
+	genStop chan struct{} // Channel to stop the running generator, nil if none
+}
+
+// New opens the snapshot of the database, resuming its generation if it was
+// interrupted, or regenerating it if it doesn't represent the given root.
+func New(diskdb vntdb.Database, triedb *trie.Database, root common.Hash) (*Tree, error) {
+	db, ok := diskdb.(Database)
+	if !ok {
+		return nil, errNoIteration
+	}
+	t := &Tree{
+		diskdb: db,
+		triedb: triedb,
+		root:   rawdb.ReadSnapshotRoot(db),
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.root != root {
+		log.Info("Rebuilding state snapshot", "root", root, "stale", t.root)
+		t.rebuild(root)
+		return t, nil
+	}
+	if marker, ok := rawdb.ReadSnapshotGenerator(db); ok {
+		accMarker, _ := splitMarker(marker)
+		log.Info("Resuming state snapshot generation", "root", root, "at", common.BytesToHash(accMarker))
+		t.marker = append([]byte{}, marker...)
+		t.startGenerator(false)
+	}
+	return t, nil
+}
+
+// Root returns the state root the snapshot represents.
+func (t *Tree) Root() common.Hash {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.root
+}
+
+// Generated reports whether the snapshot is completely generated.
+func (t *Tree) Generated() bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.marker == nil
+}
+
+// covered reports whether the account is already part of the snapshot.
+func (t *Tree) covered(hash common.Hash) bool {
+	if t.marker == nil {
+		return true
+	}
+	accMarker, _ := splitMarker(t.marker)
+	return bytes.Compare(hash[:], accMarker) <= 0
+}
+
+// storageCovered reports whether the storage slot of an account is already part
+// of the snapshot.
+func (t *Tree) storageCovered(account, slot common.Hash) bool {
+	if t.marker == nil {
+		return true
+	}
+	accMarker, storeMarker := splitMarker(t.marker)
+	switch cmp := bytes.Compare(account[:], accMarker); {
+	case cmp < 0:
+		return true
+	case cmp > 0:
+		return false
+	}
+	return storeMarker == nil || bytes.Compare(slot[:], storeMarker) <= 0
+}
+
+// Account retrieves the snapshot entry of an account, nil if it doesn't exist.
+func (t *Tree) Account(hash common.Hash) ([]byte, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if !t.covered(hash) {
+		return nil, ErrNotCoveredYet
+	}
+	return rawdb.ReadAccountSnapshot(t.diskdb, hash), nil
+}
+
+// Storage retrieves the snapshot entry of a storage slot, nil if it doesn't exist.
+func (t *Tree) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if !t.storageCovered(accountHash, storageHash) {
+		return nil, ErrNotCoveredYet
+	}
+	return rawdb.ReadStorageSnapshot(t.diskdb, accountHash, storageHash), nil
+}
+
+// Update applies the flat state changes turning the state of parentRoot into
+// the one of root. If the snapshot doesn't represent parentRoot, it is
+// regenerated for root instead.
+func (t *Tree) Update(parentRoot, root common.Hash, diff *Diff) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.root != parentRoot {
+		log.Debug("Rebuilding stale state snapshot", "root", root, "stale", t.root, "parent", parentRoot)
+		t.rebuild(root)
+		return nil
+	}
+	batch := t.diskdb.NewBatch()
+	for hash := range diff.Destructs {
+		if !t.covered(hash) {
+			continue
+		}
+		rawdb.DeleteAccountSnapshot(batch, hash)
+		if err := deletePrefix(t.diskdb, batch, rawdb.StorageSnapshotsKey(hash)); err != nil {
+			return err
+		}
+	}
+	for hash, data := range diff.Accounts {
+		if t.covered(hash) {
+			rawdb.WriteAccountSnapshot(batch, hash, data)
+		}
+	}
+	for hash, slots := range diff.Storage {
+		for slot, data := range slots {
+			if !t.storageCovered(hash, slot) {
+				continue
+			}
+			if len(data) == 0 {
+				rawdb.DeleteStorageSnapshot(batch, hash, slot)
+			} else {
+				rawdb.WriteStorageSnapshot(batch, hash, slot, data)
+			}
+		}
+	}
+	rawdb.WriteSnapshotRoot(batch, root)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	t.root = root
+	return nil
+}
+
+// Rebuild discards the snapshot and regenerates it for the given root.
+func (t *Tree) Rebuild(root common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.rebuild(root)
+}
+
+// Suspend stops maintaining the snapshot, while another writer such as the
+// state sync replaces its content. Until completed, the snapshot represents
+// no state.
+func (t *Tree) Suspend() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.stopGenerator()
+	t.root, t.marker = common.Hash{}, []byte{}
+	rawdb.WriteSnapshotRoot(t.diskdb, t.root)
+	rawdb.WriteSnapshotGenerator(t.diskdb, t.marker)
+}
+
+// Complete marks the content written during a suspension as the complete
+// snapshot of the given root.
+func (t *Tree) Complete(root common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.stopGenerator()
+	t.root, t.marker = root, nil
+	rawdb.WriteSnapshotRoot(t.diskdb, root)
+	rawdb.DeleteSnapshotGenerator(t.diskdb)
+}
+
+// Stop terminates the background generation of the snapshot, if running. The
+// generation resumes where it stopped once the snapshot is opened again.
+func (t *Tree) Stop() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.stopGenerator()
+}
+
+// rebuild restarts the generation of the snapshot from scratch. The lock must
+// be held.
+func (t *Tree) rebuild(root common.Hash) {
+	t.stopGenerator()
+
+	t.root, t.marker = root, []byte{}
+	rawdb.WriteSnapshotRoot(t.diskdb, root)
+	rawdb.WriteSnapshotGenerator(t.diskdb, t.marker)
+
+	t.startGenerator(true)
+}
+
+// startGenerator starts generating the snapshot from its marker on, wiping its
+// previous content first if requested. The lock must be held.
+func (t *Tree) startGenerator(wipe bool) {
+	t.genStop = make(chan struct{})
+	go t.generate(wipe, t.genStop)
+}
+
+// stopGenerator stops the running generator, if any. The generator only writes
+// while holding the lock after checking it was not stopped, so once this
+// returns, it doesn't touch the snapshot anymore. The lock must be held.
+func (t *Tree) stopGenerator() {
+	if t.genStop != nil {
+		close(t.genStop)
+		t.genStop = nil
+	}
+}
+
+// AccountRange returns the accounts of the snapshot of the given root from
+// origin on, ending with the first one at or beyond limit, or once maxBytes
+// worth of accounts are collected.
+func (t *Tree) AccountRange(root, origin, limit common.Hash, maxBytes int) ([]Entry, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.root != root {
+		return nil, ErrStaleRoot
+	}
+	if t.marker != nil {
+		return nil, ErrNotCoveredYet
+	}
+	return iterateRange(t.diskdb, rawdb.SnapshotAccountPrefix, origin, limit, maxBytes), nil
+}
+
+// StorageRange returns the storage slots of an account in the snapshot of the
+// given root from origin on, ending with the first one at or beyond limit, or
+// once maxBytes worth of slots are collected.
+func (t *Tree) StorageRange(root, account, origin, limit common.Hash, maxBytes int) ([]Entry, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.root != root {
+		return nil, ErrStaleRoot
+	}
+	if t.marker != nil {
+		return nil, ErrNotCoveredYet
+	}
+	return iterateRange(t.diskdb, rawdb.StorageSnapshotsKey(account), origin, limit, maxBytes), nil
+}
+
+// iterateRange collects the entries with the given prefix from origin on, up to
+// the first one at or beyond limit or until maxBytes are collected.
+func iterateRange(db vntdb.Iteratee, prefix []byte, origin, limit common.Hash, maxBytes int) []Entry {
+	it := db.NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	var (
+		entries []Entry
+		size    int
+	)
+	for ok := it.Seek(append(common.CopyBytes(prefix), origin[:]...)); ok; ok = it.Next() {
+		key := it.Key()
+		if len(key) != len(prefix)+common.HashLength {
+			continue // Trie node sharing the prefix
+		}
+		hash := common.BytesToHash(key[len(prefix):])
+		entries = append(entries, Entry{Hash: hash, Body: common.CopyBytes(it.Value())})
+
+		size += common.HashLength + len(it.Value())
+		if bytes.Compare(hash[:], limit[:]) >= 0 || size >= maxBytes {
+			break
+		}
+	}
+	return entries
+}
+
+// deletePrefix deletes all the snapshot entries with the given prefix.
+func deletePrefix(db vntdb.Iteratee, batch vntdb.Batch, prefix []byte) error {
+	it := db.NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	for it.Next() {
+		if len(it.Key()) != len(prefix)+common.HashLength {
+			continue
+		}
+		batch.Delete(common.CopyBytes(it.Key()))
+	}
+	return it.Error()
+}
@@ -0,0 +1,192 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot_test
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/core/rawdb"
+	"github.com/vntchain/go-vnt/core/state"
+	"github.com/vntchain/go-vnt/core/state/snapshot"
+	"github.com/vntchain/go-vnt/rlp"
+	"github.com/vntchain/go-vnt/trie"
+	"github.com/vntchain/go-vnt/vntdb"
+)
+
+// makeState creates a state with accounts, some of them with storage, and
+// returns its root.
+func makeState(t *testing.T, sdb state.Database) common.Hash {
+	statedb, _ := state.New(common.Hash{}, sdb)
+	for i := byte(0); i < 100; i++ {
+		addr := common.Address{i}
+		statedb.AddBalance(addr, big.NewInt(int64(i)+1))
+		statedb.SetNonce(addr, uint64(i))
+		if i%10 == 0 {
+			statedb.SetCode(addr, []byte{i, i})
+			for j := byte(1); j < 20; j++ {
+				statedb.SetState(addr, common.Hash{j}, common.Hash{i, j})
+			}
+		}
+	}
+	root, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	return root
+}
+
+// waitGenerated waits for the snapshot to be generated.
+func waitGenerated(t *testing.T, snaps *snapshot.Tree) {
+	for deadline := time.Now().Add(5 * time.Second); !snaps.Generated(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("snapshot not generated in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// checkSnapshot checks that the flat content of the database matches the state
+// trie of the given root exactly.
+func checkSnapshot(t *testing.T, db *vntdb.MemDatabase, triedb *trie.Database, root common.Hash) {
+	accTrie, err := trie.New(root, triedb)
+	if err != nil {
+		t.Fatalf("failed to open account trie: %v", err)
+	}
+	accounts, slots := 0, 0
+	it := trie.NewIterator(accTrie.NodeIterator(nil))
+	for it.Next() {
+		hash := common.BytesToHash(it.Key)
+		if flat := rawdb.ReadAccountSnapshot(db, hash); !bytes.Equal(flat, it.Value) {
+			t.Fatalf("account %x mismatch: have %x, want %x", hash, flat, it.Value)
+		}
+		accounts++
+
+		var account snapshot.Account
+		rlp.DecodeBytes(it.Value, &account)
+		storeTrie, err := trie.New(account.Root, triedb)
+		if err != nil {
+			t.Fatalf("failed to open storage trie: %v", err)
+		}
+		storeIt := trie.NewIterator(storeTrie.NodeIterator(nil))
+		for storeIt.Next() {
+			slot := common.BytesToHash(storeIt.Key)
+			if flat := rawdb.ReadStorageSnapshot(db, hash, slot); !bytes.Equal(flat, storeIt.Value) {
+				t.Fatalf("slot %x of %x mismatch: have %x, want %x", slot, hash, flat, storeIt.Value)
+			}
+			slots++
+		}
+	}
+	// No other entry should be around
+	count := func(prefix []byte, keyLen int) int {
+		n := 0
+		it := db.NewIteratorWithPrefix(prefix)
+		defer it.Release()
+		for it.Next() {
+			if len(it.Key()) == keyLen {
+				n++
+			}
+		}
+		return n
+	}
+	if have := count(rawdb.SnapshotAccountPrefix, 1+common.HashLength); have != accounts {
+		t.Fatalf("flat account count mismatch: have %d, want %d", have, accounts)
+	}
+	if have := count(rawdb.SnapshotStoragePrefix, 1+2*common.HashLength); have != slots {
+		t.Fatalf("flat slot count mismatch: have %d, want %d", have, slots)
+	}
+}
+
+// Tests that the snapshot generated from a state trie holds its exact content.
+func TestGenerate(t *testing.T) {
+	db := vntdb.NewMemDatabase()
+	sdb := state.NewDatabase(db)
+	root := makeState(t, sdb)
+
+	snaps, err := snapshot.New(db, sdb.TrieDB(), root)
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	defer snaps.Stop()
+
+	waitGenerated(t, snaps)
+	checkSnapshot(t, db, sdb.TrieDB(), root)
+
+	maxHash := common.BytesToHash(bytes.Repeat([]byte{0xff}, common.HashLength))
+	entries, err := snaps.AccountRange(root, common.Hash{}, maxHash, 1024*1024)
+	if err != nil {
+		t.Fatalf("failed to retrieve account range: %v", err)
+	}
+	if len(entries) != 100 {
+		t.Fatalf("account range size mismatch: have %d, want %d", len(entries), 100)
+	}
+	// A range ends with the first account beyond its limit
+	limit := entries[9].Hash
+	if entries, _ = snaps.AccountRange(root, common.Hash{}, limit, 1024*1024); len(entries) != 10 {
+		t.Fatalf("limited account range size mismatch: have %d, want %d", len(entries), 10)
+	}
+	if _, err := snaps.AccountRange(common.Hash{1}, common.Hash{}, limit, 1024*1024); err != snapshot.ErrStaleRoot {
+		t.Fatalf("stale root error mismatch: have %v, want %v", err, snapshot.ErrStaleRoot)
+	}
+}
+
+// Tests that the snapshot follows the state changes of the blocks, including
+// destructed accounts and deleted slots, and is rebuilt when the chain moves to
+// a state it doesn't descend from.
+func TestUpdate(t *testing.T) {
+	db := vntdb.NewMemDatabase()
+	sdb := state.NewDatabase(db)
+	root := makeState(t, sdb)
+
+	snaps, err := snapshot.New(db, sdb.TrieDB(), root)
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	defer snaps.Stop()
+	waitGenerated(t, snaps)
+
+	statedb, _ := state.New(root, sdb)
+	statedb.TrackSnapshotDiff()
+	statedb.AddBalance(common.Address{1}, big.NewInt(10))
+	statedb.AddBalance(common.Address{200}, big.NewInt(10))
+	statedb.SetState(common.Address{10}, common.Hash{1}, common.Hash{})
+	statedb.SetState(common.Address{10}, common.Hash{100}, common.Hash{1})
+	statedb.Suicide(common.Address{20})
+	statedb.Finalise(true)
+
+	child, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := snaps.Update(root, child, statedb.SnapshotDiff()); err != nil {
+		t.Fatalf("failed to update snapshot: %v", err)
+	}
+	if snaps.Root() != child || !snaps.Generated() {
+		t.Fatalf("snapshot not moved to the child state")
+	}
+	checkSnapshot(t, db, sdb.TrieDB(), child)
+
+	// Moving to a state not descending from the snapshot regenerates it
+	if err := snaps.Update(common.Hash{1}, root, snapshot.NewDiff()); err != nil {
+		t.Fatalf("failed to update snapshot: %v", err)
+	}
+	waitGenerated(t, snaps)
+	checkSnapshot(t, db, sdb.TrieDB(), root)
+}
@@ -204,15 +204,31 @@ func (self *stateObject) updateTrie(db Database) Trie {
 		delete(self.dirtyStorage, key)
 		if (value == common.Hash{}) {
 			self.setError(tr.TryDelete(key[:]))
+			self.trackStorage(key, nil)
 			continue
 		}
 		// Encoding []byte cannot fail, ok to ignore the error.
 		v, _ := rlp.EncodeToBytes(bytes.TrimLeft(value[:], "\x00"))
 		self.setError(tr.TryUpdate(key[:], v))
+		self.trackStorage(key, v)
 	}
 	return tr
 }
 
+// trackStorage records a storage change for the state snapshot, if tracked.
+func (self *stateObject) trackStorage(key common.Hash, value []byte) {
+	diff := self.db.snapDiff
+	if diff == nil {
+		return
+	}
+	slots := diff.Storage[self.addrHash]
+	if slots == nil {
+		slots = make(map[common.Hash][]byte)
+		diff.Storage[self.addrHash] = slots
+	}
+	slots[crypto.Keccak256Hash(key[:])] = value
+}
+
 // UpdateRoot sets the trie root to the current root hash of
 func (self *stateObject) updateRoot(db Database) {
 	self.updateTrie(db)
@@ -24,6 +24,7 @@ import (
 	"sync"
 
 	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/core/state/snapshot"
 	"github.com/vntchain/go-vnt/core/types"
 	"github.com/vntchain/go-vnt/crypto"
 	"github.com/vntchain/go-vnt/log"
@@ -74,6 +75,12 @@ type StateDB struct {
 
 	preimages map[common.Hash][]byte
 
+	// Flat changes of the state, tracked for the state snapshot if requested
+	snapDiff *snapshot.Diff
+
+	// Touched accounts and slots, recorded for the state diff if requested
+	diff *diffRecorder
+
 	// Journal of state modifications. This is the backbone of
 	// Snapshot and RevertToSnapshot.
 	journal        *journal
@@ -348,6 +355,10 @@ func (self *StateDB) updateStateObject(stateObject *stateObject) {
 		panic(fmt.Errorf("can't encode object at %x: %v", addr[:], err))
 	}
 	self.setError(self.trie.TryUpdate(addr[:], data))
+
+	if self.snapDiff != nil {
+		self.snapDiff.Accounts[stateObject.addrHash] = data
+	}
 }
 
 // deleteStateObject removes the given object from the state trie.
@@ -355,6 +366,24 @@ func (self *StateDB) deleteStateObject(stateObject *stateObject) {
 	stateObject.deleted = true
 	addr := stateObject.Address()
 	self.setError(self.trie.TryDelete(addr[:]))
+
+	if self.snapDiff != nil {
+		self.snapDiff.Destructs[stateObject.addrHash] = struct{}{}
+		delete(self.snapDiff.Accounts, stateObject.addrHash)
+		delete(self.snapDiff.Storage, stateObject.addrHash)
+	}
+}
+
+// TrackSnapshotDiff makes the state track the flat account and storage changes
+// it writes into the tries, for the state snapshot to follow them.
+func (self *StateDB) TrackSnapshotDiff() {
+	self.snapDiff = snapshot.NewDiff()
+}
+
+// SnapshotDiff returns the flat changes written into the tries so far, or nil
+// if they are not tracked.
+func (self *StateDB) SnapshotDiff() *snapshot.Diff {
+	return self.snapDiff
 }
 
 // Retrieve a state object given by the address. Returns nil if not found.
@@ -496,6 +525,10 @@ func (self *StateDB) Copy() *StateDB {
 	for hash, preimage := range self.preimages {
 		state.preimages[hash] = preimage
 	}
+	if self.diff != nil {
+		self.recordJournal()
+		state.diff = self.diff.copy()
+	}
 	return state
 }
 
@@ -572,6 +605,7 @@ func (self *StateDB) Prepare(thash, bhash common.Hash, ti int) {
 }
 
 func (s *StateDB) clearJournalAndRefund() {
+	s.recordJournal()
 	s.journal = newJournal()
 	s.validRevisions = s.validRevisions[:0]
 	s.refund = 0
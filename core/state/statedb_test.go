@@ -19,6 +19,7 @@ package state
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"math"
 	"math/big"
@@ -435,3 +436,58 @@ func TestCopyOfCopy(t *testing.T) {
 		t.Fatalf("2nd copy fail, expected 42, got %v", got)
 	}
 }
+
+// Tests that the state diff reports the changes made since the recording was
+// started, across the journal resets, leaving out the reverted changes.
+func TestStateDiff(t *testing.T) {
+	var (
+		db       = NewDatabase(vntdb.NewMemDatabase())
+		state, _ = New(common.Hash{}, db)
+		addr1    = common.BytesToAddress([]byte{0x01})
+		addr2    = common.BytesToAddress([]byte{0x02})
+		addr3    = common.BytesToAddress([]byte{0x03})
+	)
+	state.SetBalance(addr1, big.NewInt(10))
+	state.SetState(addr2, common.Hash{0x01}, common.Hash{0x01})
+	root, _ := state.Commit(false)
+	state, _ = New(root, db)
+	state.RecordDiff()
+
+	state.SetBalance(addr1, big.NewInt(20))
+	state.SetNonce(addr1, 1)
+	state.Finalise(false)
+
+	state.SetState(addr2, common.Hash{0x01}, common.Hash{0x02})
+	state.SetState(addr2, common.Hash{0x02}, common.Hash{0x03})
+	state.SetCode(addr2, []byte{0x60, 0x00})
+	state.Finalise(false)
+
+	snapshot := state.Snapshot()
+	state.SetBalance(addr3, big.NewInt(30))
+	state.RevertToSnapshot(snapshot)
+
+	diff, err := state.Copy().StateDiff()
+	if err != nil {
+		t.Fatalf("failed to diff state: %v", err)
+	}
+	want := types.StateDiff{
+		{
+			Address: addr1,
+			Balance: &types.BalanceDiff{From: big.NewInt(10), To: big.NewInt(20)},
+			Nonce:   &types.NonceDiff{From: 0, To: 1},
+		},
+		{
+			Address: addr2,
+			Code:    &types.CodeDiff{From: nil, To: []byte{0x60, 0x00}},
+			Storage: []types.StorageDiff{
+				{Key: common.Hash{0x01}, From: common.Hash{0x01}, To: common.Hash{0x02}},
+				{Key: common.Hash{0x02}, From: common.Hash{}, To: common.Hash{0x03}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		have, _ := json.Marshal(diff)
+		exp, _ := json.Marshal(want)
+		t.Errorf("state diff mismatch:\nhave %s\nwant %s", have, exp)
+	}
+}
@@ -61,6 +61,9 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		allLogs  []*types.Log
 		gp       = new(GasPool).AddGas(block.GasLimit())
 	)
+	if p.bc != nil && p.bc.cacheConfig.StateDiffs {
+		statedb.RecordDiff()
+	}
 
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
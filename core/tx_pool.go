@@ -111,6 +111,34 @@ const (
 	TxStatusIncluded
 )
 
+// NonceRange is an inclusive range of account nonces.
+type NonceRange struct {
+	From, To uint64
+}
+
+// TxExplanation describes the standing of a transaction in the pool, detailing
+// why it is not (yet) executable or at risk of being dropped.
+type TxExplanation struct {
+	Status TxStatus       // Whether the transaction is pending or queued
+	From   common.Address // Sender of the transaction
+	Local  bool           // Whether the sender is exempt from price based eviction
+
+	StateNonce   uint64       // Nonce of the sender in the current state
+	PendingNonce uint64       // Next nonce of the sender after its pending transactions
+	NonceGaps    []NonceRange // Nonces missing from the pool before this transaction
+
+	Balance        *big.Int // Balance of the sender in the current state
+	Cost           *big.Int // Maximum cost of the transaction (value + gas * price)
+	CumulativeCost *big.Int // Maximum cost of the sender's pooled transactions up to this one
+
+	GasLimit    uint64   // Gas allowance of the current block
+	MinGasPrice *big.Int // Minimum gas price accepted for remote transactions
+	Underpriced bool     // Whether the gas price is below the pool's minimum
+	Evictable   bool     // Whether the transaction is among the first dropped from a full pool
+
+	Reasons []string // Human readable explanation of the above
+}
+
 // blockChain provides the state of blockchain and current gas limit to do
 // some pre checks in tx pool and event subscribers.
 type blockChain interface {
@@ -516,6 +544,22 @@ func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common
 	return pending, queued
 }
 
+// ContentFrom retrieves the pending and queued transactions of the given
+// account, sorted by nonce.
+func (pool *TxPool) ContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	var pending, queued types.Transactions
+	if list := pool.pending[addr]; list != nil {
+		pending = list.Flatten()
+	}
+	if list := pool.queue[addr]; list != nil {
+		queued = list.Flatten()
+	}
+	return pending, queued
+}
+
 // Pending retrieves all currently processable transactions, groupped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
@@ -849,6 +893,93 @@ func (pool *TxPool) Status(hashes []common.Hash) []TxStatus {
 	return status
 }
 
+// Explain reports the standing of the transaction with the given hash in the
+// pool, or nil if the pool does not contain it.
+func (pool *TxPool) Explain(hash common.Hash) *TxExplanation {
+	// The priced list is pruned on access, so a write lock is needed
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	tx := pool.all.Get(hash)
+	if tx == nil {
+		return nil
+	}
+	from, _ := types.Sender(pool.signer, tx) // already validated
+
+	exp := &TxExplanation{
+		Status:         TxStatusQueued,
+		From:           from,
+		Local:          pool.locals.contains(from),
+		StateNonce:     pool.currentState.GetNonce(from),
+		PendingNonce:   pool.pendingState.GetNonce(from),
+		Balance:        pool.currentState.GetBalance(from),
+		Cost:           tx.Cost(),
+		CumulativeCost: new(big.Int),
+		GasLimit:       pool.currentMaxGas,
+		MinGasPrice:    new(big.Int).Set(pool.gasPrice),
+	}
+	if list := pool.pending[from]; list != nil && list.Overlaps(tx) {
+		exp.Status = TxStatusPending
+	}
+	// Sum up the costs of the sender's transactions preceding this one, and find
+	// the gaps in the queued nonces blocking its promotion.
+	next := exp.PendingNonce
+	if list := pool.pending[from]; list != nil {
+		for _, ptx := range list.Flatten() {
+			if ptx.Nonce() <= tx.Nonce() {
+				exp.CumulativeCost.Add(exp.CumulativeCost, ptx.Cost())
+			}
+		}
+	}
+	if list := pool.queue[from]; list != nil {
+		for _, qtx := range list.Flatten() {
+			if qtx.Nonce() > tx.Nonce() {
+				break
+			}
+			exp.CumulativeCost.Add(exp.CumulativeCost, qtx.Cost())
+			if qtx.Nonce() > next {
+				exp.NonceGaps = append(exp.NonceGaps, NonceRange{next, qtx.Nonce() - 1})
+			}
+			next = qtx.Nonce() + 1
+		}
+	}
+	// Evaluate the pricing of the transaction against the pool thresholds
+	if !exp.Local {
+		exp.Underpriced = pool.gasPrice.Cmp(tx.GasPrice()) > 0
+		if uint64(pool.all.Count()) >= pool.config.GlobalSlots+pool.config.GlobalQueue {
+			exp.Evictable = pool.priced.Underpriced(tx, pool.locals)
+		}
+	}
+	// Assemble the human readable explanation
+	if exp.Status == TxStatusPending {
+		exp.Reasons = append(exp.Reasons, "executable, waiting for inclusion in a block")
+	} else {
+		exp.Reasons = append(exp.Reasons, "not executable, waiting in the queue")
+	}
+	for _, gap := range exp.NonceGaps {
+		if gap.From == gap.To {
+			exp.Reasons = append(exp.Reasons, fmt.Sprintf("missing transaction with nonce %d", gap.From))
+		} else {
+			exp.Reasons = append(exp.Reasons, fmt.Sprintf("missing transactions with nonces %d to %d", gap.From, gap.To))
+		}
+	}
+	if exp.Balance.Cmp(exp.Cost) < 0 {
+		exp.Reasons = append(exp.Reasons, fmt.Sprintf("insufficient balance: cost %v exceeds balance %v", exp.Cost, exp.Balance))
+	} else if exp.Balance.Cmp(exp.CumulativeCost) < 0 {
+		exp.Reasons = append(exp.Reasons, fmt.Sprintf("insufficient balance: cost with preceding transactions %v exceeds balance %v", exp.CumulativeCost, exp.Balance))
+	}
+	if tx.Gas() > exp.GasLimit {
+		exp.Reasons = append(exp.Reasons, fmt.Sprintf("gas %d exceeds block gas limit %d", tx.Gas(), exp.GasLimit))
+	}
+	if exp.Underpriced {
+		exp.Reasons = append(exp.Reasons, fmt.Sprintf("underpriced: gas price %v below pool minimum %v", tx.GasPrice(), exp.MinGasPrice))
+	}
+	if exp.Evictable {
+		exp.Reasons = append(exp.Reasons, "among the cheapest transactions of a full pool, first to be evicted")
+	}
+	return exp
+}
+
 // Get returns a transaction if it is contained in the pool
 // and nil otherwise.
 func (pool *TxPool) Get(hash common.Hash) *types.Transaction {
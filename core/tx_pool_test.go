@@ -23,6 +23,7 @@ import (
 	"math/big"
 	"math/rand"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -429,6 +430,54 @@ func TestTransactionMissingNonce(t *testing.T) {
 	}
 }
 
+// Tests that the pool explains queued transactions by the nonce gaps preceding
+// them, and reports the pricing of transactions against its thresholds.
+func TestTransactionExplain(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(addr, big.NewInt(100000000000000))
+
+	txs := types.Transactions{transaction(0, 100000, key), transaction(2, 100000, key), transaction(5, 100000, key)}
+	for _, tx := range txs {
+		if err := pool.AddRemote(tx); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", tx.Nonce(), err)
+		}
+	}
+	pending, queued := pool.ContentFrom(addr)
+	if len(pending) != 1 || len(queued) != 2 {
+		t.Fatalf("account content mismatch: have %d/%d pending/queued, want 1/2", len(pending), len(queued))
+	}
+	if exp := pool.Explain(common.Hash{}); exp != nil {
+		t.Fatalf("explained unknown transaction: %+v", exp)
+	}
+	exp := pool.Explain(txs[0].Hash())
+	if exp.Status != TxStatusPending || len(exp.NonceGaps) != 0 || exp.Underpriced {
+		t.Fatalf("pending transaction explanation mismatch: %+v", exp)
+	}
+	exp = pool.Explain(txs[2].Hash())
+	if exp.Status != TxStatusQueued || exp.PendingNonce != 1 {
+		t.Fatalf("queued transaction explanation mismatch: %+v", exp)
+	}
+	if want := []NonceRange{{1, 1}, {3, 4}}; !reflect.DeepEqual(exp.NonceGaps, want) {
+		t.Fatalf("nonce gaps mismatch: have %v, want %v", exp.NonceGaps, want)
+	}
+	if want := new(big.Int).Mul(txs[0].Cost(), big.NewInt(3)); exp.CumulativeCost.Cmp(want) != 0 {
+		t.Fatalf("cumulative cost mismatch: have %v, want %v", exp.CumulativeCost, want)
+	}
+	// Raise the price threshold without evicting and check the transactions are flagged
+	pool.mu.Lock()
+	pool.gasPrice = big.NewInt(2)
+	pool.mu.Unlock()
+
+	if exp := pool.Explain(txs[0].Hash()); !exp.Underpriced {
+		t.Fatalf("underpriced transaction not flagged: %+v", exp)
+	}
+}
+
 func TestTransactionNonceRecovery(t *testing.T) {
 	t.Parallel()
 
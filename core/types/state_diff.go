@@ -0,0 +1,56 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"math/big"
+
+	"github.com/vntchain/go-vnt/common"
+)
+
+// StateDiff is the set of the accounts changed by a block, ordered by address.
+type StateDiff []*AccountDiff
+
+// AccountDiff is the change of an account by a block, with the values before
+// and after the block. The fields of the unchanged values are nil.
+type AccountDiff struct {
+	Address common.Address
+	Balance *BalanceDiff  `rlp:"nil"`
+	Nonce   *NonceDiff    `rlp:"nil"`
+	Code    *CodeDiff     `rlp:"nil"`
+	Storage []StorageDiff // Changed slots, ordered by key
+}
+
+// BalanceDiff is the change of the balance of an account.
+type BalanceDiff struct {
+	From, To *big.Int
+}
+
+// NonceDiff is the change of the nonce of an account.
+type NonceDiff struct {
+	From, To uint64
+}
+
+// CodeDiff is the change of the code of an account.
+type CodeDiff struct {
+	From, To []byte
+}
+
+// StorageDiff is the change of a storage slot of an account.
+type StorageDiff struct {
+	Key, From, To common.Hash
+}
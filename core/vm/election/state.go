@@ -0,0 +1,145 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package election
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/vntchain/go-vnt/common"
+	inter "github.com/vntchain/go-vnt/core/vm/interface"
+	"github.com/vntchain/go-vnt/log"
+)
+
+// State is the content of the election contract storage decoded into records,
+// which can be written back into the storage of another state, such as the one
+// of a genesis block.
+type State struct {
+	Candidates []Candidate `json:"candidates,omitempty"`
+	Voters     []Voter     `json:"voters,omitempty"`
+	Stakes     []Stake     `json:"stakes,omitempty"`
+	Reward     *big.Int    `json:"reward,omitempty"` // Rest of the bounty
+	Lock       *big.Int    `json:"lock,omitempty"`   // Total amount locked and staked
+}
+
+// ReadState reads all the records of the election contract from a state. The
+// records are ordered by owner address.
+func ReadState(stateDB inter.StateDB) (*State, error) {
+	// Collect the owners of the records by the prefix of their keys
+	owners := make(map[byte]map[common.Address]struct{})
+	stateDB.ForEachStorage(contractAddr, func(key common.Hash, value common.Hash) bool {
+		if owners[key[0]] == nil {
+			owners[key[0]] = make(map[common.Address]struct{})
+		}
+		owners[key[0]][common.BytesToAddress(key[PREFIXLENGTH:PREFIXLENGTH+common.AddressLength])] = struct{}{}
+		return true
+	})
+	sorted := func(prefix byte) []common.Address {
+		addrs := make([]common.Address, 0, len(owners[prefix]))
+		for addr := range owners[prefix] {
+			addrs = append(addrs, addr)
+		}
+		sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+		return addrs
+	}
+	var (
+		state  = new(State)
+		getter = genGetFunc(stateDB)
+	)
+	for _, addr := range sorted(CANDIDATEPREFIX) {
+		candidate := newCandidate()
+		if ok, err := readRecord(CANDIDATEPREFIX, addr, &candidate, &candidate.Owner, getter); err != nil {
+			return nil, fmt.Errorf("candidate %x: %v", addr, err)
+		} else if ok {
+			state.Candidates = append(state.Candidates, candidate)
+		}
+	}
+	for _, addr := range sorted(VOTERPREFIX) {
+		voter := newVoter()
+		if ok, err := readRecord(VOTERPREFIX, addr, &voter, &voter.Owner, getter); err != nil {
+			return nil, fmt.Errorf("voter %x: %v", addr, err)
+		} else if ok {
+			state.Voters = append(state.Voters, voter)
+		}
+	}
+	for _, addr := range sorted(STAKEPREFIX) {
+		var stake Stake
+		if ok, err := readRecord(STAKEPREFIX, addr, &stake, &stake.Owner, getter); err != nil {
+			return nil, fmt.Errorf("stake %x: %v", addr, err)
+		} else if ok {
+			state.Stakes = append(state.Stakes, stake)
+		}
+	}
+	if _, ok := owners[REWARDPREFIX][contractAddr]; ok {
+		state.Reward = getReward(stateDB).Rest
+	}
+	if _, ok := owners[ALLLOCKPREFIX][contractAddr]; ok {
+		lock, err := getLock(stateDB)
+		if err != nil {
+			return nil, fmt.Errorf("lock: %v", err)
+		}
+		state.Lock = lock.Amount
+	}
+	return state, nil
+}
+
+// readRecord decodes the record of an owner, reporting whether it exists. The
+// fields missing at the tail of a record are left to their defaults, as they
+// weren't written yet, while a record without owner is only leftover keys.
+func readRecord(prefix byte, addr common.Address, v interface{}, owner *common.Address, getter getFuncType) (bool, error) {
+	if err := convertToStruct(prefix, addr, v, getter); err != nil && err != KeyNotExistErr {
+		return false, err
+	}
+	if *owner != addr {
+		log.Warn("Skipping election record without owner", "prefix", prefix, "addr", addr)
+		return false, nil
+	}
+	return true, nil
+}
+
+// WriteState writes the records of the election contract into a state.
+func WriteState(stateDB inter.StateDB, state *State) error {
+	setter := genSetFunc(stateDB)
+	for _, candidate := range state.Candidates {
+		if err := convertToKV(CANDIDATEPREFIX, candidate, setter); err != nil {
+			return fmt.Errorf("candidate %x: %v", candidate.Owner, err)
+		}
+	}
+	for _, voter := range state.Voters {
+		if err := convertToKV(VOTERPREFIX, voter, setter); err != nil {
+			return fmt.Errorf("voter %x: %v", voter.Owner, err)
+		}
+	}
+	for _, stake := range state.Stakes {
+		if err := convertToKV(STAKEPREFIX, stake, setter); err != nil {
+			return fmt.Errorf("stake %x: %v", stake.Owner, err)
+		}
+	}
+	if state.Reward != nil {
+		if err := setReward(stateDB, Reward{state.Reward}); err != nil {
+			return fmt.Errorf("reward: %v", err)
+		}
+	}
+	if state.Lock != nil {
+		if err := setLock(stateDB, AllLock{state.Lock}); err != nil {
+			return fmt.Errorf("lock: %v", err)
+		}
+	}
+	return nil
+}
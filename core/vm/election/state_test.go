@@ -0,0 +1,56 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package election
+
+import (
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/core/state"
+	"github.com/vntchain/go-vnt/vntdb"
+)
+
+// Tests that the election records read back what was written, and that records
+// failing to decode are reported instead of skipped.
+func TestReadState(t *testing.T) {
+	stateDB, _ := state.New(common.Hash{}, state.NewDatabase(vntdb.NewMemDatabase()))
+
+	owner := common.HexToAddress("0x01")
+	written := &State{Stakes: []Stake{{Owner: owner, StakeCount: big.NewInt(1), Vnt: big.NewInt(1e18), TimeStamp: big.NewInt(1)}}}
+	if err := WriteState(stateDB, written); err != nil {
+		t.Fatalf("failed to write state: %v", err)
+	}
+	read, err := ReadState(stateDB)
+	if err != nil {
+		t.Fatalf("failed to read state: %v", err)
+	}
+	if len(read.Stakes) != 1 || read.Stakes[0].Owner != owner || read.Stakes[0].Vnt.Cmp(written.Stakes[0].Vnt) != 0 {
+		t.Fatalf("stakes mismatch: have %+v, want %+v", read.Stakes, written.Stakes)
+	}
+	// Corrupt the owner of the stake, which must not be mistaken for a leftover
+	var key common.Hash
+	key[0] = STAKEPREFIX
+	copy(key[PREFIXLENGTH:], owner.Bytes())
+	binary.BigEndian.PutUint64(key[PREFIXLENGTH+common.AddressLength:], 0)
+	stateDB.SetState(contractAddr, key, common.BytesToHash([]byte{0x94, 0x01}))
+
+	if _, err := ReadState(stateDB); err == nil {
+		t.Fatal("corrupted stake read without error")
+	}
+}
@@ -100,3 +100,9 @@ type Tracer interface {
 	CaptureFault(env VM, pc uint64, op OPCode, gas, cost uint64, contract inter.Contract, depth int, err error) error
 	CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error
 }
+
+// RevertTracer is implemented by the tracers interested in the message a
+// contract reverted its execution with, which isn't part of the VM error.
+type RevertTracer interface {
+	CaptureRevert(env VM, msg string) error
+}
@@ -1245,6 +1245,9 @@ func (ef *EnvFunctions) Revert(proc *exec.WavmProcess, msgIdx uint64) {
 	msg := proc.ReadAt(msgIdx)
 	ctx.GasCounter.GasMemoryCost(uint64(len(msg)))
 	log.Info("Contract Revert >>>>", "message", string(msg))
+	if tracer, ok := ctx.Wavm.wavmConfig.Tracer.(errormsg.RevertTracer); ok && ctx.Wavm.wavmConfig.Debug {
+		tracer.CaptureRevert(ctx.Wavm, string(msg))
+	}
 	panic(errormsg.ErrExecutionReverted)
 }
 
@@ -1,6 +1,7 @@
 package wavm
 
 import (
+	"fmt"
 	"io"
 	"math/big"
 	"time"
@@ -104,5 +105,11 @@ func (l *WasmLogger) DebugLogs() []DebugLog { return l.debugLogs }
 
 // WriteTrace writes a formatted trace to the given writer
 func WriteTrace(writer io.Writer, logs []StructLog) {
-
+	for _, log := range logs {
+		fmt.Fprintf(writer, "%-16spc=%08d gas=%v cost=%v depth=%d", log.OpName(), log.Pc, log.Gas, log.GasCost, log.Depth)
+		if log.Err != nil {
+			fmt.Fprintf(writer, " ERROR: %v", log.Err)
+		}
+		fmt.Fprintln(writer)
+	}
 }
@@ -27,7 +27,6 @@ import (
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/util"
 	"github.com/vntchain/go-vnt/accounts"
 	"github.com/vntchain/go-vnt/accounts/keystore"
 	"github.com/vntchain/go-vnt/common"
@@ -46,6 +45,7 @@ import (
 	"github.com/vntchain/go-vnt/params"
 	"github.com/vntchain/go-vnt/rlp"
 	"github.com/vntchain/go-vnt/rpc"
+	"github.com/vntchain/go-vnt/vntdb"
 	"github.com/vntchain/go-vnt/vntp2p"
 )
 
@@ -136,6 +136,59 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	return content
 }
 
+// ContentFrom returns the transactions contained within the transaction pool
+// sent by the given account.
+func (s *PublicTxPoolAPI) ContentFrom(addr common.Address) map[string]map[string]*RPCTransaction {
+	content := map[string]map[string]*RPCTransaction{
+		"pending": make(map[string]*RPCTransaction),
+		"queued":  make(map[string]*RPCTransaction),
+	}
+	pending, queue := s.b.TxPoolContentFrom(addr)
+
+	for _, tx := range pending {
+		content["pending"][fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+	}
+	for _, tx := range queue {
+		content["queued"][fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+	}
+	return content
+}
+
+// Explain reports why the transaction with the given hash is still in the pool:
+// whether it is executable, which nonces are missing before it, and whether its
+// sender's balance and its gas price satisfy the pool. It returns nil if the
+// transaction is not in the pool.
+func (s *PublicTxPoolAPI) Explain(hash common.Hash) map[string]interface{} {
+	exp := s.b.TxPoolExplain(hash)
+	if exp == nil {
+		return nil
+	}
+	status := "queued"
+	if exp.Status == core.TxStatusPending {
+		status = "pending"
+	}
+	gaps := make([]map[string]hexutil.Uint64, len(exp.NonceGaps))
+	for i, gap := range exp.NonceGaps {
+		gaps[i] = map[string]hexutil.Uint64{"from": hexutil.Uint64(gap.From), "to": hexutil.Uint64(gap.To)}
+	}
+	return map[string]interface{}{
+		"status":         status,
+		"from":           exp.From,
+		"local":          exp.Local,
+		"stateNonce":     hexutil.Uint64(exp.StateNonce),
+		"pendingNonce":   hexutil.Uint64(exp.PendingNonce),
+		"nonceGaps":      gaps,
+		"balance":        (*hexutil.Big)(exp.Balance),
+		"cost":           (*hexutil.Big)(exp.Cost),
+		"cumulativeCost": (*hexutil.Big)(exp.CumulativeCost),
+		"gasLimit":       hexutil.Uint64(exp.GasLimit),
+		"minGasPrice":    (*hexutil.Big)(exp.MinGasPrice),
+		"underpriced":    exp.Underpriced,
+		"evictable":      exp.Evictable,
+		"reasons":        exp.Reasons,
+	}
+}
+
 // Status returns the number of pending and queued transaction in the pool.
 func (s *PublicTxPoolAPI) Status() map[string]hexutil.Uint {
 	pending, queue := s.b.Stats()
@@ -922,6 +975,7 @@ type RPCTransaction struct {
 	V                *hexutil.Big    `json:"v"`
 	R                *hexutil.Big    `json:"r"`
 	S                *hexutil.Big    `json:"s"`
+	Decoded          *DecodedCall    `json:"decoded,omitempty"`
 }
 
 // newRPCTransaction returns a transaction that will serialize to the RPC
@@ -1057,18 +1111,30 @@ func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, addr
 	return (*hexutil.Uint64)(&nonce), state.Error()
 }
 
-// GetTransactionByHash returns the transaction for the given hash
-func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) *RPCTransaction {
+// GetTransactionByHash returns the transaction for the given hash. If decode is
+// set, the invoked contract method and its arguments are decoded as well.
+func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash, decode *bool) *RPCTransaction {
+	var result *RPCTransaction
+
 	// Try to return an already finalized transaction
-	if tx, blockHash, blockNumber, index := rawdb.ReadTransaction(s.b.ChainDb(), hash); tx != nil {
-		return newRPCTransaction(tx, blockHash, blockNumber, index)
+	tx, blockHash, blockNumber, index := rawdb.ReadTransaction(s.b.ChainDb(), hash)
+	if tx != nil {
+		result = newRPCTransaction(tx, blockHash, blockNumber, index)
+	} else if tx = s.b.GetPoolTransaction(hash); tx != nil {
+		// No finalized transaction, try to retrieve it from the pool
+		result = newRPCPendingTransaction(tx)
+	} else {
+		// Transaction unknown, return as such
+		return nil
 	}
-	// No finalized transaction, try to retrieve it from the pool
-	if tx := s.b.GetPoolTransaction(hash); tx != nil {
-		return newRPCPendingTransaction(tx)
+	if decode != nil && *decode {
+		if _, call, err := decodeCall(ctx, s.b, tx); err != nil {
+			log.Debug("Failed to decode transaction", "hash", hash, "err", err)
+		} else {
+			result.Decoded = call
+		}
 	}
-	// Transaction unknown, return as such
-	return nil
+	return result
 }
 
 // GetRawTransactionByHash returns the bytes of the transaction for the given hash.
@@ -1087,7 +1153,10 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 }
 
 // GetTransactionReceipt returns the transaction receipt for the given transaction hash.
-func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
+// If decode is set, the invoked contract method, its arguments, the events it emitted
+// and either its return values or the error it failed with are decoded as well, by
+// re-executing the transaction.
+func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash, decode *bool) (map[string]interface{}, error) {
 	tx, blockHash, blockNumber, index := rawdb.ReadTransaction(s.b.ChainDb(), hash)
 	if tx == nil {
 		return nil, nil
@@ -1131,9 +1200,34 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
+	if decode != nil && *decode {
+		if call, err := s.decodeReceipt(ctx, tx, blockHash, index, receipt.Logs); err != nil {
+			log.Debug("Failed to decode transaction", "hash", hash, "err", err)
+		} else {
+			fields["decoded"] = call
+		}
+	}
 	return fields, nil
 }
 
+// decodeReceipt decodes the contract invocation of a finalized transaction,
+// including its outcome and the events it emitted.
+func (s *PublicTransactionPoolAPI) decodeReceipt(ctx context.Context, tx *types.Transaction, blockHash common.Hash, index uint64, logs []*types.Log) (*DecodedCall, error) {
+	method, call, err := decodeCall(ctx, s.b, tx)
+	if err != nil {
+		return nil, err
+	}
+	block, err := s.b.GetBlock(ctx, blockHash)
+	if block == nil || err != nil {
+		return nil, fmt.Errorf("block %x not found: %v", blockHash, err)
+	}
+	if err := decodeOutput(ctx, s.b, block, index, method, call); err != nil {
+		return nil, err
+	}
+	decodeEvents(ctx, s.b, logs, call)
+	return call, nil
+}
+
 // sign is a helper function that signs a transaction with the private key of the given address.
 func (s *PublicTransactionPoolAPI) sign(addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
 	// Look up the wallet containing the requested signer
@@ -1459,11 +1553,11 @@ func NewPrivateDebugAPI(b Backend) *PrivateDebugAPI {
 
 // ChaindbProperty returns leveldb properties of the chain database.
 func (api *PrivateDebugAPI) ChaindbProperty(property string) (string, error) {
-	ldb, ok := api.b.ChainDb().(interface {
+	ldb, ok := rawdb.KeyValueStore(api.b.ChainDb()).(interface {
 		LDB() *leveldb.DB
 	})
 	if !ok {
-		return "", fmt.Errorf("chaindbProperty does not work for memory databases")
+		return "", fmt.Errorf("chaindbProperty only works for leveldb databases")
 	}
 	if property == "" {
 		property = "leveldb.stats"
@@ -1474,15 +1568,18 @@ func (api *PrivateDebugAPI) ChaindbProperty(property string) (string, error) {
 }
 
 func (api *PrivateDebugAPI) ChaindbCompact() error {
-	ldb, ok := api.b.ChainDb().(interface {
-		LDB() *leveldb.DB
-	})
+	db, ok := rawdb.KeyValueStore(api.b.ChainDb()).(vntdb.Compacter)
 	if !ok {
 		return fmt.Errorf("chaindbCompact does not work for memory databases")
 	}
+	// Only leveldb compacts by range, the other engines at once
+	if _, ok := db.(*vntdb.LDBDatabase); !ok {
+		log.Info("Compacting chain database")
+		return db.Compact(nil, nil)
+	}
 	for b := byte(0); b < 255; b++ {
 		log.Info("Compacting chain database", "range", fmt.Sprintf("0x%0.2X-0x%0.2X", b, b+1))
-		err := ldb.LDB().CompactRange(util.Range{Start: []byte{b}, Limit: []byte{b + 1}})
+		err := db.Compact([]byte{b}, []byte{b + 1})
 		if err != nil {
 			log.Error("Database compaction failed", "err", err)
 			return err
@@ -0,0 +1,277 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package vntapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/vntchain/go-vnt/accounts/abi"
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/consensus"
+	"github.com/vntchain/go-vnt/core"
+	"github.com/vntchain/go-vnt/core/rawdb"
+	"github.com/vntchain/go-vnt/core/types"
+	"github.com/vntchain/go-vnt/core/vm"
+	"github.com/vntchain/go-vnt/core/vm/election"
+	"github.com/vntchain/go-vnt/core/vm/interface"
+	"github.com/vntchain/go-vnt/core/wavm"
+	"github.com/vntchain/go-vnt/core/wavm/utils"
+	"github.com/vntchain/go-vnt/log"
+	"github.com/vntchain/go-vnt/rpc"
+)
+
+var (
+	electionAddress = common.HexToAddress(election.ContractAddr)
+
+	errNoMethodId = errors.New("input too short for a method id")
+)
+
+// DecodedCall is the contract invocation performed by a transaction, decoded
+// with the ABI of the called contract.
+type DecodedCall struct {
+	Method  string           `json:"method"`
+	Args    []abi.NamedValue `json:"args"`
+	Outputs []abi.NamedValue `json:"outputs,omitempty"`
+	Events  []DecodedEvent   `json:"events,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// DecodedEvent is an event emitted by a transaction, decoded with the ABI of
+// the emitting contract.
+type DecodedEvent struct {
+	Index   uint             `json:"logIndex"`
+	Address common.Address   `json:"address"`
+	Event   string           `json:"event"`
+	Args    []abi.NamedValue `json:"args"`
+}
+
+// lookupAbi returns the ABI governing calls to the given address: the
+// built-in one of the election contract, or the one stored with the code of
+// a WASM contract.
+func lookupAbi(ctx context.Context, b Backend, address common.Address) (abi.ABI, error) {
+	if address == electionAddress {
+		return election.GetElectionABI()
+	}
+	state, _, err := b.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if state == nil || err != nil {
+		return abi.ABI{}, err
+	}
+	wasmcode, _, err := utils.DecodeContractCode(state.GetCode(address))
+	if err != nil {
+		return abi.ABI{}, err
+	}
+	return wavm.GetAbi(wasmcode.Abi)
+}
+
+// decodeCall resolves the method invoked by the given transaction and decodes
+// its arguments. Contract creations are decoded against the constructor.
+func decodeCall(ctx context.Context, b Backend, tx *types.Transaction) (*abi.Method, *DecodedCall, error) {
+	var (
+		method *abi.Method
+		input  []byte
+	)
+	if tx.To() == nil {
+		wasmcode, args, err := utils.DecodeContractCode(tx.Data())
+		if err != nil {
+			return nil, nil, err
+		}
+		contractAbi, err := wavm.GetAbi(wasmcode.Abi)
+		if err != nil {
+			return nil, nil, err
+		}
+		method, input = &contractAbi.Constructor, args
+	} else {
+		if len(tx.Data()) < 4 {
+			return nil, nil, errNoMethodId
+		}
+		contractAbi, err := lookupAbi(ctx, b, *tx.To())
+		if err != nil {
+			return nil, nil, err
+		}
+		if method, err = contractAbi.MethodById(tx.Data()); err != nil {
+			return nil, nil, err
+		}
+		input = tx.Data()[4:]
+	}
+	values, err := method.Inputs.UnpackValues(input)
+	if err != nil {
+		return nil, nil, err
+	}
+	args, err := method.Inputs.NamedValues(values)
+	if err != nil {
+		return nil, nil, err
+	}
+	return method, &DecodedCall{Method: method.Name, Args: args}, nil
+}
+
+// decodeOutput fills in the return values or the error of a decoded call
+// by re-executing the transaction at the given index of its block.
+func decodeOutput(ctx context.Context, b Backend, block *types.Block, index uint64, method *abi.Method, call *DecodedCall) error {
+	ret, failure, err := replayTransaction(ctx, b, block, index)
+	if err != nil {
+		return err
+	}
+	if failure != "" {
+		call.Error = failure
+		return nil
+	}
+	if block.Transactions()[index].To() == nil || len(method.Outputs) == 0 {
+		return nil
+	}
+	values, err := method.Outputs.UnpackValues(ret)
+	if err != nil {
+		return err
+	}
+	call.Outputs, err = method.Outputs.NamedValues(values)
+	return err
+}
+
+// decodeEvents fills in the events of a decoded call from the logs of its
+// receipt. Logs of contracts without a usable ABI or not matching any of its
+// events are left out.
+func decodeEvents(ctx context.Context, b Backend, logs []*types.Log, call *DecodedCall) {
+	abis := make(map[common.Address]*abi.ABI)
+	for _, l := range logs {
+		if len(l.Topics) == 0 {
+			continue
+		}
+		contractAbi, ok := abis[l.Address]
+		if !ok {
+			if parsed, err := lookupAbi(ctx, b, l.Address); err == nil {
+				contractAbi = &parsed
+			}
+			abis[l.Address] = contractAbi
+		}
+		if contractAbi == nil {
+			continue
+		}
+		event, err := contractAbi.EventById(l.Topics[0])
+		if err != nil {
+			continue
+		}
+		values, err := event.UnpackLog(l.Topics, l.Data)
+		if err != nil {
+			log.Debug("Failed to decode log", "address", l.Address, "event", event.Name, "err", err)
+			continue
+		}
+		args, err := event.Inputs.NamedValues(values)
+		if err != nil {
+			continue
+		}
+		call.Events = append(call.Events, DecodedEvent{Index: l.Index, Address: l.Address, Event: event.Name, Args: args})
+	}
+}
+
+// replayTransaction re-executes the transaction at the given index of the block
+// on top of its parent state, returning the data returned by the invoked
+// contract, or the reason of the failure if its execution failed.
+func replayTransaction(ctx context.Context, b Backend, block *types.Block, index uint64) ([]byte, string, error) {
+	statedb, _, err := b.StateAndHeaderByNumber(ctx, rpc.BlockNumber(block.NumberU64()-1))
+	if statedb == nil || err != nil {
+		return nil, "", fmt.Errorf("parent state of block %x unavailable: %v", block.Hash(), err)
+	}
+	var (
+		config = b.ChainConfig()
+		header = block.Header()
+		signer = types.MakeSigner(config, block.Number())
+		chain  = &chainContext{b: b}
+	)
+	for idx, tx := range block.Transactions() {
+		msg, err := tx.AsMessage(signer)
+		if err != nil {
+			return nil, "", err
+		}
+		var (
+			vmctx  = core.NewVMContext(msg, header, chain, &header.Coinbase)
+			tracer = new(outcomeTracer)
+			vmenv  = core.GetVM(msg, vmctx, statedb, config, vm.Config{Debug: uint64(idx) == index, Tracer: tracer})
+		)
+		ret, _, failed, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas()))
+		if err != nil {
+			return nil, "", fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
+		}
+		if uint64(idx) == index {
+			if failed {
+				return nil, tracer.failure(), nil
+			}
+			return ret, "", nil
+		}
+		// Ensure any modifications are committed to the state
+		statedb.Finalise(true)
+	}
+	return nil, "", fmt.Errorf("tx index %d out of range for block %x", index, block.Hash())
+}
+
+// outcomeTracer captures the error a transaction failed with, along with the
+// message of the contract reverting it, if any.
+type outcomeTracer struct {
+	err    error
+	revert string
+}
+
+func (t *outcomeTracer) CaptureStart(from common.Address, to common.Address, call bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+func (t *outcomeTracer) CaptureState(env vm.VM, pc uint64, op vm.OPCode, gas, cost uint64, contract inter.Contract, depth int, err error) error {
+	return nil
+}
+
+func (t *outcomeTracer) CaptureLog(env vm.VM, msg string) error { return nil }
+
+func (t *outcomeTracer) CaptureFault(env vm.VM, pc uint64, op vm.OPCode, gas, cost uint64, contract inter.Contract, depth int, err error) error {
+	return nil
+}
+
+func (t *outcomeTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	t.err = err
+	return nil
+}
+
+func (t *outcomeTracer) CaptureRevert(env vm.VM, msg string) error {
+	t.revert = msg
+	return nil
+}
+
+// failure returns the reason of the failed execution: the revert message of
+// the contract if it reverted, the error of the VM otherwise.
+func (t *outcomeTracer) failure() string {
+	switch {
+	case t.err == nil:
+		return "execution failed"
+	case t.revert != "" && t.err.Error() == vm.ErrExecutionReverted.Error():
+		return fmt.Sprintf("%v: %s", t.err, t.revert)
+	default:
+		return t.err.Error()
+	}
+}
+
+// chainContext implements core.ChainContext on top of the chain database, for
+// re-executing transactions whose block author is known.
+type chainContext struct {
+	b Backend
+}
+
+func (c *chainContext) Engine() consensus.Engine { return nil }
+
+func (c *chainContext) GetHeader(hash common.Hash, number uint64) *types.Header {
+	return rawdb.ReadHeader(c.b.ChainDb(), hash, number)
+}
@@ -0,0 +1,213 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package vntapi
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/vntchain/go-vnt/accounts/abi"
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/consensus/mock"
+	"github.com/vntchain/go-vnt/core"
+	"github.com/vntchain/go-vnt/core/rawdb"
+	"github.com/vntchain/go-vnt/core/state"
+	"github.com/vntchain/go-vnt/core/types"
+	"github.com/vntchain/go-vnt/core/vm"
+	"github.com/vntchain/go-vnt/core/wavm"
+	"github.com/vntchain/go-vnt/core/wavm/utils"
+	"github.com/vntchain/go-vnt/crypto"
+	"github.com/vntchain/go-vnt/params"
+	"github.com/vntchain/go-vnt/rpc"
+	"github.com/vntchain/go-vnt/vntdb"
+)
+
+// decodeBackend implements the parts of Backend needed to decode transactions
+// on top of a local chain.
+type decodeBackend struct {
+	Backend
+	db    vntdb.Database
+	chain *core.BlockChain
+}
+
+func (b *decodeBackend) ChainDb() vntdb.Database          { return b.db }
+func (b *decodeBackend) ChainConfig() *params.ChainConfig { return b.chain.Config() }
+
+func (b *decodeBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	header := b.chain.CurrentBlock().Header()
+	if number != rpc.LatestBlockNumber {
+		header = b.chain.GetHeaderByNumber(uint64(number))
+	}
+	statedb, err := b.chain.StateAt(header.Root)
+	return statedb, header, err
+}
+
+func (b *decodeBackend) GetBlock(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return b.chain.GetBlockByHash(hash), nil
+}
+
+func (b *decodeBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return rawdb.ReadReceipts(b.db, hash, *rawdb.ReadHeaderNumber(b.db, hash)), nil
+}
+
+// Tests that the calls of finalized transactions are decoded along with their
+// return values, events and failures.
+func TestDecodeTransaction(t *testing.T) {
+	tokenCode, tokenAbi := loadContract(t, "../../core/wavm/tests/erc20/TokenERC20.compress")
+	envCode, envAbi := loadContract(t, "../../core/wavm/tests/env/testEnv.compress")
+
+	var (
+		key, _    = crypto.GenerateKey()
+		from      = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.HexToAddress("0x02")
+		token     = crypto.CreateAddress(from, 0)
+		env       = crypto.CreateAddress(from, 1)
+		signer    = types.NewHubbleSigner(params.TestChainConfig.ChainID)
+		db        = vntdb.NewMemDatabase()
+		gspec     = &core.Genesis{
+			Config:   params.TestChainConfig,
+			GasLimit: 100000000,
+			Alloc:    core.GenesisAlloc{from: {Balance: big.NewInt(params.Vnt)}},
+		}
+		genesis = gspec.MustCommit(db)
+		txs     []*types.Transaction
+	)
+	pack := func(contractAbi abi.ABI, method string, args ...interface{}) []byte {
+		input, err := contractAbi.Pack(method, args...)
+		if err != nil {
+			t.Fatalf("failed to pack %q: %v", method, err)
+		}
+		return input
+	}
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, mock.NewMock(), db, 2, func(i int, gen *core.BlockGen) {
+		var unsigned []*types.Transaction
+		if i == 0 {
+			unsigned = append(unsigned,
+				types.NewContractCreation(0, new(big.Int), 10000000, new(big.Int), append(tokenCode, pack(tokenAbi, "", big.NewInt(1000), "bitcoin", "BTC")...)),
+				types.NewContractCreation(1, new(big.Int), 10000000, new(big.Int), envCode),
+			)
+		} else {
+			unsigned = append(unsigned,
+				types.NewTransaction(2, token, new(big.Int), 1000000, new(big.Int), pack(tokenAbi, "transfer", recipient, big.NewInt(100))),
+				types.NewTransaction(3, token, new(big.Int), 1000000, new(big.Int), pack(tokenAbi, "transfer", recipient, new(big.Int).Exp(big.NewInt(10), big.NewInt(30), nil))),
+				types.NewTransaction(4, env, new(big.Int), 1000000, new(big.Int), pack(envAbi, "testRevert")),
+			)
+		}
+		for _, tx := range unsigned {
+			signed, err := types.SignTx(tx, signer, key)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			gen.AddTx(signed)
+			txs = append(txs, signed)
+		}
+	})
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, mock.NewMock(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	var (
+		api    = NewPublicTransactionPoolAPI(&decodeBackend{db: db, chain: chain}, nil)
+		decode = true
+	)
+	// The deployment is decoded against the constructor
+	if tx := api.GetTransactionByHash(context.Background(), txs[0].Hash(), &decode); tx == nil || tx.Decoded == nil {
+		t.Fatalf("deployment not decoded: %+v", tx)
+	} else if have := namedValues(tx.Decoded.Args); have != "initialSupply=1000 tokenName=bitcoin tokenSymbol=BTC" {
+		t.Errorf("constructor arguments mismatch: have %q", have)
+	}
+	// The transfer is decoded with its return value and event
+	receipt, err := api.GetTransactionReceipt(context.Background(), txs[2].Hash(), &decode)
+	if err != nil {
+		t.Fatalf("failed to retrieve receipt: %v", err)
+	}
+	call, ok := receipt["decoded"].(*DecodedCall)
+	if !ok {
+		t.Fatalf("transfer not decoded: %v", receipt)
+	}
+	if call.Method != "transfer" || call.Error != "" {
+		t.Errorf("call mismatch: have method %q, error %q", call.Method, call.Error)
+	}
+	if have, want := namedValues(call.Args), "_to="+recipient.String()+" _value=100"; have != want {
+		t.Errorf("arguments mismatch: have %q, want %q", have, want)
+	}
+	if have := namedValues(call.Outputs); have != "output=true" {
+		t.Errorf("outputs mismatch: have %q", have)
+	}
+	if len(call.Events) != 1 || call.Events[0].Event != "Transfer" || call.Events[0].Address != token {
+		t.Fatalf("events mismatch: have %+v", call.Events)
+	}
+	if have, want := namedValues(call.Events[0].Args), "from="+from.String()+" to="+recipient.String()+" value=100"; have != want {
+		t.Errorf("event arguments mismatch: have %q, want %q", have, want)
+	}
+	// The failed transfer and the reverted call are decoded with the reasons of
+	// their failures
+	for i, want := range map[int]string{
+		3: "wavm: execution assert: sender does not have enough token",
+		4: "wavm: execution reverted: revert",
+	} {
+		receipt, err = api.GetTransactionReceipt(context.Background(), txs[i].Hash(), &decode)
+		if err != nil {
+			t.Fatalf("tx %d: failed to retrieve receipt: %v", i, err)
+		}
+		if call, ok = receipt["decoded"].(*DecodedCall); !ok {
+			t.Fatalf("tx %d: not decoded: %v", i, receipt)
+		}
+		if call.Error != want || call.Outputs != nil || call.Events != nil {
+			t.Errorf("tx %d: failure mismatch: have %+v, want error %q", i, call, want)
+		}
+	}
+}
+
+// loadContract reads the deployment code of a WAVM test contract, along with
+// its ABI.
+func loadContract(t *testing.T, path string) ([]byte, abi.ABI) {
+	code, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read contract: %v", err)
+	}
+	wasm, _, err := utils.DecodeContractCode(code)
+	if err != nil {
+		t.Fatalf("failed to decode contract: %v", err)
+	}
+	contractAbi, err := wavm.GetAbi(wasm.Abi)
+	if err != nil {
+		t.Fatalf("failed to parse abi: %v", err)
+	}
+	return code, contractAbi
+}
+
+// namedValues formats decoded values for comparison.
+func namedValues(values []abi.NamedValue) string {
+	formatted := make([]string, len(values))
+	for i, v := range values {
+		if address, ok := v.Value.(common.Address); ok {
+			formatted[i] = v.Name + "=" + address.String()
+		} else {
+			formatted[i] = fmt.Sprintf("%s=%v", v.Name, v.Value)
+		}
+	}
+	return strings.Join(formatted, " ")
+}
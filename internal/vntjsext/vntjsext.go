@@ -77,6 +77,21 @@ vnt._extend({
 			call: 'admin_removePeer',
 			params: 1
 		}),
+		new vnt._extend.Method({
+			name: 'banPeer',
+			call: 'admin_banPeer',
+			params: 3,
+			inputFormatter: [null, null, null]
+		}),
+		new vnt._extend.Method({
+			name: 'unbanPeer',
+			call: 'admin_unbanPeer',
+			params: 1
+		}),
+		new vnt._extend.Method({
+			name: 'listBans',
+			call: 'admin_listBans'
+		}),
 		new vnt._extend.Method({
 			name: 'exportChain',
 			call: 'admin_exportChain',
@@ -339,6 +354,12 @@ vnt._extend({
 			params: 2,
 			inputFormatter:[null, null],
 		}),
+		new vnt._extend.Method({
+			name: 'getStateDiff',
+			call: 'debug_getStateDiff',
+			params: 1,
+			inputFormatter: [vnt._extend.formatters.inputBlockNumberFormatter]
+		}),
 	],
 	properties: []
 });
@@ -354,6 +375,20 @@ vnt._extend({
 			params: 3,
 			inputFormatter: [vnt._extend.formatters.inputTransactionFormatter, vnt._extend.utils.fromDecimal, vnt._extend.utils.fromDecimal]
 		}),
+		new vnt._extend.Method({
+			name: 'getTransaction',
+			call: 'core_getTransactionByHash',
+			params: 2,
+			inputFormatter: [null, function (decode) { return !!decode; }],
+			outputFormatter: vnt._extend.formatters.outputTransactionFormatter
+		}),
+		new vnt._extend.Method({
+			name: 'getTransactionReceipt',
+			call: 'core_getTransactionReceipt',
+			params: 2,
+			inputFormatter: [null, function (decode) { return !!decode; }],
+			outputFormatter: vnt._extend.formatters.outputTransactionReceiptFormatter
+		}),
 		new vnt._extend.Method({
 			name: 'getRawTransaction',
 			call: 'core_getRawTransactionByHash',
@@ -535,7 +570,19 @@ vnt._extend({
 const TxPool_JS = `
 vnt._extend({
 	property: 'txpool',
-	methods: [],
+	methods: [
+		new vnt._extend.Method({
+			name: 'contentFrom',
+			call: 'txpool_contentFrom',
+			params: 1,
+			inputFormatter: [vnt._extend.formatters.inputAddressFormatter]
+		}),
+		new vnt._extend.Method({
+			name: 'explain',
+			call: 'txpool_explain',
+			params: 1
+		}),
+	],
 	properties:
 	[
 		new vnt._extend.Property({
@@ -138,6 +138,17 @@ func (b *LesApiBackend) TxPoolContent() (map[common.Address]types.Transactions,
 	return b.vnt.txPool.Content()
 }
 
+func (b *LesApiBackend) TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	pending, queued := b.vnt.txPool.Content()
+	return pending[addr], queued[addr]
+}
+
+// TxPoolExplain always returns nil, as the light pool keeps no nonce or price
+// bookkeeping to explain.
+func (b *LesApiBackend) TxPoolExplain(hash common.Hash) *core.TxExplanation {
+	return nil
+}
+
 func (b *LesApiBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
 	return b.vnt.txPool.SubscribeNewTxsEvent(ch)
 }
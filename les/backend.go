@@ -213,6 +213,7 @@ func (s *LightVnt) Start(srvr *vntp2p.Server) error {
 	s.startBloomHandlers()
 	log.Warn("Light client mode is an experimental feature")
 	s.netRPCService = vntapi.NewPublicNetAPI(srvr, s.networkId)
+	srvr.SetChainID(vntp2p.ChainID{NetworkID: s.networkId, Genesis: s.blockchain.Genesis().Hash()})
 	// clients are searching for the first advertised protocol in the list
 	protocolVersion := AdvertiseProtocolVersions[0]
 	fmt.Println(protocolVersion)
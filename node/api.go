@@ -22,6 +22,7 @@ import (
 	"strings"
 	"time"
 
+	peer "github.com/libp2p/go-libp2p-peer"
 	"github.com/vntchain/go-vnt/common/hexutil"
 	"github.com/vntchain/go-vnt/crypto"
 	"github.com/vntchain/go-vnt/metrics"
@@ -113,6 +114,75 @@ func (api *PrivateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription,
 	return rpcSub, nil
 }
 
+// BanPeer bans a remote node, given by its vnode URL or peer id, for the given
+// number of seconds, disconnecting it if the connection exists. Without a
+// duration the node is banned for the configured ban duration.
+func (api *PrivateAdminAPI) BanPeer(id string, seconds *uint64, reason *string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	pid, err := parsePeerID(id)
+	if err != nil {
+		return false, err
+	}
+	var duration time.Duration
+	if seconds != nil {
+		duration = time.Duration(*seconds) * time.Second
+	}
+	why := "banned by admin"
+	if reason != nil {
+		why = *reason
+	}
+	if err := server.BanPeer(pid, duration, why); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UnbanPeer lifts the ban of a remote node, given by its vnode URL or peer id,
+// reporting whether it was banned.
+func (api *PrivateAdminAPI) UnbanPeer(id string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	pid, err := parsePeerID(id)
+	if err != nil {
+		return false, err
+	}
+	return server.UnbanPeer(pid), nil
+}
+
+// ListBans returns the remote nodes currently banned, either by an admin or
+// for misbehaving.
+func (api *PrivateAdminAPI) ListBans() ([]vntp2p.Ban, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.Bans(), nil
+}
+
+// parsePeerID extracts the peer id from a vnode URL or a base58 peer id.
+func parsePeerID(id string) (peer.ID, error) {
+	if strings.Contains(id, "/") {
+		node, err := vntp2p.ParseNode(id)
+		if err != nil {
+			return "", fmt.Errorf("invalid vnode: %v", err)
+		}
+		return node.Id, nil
+	}
+	pid, err := peer.IDB58Decode(id)
+	if err != nil {
+		return "", fmt.Errorf("invalid peer id: %v", err)
+	}
+	return pid, nil
+}
+
 // StartRPC starts the HTTP RPC API server.
 func (api *PrivateAdminAPI) StartRPC(host *string, port *int, cors *string, apis *string, vhosts *string) (bool, error) {
 	api.node.lock.Lock()
@@ -30,6 +30,7 @@ import (
 	"github.com/vntchain/go-vnt/common"
 	"github.com/vntchain/go-vnt/crypto"
 	"github.com/vntchain/go-vnt/log"
+	"github.com/vntchain/go-vnt/rpc"
 	"github.com/vntchain/go-vnt/vntp2p"
 )
 
@@ -64,6 +65,11 @@ type Config struct {
 	// in memory.
 	DataDir string
 
+	// DBEngine is the storage engine of the databases created in the data
+	// directory, "leveldb" or "badger". The existing databases keep the engine
+	// they were created with, if empty.
+	DBEngine string `toml:",omitempty"`
+
 	// Configuration of peer-to-peer networking.
 	P2P vntp2p.Config
 
@@ -140,6 +146,11 @@ type Config struct {
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
 
+	// RPCAccess is the access policy (rate limits, API keys and method filters)
+	// enforced on the HTTP and websocket RPC interfaces. The IPC endpoint is
+	// never restricted.
+	RPCAccess rpc.AccessPolicy
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 }
@@ -280,6 +291,19 @@ func (c *Config) resolvePath(path string) string {
 	return filepath.Join(c.instanceDir(), path)
 }
 
+// resolveFreezerPath resolves the freezer directory of a database, the "ancient"
+// directory of the database by default, and relative to it otherwise.
+func (c *Config) resolveFreezerPath(name, freezer string) string {
+	switch {
+	case freezer == "":
+		return filepath.Join(c.resolvePath(name), "ancient")
+	case filepath.IsAbs(freezer):
+		return freezer
+	default:
+		return filepath.Join(c.resolvePath(name), freezer)
+	}
+}
+
 func (c *Config) instanceDir() string {
 	if c.DataDir == "" {
 		return ""
@@ -28,6 +28,7 @@ import (
 
 	"github.com/prometheus/prometheus/util/flock"
 	"github.com/vntchain/go-vnt/accounts"
+	"github.com/vntchain/go-vnt/core/rawdb"
 	"github.com/vntchain/go-vnt/event"
 	"github.com/vntchain/go-vnt/internal/debug"
 	"github.com/vntchain/go-vnt/log"
@@ -341,7 +342,7 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := rpc.StartHTTPEndpoint(endpoint, apis, modules, cors, vhosts)
+	listener, handler, err := rpc.StartHTTPEndpoint(endpoint, apis, modules, cors, vhosts, n.config.RPCAccess)
 	if err != nil {
 		return err
 	}
@@ -374,7 +375,7 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := rpc.StartWSEndpoint(endpoint, apis, modules, wsOrigins, exposeAll)
+	listener, handler, err := rpc.StartWSEndpoint(endpoint, apis, modules, wsOrigins, exposeAll, n.config.RPCAccess)
 	if err != nil {
 		return err
 	}
@@ -589,7 +590,34 @@ func (n *Node) OpenDatabase(name string, cache, handles int) (vntdb.Database, er
 	if n.config.DataDir == "" {
 		return vntdb.NewMemDatabase(), nil
 	}
-	return vntdb.NewLDBDatabase(n.config.resolvePath(name), cache, handles)
+	return vntdb.Open(n.config.DBEngine, n.config.resolvePath(name), cache, handles)
+}
+
+// OpenDatabaseWithFreezer opens an existing database with the given name (or
+// creates one if no previous can be found) from within the node's instance
+// directory, moving the immutable chain segments into a freezer in the given
+// directory. The freezer defaults to the "ancient" directory of the database. If
+// the node is ephemeral, a memory database without freezer is returned.
+func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer string) (vntdb.Database, error) {
+	if n.config.DataDir == "" {
+		return vntdb.NewMemDatabase(), nil
+	}
+	return openDatabaseWithFreezer(n.config.DBEngine, n.config.resolvePath(name), cache, handles, n.config.resolveFreezerPath(name, freezer))
+}
+
+// openDatabaseWithFreezer opens a database of the given engine wrapped with a
+// freezer.
+func openDatabaseWithFreezer(engine string, file string, cache, handles int, freezer string) (vntdb.Database, error) {
+	kvdb, err := vntdb.Open(engine, file, cache, handles)
+	if err != nil {
+		return nil, err
+	}
+	db, err := rawdb.NewDatabaseWithFreezer(kvdb, freezer)
+	if err != nil {
+		kvdb.Close()
+		return nil, err
+	}
+	return db, nil
 }
 
 // ResolvePath returns the absolute path of a resource in the instance directory.
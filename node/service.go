@@ -43,13 +43,25 @@ func (ctx *ServiceContext) OpenDatabase(name string, cache int, handles int) (vn
 	if ctx.config.DataDir == "" {
 		return vntdb.NewMemDatabase(), nil
 	}
-	db, err := vntdb.NewLDBDatabase(ctx.config.resolvePath(name), cache, handles)
+	db, err := vntdb.Open(ctx.config.DBEngine, ctx.config.resolvePath(name), cache, handles)
 	if err != nil {
 		return nil, err
 	}
 	return db, nil
 }
 
+// OpenDatabaseWithFreezer opens an existing database with the given name (or
+// creates one if no previous can be found) from within the node's data directory,
+// moving the immutable chain segments into a freezer in the given directory. The
+// freezer defaults to the "ancient" directory of the database. If the node is an
+// ephemeral one, a memory database without freezer is returned.
+func (ctx *ServiceContext) OpenDatabaseWithFreezer(name string, cache int, handles int, freezer string) (vntdb.Database, error) {
+	if ctx.config.DataDir == "" {
+		return vntdb.NewMemDatabase(), nil
+	}
+	return openDatabaseWithFreezer(ctx.config.DBEngine, ctx.config.resolvePath(name), cache, handles, ctx.config.resolveFreezerPath(name, freezer))
+}
+
 // ResolvePath resolves a user path into the data directory if that was relative
 // and if the user actually uses persistent storage. It will return an empty string
 // for emphemeral storage and the user's own input for absolute paths.
@@ -23,4 +23,14 @@ const (
 	// BloomBitsBlocks is the number of blocks a single bloom bit section vector
 	// contains.
 	BloomBitsBlocks uint64 = 4096
+
+	// ImmutabilityThreshold is the number of blocks below the head after which
+	// the chain segments are final, and moved from the key-value store into the
+	// flat file freezer.
+	ImmutabilityThreshold uint64 = 90000
+
+	// MinHistoryRetention is the least number of recent blocks whose bodies and
+	// receipts are kept by the history expiry, for the chain reorganisations to
+	// find them.
+	MinHistoryRetention uint64 = 128
 )
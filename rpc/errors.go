@@ -30,6 +30,18 @@ func (e *methodNotFoundError) Error() string {
 	return fmt.Sprintf("The method %s%s%s does not exist/is not available", e.service, serviceMethodSeparator, e.method)
 }
 
+// request is for a method excluded by the server's access policy
+type methodNotAllowedError struct {
+	service string
+	method  string
+}
+
+func (e *methodNotAllowedError) ErrorCode() int { return -32601 }
+
+func (e *methodNotAllowedError) Error() string {
+	return fmt.Sprintf("The method %s%s%s is not allowed", e.service, serviceMethodSeparator, e.method)
+}
+
 // received message isn't a valid request
 type invalidRequestError struct{ message string }
 
@@ -64,3 +76,10 @@ type shutdownError struct{}
 func (e *shutdownError) ErrorCode() int { return -32000 }
 
 func (e *shutdownError) Error() string { return "server is shutting down" }
+
+// issued when a request exceeds the rate or size limits of the server.
+type limitExceededError struct{ message string }
+
+func (e *limitExceededError) ErrorCode() int { return -32005 }
+
+func (e *limitExceededError) Error() string { return e.message }
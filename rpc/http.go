@@ -179,10 +179,18 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet && r.ContentLength == 0 && r.URL.RawQuery == "" {
 		return
 	}
-	if code, err := validateRequest(r); err != nil {
+	if code, err := validateRequest(r, srv.access.maxRequestSize()); err != nil {
+		if code == http.StatusRequestEntityTooLarge {
+			rejectedSizeMeter.Mark(1)
+		}
 		http.Error(w, err.Error(), code)
 		return
 	}
+	key := r.Header.Get(apiKeyHeader)
+	if !srv.access.checkKey(key) {
+		http.Error(w, "invalid api key", http.StatusUnauthorized)
+		return
+	}
 	// All checks passed, create a codec that reads direct from the request body
 	// untilEOF and writes the response to w and order the server to process a
 	// single request.
@@ -190,8 +198,9 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx = context.WithValue(ctx, "remote", r.RemoteAddr)
 	ctx = context.WithValue(ctx, "scheme", r.Proto)
 	ctx = context.WithValue(ctx, "local", r.Host)
+	ctx = context.WithValue(ctx, "apikey", key)
 
-	body := io.LimitReader(r.Body, maxRequestContentLength)
+	body := io.LimitReader(r.Body, srv.access.maxRequestSize())
 	codec := NewJSONCodec(&httpReadWriteNopCloser{body, w})
 	defer codec.Close()
 
@@ -200,13 +209,13 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // validateRequest returns a non-zero response code and error message if the
-// request is invalid.
-func validateRequest(r *http.Request) (int, error) {
+// request is invalid or larger than maxSize bytes.
+func validateRequest(r *http.Request, maxSize int64) (int, error) {
 	if r.Method == http.MethodPut || r.Method == http.MethodDelete {
 		return http.StatusMethodNotAllowed, errors.New("method not allowed")
 	}
-	if r.ContentLength > maxRequestContentLength {
-		err := fmt.Errorf("content length too large (%d>%d)", r.ContentLength, maxRequestContentLength)
+	if r.ContentLength > maxSize {
+		err := fmt.Errorf("content length too large (%d>%d)", r.ContentLength, maxSize)
 		return http.StatusRequestEntityTooLarge, err
 	}
 	mt, _, err := mime.ParseMediaType(r.Header.Get("content-type"))
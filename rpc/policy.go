@@ -0,0 +1,286 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/vntchain/go-vnt/metrics"
+)
+
+const (
+	apiKeyHeader   = "X-API-Key" // HTTP header carrying the client's API key
+	maxRateBuckets = 16384       // number of tracked clients before idle ones are dropped
+)
+
+var (
+	rejectedKeyMeter    = metrics.NewRegisteredMeter("rpc/rejected/apikey", nil)
+	rejectedRateMeter   = metrics.NewRegisteredMeter("rpc/rejected/ratelimit", nil)
+	rejectedMethodMeter = metrics.NewRegisteredMeter("rpc/rejected/method", nil)
+	rejectedSizeMeter   = metrics.NewRegisteredMeter("rpc/rejected/size", nil)
+	rejectedBatchMeter  = metrics.NewRegisteredMeter("rpc/rejected/batch", nil)
+)
+
+// AccessPolicy restricts the clients allowed to use an RPC server, the methods
+// they may call and the rate at which they may call them. The zero value
+// imposes no restrictions beyond the transport defaults.
+type AccessPolicy struct {
+	// RateLimit is the number of requests per second a single client IP may
+	// issue, allowing bursts of up to one second worth of requests. Every call
+	// of a batch counts as a request, batches of more calls than the burst are
+	// rejected. Zero disables the limit.
+	RateLimit float64 `toml:",omitempty"`
+
+	// KeyRateLimit is the number of requests per second a single API key may
+	// issue, counted like RateLimit. Zero disables the limit.
+	KeyRateLimit float64 `toml:",omitempty"`
+
+	// APIKeys is the list of keys accepted in the X-API-Key header. If it is
+	// empty, requests are served without a key.
+	APIKeys []string `toml:",omitempty"`
+
+	// AllowedMethods is the list of methods clients may call, either fully
+	// qualified (core_getBalance) or as a namespace wildcard (core_*). If it is
+	// empty, all registered methods may be called.
+	AllowedMethods []string `toml:",omitempty"`
+
+	// DeniedMethods is the list of methods clients may never call, in the same
+	// format as AllowedMethods. It takes precedence over AllowedMethods.
+	DeniedMethods []string `toml:",omitempty"`
+
+	// MaxRequestSize is the maximum size of a request in bytes. Zero keeps the
+	// default of 128KB.
+	MaxRequestSize int64 `toml:",omitempty"`
+
+	// MaxBatchLength is the maximum number of calls in a batch request. Zero
+	// disables the limit.
+	MaxBatchLength int `toml:",omitempty"`
+
+	// MaxBatchResponseSize is the maximum total size in bytes of the responses
+	// to a batch request. Calls whose response would exceed it are answered with
+	// an error instead. Zero disables the limit.
+	MaxBatchResponseSize int `toml:",omitempty"`
+
+	// BatchWorkers is the number of calls of a single batch request executed
+	// concurrently, among the calls of ConcurrentMethods. Zero or one executes
+	// batches sequentially.
+	BatchWorkers int `toml:",omitempty"`
+
+	// ConcurrentMethods is the list of methods whose calls in a batch request
+	// may be executed concurrently with each other, in the same format as
+	// AllowedMethods. They should not depend on the order of execution, like
+	// read-only methods. The calls of other methods are executed in the order
+	// of the batch, once all the calls preceding them completed, and before
+	// any call following them starts.
+	ConcurrentMethods []string `toml:",omitempty"`
+}
+
+// accessControl is the compiled form of an AccessPolicy enforced by a server.
+type accessControl struct {
+	policy     AccessPolicy
+	keys       map[string]struct{}
+	allowed    map[string]struct{}
+	denied     map[string]struct{}
+	concurrent map[string]struct{}
+
+	ipLimiter  *rateLimiter
+	keyLimiter *rateLimiter
+}
+
+// newAccessControl compiles the given policy.
+func newAccessControl(policy AccessPolicy) *accessControl {
+	ac := &accessControl{
+		policy:     policy,
+		keys:       toSet(policy.APIKeys),
+		allowed:    toSet(policy.AllowedMethods),
+		denied:     toSet(policy.DeniedMethods),
+		concurrent: toSet(policy.ConcurrentMethods),
+		ipLimiter:  newRateLimiter(policy.RateLimit),
+		keyLimiter: newRateLimiter(policy.KeyRateLimit),
+	}
+	if policy.MaxRequestSize <= 0 {
+		ac.policy.MaxRequestSize = maxRequestContentLength
+	}
+	return ac
+}
+
+// SetAccessPolicy replaces the access policy enforced on the HTTP and websocket
+// transports of the server. It must be called before the server starts serving.
+func (s *Server) SetAccessPolicy(policy AccessPolicy) {
+	s.access = newAccessControl(policy)
+}
+
+// maxRequestSize returns the maximum accepted size of a request in bytes.
+func (ac *accessControl) maxRequestSize() int64 {
+	return ac.policy.MaxRequestSize
+}
+
+// checkKey reports whether the given API key grants access to the server.
+func (ac *accessControl) checkKey(key string) bool {
+	if len(ac.keys) == 0 {
+		return true
+	}
+	if _, ok := ac.keys[key]; ok {
+		return true
+	}
+	rejectedKeyMeter.Mark(1)
+	return false
+}
+
+// admit checks a freshly read (batch) request against the batch length and the
+// rate limits of the client it originates from.
+func (ac *accessControl) admit(ctx context.Context, reqs []*serverRequest, batch bool) Error {
+	if batch && ac.policy.MaxBatchLength > 0 && len(reqs) > ac.policy.MaxBatchLength {
+		rejectedBatchMeter.Mark(1)
+		return &limitExceededError{"batch too large"}
+	}
+	if !ac.ipLimiter.allow(remoteIP(ctx), len(reqs)) {
+		rejectedRateMeter.Mark(1)
+		return &limitExceededError{"request rate limit exceeded"}
+	}
+	if key, ok := ctx.Value("apikey").(string); ok && key != "" && !ac.keyLimiter.allow(key, len(reqs)) {
+		rejectedRateMeter.Mark(1)
+		return &limitExceededError{"api key rate limit exceeded"}
+	}
+	return nil
+}
+
+// methodAllowed reports whether the given method may be called.
+func (ac *accessControl) methodAllowed(service, method string) bool {
+	name := service + serviceMethodSeparator + method
+	wildcard := service + serviceMethodSeparator + "*"
+
+	if matchMethod(ac.denied, name, wildcard) {
+		rejectedMethodMeter.Mark(1)
+		return false
+	}
+	if len(ac.allowed) > 0 && !matchMethod(ac.allowed, name, wildcard) {
+		rejectedMethodMeter.Mark(1)
+		return false
+	}
+	return true
+}
+
+// methodConcurrent reports whether the calls of the given method in a batch
+// may be executed concurrently.
+func (ac *accessControl) methodConcurrent(service, method string) bool {
+	if ac.policy.BatchWorkers <= 1 {
+		return false
+	}
+	name := service + serviceMethodSeparator + method
+	return matchMethod(ac.concurrent, name, service+serviceMethodSeparator+"*")
+}
+
+// matchMethod reports whether the method list contains the given method name,
+// its namespace wildcard or the global wildcard.
+func matchMethod(methods map[string]struct{}, name, wildcard string) bool {
+	for _, candidate := range []string{name, wildcard, "*"} {
+		if _, ok := methods[candidate]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP extracts the IP address of the client from the request context.
+func remoteIP(ctx context.Context) string {
+	remote, _ := ctx.Value("remote").(string)
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		return host
+	}
+	return remote
+}
+
+func toSet(items []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		set[item] = struct{}{}
+	}
+	return set
+}
+
+// tokenBucket tracks the requests budget of a single client.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a token bucket rate limiter keyed by client identity.
+type rateLimiter struct {
+	rate  float64 // tokens refilled per second
+	burst float64 // capacity of each bucket
+
+	lock    sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// newRateLimiter creates a rate limiter allowing rate requests per second per
+// client, or nil if rate limiting is disabled.
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		rate:    rate,
+		burst:   math.Max(rate, 1),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow consumes the given number of tokens from the bucket of the client,
+// reporting whether the requests are within the allowed rate. Requests beyond
+// it consume no tokens.
+func (l *rateLimiter) allow(client string, n int) bool {
+	if l == nil {
+		return true
+	}
+	now := time.Now()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	bucket := l.buckets[client]
+	if bucket == nil {
+		if len(l.buckets) >= maxRateBuckets {
+			l.expire(now)
+		}
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = bucket
+	} else {
+		bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+		bucket.last = now
+	}
+	if bucket.tokens < float64(n) {
+		return false
+	}
+	bucket.tokens -= float64(n)
+	return true
+}
+
+// expire drops the buckets of clients that would have been refilled by now,
+// since they are indistinguishable from new ones.
+func (l *rateLimiter) expire(now time.Time) {
+	for client, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+}
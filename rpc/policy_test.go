@@ -0,0 +1,274 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAccessPolicyMethods(t *testing.T) {
+	tests := []struct {
+		policy          AccessPolicy
+		service, method string
+		allowed         bool
+	}{
+		{AccessPolicy{}, "test", "echo", true},
+		{AccessPolicy{AllowedMethods: []string{"test_echo"}}, "test", "echo", true},
+		{AccessPolicy{AllowedMethods: []string{"test_echo"}}, "test", "rets", false},
+		{AccessPolicy{AllowedMethods: []string{"test_*"}}, "test", "rets", true},
+		{AccessPolicy{AllowedMethods: []string{"test_*"}}, "admin", "peers", false},
+		{AccessPolicy{DeniedMethods: []string{"admin_*"}}, "admin", "peers", false},
+		{AccessPolicy{DeniedMethods: []string{"admin_*"}}, "test", "echo", true},
+		{AccessPolicy{AllowedMethods: []string{"*"}, DeniedMethods: []string{"test_echo"}}, "test", "echo", false},
+		{AccessPolicy{AllowedMethods: []string{"test_echo"}, DeniedMethods: []string{"test_*"}}, "test", "echo", false},
+	}
+	for i, tt := range tests {
+		ac := newAccessControl(tt.policy)
+		if allowed := ac.methodAllowed(tt.service, tt.method); allowed != tt.allowed {
+			t.Errorf("test %d: %s_%s allowed mismatch: have %v, want %v", i, tt.service, tt.method, allowed, tt.allowed)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(3)
+	for i := 0; i < 3; i++ {
+		if !limiter.allow("1.2.3.4", 1) {
+			t.Fatalf("request %d within burst rejected", i)
+		}
+	}
+	if limiter.allow("1.2.3.4", 1) {
+		t.Fatalf("request beyond burst accepted")
+	}
+	if !limiter.allow("5.6.7.8", 1) {
+		t.Fatalf("request from other client rejected")
+	}
+	if limiter := newRateLimiter(0); limiter != nil || !limiter.allow("1.2.3.4", 1) {
+		t.Fatalf("disabled limiter rejected request")
+	}
+}
+
+func TestAccessPolicyAdmit(t *testing.T) {
+	ac := newAccessControl(AccessPolicy{RateLimit: 1, KeyRateLimit: 2, MaxBatchLength: 2})
+
+	batch := make([]*serverRequest, 3)
+	if err := ac.admit(context.Background(), batch, true); err == nil {
+		t.Fatalf("oversized batch admitted")
+	}
+	// Requests from distinct IPs sharing a key are limited by the key
+	for i, remote := range []string{"1.1.1.1:1", "2.2.2.2:2", "3.3.3.3:3"} {
+		ctx := context.WithValue(context.Background(), "remote", remote)
+		ctx = context.WithValue(ctx, "apikey", "key")
+
+		err := ac.admit(ctx, batch[:1], false)
+		if (err == nil) != (i < 2) {
+			t.Fatalf("request %d: admission mismatch: %v", i, err)
+		}
+	}
+	// Requests from the same IP on different ports are limited by the IP
+	ctx := context.WithValue(context.Background(), "remote", "1.1.1.1:2")
+	if err := ac.admit(ctx, batch[:1], false); err == nil {
+		t.Fatalf("request over ip rate limit admitted")
+	}
+}
+
+// Tests that every call of a batch is charged against the rate limits.
+func TestAccessPolicyBatchCost(t *testing.T) {
+	ac := newAccessControl(AccessPolicy{RateLimit: 3, KeyRateLimit: 3})
+
+	ctx := context.WithValue(context.Background(), "remote", "1.1.1.1:1")
+	batch := make([]*serverRequest, 4)
+	if err := ac.admit(ctx, batch, true); err == nil {
+		t.Fatalf("batch beyond ip burst admitted")
+	}
+	if err := ac.admit(ctx, batch[:2], true); err != nil {
+		t.Fatalf("batch within ip burst rejected: %v", err)
+	}
+	if err := ac.admit(ctx, batch[:2], true); err == nil {
+		t.Fatalf("batch over ip rate limit admitted")
+	}
+	if err := ac.admit(ctx, batch[:1], false); err != nil {
+		t.Fatalf("request within ip rate limit rejected: %v", err)
+	}
+	// Batches sent with a key from distinct IPs are charged to the key
+	for i, remote := range []string{"2.2.2.2:2", "3.3.3.3:3"} {
+		ctx := context.WithValue(context.Background(), "remote", remote)
+		ctx = context.WithValue(ctx, "apikey", "key")
+
+		err := ac.admit(ctx, batch[:2], true)
+		if (err == nil) != (i == 0) {
+			t.Fatalf("batch %d: admission mismatch: %v", i, err)
+		}
+	}
+}
+
+func TestServerMethodNotAllowed(t *testing.T) {
+	server := NewServer()
+	server.SetAccessPolicy(AccessPolicy{DeniedMethods: []string{"test_echo"}})
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatalf("%v", err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+
+	request := map[string]interface{}{
+		"id":      1,
+		"method":  "test_echo",
+		"version": "2.0",
+		"params":  []interface{}{"string arg", 1, &Args{"abcde"}},
+	}
+	if err := json.NewEncoder(clientConn).Encode(request); err != nil {
+		t.Fatal(err)
+	}
+	var response jsonErrResponse
+	if err := json.NewDecoder(clientConn).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error.Code != (&methodNotAllowedError{}).ErrorCode() {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+}
+
+func TestBatchWorkers(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	server.SetAccessPolicy(AccessPolicy{BatchWorkers: 4, ConcurrentMethods: []string{"service_sleep"}})
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	// The calls of concurrent methods run concurrently between the other calls
+	var batch []BatchElem
+	for i := 0; i < 9; i++ {
+		if i == 4 {
+			batch = append(batch, BatchElem{Method: "service_echo", Args: []interface{}{"hello", 10, &Args{"world"}}, Result: new(Result)})
+			continue
+		}
+		batch = append(batch, BatchElem{Method: "service_sleep", Args: []interface{}{100 * time.Millisecond}, Result: new(interface{})})
+	}
+	start := time.Now()
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 600*time.Millisecond {
+		t.Errorf("batch not executed concurrently: took %v", elapsed)
+	} else if elapsed < 200*time.Millisecond {
+		t.Errorf("calls run concurrently across a sequential call: took %v", elapsed)
+	}
+	for i, elem := range batch {
+		if elem.Error != nil {
+			t.Errorf("call %d failed: %v", i, elem.Error)
+		}
+	}
+	if res := batch[4].Result.(*Result); res.String != "hello" || res.Int != 10 {
+		t.Errorf("incorrect result %#v", res)
+	}
+}
+
+func TestBatchWorkersSequentialMethods(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	server.SetAccessPolicy(AccessPolicy{BatchWorkers: 4, ConcurrentMethods: []string{"service_echo"}})
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	batch := make([]BatchElem, 3)
+	for i := range batch {
+		batch[i] = BatchElem{Method: "service_sleep", Args: []interface{}{100 * time.Millisecond}, Result: new(interface{})}
+	}
+	start := time.Now()
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("calls of sequential method executed concurrently: took %v", elapsed)
+	}
+}
+
+func TestBatchResponseLimit(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	server.SetAccessPolicy(AccessPolicy{MaxBatchResponseSize: 200})
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	batch := make([]BatchElem, 4)
+	for i := range batch {
+		batch[i] = BatchElem{Method: "service_echo", Args: []interface{}{"hello", i, &Args{"world"}}, Result: new(Result)}
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatal(err)
+	}
+	// Each echo response takes about 90 bytes, so only the first two fit
+	for i, elem := range batch {
+		if failed := elem.Error != nil; failed != (i >= 2) {
+			t.Errorf("call %d: error mismatch: %v", i, elem.Error)
+		}
+	}
+	if res := batch[1].Result.(*Result); res.Int != 1 {
+		t.Errorf("incorrect result %#v", res)
+	}
+}
+
+func TestBatchResponseLimitSingleResponse(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	server.SetAccessPolicy(AccessPolicy{MaxBatchResponseSize: 50})
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	batch := []BatchElem{{Method: "service_echo", Args: []interface{}{"hello", 1, &Args{"world"}}, Result: new(Result)}}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatal(err)
+	}
+	if batch[0].Error == nil {
+		t.Errorf("response larger than the limit accepted")
+	}
+}
+
+func TestBatchResponseLimitStopsExecution(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	server.SetAccessPolicy(AccessPolicy{MaxBatchResponseSize: 200})
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	batch := make([]BatchElem, 3)
+	for i := range batch {
+		batch[i] = BatchElem{Method: "service_echo", Args: []interface{}{"hello", i, &Args{"world"}}, Result: new(Result)}
+	}
+	for i := 0; i < 3; i++ {
+		batch = append(batch, BatchElem{Method: "service_sleep", Args: []interface{}{time.Second}, Result: new(interface{})})
+	}
+	start := time.Now()
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("requests past the limit executed: took %v", elapsed)
+	}
+	for i, elem := range batch[2:] {
+		if elem.Error == nil {
+			t.Errorf("call %d: expected error", i+2)
+		}
+	}
+}
@@ -18,6 +18,7 @@ package rpc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"runtime"
@@ -46,6 +47,7 @@ const (
 func NewServer() *Server {
 	server := &Server{
 		services: make(serviceRegistry),
+		access:   newAccessControl(AccessPolicy{}),
 		codecs:   set.New(),
 		run:      1,
 	}
@@ -175,18 +177,17 @@ func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot
 		// check if server is ordered to shutdown and return an error
 		// telling the client that his request failed.
 		if atomic.LoadInt32(&s.run) != 1 {
-			err = &shutdownError{}
-			if batch {
-				resps := make([]interface{}, len(reqs))
-				for i, r := range reqs {
-					resps[i] = codec.CreateErrorResponse(&r.id, err)
-				}
-				codec.Write(resps)
-			} else {
-				codec.Write(codec.CreateErrorResponse(&reqs[0].id, err))
-			}
+			s.rejectRequest(codec, reqs, batch, &shutdownError{})
 			return nil
 		}
+		// reject the request if the client exceeded its limits
+		if err := s.access.admit(ctx, reqs, batch); err != nil {
+			s.rejectRequest(codec, reqs, batch, err)
+			if singleShot {
+				return nil
+			}
+			continue
+		}
 		// If a single shot request is executing, run and return immediately
 		if singleShot {
 			if batch {
@@ -211,6 +212,19 @@ func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot
 	return nil
 }
 
+// rejectRequest answers every call of the given (batch) request with err.
+func (s *Server) rejectRequest(codec ServerCodec, reqs []*serverRequest, batch bool, err Error) {
+	if batch {
+		resps := make([]interface{}, len(reqs))
+		for i, r := range reqs {
+			resps[i] = codec.CreateErrorResponse(&r.id, err)
+		}
+		codec.Write(resps)
+	} else {
+		codec.Write(codec.CreateErrorResponse(&reqs[0].id, err))
+	}
+}
+
 // ServeCodec reads incoming requests from codec, calls the appropriate callback and writes the
 // response back using the given codec. It will block until the codec is closed or the server is
 // stopped. In either case the codec is closed.
@@ -282,6 +296,7 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		if err != nil {
 			return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil
 		}
+		req.subid = subid
 
 		// active the subscription after the sub id was successfully sent to the client
 		activateSub := func() {
@@ -345,21 +360,52 @@ func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest
 }
 
 // execBatch executes the given requests and writes the result back using the codec.
-// It will only write the response back when the last request is processed.
+// It will only write the response back when the last request is processed. The
+// calls of the concurrent methods of the access policy are executed concurrently
+// with each other, the other requests one at a time in order. No more requests
+// are executed once the responses use up the maximum batch response size.
 func (s *Server) execBatch(ctx context.Context, codec ServerCodec, requests []*serverRequest) {
-	responses := make([]interface{}, len(requests))
-	var callbacks []func()
+	var (
+		responses = make([]interface{}, len(requests))
+		callbacks = make([]func(), len(requests))
+		limit     = &batchLimit{max: s.access.policy.MaxBatchResponseSize}
+	)
+	exec := func(i int, req *serverRequest) {
+		if limit.exhausted() {
+			responses[i] = codec.CreateErrorResponse(&req.id, &limitExceededError{"batch response too large"})
+			return
+		}
+		response, callback := s.handle(ctx, codec, req)
+		if responses[i] = limit.add(response); responses[i] == nil {
+			responses[i] = codec.CreateErrorResponse(&req.id, &limitExceededError{"batch response too large"})
+			s.dropSubscription(ctx, req)
+			return
+		}
+		callbacks[i] = callback
+	}
+	var (
+		pend  sync.WaitGroup
+		slots = make(chan struct{}, s.access.policy.BatchWorkers)
+	)
 	for i, req := range requests {
-		if req.err != nil {
-			responses[i] = codec.CreateErrorResponse(&req.id, req.err)
-		} else {
-			var callback func()
-			if responses[i], callback = s.handle(ctx, codec, req); callback != nil {
-				callbacks = append(callbacks, callback)
-			}
+		if !req.concurrent || req.err != nil {
+			// Wait for the concurrent calls preceding the request
+			pend.Wait()
+			exec(i, req)
+			continue
 		}
+		pend.Add(1)
+		slots <- struct{}{}
+		go func(i int, req *serverRequest) {
+			defer func() { <-slots; pend.Done() }()
+			exec(i, req)
+		}(i, req)
 	}
+	pend.Wait()
 
+	if limit.exceeded() {
+		rejectedSizeMeter.Mark(1)
+	}
 	if err := codec.Write(responses); err != nil {
 		log.Error(fmt.Sprintf("%v\n", err))
 		codec.Close()
@@ -367,7 +413,72 @@ func (s *Server) execBatch(ctx context.Context, codec ServerCodec, requests []*s
 
 	// when request holds one of more subscribe requests this allows these subscriptions to be activated
 	for _, c := range callbacks {
-		c()
+		if c != nil {
+			c()
+		}
+	}
+}
+
+// batchLimit accounts the size of the responses to a batch request against the
+// maximum batch response size of the access policy.
+type batchLimit struct {
+	max  int // Maximum total size of the responses, zero if unlimited
+	size int // Total size of the responses accepted so far
+	over bool
+
+	lock sync.Mutex
+}
+
+// add checks a response against the size left to the responses, returning it
+// in its JSON encoding for the codec to write as it is if it fits, or nil if
+// it exceeds the limit.
+func (l *batchLimit) add(response interface{}) interface{} {
+	if l.max <= 0 {
+		return response
+	}
+	enc, err := json.Marshal(response)
+	if err != nil {
+		return response // Let the codec report the encoding failure
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.over || len(enc) > l.max-l.size {
+		l.over = true
+		return nil
+	}
+	l.size += len(enc)
+	return json.RawMessage(enc)
+}
+
+// exhausted reports whether no more responses fit in the limit, the remaining
+// requests are then not executed.
+func (l *batchLimit) exhausted() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.max > 0 && l.size >= l.max {
+		l.over = true
+	}
+	return l.over
+}
+
+// exceeded reports whether a request was rejected for exceeding the limit.
+func (l *batchLimit) exceeded() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.over
+}
+
+// dropSubscription cancels the subscription created by a request whose response
+// was not sent, the client never learning its id.
+func (s *Server) dropSubscription(ctx context.Context, req *serverRequest) {
+	if req.subid == "" {
+		return
+	}
+	if notifier, supported := NotifierFromContext(ctx); supported {
+		notifier.unsubscribe(req.subid)
 	}
 }
 
@@ -408,6 +519,11 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 			continue
 		}
 
+		if !s.access.methodAllowed(r.service, r.method) { // rpc method excluded by policy
+			requests[i] = &serverRequest{id: r.id, err: &methodNotAllowedError{r.service, r.method}}
+			continue
+		}
+
 		if r.isPubSub { // core_subscribe, r.method contains the subscription method name
 			if callb, ok := svc.subscriptions[r.method]; ok {
 				requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
@@ -427,7 +543,7 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 		}
 
 		if callb, ok := svc.callbacks[r.method]; ok { // lookup RPC method
-			requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+			requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb, concurrent: s.access.methodConcurrent(r.service, r.method)}
 			if r.params != nil && len(callb.argTypes) > 0 {
 				if args, err := codec.ParseRequestArguments(callb.argTypes, r.params); err == nil {
 					requests[i].args = args
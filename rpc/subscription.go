@@ -117,6 +117,11 @@ func (n *Notifier) unsubscribe(id ID) error {
 		delete(n.active, id)
 		return nil
 	}
+	if s, found := n.inactive[id]; found {
+		close(s.err)
+		delete(n.inactive, id)
+		return nil
+	}
 	return ErrSubscriptionNotFound
 }
 
@@ -328,3 +328,20 @@ func TestSubscriptionMultipleNamespaces(t *testing.T) {
 		}
 	}
 }
+
+func TestUnsubscribeInactive(t *testing.T) {
+	notifier := newNotifier(nil)
+	sub := notifier.CreateSubscription()
+
+	if err := notifier.unsubscribe(sub.ID); err != nil {
+		t.Fatalf("failed to unsubscribe inactive subscription: %v", err)
+	}
+	select {
+	case <-sub.Err():
+	default:
+		t.Fatal("subscription not closed")
+	}
+	if err := notifier.unsubscribe(sub.ID); err != ErrSubscriptionNotFound {
+		t.Fatalf("unexpected error on second unsubscribe: %v", err)
+	}
+}
@@ -61,6 +61,8 @@ type serverRequest struct {
 	callb         *callback
 	args          []reflect.Value
 	isUnsubscribe bool
+	subid         ID   // Subscription created by the request, if any
+	concurrent    bool // Whether the call may run concurrently within a batch
 	err           Error
 }
 
@@ -71,6 +73,7 @@ type subscriptions map[string]*callback  // collection of subscription callbacks
 // Server represents a RPC server
 type Server struct {
 	services serviceRegistry
+	access   *accessControl
 
 	run      int32
 	codecsMu sync.Mutex
@@ -21,6 +21,7 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -57,10 +58,10 @@ var websocketJSONCodec = websocket.Codec{
 // To allow connections with any origin, pass "*".
 func (srv *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 	return websocket.Server{
-		Handshake: wsHandshakeValidator(allowedOrigins),
+		Handshake: wsHandshakeValidator(allowedOrigins, srv.access),
 		Handler: func(conn *websocket.Conn) {
 			// Create a custom encode/decode pair to enforce payload size and number encoding
-			conn.MaxPayloadBytes = maxRequestContentLength
+			conn.MaxPayloadBytes = int(srv.access.maxRequestSize())
 
 			encoder := func(v interface{}) error {
 				return websocketJSONCodec.Send(conn, v)
@@ -68,7 +69,13 @@ func (srv *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 			decoder := func(v interface{}) error {
 				return websocketJSONCodec.Receive(conn, v)
 			}
-			srv.ServeCodec(NewCodec(conn, encoder, decoder), OptionMethodInvocation|OptionSubscriptions)
+			// Tag the connection with the client identity for the rate limiters
+			ctx := context.WithValue(context.Background(), "remote", conn.Request().RemoteAddr)
+			ctx = context.WithValue(ctx, "apikey", conn.Request().Header.Get(apiKeyHeader))
+
+			codec := NewCodec(conn, encoder, decoder)
+			defer codec.Close()
+			srv.serveRequest(ctx, codec, false, OptionMethodInvocation|OptionSubscriptions)
 		},
 	}
 }
@@ -82,8 +89,9 @@ func NewWSServer(allowedOrigins []string, srv *Server) *http.Server {
 
 // wsHandshakeValidator returns a handler that verifies the origin during the
 // websocket upgrade process. When a '*' is specified as an allowed origins all
-// connections are accepted.
-func wsHandshakeValidator(allowedOrigins []string) func(*websocket.Config, *http.Request) error {
+// connections are accepted. Connections without a valid API key are refused if
+// the access policy requires one.
+func wsHandshakeValidator(allowedOrigins []string, access *accessControl) func(*websocket.Config, *http.Request) error {
 	origins := set.New()
 	allowAllOrigins := false
 
@@ -107,6 +115,10 @@ func wsHandshakeValidator(allowedOrigins []string) func(*websocket.Config, *http
 	log.Debug(fmt.Sprintf("Allowed origin(s) for WS RPC interface %v\n", origins.List()))
 
 	f := func(cfg *websocket.Config, req *http.Request) error {
+		if !access.checkKey(req.Header.Get(apiKeyHeader)) {
+			log.Warn("Invalid api key on WS-RPC interface", "remote", req.RemoteAddr)
+			return errors.New("invalid api key")
+		}
 		origin := strings.ToLower(req.Header.Get("Origin"))
 		if allowAllOrigins || origins.Has(origin) {
 			return nil
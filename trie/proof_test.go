@@ -20,6 +20,7 @@ import (
 	"bytes"
 	crand "crypto/rand"
 	mrand "math/rand"
+	"sort"
 	"testing"
 	"time"
 
@@ -154,6 +155,197 @@ func mutateByte(b []byte) {
 	}
 }
 
+type entrySlice []*kv
+
+func (p entrySlice) Len() int           { return len(p) }
+func (p entrySlice) Less(i, j int) bool { return bytes.Compare(p[i].k, p[j].k) < 0 }
+func (p entrySlice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// sortedEntries returns the entries of a random trie in key order.
+func sortedEntries(vals map[string]*kv) entrySlice {
+	var entries entrySlice
+	for _, kv := range vals {
+		entries = append(entries, kv)
+	}
+	sort.Sort(entries)
+	return entries
+}
+
+// proveRange returns the edge proofs and the leaves of the given entries.
+func proveRange(t *testing.T, trie *Trie, first, last []byte, entries entrySlice) (*vntdb.MemDatabase, [][]byte, [][]byte) {
+	proof := vntdb.NewMemDatabase()
+	if err := trie.Prove(first, 0, proof); err != nil {
+		t.Fatalf("failed to prove the first node %v", err)
+	}
+	if err := trie.Prove(last, 0, proof); err != nil {
+		t.Fatalf("failed to prove the last node %v", err)
+	}
+	var keys, vals [][]byte
+	for _, entry := range entries {
+		keys = append(keys, entry.k)
+		vals = append(vals, entry.v)
+	}
+	return proof, keys, vals
+}
+
+// increaseKey returns the key following the given one.
+func increaseKey(key []byte) []byte {
+	key = common.CopyBytes(key)
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]++
+		if key[i] != 0x0 {
+			break
+		}
+	}
+	return key
+}
+
+// decreaseKey returns the key preceding the given one.
+func decreaseKey(key []byte) []byte {
+	key = common.CopyBytes(key)
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]--
+		if key[i] != 0xff {
+			break
+		}
+	}
+	return key
+}
+
+// Tests that random ranges of leaves are proven by the proofs of their edges,
+// existing in the trie or not.
+func TestRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+
+	for i := 0; i < 500; i++ {
+		start := mrand.Intn(len(entries))
+		end := mrand.Intn(len(entries)-start) + start + 1
+
+		first, last := entries[start].k, entries[end-1].k
+		if i%2 == 1 && start > 0 && end < len(entries) {
+			// Prove the range with edge keys missing from the trie
+			first, last = decreaseKey(first), increaseKey(last)
+			if bytes.Compare(first, entries[start-1].k) <= 0 || bytes.Compare(last, entries[end].k) >= 0 {
+				continue
+			}
+		}
+		proof, keys, values := proveRange(t, trie, first, last, entries[start:end])
+		more, err := VerifyRangeProof(trie.Hash(), first, last, keys, values, proof)
+		if err != nil {
+			t.Fatalf("case %d(%d->%d): expected no error, got %v", i, start, end-1, err)
+		}
+		if want := end < len(entries); more != want {
+			t.Fatalf("case %d(%d->%d): more elements mismatch: have %v, want %v", i, start, end-1, more, want)
+		}
+	}
+}
+
+// Tests that single leaf ranges are proven, with edges on the leaf or around.
+func TestOneElementRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+	start := 1000
+
+	proof, keys, values := proveRange(t, trie, entries[start].k, entries[start].k, entries[start:start+1])
+	if _, err := VerifyRangeProof(trie.Hash(), keys[0], keys[0], keys, values, proof); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	first, last := decreaseKey(entries[start].k), increaseKey(entries[start].k)
+	proof, keys, values = proveRange(t, trie, first, last, entries[start:start+1])
+	if _, err := VerifyRangeProof(trie.Hash(), first, last, keys, values, proof); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+// Tests that the whole set of leaves is proven with or without edge proofs.
+func TestAllElementsProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+
+	_, keys, values := proveRange(t, trie, entries[0].k, entries[len(entries)-1].k, entries)
+	if _, err := VerifyRangeProof(trie.Hash(), nil, nil, keys, values, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	proof, _, _ := proveRange(t, trie, entries[0].k, entries[len(entries)-1].k, entries)
+	more, err := VerifyRangeProof(trie.Hash(), keys[0], keys[len(keys)-1], keys, values, proof)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if more {
+		t.Fatalf("more elements reported after the last leaf")
+	}
+	// Missing leaves are detected without edge proofs too
+	if _, err := VerifyRangeProof(trie.Hash(), nil, nil, keys[1:], values[1:], nil); err == nil {
+		t.Fatalf("expected error for incomplete leaf set")
+	}
+}
+
+// Tests that an empty range is only proven after the last leaf.
+func TestEmptyRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+
+	last := increaseKey(entries[len(entries)-1].k)
+	proof := vntdb.NewMemDatabase()
+	trie.Prove(last, 0, proof)
+	if _, err := VerifyRangeProof(trie.Hash(), last, nil, nil, nil, proof); err != nil {
+		t.Fatalf("expected no error after the last leaf, got %v", err)
+	}
+	inner := increaseKey(entries[2000].k)
+	proof = vntdb.NewMemDatabase()
+	trie.Prove(inner, 0, proof)
+	if _, err := VerifyRangeProof(trie.Hash(), inner, nil, nil, nil, proof); err == nil {
+		t.Fatalf("expected error for empty range before existing leaves")
+	}
+}
+
+// Tests that tampered ranges are rejected.
+func TestBadRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+
+	for i := 0; i < 500; i++ {
+		start := mrand.Intn(len(entries))
+		end := mrand.Intn(len(entries)-start) + start + 1
+		if end-start < 3 {
+			continue
+		}
+		proof, keys, values := proveRange(t, trie, entries[start].k, entries[end-1].k, entries[start:end])
+		first, last := keys[0], keys[len(keys)-1]
+
+		index := mrand.Intn(end - start)
+		switch mrand.Intn(4) {
+		case 0:
+			// Modified value
+			values[index] = randBytes(20)
+		case 1:
+			// Inner leaf dropped
+			index = 1 + mrand.Intn(end-start-2)
+			keys = append(keys[:index:index], keys[index+1:]...)
+			values = append(values[:index:index], values[index+1:]...)
+		case 2:
+			// Extra leaf
+			key := increaseKey(keys[index])
+			if index < len(keys)-1 && bytes.Equal(key, keys[index+1]) || index == len(keys)-1 {
+				continue
+			}
+			keys = append(keys[:index+1:index+1], append([][]byte{key}, keys[index+1:]...)...)
+			values = append(values[:index+1:index+1], append([][]byte{randBytes(20)}, values[index+1:]...)...)
+		case 3:
+			// Non-sorted leaves
+			if index == len(keys)-1 {
+				continue
+			}
+			keys[index], keys[index+1] = keys[index+1], keys[index]
+			values[index], values[index+1] = values[index+1], values[index]
+		}
+		if _, err := VerifyRangeProof(trie.Hash(), first, last, keys, values, proof); err == nil {
+			t.Fatalf("case %d(%d->%d): expected error for tampered range", i, start, end-1)
+		}
+	}
+}
+
 func BenchmarkProve(b *testing.B) {
 	trie, vals := randomTrie(100)
 	var keys []string
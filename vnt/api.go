@@ -380,6 +380,83 @@ func storageRangeAt(st state.Trie, start []byte, maxResult int) (StorageRangeRes
 	return result, nil
 }
 
+// AccountDiffResult is the change of an account by a block, as returned by a
+// debug_getStateDiff API call. The fields of the unchanged values are omitted.
+type AccountDiffResult struct {
+	Address common.Address                    `json:"address"`
+	Balance *BalanceDiffResult                `json:"balance,omitempty"`
+	Nonce   *NonceDiffResult                  `json:"nonce,omitempty"`
+	Code    *CodeDiffResult                   `json:"code,omitempty"`
+	Storage map[common.Hash]StorageDiffResult `json:"storage,omitempty"`
+}
+
+// BalanceDiffResult is the change of the balance of an account.
+type BalanceDiffResult struct {
+	From *hexutil.Big `json:"from"`
+	To   *hexutil.Big `json:"to"`
+}
+
+// NonceDiffResult is the change of the nonce of an account.
+type NonceDiffResult struct {
+	From hexutil.Uint64 `json:"from"`
+	To   hexutil.Uint64 `json:"to"`
+}
+
+// CodeDiffResult is the change of the code of an account.
+type CodeDiffResult struct {
+	From hexutil.Bytes `json:"from"`
+	To   hexutil.Bytes `json:"to"`
+}
+
+// StorageDiffResult is the change of a storage slot of an account.
+type StorageDiffResult struct {
+	From common.Hash `json:"from"`
+	To   common.Hash `json:"to"`
+}
+
+// GetStateDiff returns the accounts changed by the given block, with their values
+// before and after it. The changes are only known for the blocks imported with
+// the state diffs recording enabled.
+func (api *PrivateDebugAPI) GetStateDiff(ctx context.Context, blockNr rpc.BlockNumber) ([]*AccountDiffResult, error) {
+	var block *types.Block
+	switch blockNr {
+	case rpc.PendingBlockNumber:
+		return nil, errors.New("state diff of the pending block not available")
+	case rpc.LatestBlockNumber:
+		block = api.vnt.blockchain.CurrentBlock()
+	default:
+		block = api.vnt.blockchain.GetBlockByNumber(uint64(blockNr))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", blockNr)
+	}
+	diff := rawdb.ReadStateDiff(api.vnt.ChainDb(), block.Hash(), block.NumberU64())
+	if diff == nil {
+		return nil, fmt.Errorf("state diff of block #%d not recorded", block.NumberU64())
+	}
+	results := make([]*AccountDiffResult, len(diff))
+	for i, account := range diff {
+		result := &AccountDiffResult{Address: account.Address}
+		if account.Balance != nil {
+			result.Balance = &BalanceDiffResult{From: (*hexutil.Big)(account.Balance.From), To: (*hexutil.Big)(account.Balance.To)}
+		}
+		if account.Nonce != nil {
+			result.Nonce = &NonceDiffResult{From: hexutil.Uint64(account.Nonce.From), To: hexutil.Uint64(account.Nonce.To)}
+		}
+		if account.Code != nil {
+			result.Code = &CodeDiffResult{From: account.Code.From, To: account.Code.To}
+		}
+		if len(account.Storage) > 0 {
+			result.Storage = make(map[common.Hash]StorageDiffResult, len(account.Storage))
+			for _, slot := range account.Storage {
+				result.Storage[slot.Key] = StorageDiffResult{From: slot.From, To: slot.To}
+			}
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
 // GetModifiedAccountsByumber returns all accounts that have changed between the
 // two blocks specified. A change is defined as a difference in nonce, balance,
 // code hash, or storage hash.
@@ -189,6 +189,14 @@ func (b *VntAPIBackend) TxPoolContent() (map[common.Address]types.Transactions,
 	return b.vnt.TxPool().Content()
 }
 
+func (b *VntAPIBackend) TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	return b.vnt.TxPool().ContentFrom(addr)
+}
+
+func (b *VntAPIBackend) TxPoolExplain(hash common.Hash) *core.TxExplanation {
+	return b.vnt.TxPool().Explain(hash)
+}
+
 func (b *VntAPIBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
 	return b.vnt.TxPool().SubscribeNewTxsEvent(ch)
 }
@@ -107,7 +107,7 @@ func New(ctx *node.ServiceContext, config *Config, node *node.Node) (*VNT, error
 	if !config.SyncMode.IsValid() {
 		return nil, fmt.Errorf("invalid sync mode %d", config.SyncMode)
 	}
-	chainDb, err := CreateDB(ctx, config, "chaindata")
+	chainDb, err := createChainDB(ctx, config)
 	if err != nil {
 		return nil, err
 	}
@@ -143,7 +143,7 @@ func New(ctx *node.ServiceContext, config *Config, node *node.Node) (*VNT, error
 	}
 	var (
 		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
-		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout}
+		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout, Snapshot: !config.NoSnapshot, HistoryRetention: config.HistoryRetention, StateDiffs: config.StateDiffs}
 	)
 	vnt.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, vnt.chainConfig, vnt.engine, vmConfig)
 	if err != nil {
@@ -165,6 +165,15 @@ func New(ctx *node.ServiceContext, config *Config, node *node.Node) (*VNT, error
 	if vnt.protocolManager, err = NewProtocolManager(vnt.chainConfig, config.SyncMode, config.NetworkId, vnt.eventMux, vnt.txPool, vnt.engine, vnt.blockchain, chainDb, node); err != nil {
 		return nil, err
 	}
+	if config.Gossip {
+		vnt.protocolManager.enableGossip(vnt.engine)
+	}
+	if !config.NoSnapshot {
+		vnt.protocolManager.enableSnap(chainDb)
+	}
+	if config.Checkpoint != nil {
+		vnt.protocolManager.enableCheckpoint(config.Checkpoint, vnt.engine)
+	}
 	vnt.producer = producer.New(vnt, vnt.chainConfig, vnt.EventMux(), vnt.engine)
 	vnt.producer.SetExtra(makeExtraData(config.ExtraData))
 
@@ -208,6 +217,19 @@ func CreateDB(ctx *node.ServiceContext, config *Config, name string) (vntdb.Data
 	return db, nil
 }
 
+// createChainDB creates the chain database, moving its immutable segments into
+// the freezer.
+func createChainDB(ctx *node.ServiceContext, config *Config) (vntdb.Database, error) {
+	db, err := ctx.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer)
+	if err != nil {
+		return nil, err
+	}
+	if db, ok := rawdb.KeyValueStore(db).(*vntdb.LDBDatabase); ok {
+		db.Meter("vnt/db/chaindata/")
+	}
+	return db, nil
+}
+
 // CreateConsensusEngine creates the required type of consensus engine instance for an vnt service
 func CreateConsensusEngine(ctx *node.ServiceContext, chainConfig *params.ChainConfig, db vntdb.Database) consensus.Engine {
 	cfg := chainConfig.Dpos
@@ -385,6 +407,8 @@ func (s *VNT) Start(srvr *vntp2p.Server) error {
 		maxPeers -= s.config.LightPeers
 	}
 	// Start the networking layer and the light server if requested
+	srvr.SetChainID(vntp2p.ChainID{NetworkID: s.networkId, Genesis: s.blockchain.Genesis().Hash()})
+	srvr.SetWitnessHandler(s.protocolManager.handleWitnessMsg)
 	s.protocolManager.Start(maxPeers)
 	if s.lesServer != nil {
 		s.lesServer.Start(srvr)
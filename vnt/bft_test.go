@@ -0,0 +1,115 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package vnt
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	libp2p "github.com/libp2p/go-libp2p-peer"
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/consensus/dpos"
+	"github.com/vntchain/go-vnt/consensus/mock"
+	"github.com/vntchain/go-vnt/core"
+	"github.com/vntchain/go-vnt/core/types"
+	"github.com/vntchain/go-vnt/core/vm"
+	"github.com/vntchain/go-vnt/crypto"
+	"github.com/vntchain/go-vnt/event"
+	"github.com/vntchain/go-vnt/params"
+	"github.com/vntchain/go-vnt/rlp"
+	"github.com/vntchain/go-vnt/vntdb"
+	"github.com/vntchain/go-vnt/vntp2p"
+	"github.com/vntchain/go-vnt/vntp2p/gossip"
+)
+
+// Tests that the bft messages of a witness joining the witness set at the block
+// after the head reach the consensus engine, without being relayed, while the
+// messages of a sender which is not a witness of the head are rejected.
+func TestHandleBftMsgWitnessRotation(t *testing.T) {
+	var (
+		oldKey, _ = crypto.GenerateKey()
+		newKey, _ = crypto.GenerateKey()
+		oldWit    = crypto.PubkeyToAddress(oldKey.PublicKey)
+		newWit    = crypto.PubkeyToAddress(newKey.PublicKey)
+		db        = vntdb.NewMemDatabase()
+		gspec     = &core.Genesis{Config: params.TestChainConfig, Witnesses: []common.Address{oldWit}}
+		genesis   = gspec.MustCommit(db)
+	)
+	// The witness set rotates with the block after the head
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, mock.NewMock(), db, 1, func(i int, gen *core.BlockGen) {
+		gen.SetWitnesses([]common.Address{oldWit})
+	})
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, mock.NewMock(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import blocks: %v", err)
+	}
+	pm := &ProtocolManager{
+		eventMux:     new(event.TypeMux),
+		blockchain:   chain,
+		bftValidator: dpos.New(&params.DposConfig{WitnessesNum: 1, Period: 2}, nil),
+	}
+	sub := pm.eventMux.Subscribe(core.RecBftMsgEvent{})
+	defer sub.Unsubscribe()
+
+	prepare := func(number int64) *types.PrepareMsg {
+		msg := &types.PrepareMsg{PrepareAddr: newWit, BlockNumber: big.NewInt(number), BlockHash: common.Hash{1}}
+		sig, err := crypto.Sign(msg.Hash().Bytes(), newKey)
+		if err != nil {
+			t.Fatalf("failed to sign prepare msg: %v", err)
+		}
+		msg.PrepareSig = sig
+		return msg
+	}
+	received := func(want *types.PrepareMsg) {
+		select {
+		case ev := <-sub.Chan():
+			if have := ev.Data.(core.RecBftMsgEvent).BftMsg.Msg; have.Hash() != want.Hash() {
+				t.Fatalf("received msg mismatch: have %v, want %v", have.Hash(), want.Hash())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("msg of height %d not received", want.BlockNumber)
+		}
+	}
+	// The new witness can't send messages for the head
+	var reports []vntp2p.Misbehaviour
+	pm.handleBftMsg(libp2p.ID("peer"), func(m vntp2p.Misbehaviour) { reports = append(reports, m) }, prepare(1))
+	if len(reports) != 1 || reports[0] != vntp2p.InvalidBftMsg {
+		t.Fatalf("invalid msg reports mismatch: have %v", reports)
+	}
+	// The messages of the next block are handed to the engine
+	next := prepare(2)
+	go pm.handleBftMsg(libp2p.ID("peer"), func(m vntp2p.Misbehaviour) { t.Errorf("unexpected report: %v", m) }, next)
+	received(next)
+
+	// The gossiped ones too, but they are not relayed
+	data, err := rlp.EncodeToBytes(next)
+	if err != nil {
+		t.Fatalf("failed to encode prepare msg: %v", err)
+	}
+	validate := pm.bftGossipValidator(pm.bftValidator, func() types.ConsensusMsg { return new(types.PrepareMsg) })
+	results := make(chan gossip.ValidationResult, 1)
+	go func() { results <- validate(libp2p.ID("peer"), data) }()
+	received(next)
+	if result := <-results; result != gossip.Ignore {
+		t.Errorf("gossip validation result mismatch: have %v, want %v", result, gossip.Ignore)
+	}
+}
@@ -142,3 +142,26 @@ func (b *BloomIndexer) Commit() error {
 	}
 	return batch.Write()
 }
+
+// RewindBloomBits discards the bloombits sections of a database which aren't
+// entirely below the given head block, for an offline chain rewind. The node
+// mustn't be running.
+func RewindBloomBits(db vntdb.Database, head uint64) (int, error) {
+	indexer := NewBloomIndexer(db, params.BloomBitsBlocks)
+	defer indexer.Close()
+
+	heads := indexer.Rewind(head)
+	batch := db.NewBatch()
+	for section, shead := range heads {
+		for i := 0; i < types.BloomBitLength; i++ {
+			rawdb.DeleteBloomBits(batch, uint(i), section, shead)
+		}
+		if batch.ValueSize() > vntdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return 0, err
+			}
+			batch.Reset()
+		}
+	}
+	return len(heads), batch.Write()
+}
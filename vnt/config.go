@@ -68,6 +68,26 @@ type Config struct {
 	SyncMode  downloader.SyncMode
 	NoPruning bool
 
+	// Checkpoint is a trusted finalized block to fast sync from instead of the
+	// genesis
+	Checkpoint *downloader.Checkpoint `toml:",omitempty"`
+
+	// NoSnapshot disables the flat state snapshot, along with the snap sync
+	// relying on it
+	NoSnapshot bool `toml:",omitempty"`
+
+	// HistoryRetention is the number of recent blocks whose bodies and receipts
+	// are kept, the older ones are pruned (0 = keep the whole history)
+	HistoryRetention uint64 `toml:",omitempty"`
+
+	// StateDiffs enables the recording of the state changes made by each block,
+	// served by debug_getStateDiff
+	StateDiffs bool `toml:",omitempty"`
+
+	// Gossip enables the propagation of transactions and bft messages through
+	// gossip topics to the peers supporting them
+	Gossip bool `toml:",omitempty"`
+
 	// Light client options
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
@@ -76,6 +96,7 @@ type Config struct {
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
+	DatabaseFreezer    string `toml:",omitempty"` // Directory of the ancient chain segments, inside the chain database by default
 	TrieCache          int
 	TrieTimeout        time.Duration
 
@@ -0,0 +1,96 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/core/types"
+	"github.com/vntchain/go-vnt/log"
+)
+
+// Checkpoint is a trusted block, made final by the commit messages of the
+// witnesses, from which a fresh fast sync starts instead of the genesis. The
+// history before it is not retrieved.
+type Checkpoint struct {
+	Number uint64
+	Hash   common.Hash
+}
+
+// ParseCheckpoint parses a checkpoint in the <number>:<hash> format.
+func ParseCheckpoint(s string) (*Checkpoint, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid checkpoint %q, want <number>:<hash>", s)
+	}
+	number, err := strconv.ParseUint(parts[0], 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checkpoint number %q: %v", parts[0], err)
+	}
+	var hash common.Hash
+	if err := hash.UnmarshalText([]byte(parts[1])); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint hash %q: %v", parts[1], err)
+	}
+	return &Checkpoint{Number: number, Hash: hash}, nil
+}
+
+func (cp *Checkpoint) String() string {
+	return fmt.Sprintf("%d:%s", cp.Number, cp.Hash.Hex())
+}
+
+// SetCheckpoint makes fast sync start from a trusted checkpoint when the local
+// chain is still below it. The checkpoint header is only accepted if verify
+// approves its commit messages. It must be set before any sync starts.
+func (d *Downloader) SetCheckpoint(cp *Checkpoint, verify func(*types.Header) error) {
+	d.checkpoint, d.verifyCheckpoint = cp, verify
+}
+
+// syncCheckpoint retrieves the checkpoint header from the peer, verifies its
+// finality and anchors the local chain at it.
+func (d *Downloader) syncCheckpoint(p *peerConnection) error {
+	cp := d.checkpoint
+	p.log.Debug("Retrieving checkpoint header", "number", cp.Number, "hash", cp.Hash)
+
+	header, err := d.fetchHeader(p, cp.Hash)
+	if err != nil {
+		return err
+	}
+	if header == nil {
+		return errCheckpointUnavailable
+	}
+	if header.Hash() != cp.Hash {
+		p.log.Debug("Checkpoint header hash mismatch", "have", header.Hash(), "want", cp.Hash)
+		return errBadPeer
+	}
+	if number := header.Number.Uint64(); number != cp.Number {
+		return fmt.Errorf("checkpoint number mismatch: have %d, want %d", number, cp.Number)
+	}
+	if d.verifyCheckpoint != nil {
+		if err := d.verifyCheckpoint(header); err != nil {
+			p.log.Warn("Checkpoint commit messages invalid", "number", cp.Number, "hash", cp.Hash, "err", err)
+			return errInvalidCheckpoint
+		}
+	}
+	if err := d.blockchain.InsertCheckpoint(header); err != nil {
+		return err
+	}
+	log.Info("Fast syncing from checkpoint", "number", cp.Number, "hash", cp.Hash)
+	return nil
+}
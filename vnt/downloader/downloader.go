@@ -34,6 +34,7 @@ import (
 	"github.com/vntchain/go-vnt/metrics"
 	"github.com/vntchain/go-vnt/params"
 	"github.com/vntchain/go-vnt/vntdb"
+	"github.com/vntchain/go-vnt/vntp2p"
 
 	libp2p "github.com/libp2p/go-libp2p-peer"
 )
@@ -91,6 +92,8 @@ var (
 	errCancelHeaderProcessing  = errors.New("header processing canceled (requested)")
 	errCancelContentProcessing = errors.New("content processing canceled (requested)")
 	errNoSyncActive            = errors.New("no sync active")
+	errInvalidCheckpoint       = errors.New("retrieved checkpoint is invalid")
+	errCheckpointUnavailable   = errors.New("checkpoint unavailable from peer")
 	errTooOld                  = errors.New("peer doesn't speak recent enough protocol version (need version >= 62)")
 )
 
@@ -113,6 +116,10 @@ type Downloader struct {
 
 	lightchain LightChain
 	blockchain BlockChain
+	snapSyncer SnapSyncer // Optional syncer retrieving the state ahead of the trie node sync
+
+	checkpoint       *Checkpoint               // Optional trusted block to start fast sync from
+	verifyCheckpoint func(*types.Header) error // Verifier of the finality of the checkpoint header
 
 	// Callbacks
 	dropPeer peerDropFn // Drops a peer for misbehaving
@@ -197,6 +204,17 @@ type BlockChain interface {
 
 	// InsertReceiptChain inserts a batch of receipts into the local chain.
 	InsertReceiptChain(types.Blocks, []types.Receipts) (int, error)
+
+	// InsertCheckpoint anchors the local chain at a trusted header, without
+	// the history before it.
+	InsertCheckpoint(*types.Header) error
+}
+
+// SnapSyncer retrieves a state in bulk during fast sync. Whatever it leaves
+// missing, the trie node sync retrieves afterwards.
+type SnapSyncer interface {
+	// Sync retrieves the state of the given root until complete or cancelled.
+	Sync(root common.Hash, cancel chan struct{}) error
 }
 
 // New creates a new downloader to fetch hashes and blocks from remote peers.
@@ -235,6 +253,13 @@ func New(mode SyncMode, stateDb vntdb.Database, mux *event.TypeMux, chain BlockC
 	return dl
 }
 
+// SetSnapSyncer sets the syncer retrieving the state in bulk during fast sync,
+// before the remaining trie nodes are retrieved one by one. It must be set
+// before any sync starts.
+func (d *Downloader) SetSnapSyncer(syncer SnapSyncer) {
+	d.snapSyncer = syncer
+}
+
 // Progress retrieves the synchronisation boundaries, specifically the origin
 // block where synchronisation started at (may have failed/suspended); the block
 // or header sync is currently at; and the latest known block which the sync targets.
@@ -316,6 +341,10 @@ func (d *Downloader) UnregisterPeer(id libp2p.ID) error {
 // Synchronise tries to sync up our local block chain with a remote peer, both
 // adding various sanity checks as well as wrapping it with various log entries.
 func (d *Downloader) Synchronise(id libp2p.ID, head common.Hash, td *big.Int, mode SyncMode) error {
+	// Retrieve the reporter of the peer ahead, as the failing peer may be
+	// dropped during the synchronisation
+	reporter := d.reporter(id)
+
 	err := d.synchronise(id, head, td, mode)
 	switch err {
 	case nil:
@@ -323,8 +352,11 @@ func (d *Downloader) Synchronise(id libp2p.ID, head common.Hash, td *big.Int, mo
 
 	case errTimeout, errBadPeer, errStallingPeer,
 		errEmptyHeaderSet, errPeersUnavailable, errTooOld,
-		errInvalidAncestor, errInvalidChain:
+		errInvalidAncestor, errInvalidChain, errInvalidCheckpoint:
 		log.Warn("Synchronisation failed, dropping peer", "peer", id, "err", err)
+		if reporter != nil {
+			reporter.Report(syncMisbehaviour(err))
+		}
 		if d.dropPeer == nil {
 			// The dropPeer method is nil when `--copydb` is used for a local copy.
 			// Timeouts can occur if e.g. compaction hits at the wrong time, and can be ignored
@@ -338,6 +370,38 @@ func (d *Downloader) Synchronise(id libp2p.ID, head common.Hash, td *big.Int, mo
 	return err
 }
 
+// syncMisbehaviour classifies the misbehaviour of a peer a synchronisation
+// failed with.
+func syncMisbehaviour(err error) vntp2p.Misbehaviour {
+	switch err {
+	case errTimeout, errStallingPeer:
+		return vntp2p.RequestTimeout
+	case errBadPeer, errInvalidAncestor, errInvalidChain, errInvalidCheckpoint:
+		return vntp2p.InvalidBlock
+	default:
+		return vntp2p.UselessResponse
+	}
+}
+
+// reportPeer reports the misbehaviour of a peer to the networking layer, if its
+// connection supports it.
+func (d *Downloader) reportPeer(id libp2p.ID, m vntp2p.Misbehaviour) {
+	if reporter := d.reporter(id); reporter != nil {
+		reporter.Report(m)
+	}
+}
+
+// reporter returns the connection of a registered peer if it supports the
+// reports of misbehaviours, nil otherwise.
+func (d *Downloader) reporter(id libp2p.ID) peerReporter {
+	if p := d.peers.Peer(id); p != nil {
+		if reporter, ok := p.peer.(peerReporter); ok {
+			return reporter
+		}
+	}
+	return nil
+}
+
 // synchronise will select the peer and use it for synchronising. If an empty string is given
 // it will use the best peer possible and synchronize if its TD is higher than our own. If any of the
 // checks fail an error will be returned. This method is synchronous
@@ -433,6 +497,14 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 	if err != nil {
 		return err
 	}
+	// Start a fresh fast sync from the trusted checkpoint, if old enough to
+	// stay below the pivot
+	if cp := d.checkpoint; cp != nil && d.mode == FastSync && origin < cp.Number && cp.Number+uint64(fsMinFullBlocks) < height {
+		if err := d.syncCheckpoint(p); err != nil {
+			return err
+		}
+		origin = cp.Number
+	}
 	d.syncStatsLock.Lock()
 	if d.syncStatsChainHeight <= origin || d.syncStatsChainOrigin > origin {
 		d.syncStatsChainOrigin = origin
@@ -550,7 +622,22 @@ func (d *Downloader) fetchHeight(p *peerConnection) (*types.Header, error) {
 
 	// Request the advertised remote head block and wait for the response
 	head, _ := p.peer.Head()
-	go p.peer.RequestHeadersByHash(head, 1, 0, false)
+	header, err := d.fetchHeader(p, head)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		p.log.Debug("No header for head request")
+		return nil, errBadPeer
+	}
+	p.log.Debug("Remote head header identified", "number", header.Number, "hash", header.Hash())
+	return header, nil
+}
+
+// fetchHeader retrieves a header by hash from the remote peer, nil if the peer
+// doesn't know it.
+func (d *Downloader) fetchHeader(p *peerConnection, hash common.Hash) (*types.Header, error) {
+	go p.peer.RequestHeadersByHash(hash, 1, 0, false)
 
 	ttl := d.requestTTL()
 	timeout := time.After(ttl)
@@ -567,16 +654,17 @@ func (d *Downloader) fetchHeight(p *peerConnection) (*types.Header, error) {
 			}
 			// Make sure the peer actually gave something valid
 			headers := packet.(*headerPack).headers
-			if len(headers) != 1 {
+			if len(headers) > 1 {
 				p.log.Debug("Multiple headers for single request", "headers", len(headers))
 				return nil, errBadPeer
 			}
-			head := headers[0]
-			p.log.Debug("Remote head header identified", "number", head.Number, "hash", head.Hash())
-			return head, nil
+			if len(headers) == 0 {
+				return nil, nil
+			}
+			return headers[0], nil
 
 		case <-timeout:
-			p.log.Debug("Waiting for head header timed out", "elapsed", ttl)
+			p.log.Debug("Waiting for header timed out", "hash", hash, "elapsed", ttl)
 			return nil, errTimeout
 
 		case <-d.bodyCh:
@@ -865,7 +953,8 @@ func (d *Downloader) fetchHeaders(p *peerConnection, from uint64, pivot uint64)
 				p.log.Warn("Downloader wants to drop peer, but peerdrop-function is not set", "peer", p.id)
 				break
 			}
-			// Header retrieval timed out, consider the peer bad and drop
+			// Header retrieval timed out, consider the peer bad and drop. The
+			// timeout is reported with the failure of the synchronisation.
 			p.log.Debug("Header request timed out", "elapsed", ttl)
 			headerTimeoutMeter.Mark(1)
 			d.dropPeer(p.id)
@@ -881,7 +970,7 @@ func (d *Downloader) fetchHeaders(p *peerConnection, from uint64, pivot uint64)
 			case d.headerProcCh <- nil:
 			case <-d.cancelCh:
 			}
-			return errBadPeer
+			return errTimeout
 		}
 	}
 }
@@ -1088,6 +1177,7 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 							// Timeouts can occur if e.g. compaction hits at the wrong time, and can be ignored
 							peer.log.Warn("Downloader wants to drop peer, but peerdrop-function is not set", "peer", pid)
 						} else {
+							d.reportPeer(pid, vntp2p.RequestTimeout)
 							d.dropPeer(pid)
 						}
 					}
@@ -35,6 +35,7 @@ import (
 	"github.com/vntchain/go-vnt/params"
 	"github.com/vntchain/go-vnt/trie"
 	"github.com/vntchain/go-vnt/vntdb"
+	"github.com/vntchain/go-vnt/vntp2p"
 )
 
 var (
@@ -62,6 +63,7 @@ type downloadTester struct {
 	ownBlocks   map[common.Hash]*types.Block   // Blocks belonging to the tester
 	ownReceipts map[common.Hash]types.Receipts // Receipts belonging to the tester
 	ownChainTd  map[common.Hash]*big.Int       // Total difficulties of the blocks in the local chain
+	checkpoint  common.Hash                    // Trusted header the local chain is anchored at, if any
 
 	peerHashes   map[libp2p.ID][]common.Hash                  // Hash chain belonging to different test peers
 	peerHeaders  map[libp2p.ID]map[common.Hash]*types.Header  // Headers belonging to different test peers
@@ -355,7 +357,7 @@ func (dl *downloadTester) InsertReceiptChain(blocks types.Blocks, receipts []typ
 		if _, ok := dl.ownHeaders[blocks[i].Hash()]; !ok {
 			return i, errors.New("unknown owner")
 		}
-		if _, ok := dl.ownBlocks[blocks[i].ParentHash()]; !ok {
+		if _, ok := dl.ownBlocks[blocks[i].ParentHash()]; !ok && blocks[i].ParentHash() != dl.checkpoint {
 			return i, errors.New("unknown parent")
 		}
 		dl.ownBlocks[blocks[i].Hash()] = blocks[i]
@@ -364,6 +366,22 @@ func (dl *downloadTester) InsertReceiptChain(blocks types.Blocks, receipts []typ
 	return len(blocks), nil
 }
 
+// InsertCheckpoint anchors the simulated chain at a trusted header.
+func (dl *downloadTester) InsertCheckpoint(header *types.Header) error {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	hash := header.Hash()
+	if _, ok := dl.ownHeaders[hash]; ok {
+		return nil
+	}
+	dl.checkpoint = hash
+	dl.ownHashes = append(dl.ownHashes, hash)
+	dl.ownHeaders[hash] = header
+	dl.ownChainTd[hash] = new(big.Int).Add(dl.genesis.Difficulty(), header.Number)
+	return nil
+}
+
 // Rollback removes some recently added elements from the chain.
 func (dl *downloadTester) Rollback(hashes []common.Hash) {
 	dl.lock.Lock()
@@ -677,6 +695,74 @@ func testCanonicalSynchronisation(t *testing.T, protocol int, mode SyncMode) {
 	assertOwnChain(t, tester, targetBlocks+1)
 }
 
+// Tests that a fresh fast sync starts from a trusted checkpoint, retrieving none
+// of the history before it.
+func TestCheckpointSynchronisation(t *testing.T) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	targetBlocks := blockCacheItems - 15
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+	tester.newPeer("peer", 63, hashes, headers, blocks, receipts)
+
+	number := targetBlocks / 2
+	checkpoint := hashes[len(hashes)-1-number]
+
+	verified := 0
+	tester.downloader.SetCheckpoint(&Checkpoint{Number: uint64(number), Hash: checkpoint}, func(header *types.Header) error {
+		if header.Hash() != checkpoint {
+			t.Errorf("verified header mismatch: have %x, want %x", header.Hash(), checkpoint)
+		}
+		verified++
+		return nil
+	})
+	if err := tester.sync("peer", nil, FastSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	if verified != 1 {
+		t.Fatalf("checkpoint verifications mismatch: have %d, want %d", verified, 1)
+	}
+	// The genesis and the checkpoint header are followed by the synced blocks
+	if hs := len(tester.ownHeaders); hs != targetBlocks-number+2 {
+		t.Fatalf("synchronised headers mismatch: have %v, want %v", hs, targetBlocks-number+2)
+	}
+	if bs := len(tester.ownBlocks); bs != targetBlocks-number+1 {
+		t.Fatalf("synchronised blocks mismatch: have %v, want %v", bs, targetBlocks-number+1)
+	}
+	if head := tester.CurrentBlock().Hash(); head != hashes[0] {
+		t.Fatalf("head block mismatch: have %x, want %x", head, hashes[0])
+	}
+}
+
+// Tests that a checkpoint whose commit messages don't verify is rejected, and
+// the peer serving it dropped.
+func TestCheckpointSynchronisationInvalid(t *testing.T) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	targetBlocks := blockCacheItems - 15
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+	tester.newPeer("peer", 63, hashes, headers, blocks, receipts)
+
+	number := targetBlocks / 2
+	tester.downloader.SetCheckpoint(&Checkpoint{Number: uint64(number), Hash: hashes[len(hashes)-1-number]}, func(header *types.Header) error {
+		return errors.New("missing commit messages")
+	})
+	if err := tester.downloader.Synchronise("peer", hashes[0], big.NewInt(int64(targetBlocks+1)), FastSync); err != errInvalidCheckpoint {
+		t.Fatalf("sync error mismatch: have %v, want %v", err, errInvalidCheckpoint)
+	}
+	if _, ok := tester.peerHashes["peer"]; ok {
+		t.Fatalf("peer serving an invalid checkpoint not dropped")
+	}
+	if hs := len(tester.ownHeaders); hs != 1 {
+		t.Fatalf("synchronised headers mismatch: have %v, want %v", hs, 1)
+	}
+}
+
 // Tests that if a large batch of blocks are being downloaded, it is throttled
 // until the cached blocks are retrieved.
 func TestThrottling62(t *testing.T)     { testThrottling(t, 62, FullSync) }
@@ -1345,6 +1431,42 @@ func testBlockHeaderAttackerDropping(t *testing.T, protocol int) {
 	}
 }
 
+// reportingTesterPeer is a tester peer recording the reports of its misbehaviour.
+type reportingTesterPeer struct {
+	*downloadTesterPeer
+	reports []vntp2p.Misbehaviour
+}
+
+func (p *reportingTesterPeer) Report(m vntp2p.Misbehaviour) {
+	p.reports = append(p.reports, m)
+}
+
+// Tests that a failed synchronisation is reported once, even if the peer was
+// already dropped when failing it, like on header timeouts.
+func TestSyncFailureReport(t *testing.T) {
+	tester := newTester()
+	defer tester.terminate()
+
+	id := libp2p.ID("reported")
+	if err := tester.newPeer(id, 63, []common.Hash{tester.genesis.Hash()}, nil, nil, nil); err != nil {
+		t.Fatalf("failed to register new peer: %v", err)
+	}
+	peer := &reportingTesterPeer{downloadTesterPeer: &downloadTesterPeer{dl: tester, id: id}}
+	tester.downloader.UnregisterPeer(id)
+	if err := tester.downloader.RegisterPeer(id, 63, peer); err != nil {
+		t.Fatalf("failed to register reporting peer: %v", err)
+	}
+	tester.downloader.synchroniseMock = func(libp2p.ID, common.Hash) error {
+		tester.dropPeer(id)
+		return errTimeout
+	}
+	tester.downloader.Synchronise(id, tester.genesis.Hash(), big.NewInt(1000), FullSync)
+
+	if len(peer.reports) != 1 || peer.reports[0] != vntp2p.RequestTimeout {
+		t.Errorf("reports mismatch: have %v, want [%v]", peer.reports, vntp2p.RequestTimeout)
+	}
+}
+
 // Tests that synchronisation progress (origin block number, current block number
 // and highest block number) is tracked and updated correctly.
 func TestSyncProgress62(t *testing.T)      { testSyncProgress(t, 62, FullSync) }
@@ -68,6 +68,7 @@ type peerConnection struct {
 	stateStarted   time.Time // Time instance when the last node data fetch was started
 
 	lacking map[common.Hash]struct{} // Set of hashes not to request (didn't have previously)
+	tail    uint64                   // First block whose body and receipts the peer serves
 
 	peer Peer
 
@@ -91,6 +92,12 @@ type Peer interface {
 	RequestNodeData([]common.Hash) error
 }
 
+// historyPeer is implemented by the peers advertising the block history they
+// retain, serving only the bodies and receipts from their history tail onward.
+type historyPeer interface {
+	HistoryTail() uint64
+}
+
 // lightPeerWrapper wraps a LightPeer struct, stubbing out the Peer-only methods.
 type lightPeerWrapper struct {
 	peer LightPeer
@@ -115,9 +122,14 @@ func (w *lightPeerWrapper) RequestNodeData([]common.Hash) error {
 
 // newPeerConnection creates a new downloader peer.
 func newPeerConnection(id libp2p.ID, version int, peer Peer, logger log.Logger) *peerConnection {
+	var tail uint64
+	if hp, ok := peer.(historyPeer); ok {
+		tail = hp.HistoryTail()
+	}
 	return &peerConnection{
 		id:      id,
 		lacking: make(map[common.Hash]struct{}),
+		tail:    tail,
 
 		peer: peer,
 
@@ -347,6 +359,12 @@ func (p *peerConnection) Lacks(hash common.Hash) bool {
 	return ok
 }
 
+// LacksHistory retrieves whether the body and receipts of a block are below the
+// history tail the peer advertised, thus expired and not worth requesting.
+func (p *peerConnection) LacksHistory(number uint64) bool {
+	return number > 0 && number < p.tail
+}
+
 // peerSet represents the collection of active peer participating in the chain
 // download procedure.
 type peerSet struct {
@@ -531,7 +531,7 @@ func (q *queue) reserveHeaders(p *peerConnection, count int, taskPool map[common
 			continue
 		}
 		// Otherwise unless the peer is known not to have the data, add to the retrieve list
-		if p.Lacks(hash) {
+		if p.Lacks(hash) || p.LacksHistory(header.Number.Uint64()) {
 			skip = append(skip, header)
 		} else {
 			send = append(send, header)
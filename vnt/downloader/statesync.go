@@ -30,6 +30,7 @@ import (
 	"github.com/vntchain/go-vnt/log"
 	"github.com/vntchain/go-vnt/trie"
 	"github.com/vntchain/go-vnt/vntdb"
+	"github.com/vntchain/go-vnt/vntp2p"
 )
 
 // stateReq represents a batch of state fetch requests grouped together into
@@ -215,6 +216,7 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 type stateSync struct {
 	d *Downloader // Downloader instance to access and manage current peerset
 
+	root   common.Hash                // State root to retrieve
 	sched  *trie.Sync                 // State trie sync scheduler defining the tasks
 	keccak hash.Hash                  // Keccak256 hasher to verify deliveries with
 	tasks  map[common.Hash]*stateTask // Set of tasks currently queued for retrieval
@@ -240,7 +242,7 @@ type stateTask struct {
 func newStateSync(d *Downloader, root common.Hash) *stateSync {
 	return &stateSync{
 		d:       d,
-		sched:   state.NewStateSync(root, d.stateDB),
+		root:    root,
 		keccak:  sha3.NewKeccak256(),
 		tasks:   make(map[common.Hash]*stateTask),
 		deliver: make(chan *stateReq),
@@ -253,8 +255,23 @@ func newStateSync(d *Downloader, root common.Hash) *stateSync {
 // it finishes, and finally notifying any goroutines waiting for the loop to
 // finish.
 func (s *stateSync) run() {
+	defer close(s.done)
+
+	// Retrieve the state in bulk first if possible, the trie node sync healing
+	// whatever is left
+	if s.d.snapSyncer != nil {
+		if err := s.d.snapSyncer.Sync(s.root, s.cancel); err != nil {
+			select {
+			case <-s.cancel:
+				s.err = errCancelStateFetch
+				return
+			default:
+			}
+			log.Warn("Snap sync failed, syncing trie nodes", "root", s.root, "err", err)
+		}
+	}
+	s.sched = state.NewStateSync(s.root, s.d.stateDB)
 	s.err = s.loop()
-	close(s.done)
 }
 
 // Wait blocks until the sync is done or canceled.
@@ -311,6 +328,7 @@ func (s *stateSync) loop() (err error) {
 				// 2 items are the minimum requested, if even that times out, we've no use of
 				// this peer at the moment.
 				log.Warn("Stalling state sync, dropping peer", "peer", req.peer.id)
+				s.d.reportPeer(req.peer.id, vntp2p.RequestTimeout)
 				s.d.dropPeer(req.peer.id)
 			}
 			// Process all the received blobs and check for stale delivery
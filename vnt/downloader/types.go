@@ -21,11 +21,18 @@ import (
 
 	libp2p "github.com/libp2p/go-libp2p-peer"
 	"github.com/vntchain/go-vnt/core/types"
+	"github.com/vntchain/go-vnt/vntp2p"
 )
 
 // peerDropFn is a callback type for dropping a peer detected as malicious.
 type peerDropFn func(id libp2p.ID)
 
+// peerReporter is implemented by peers able to report their misbehaviour to
+// the networking layer, which bans repeat offenders.
+type peerReporter interface {
+	Report(m vntp2p.Misbehaviour)
+}
+
 // dataPack is a data message returned by a peer for some query.
 type dataPack interface {
 	PeerId() libp2p.ID
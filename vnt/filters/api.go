@@ -245,17 +245,24 @@ func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc
 		matchedLogs = make(chan []*types.Log)
 	)
 
-	logsSub, err := api.events.SubscribeLogs(hubble.FilterQuery(crit), matchedLogs)
+	logsSub, err := api.events.SubscribeLogs(crit.query(), matchedLogs)
 	if err != nil {
 		return nil, err
 	}
 
 	go func() {
-
+		var decoder *logDecoder
+		if crit.Decode {
+			decoder = newLogDecoder(api.backend)
+		}
 		for {
 			select {
 			case logs := <-matchedLogs:
 				for _, log := range logs {
+					if decoder != nil {
+						notifier.Notify(rpcSub.ID, decoder.decodeLog(ctx, log))
+						continue
+					}
 					notifier.Notify(rpcSub.ID, &log)
 				}
 			case <-rpcSub.Err(): // client send an unsubscribe request
@@ -272,8 +279,28 @@ func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc
 }
 
 // FilterCriteria represents a request to create a new filter.
-// Same as hubble.FilterQuery but with UnmarshalJSON() method.
-type FilterCriteria hubble.FilterQuery
+// Same as hubble.FilterQuery but with UnmarshalJSON() method and the
+// option to decode matched logs against the emitting contract's ABI.
+type FilterCriteria struct {
+	FromBlock *big.Int
+	ToBlock   *big.Int
+	Addresses []common.Address
+	Topics    [][]common.Hash
+
+	// Decode requests that matched logs be returned as DecodedLog, carrying
+	// the event name and arguments unpacked with the contract's stored ABI.
+	Decode bool
+}
+
+// query converts the criteria into the filter query used by the event system.
+func (crit FilterCriteria) query() hubble.FilterQuery {
+	return hubble.FilterQuery{
+		FromBlock: crit.FromBlock,
+		ToBlock:   crit.ToBlock,
+		Addresses: crit.Addresses,
+		Topics:    crit.Topics,
+	}
+}
 
 // NewFilter creates a new filter and returns the filter id. It can be
 // used to retrieve logs when the state changes. This method cannot be
@@ -290,7 +317,7 @@ type FilterCriteria hubble.FilterQuery
 // https://github.com/vntchain/vnt-documentation/blob/master/api/vnt-json-rpc-api.md#core_newfilter
 func (api *PublicFilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
 	logs := make(chan []*types.Log)
-	logsSub, err := api.events.SubscribeLogs(hubble.FilterQuery(crit), logs)
+	logsSub, err := api.events.SubscribeLogs(crit.query(), logs)
 	if err != nil {
 		return rpc.ID(""), err
 	}
@@ -321,9 +348,10 @@ func (api *PublicFilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
 }
 
 // GetLogs returns logs matching the given argument that are stored within the state.
+// If decoding was requested the logs are returned as []*DecodedLog.
 //
 // https://github.com/vntchain/vnt-documentation/blob/master/api/vnt-json-rpc-api.md#core_getlogs
-func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
+func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) (interface{}, error) {
 	// Convert the RPC block numbers into internal representations
 	if crit.FromBlock == nil {
 		crit.FromBlock = big.NewInt(rpc.LatestBlockNumber.Int64())
@@ -338,7 +366,7 @@ func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([
 	if err != nil {
 		return nil, err
 	}
-	return returnLogs(logs), err
+	return api.returnLogs(ctx, crit, logs), err
 }
 
 // UninstallFilter removes the filter with the given filter id.
@@ -360,9 +388,10 @@ func (api *PublicFilterAPI) UninstallFilter(id rpc.ID) bool {
 
 // GetFilterLogs returns the logs for the filter with the given id.
 // If the filter could not be found an empty array of logs is returned.
+// If decoding was requested the logs are returned as []*DecodedLog.
 //
 // https://github.com/vntchain/vnt-documentation/blob/master/api/vnt-json-rpc-api.md#core_getfilterlogs
-func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*types.Log, error) {
+func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) (interface{}, error) {
 	api.filtersMu.Lock()
 	f, found := api.filters[id]
 	api.filtersMu.Unlock()
@@ -386,14 +415,14 @@ func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*ty
 	if err != nil {
 		return nil, err
 	}
-	return returnLogs(logs), nil
+	return api.returnLogs(ctx, f.crit, logs), nil
 }
 
 // GetFilterChanges returns the logs for the filter with the given id since
 // last time it was called. This can be used for polling.
 //
 // For pending transaction and block filters the result is []common.Hash.
-// (pending)Log filters return []Log.
+// (pending)Log filters return []Log, or []DecodedLog if decoding was requested.
 //
 // https://github.com/vntchain/vnt-documentation/blob/master/api/vnt-json-rpc-api.md#core_getfilterchanges
 func (api *PublicFilterAPI) GetFilterChanges(id rpc.ID) (interface{}, error) {
@@ -416,7 +445,7 @@ func (api *PublicFilterAPI) GetFilterChanges(id rpc.ID) (interface{}, error) {
 		case LogsSubscription:
 			logs := f.logs
 			f.logs = nil
-			return returnLogs(logs), nil
+			return api.returnLogs(context.Background(), f.crit, logs), nil
 		}
 	}
 
@@ -441,6 +470,15 @@ func returnLogs(logs []*types.Log) []*types.Log {
 	return logs
 }
 
+// returnLogs is a helper that will decode the given logs if the filter criteria
+// asks for it, otherwise it behaves like returnLogs.
+func (api *PublicFilterAPI) returnLogs(ctx context.Context, crit FilterCriteria, logs []*types.Log) interface{} {
+	if !crit.Decode {
+		return returnLogs(logs)
+	}
+	return newLogDecoder(api.backend).decodeLogs(ctx, logs)
+}
+
 // UnmarshalJSON sets *args fields with given data.
 func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 	type input struct {
@@ -448,6 +486,7 @@ func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 		ToBlock   *rpc.BlockNumber `json:"toBlock"`
 		Addresses interface{}      `json:"address"`
 		Topics    []interface{}    `json:"topics"`
+		Decode    bool             `json:"decode"`
 	}
 
 	var raw input
@@ -463,6 +502,7 @@ func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 		args.ToBlock = big.NewInt(raw.ToBlock.Int64())
 	}
 
+	args.Decode = raw.Decode
 	args.Addresses = []common.Address{}
 
 	if raw.Addresses != nil {
@@ -182,4 +182,20 @@ func TestUnmarshalJSONNewFilterArgs(t *testing.T) {
 	if len(test7.Topics[2]) != 0 {
 		t.Fatalf("expected 0 topics, got %d topics", len(test7.Topics[2]))
 	}
+	if test7.Decode {
+		t.Fatalf("expected decoding to be disabled by default")
+	}
+
+	// decode option
+	var test8 FilterCriteria
+	vector = fmt.Sprintf(`{"address": "%s", "decode": true}`, address0.Hex())
+	if err := json.Unmarshal([]byte(vector), &test8); err != nil {
+		t.Fatal(err)
+	}
+	if !test8.Decode {
+		t.Fatalf("expected decoding to be enabled")
+	}
+	if len(test8.Addresses) != 1 || test8.Addresses[0] != address0 {
+		t.Fatalf("expected address %x, got %v", address0, test8.Addresses)
+	}
 }
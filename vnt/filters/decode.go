@@ -0,0 +1,138 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"encoding/json"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/vntchain/go-vnt/accounts/abi"
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/core/types"
+	"github.com/vntchain/go-vnt/core/wavm"
+	"github.com/vntchain/go-vnt/core/wavm/utils"
+	"github.com/vntchain/go-vnt/log"
+	"github.com/vntchain/go-vnt/rpc"
+)
+
+// DecodedLog is a log entry annotated with the event it was decoded against,
+// using the ABI stored on-chain alongside the emitting contract's code. Logs
+// that could not be decoded carry no event name and marshal like plain logs.
+type DecodedLog struct {
+	*types.Log
+	Event string           // name of the matching ABI event
+	Args  []abi.NamedValue // event arguments in declaration order
+}
+
+// MarshalJSON implements json.Marshaler, adding the decoded event name and
+// arguments to the regular log fields.
+func (l DecodedLog) MarshalJSON() ([]byte, error) {
+	enc, err := json.Marshal(l.Log)
+	if err != nil || l.Event == "" {
+		return enc, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(enc, &fields); err != nil {
+		return nil, err
+	}
+	fields["event"] = l.Event
+	fields["args"] = l.Args
+	return json.Marshal(fields)
+}
+
+// abiCacheLimit is the maximum number of contract ABIs cached by a log decoder.
+const abiCacheLimit = 256
+
+// logDecoder decodes logs against the ABIs of the contracts that emitted
+// them. Lookups of the recently seen contracts are cached since contract code
+// is immutable.
+type logDecoder struct {
+	backend Backend
+	abis    *lru.Cache // ABIs of the contracts, nil for accounts without one
+}
+
+// newLogDecoder creates a log decoder retrieving contract ABIs through the
+// given backend.
+func newLogDecoder(backend Backend) *logDecoder {
+	abis, _ := lru.New(abiCacheLimit)
+	return &logDecoder{
+		backend: backend,
+		abis:    abis,
+	}
+}
+
+// decodeLogs decodes the given logs. Logs emitted by accounts without a
+// usable ABI or not matching any of its events are returned undecoded.
+func (d *logDecoder) decodeLogs(ctx context.Context, logs []*types.Log) []*DecodedLog {
+	decoded := make([]*DecodedLog, len(logs))
+	for i, l := range logs {
+		decoded[i] = d.decodeLog(ctx, l)
+	}
+	return decoded
+}
+
+// decodeLog decodes a single log entry.
+func (d *logDecoder) decodeLog(ctx context.Context, l *types.Log) *DecodedLog {
+	decoded := &DecodedLog{Log: l}
+	if len(l.Topics) == 0 {
+		return decoded
+	}
+	contractAbi := d.contractAbi(ctx, l.Address)
+	if contractAbi == nil {
+		return decoded
+	}
+	event, err := contractAbi.EventById(l.Topics[0])
+	if err != nil {
+		return decoded
+	}
+	values, err := event.UnpackLog(l.Topics, l.Data)
+	if err != nil {
+		log.Debug("Failed to decode log", "address", l.Address, "event", event.Name, "err", err)
+		return decoded
+	}
+	args, err := event.Inputs.NamedValues(values)
+	if err != nil {
+		return decoded
+	}
+	decoded.Event, decoded.Args = event.Name, args
+	return decoded
+}
+
+// contractAbi returns the ABI stored with the code of the given contract, or
+// nil if the account holds no WASM contract.
+func (d *logDecoder) contractAbi(ctx context.Context, address common.Address) *abi.ABI {
+	if contractAbi, ok := d.abis.Get(address); ok {
+		return contractAbi.(*abi.ABI)
+	}
+	state, _, err := d.backend.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if state == nil || err != nil {
+		return nil
+	}
+	d.abis.Add(address, (*abi.ABI)(nil))
+
+	wasmcode, _, err := utils.DecodeContractCode(state.GetCode(address))
+	if err != nil {
+		return nil
+	}
+	contractAbi, err := wavm.GetAbi(wasmcode.Abi)
+	if err != nil {
+		return nil
+	}
+	d.abis.Add(address, &contractAbi)
+	return &contractAbi
+}
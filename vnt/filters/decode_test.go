@@ -0,0 +1,169 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"testing"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/consensus/mock"
+	"github.com/vntchain/go-vnt/core"
+	"github.com/vntchain/go-vnt/core/rawdb"
+	"github.com/vntchain/go-vnt/core/types"
+	"github.com/vntchain/go-vnt/core/wavm"
+	"github.com/vntchain/go-vnt/core/wavm/utils"
+	"github.com/vntchain/go-vnt/event"
+	"github.com/vntchain/go-vnt/params"
+	"github.com/vntchain/go-vnt/rpc"
+	"github.com/vntchain/go-vnt/vntdb"
+)
+
+// Tests that the logs retrieved with decoding requested carry the events and
+// arguments of the emitting contracts, and that the other logs are returned
+// undecoded.
+func TestDecodeLogs(t *testing.T) {
+	code, err := ioutil.ReadFile("../../core/wavm/tests/erc20/TokenERC20.compress")
+	if err != nil {
+		t.Fatalf("failed to read contract: %v", err)
+	}
+	wasm, _, err := utils.DecodeContractCode(code)
+	if err != nil {
+		t.Fatalf("failed to decode contract: %v", err)
+	}
+	tokenAbi, err := wavm.GetAbi(wasm.Abi)
+	if err != nil {
+		t.Fatalf("failed to parse abi: %v", err)
+	}
+	var (
+		db       = vntdb.NewMemDatabase()
+		backend  = &testBackend{new(event.TypeMux), db, 0, new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed)}
+		token    = common.HexToAddress("0x0100")
+		account  = common.HexToAddress("0x0200")
+		from, to = common.HexToAddress("0x01"), common.HexToAddress("0x02")
+		transfer = tokenAbi.Events["Transfer"].Id()
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{token: {Code: code, Balance: new(big.Int)}},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+	chain, receipts := core.GenerateChain(gspec.Config, genesis, mock.NewMock(), db, 1, func(i int, gen *core.BlockGen) {
+		receipt := types.NewReceipt(nil, false, 0)
+		receipt.Logs = []*types.Log{
+			{
+				Address: token,
+				Topics:  []common.Hash{transfer, from.Hash(), to.Hash()},
+				Data:    common.LeftPadBytes(big.NewInt(100).Bytes(), 32),
+			},
+			{Address: token, Topics: []common.Hash{common.HexToHash("0x01")}},
+			{Address: account, Topics: []common.Hash{transfer, from.Hash(), to.Hash()}},
+		}
+		gen.AddUncheckedReceipt(receipt)
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	check := func(name string, result interface{}) {
+		logs, ok := result.([]*DecodedLog)
+		if !ok || len(logs) != 3 {
+			t.Fatalf("%s: logs mismatch: have %v", name, result)
+		}
+		if logs[0].Event != "Transfer" || len(logs[0].Args) != 3 {
+			t.Fatalf("%s: transfer not decoded: %+v", name, logs[0])
+		}
+		if logs[0].Args[0].Value != from || logs[0].Args[1].Value != to || logs[0].Args[2].Value.(*big.Int).Cmp(big.NewInt(100)) != 0 {
+			t.Errorf("%s: transfer arguments mismatch: %+v", name, logs[0].Args)
+		}
+		for i, l := range logs[1:] {
+			if l.Event != "" || l.Args != nil {
+				t.Errorf("%s: log %d of unknown event decoded: %+v", name, i+1, l)
+			}
+		}
+		// Decoded logs extend the plain log fields, the others marshal like plain logs
+		var fields map[string]interface{}
+		enc, _ := json.Marshal(logs[0])
+		if err := json.Unmarshal(enc, &fields); err != nil {
+			t.Fatalf("%s: failed to unmarshal decoded log: %v", name, err)
+		}
+		if fields["event"] != "Transfer" || fields["address"] != token.Hex() {
+			t.Errorf("%s: decoded log fields mismatch: %s", name, enc)
+		}
+		if args, ok := fields["args"].([]interface{}); !ok || len(args) != 3 || args[2].(map[string]interface{})["value"] != "0x64" {
+			t.Errorf("%s: decoded log arguments mismatch: %s", name, enc)
+		}
+		have, _ := json.Marshal(logs[2])
+		want, _ := json.Marshal(logs[2].Log)
+		if string(have) != string(want) {
+			t.Errorf("%s: undecoded log mismatch: have %s, want %s", name, have, want)
+		}
+	}
+	var (
+		api  = NewPublicFilterAPI(backend, false)
+		crit = FilterCriteria{FromBlock: big.NewInt(0), ToBlock: big.NewInt(rpc.LatestBlockNumber.Int64()), Decode: true}
+	)
+	result, err := api.GetLogs(context.Background(), crit)
+	if err != nil {
+		t.Fatalf("failed to get logs: %v", err)
+	}
+	check("GetLogs", result)
+
+	id, err := api.NewFilter(crit)
+	if err != nil {
+		t.Fatalf("failed to create filter: %v", err)
+	}
+	if result, err = api.GetFilterLogs(context.Background(), id); err != nil {
+		t.Fatalf("failed to get filter logs: %v", err)
+	}
+	check("GetFilterLogs", result)
+
+	// Logs are returned plain without decoding
+	crit.Decode = false
+	if result, err = api.GetLogs(context.Background(), crit); err != nil {
+		t.Fatalf("failed to get logs: %v", err)
+	}
+	if logs, ok := result.([]*types.Log); !ok || len(logs) != 3 {
+		t.Errorf("plain logs mismatch: have %v", result)
+	}
+}
+
+// Tests that a log decoder only caches the ABIs of the recent contracts.
+func TestLogDecoderCacheLimit(t *testing.T) {
+	var (
+		db      = vntdb.NewMemDatabase()
+		backend = &testBackend{new(event.TypeMux), db, 0, new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed)}
+		genesis = (&core.Genesis{Config: params.TestChainConfig}).MustCommit(db)
+	)
+	rawdb.WriteHeadBlockHash(db, genesis.Hash())
+
+	decoder := newLogDecoder(backend)
+	for i := 0; i < 2*abiCacheLimit; i++ {
+		decoder.decodeLog(context.Background(), &types.Log{
+			Address: common.BigToAddress(big.NewInt(int64(i))),
+			Topics:  []common.Hash{{}},
+		})
+	}
+	if have := decoder.abis.Len(); have != abiCacheLimit {
+		t.Errorf("cached abis mismatch: have %d, want %d", have, abiCacheLimit)
+	}
+}
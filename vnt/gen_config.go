@@ -19,11 +19,17 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		Genesis                 *core.Genesis `toml:",omitempty"`
 		NetworkId               uint64
 		SyncMode                downloader.SyncMode
-		LightServ               int  `toml:",omitempty"`
-		LightPeers              int  `toml:",omitempty"`
-		SkipBcVersionCheck      bool `toml:"-"`
-		DatabaseHandles         int  `toml:"-"`
+		Checkpoint              *downloader.Checkpoint `toml:",omitempty"`
+		NoSnapshot              bool                   `toml:",omitempty"`
+		HistoryRetention        uint64                 `toml:",omitempty"`
+		StateDiffs              bool                   `toml:",omitempty"`
+		Gossip                  bool                   `toml:",omitempty"`
+		LightServ               int                    `toml:",omitempty"`
+		LightPeers              int                    `toml:",omitempty"`
+		SkipBcVersionCheck      bool                   `toml:"-"`
+		DatabaseHandles         int                    `toml:"-"`
 		DatabaseCache           int
+		DatabaseFreezer         string         `toml:",omitempty"`
 		Coinbase                common.Address `toml:",omitempty"`
 		ExtraData               hexutil.Bytes  `toml:",omitempty"`
 		GasPrice                *big.Int
@@ -36,11 +42,17 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.Genesis = c.Genesis
 	enc.NetworkId = c.NetworkId
 	enc.SyncMode = c.SyncMode
+	enc.Checkpoint = c.Checkpoint
+	enc.NoSnapshot = c.NoSnapshot
+	enc.HistoryRetention = c.HistoryRetention
+	enc.StateDiffs = c.StateDiffs
+	enc.Gossip = c.Gossip
 	enc.LightServ = c.LightServ
 	enc.LightPeers = c.LightPeers
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
+	enc.DatabaseFreezer = c.DatabaseFreezer
 	enc.Coinbase = c.Coinbase
 	enc.ExtraData = c.ExtraData
 	enc.GasPrice = c.GasPrice
@@ -56,11 +68,17 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		Genesis                 *core.Genesis `toml:",omitempty"`
 		NetworkId               *uint64
 		SyncMode                *downloader.SyncMode
-		LightServ               *int  `toml:",omitempty"`
-		LightPeers              *int  `toml:",omitempty"`
-		SkipBcVersionCheck      *bool `toml:"-"`
-		DatabaseHandles         *int  `toml:"-"`
+		Checkpoint              *downloader.Checkpoint `toml:",omitempty"`
+		NoSnapshot              *bool                  `toml:",omitempty"`
+		HistoryRetention        *uint64                `toml:",omitempty"`
+		StateDiffs              *bool                  `toml:",omitempty"`
+		Gossip                  *bool                  `toml:",omitempty"`
+		LightServ               *int                   `toml:",omitempty"`
+		LightPeers              *int                   `toml:",omitempty"`
+		SkipBcVersionCheck      *bool                  `toml:"-"`
+		DatabaseHandles         *int                   `toml:"-"`
 		DatabaseCache           *int
+		DatabaseFreezer         *string         `toml:",omitempty"`
 		Coinbase                *common.Address `toml:",omitempty"`
 		ProducerThreads         *int            `toml:",omitempty"`
 		ExtraData               *hexutil.Bytes  `toml:",omitempty"`
@@ -83,6 +101,21 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.SyncMode != nil {
 		c.SyncMode = *dec.SyncMode
 	}
+	if dec.Checkpoint != nil {
+		c.Checkpoint = dec.Checkpoint
+	}
+	if dec.NoSnapshot != nil {
+		c.NoSnapshot = *dec.NoSnapshot
+	}
+	if dec.HistoryRetention != nil {
+		c.HistoryRetention = *dec.HistoryRetention
+	}
+	if dec.StateDiffs != nil {
+		c.StateDiffs = *dec.StateDiffs
+	}
+	if dec.Gossip != nil {
+		c.Gossip = *dec.Gossip
+	}
 	if dec.LightServ != nil {
 		c.LightServ = *dec.LightServ
 	}
@@ -98,6 +131,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.DatabaseCache != nil {
 		c.DatabaseCache = *dec.DatabaseCache
 	}
+	if dec.DatabaseFreezer != nil {
+		c.DatabaseFreezer = *dec.DatabaseFreezer
+	}
 	if dec.Coinbase != nil {
 		c.Coinbase = *dec.Coinbase
 	}
@@ -0,0 +1,161 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package vnt
+
+import (
+	"sync/atomic"
+
+	lru "github.com/hashicorp/golang-lru"
+	libp2p "github.com/libp2p/go-libp2p-peer"
+	"github.com/vntchain/go-vnt/consensus"
+	"github.com/vntchain/go-vnt/core"
+	"github.com/vntchain/go-vnt/core/types"
+	"github.com/vntchain/go-vnt/log"
+	"github.com/vntchain/go-vnt/rlp"
+	"github.com/vntchain/go-vnt/vntp2p/gossip"
+)
+
+// Gossip topics of the VNT protocol
+const (
+	txTopic            = "/vnt/txs/1"
+	bftPreprepareTopic = "/vnt/bft/preprepare/1"
+	bftPrepareTopic    = "/vnt/bft/prepare/1"
+	bftCommitTopic     = "/vnt/bft/commit/1"
+)
+
+// bftValidator is implemented by consensus engines able to check bft messages
+// before they are relayed to other peers.
+type bftValidator interface {
+	ValidateBftMsg(chain consensus.ChainReader, msg types.ConsensusMsg) error
+}
+
+// enableGossip propagates transactions and bft messages through gossip topics
+// to the peers supporting them, in addition to the direct broadcasts to the
+// peers which do not. It must be called before the protocols are started.
+func (pm *ProtocolManager) enableGossip(engine consensus.Engine) {
+	pm.gossip = gossip.New(gossip.DefaultConfig)
+	pm.gossipTxs, _ = lru.New(maxKnownTxs)
+	pm.SubProtocols = append(pm.SubProtocols, pm.gossip.Protocol())
+
+	pm.gossip.Subscribe(txTopic, pm.validateGossipTxs)
+
+	validator, _ := engine.(bftValidator)
+	pm.gossip.Subscribe(bftPreprepareTopic, pm.bftGossipValidator(validator, func() types.ConsensusMsg { return new(types.PreprepareMsg) }))
+	pm.gossip.Subscribe(bftPrepareTopic, pm.bftGossipValidator(validator, func() types.ConsensusMsg { return new(types.PrepareMsg) }))
+	pm.gossip.Subscribe(bftCommitTopic, pm.bftGossipValidator(validator, func() types.ConsensusMsg { return new(types.CommitMsg) }))
+}
+
+// validateGossipTxs adds a batch of gossiped transactions to the pool, relaying
+// it if any of them was new and acceptable.
+func (pm *ProtocolManager) validateGossipTxs(from libp2p.ID, data []byte) gossip.ValidationResult {
+	if atomic.LoadUint32(&pm.acceptTxs) == 0 {
+		return gossip.Ignore
+	}
+	var txs []*types.Transaction
+	if err := rlp.DecodeBytes(data, &txs); err != nil {
+		return gossip.Reject
+	}
+	p := pm.peers.Peer(from)
+	for _, tx := range txs {
+		if tx == nil {
+			return gossip.Reject
+		}
+		pm.gossipTxs.Add(tx.Hash(), struct{}{})
+		if p != nil {
+			p.MarkTransaction(tx.Hash())
+		}
+	}
+	result := gossip.Ignore
+	for _, err := range pm.txpool.AddRemotes(txs) {
+		switch err {
+		case nil:
+			result = gossip.Accept
+		case core.ErrInvalidSender, core.ErrNegativeValue, core.ErrOversizedData, core.ErrIntrinsicGas:
+			// Invalid regardless of the local state, the sender should not have relayed it
+			return gossip.Reject
+		}
+	}
+	return result
+}
+
+// bftGossipValidator creates the validator of a bft message topic, which hands
+// the well formed messages to the consensus engine. The messages which cannot
+// be verified yet are handed to the engine too, but not relayed.
+func (pm *ProtocolManager) bftGossipValidator(validator bftValidator, newMsg func() types.ConsensusMsg) gossip.Validator {
+	return func(from libp2p.ID, data []byte) gossip.ValidationResult {
+		msg := newMsg()
+		if err := rlp.DecodeBytes(data, msg); err != nil {
+			return gossip.Reject
+		}
+		if validator != nil {
+			if err := validator.ValidateBftMsg(pm.blockchain, msg); err == consensus.ErrUnverifiableMsg {
+				pm.postRecBftEvent(msg)
+				return gossip.Ignore
+			} else if err != nil {
+				log.Debug("Invalid gossiped bft message", "peer", from, "type", msg.Type(), "err", err)
+				return gossip.Reject
+			}
+		}
+		pm.postRecBftEvent(msg)
+		return gossip.Accept
+	}
+}
+
+// publishTxs publishes the transactions not received through gossip on the
+// transaction topic.
+func (pm *ProtocolManager) publishTxs(txs types.Transactions) {
+	var fresh types.Transactions
+	for _, tx := range txs {
+		if !pm.gossipTxs.Contains(tx.Hash()) {
+			fresh = append(fresh, tx)
+		}
+	}
+	if len(fresh) == 0 {
+		return
+	}
+	data, err := rlp.EncodeToBytes(fresh)
+	if err != nil {
+		log.Error("Failed to encode gossiped transactions", "err", err)
+		return
+	}
+	if err := pm.gossip.Publish(txTopic, data); err != nil {
+		log.Debug("Failed to publish transactions", "err", err)
+	}
+}
+
+// publishBftMsg publishes a bft message on the topic of its type.
+func (pm *ProtocolManager) publishBftMsg(bftMsg types.BftMsg) {
+	var topic string
+	switch bftMsg.BftType {
+	case types.BftPreprepareMessage:
+		topic = bftPreprepareTopic
+	case types.BftPrepareMessage:
+		topic = bftPrepareTopic
+	case types.BftCommitMessage:
+		topic = bftCommitTopic
+	default:
+		return
+	}
+	data, err := rlp.EncodeToBytes(bftMsg.Msg)
+	if err != nil {
+		log.Error("Failed to encode gossiped bft message", "err", err)
+		return
+	}
+	if err := pm.gossip.Publish(topic, data); err != nil {
+		log.Debug("Failed to publish bft message", "err", err)
+	}
+}
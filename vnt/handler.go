@@ -29,6 +29,7 @@ import (
 	"github.com/vntchain/go-vnt/common"
 	"github.com/vntchain/go-vnt/consensus"
 	"github.com/vntchain/go-vnt/core"
+	"github.com/vntchain/go-vnt/core/state/snapshot"
 	"github.com/vntchain/go-vnt/core/types"
 	"github.com/vntchain/go-vnt/event"
 	"github.com/vntchain/go-vnt/log"
@@ -37,9 +38,12 @@ import (
 	"github.com/vntchain/go-vnt/rlp"
 	"github.com/vntchain/go-vnt/vnt/downloader"
 	"github.com/vntchain/go-vnt/vnt/fetcher"
+	"github.com/vntchain/go-vnt/vnt/snap"
 	"github.com/vntchain/go-vnt/vntdb"
 	"github.com/vntchain/go-vnt/vntp2p"
+	"github.com/vntchain/go-vnt/vntp2p/gossip"
 
+	lru "github.com/hashicorp/golang-lru"
 	libp2p "github.com/libp2p/go-libp2p-peer"
 )
 
@@ -96,6 +100,11 @@ type ProtocolManager struct {
 	wg sync.WaitGroup
 
 	urlsCh chan []string // 传递p2p urls of witnesses
+
+	gossip    *gossip.Router // Gossip topics for txs and bft messages, nil if disabled
+	gossipTxs *lru.Cache     // Hashes of the transactions received through gossip
+
+	bftValidator bftValidator // Checks of the received bft messages, nil if unsupported by the engine
 }
 
 // NewProtocolManager returns a new VNT sub protocol manager. The VNT sub protocol manages peers capable
@@ -181,11 +190,33 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		atomic.StoreUint32(&manager.acceptTxs, 1) // Mark initial sync done on any fetcher import
 		return manager.blockchain.InsertChain(blocks)
 	}
-	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.removePeer)
+	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.dropInvalidBlockPeer)
+	manager.bftValidator, _ = engine.(bftValidator)
 
 	return manager, nil
 }
 
+// enableSnap serves the state over the snap protocol, and retrieves it from the
+// peers supporting it during fast sync.
+func (pm *ProtocolManager) enableSnap(chaindb vntdb.Database) {
+	db, ok := chaindb.(snapshot.Database)
+	if !ok {
+		log.Warn("Database not iterable, snap sync disabled")
+		return
+	}
+	handler := snap.NewHandler(pm.blockchain, db)
+	pm.SubProtocols = append(pm.SubProtocols, handler.Protocol())
+	pm.downloader.SetSnapSyncer(handler.Syncer())
+}
+
+// enableCheckpoint makes fast sync start from a trusted checkpoint, accepted
+// once the engine approves the commit messages finalizing it.
+func (pm *ProtocolManager) enableCheckpoint(cp *downloader.Checkpoint, engine consensus.Engine) {
+	pm.downloader.SetCheckpoint(cp, func(header *types.Header) error {
+		return engine.VerifyCommitMsg(types.NewBlockWithHeader(header))
+	})
+}
+
 func (pm *ProtocolManager) removePeer(id libp2p.ID) {
 	// Short circuit if the peer was already removed
 	peer := pm.peers.Peer(id)
@@ -205,6 +236,15 @@ func (pm *ProtocolManager) removePeer(id libp2p.ID) {
 	}
 }
 
+// dropInvalidBlockPeer penalises a peer for propagating an invalid block and
+// disconnects it.
+func (pm *ProtocolManager) dropInvalidBlockPeer(id libp2p.ID) {
+	if peer := pm.peers.Peer(id); peer != nil {
+		peer.Report(vntp2p.InvalidBlock)
+	}
+	pm.removePeer(id)
+}
+
 // resetBftPeer update current bft peer connection. If node not has connection
 // will them, will connecting to them. url format is:
 // /ip4/192.168.102.2/tcp/5216/ipfs/1kHBzN17vVE75rwZA7vKAFfxUYS8XMh6QBYS6JWF13xHGX9
@@ -217,6 +257,7 @@ func (pm *ProtocolManager) resetBftPeer(urls []string) {
 
 	// Add new records, and addPeer if not connect
 	selfID := pm.node.Server().NodeInfo().ID
+	var witnesses []*vntp2p.Node
 	for _, url := range urls {
 		node, err := vntp2p.ParseNode(url)
 		if err != nil {
@@ -228,11 +269,13 @@ func (pm *ProtocolManager) resetBftPeer(urls []string) {
 		}
 
 		pm.peers.bftPeers[node.Id] = struct{}{}
+		witnesses = append(witnesses, node)
 		if _, exists := pm.peers.peers[node.Id]; !exists {
 			log.Debug("Reset bft peer, connecting to", "peer", url)
 			go pm.node.Server().AddPeer(context.Background(), node)
 		}
 	}
+	pm.node.Server().SetWitnesses(witnesses)
 }
 
 func (pm *ProtocolManager) Start(maxPeers int) {
@@ -265,6 +308,9 @@ func (pm *ProtocolManager) Stop() {
 	pm.producedBlockSub.Unsubscribe() // quits blockBroadcastLoop
 	pm.bftMsgSub.Unsubscribe()
 	pm.bftPeerSub.Unsubscribe()
+	if pm.gossip != nil {
+		pm.gossip.Stop()
+	}
 
 	close(pm.urlsCh)
 
@@ -294,11 +340,10 @@ func (pm *ProtocolManager) newPeer(pv int, p *vntp2p.Peer, rw vntp2p.MsgReadWrit
 // handle is the callback invoked to manage the life cycle of an vnt peer. When
 // this function terminates, the peer is disconnected.
 func (pm *ProtocolManager) handle(p *peer) error {
-	// Ignore maxPeers if this is a trusted peer
-
-	// if pm.peers.Len() >= pm.maxPeers && !p.Peer.Info().Network.Trusted {
-	// 	return vntp2p.DiscTooManyPeers
-	// }
+	// Witnesses reserve slots to the other witnesses beyond maxPeers
+	if pm.peers.HasBftPeers() && pm.peers.RegularLen() >= pm.maxPeers && !pm.isWitness(p.id) {
+		return vntp2p.DiscTooManyPeers
+	}
 
 	// p.Log().Debug("VNT peer connected", "name", p.Name())
 
@@ -310,7 +355,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 		number  = head.Number.Uint64()
 		td      = pm.blockchain.GetTd(hash, number)
 	)
-	if err := p.Handshake(pm.networkId, td, hash, genesis.Hash()); err != nil {
+	if err := p.Handshake(pm.networkId, td, hash, genesis.Hash(), pm.blockchain.HistoryTail()); err != nil {
 		p.Log().Debug("VNT handshake failed", "err", err)
 		return err
 	}
@@ -351,7 +396,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	}
 	size := msg.GetBodySize()
 	if size > ProtocolMaxMsgSize {
-		return errResp(ErrMsgTooLarge, "%v > %v", size, ProtocolMaxMsgSize)
+		return misbehaving(p, msg.Body.Type, errResp(ErrMsgTooLarge, "%v > %v", size, ProtocolMaxMsgSize))
 	}
 
 	//按理说，新版的协议处理方式，不会有残留数据得不到处理
@@ -361,14 +406,14 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	switch {
 	case msg.Body.Type == StatusMsg:
 		// Status messages should never arrive after the handshake
-		return errResp(ErrExtraStatusMsg, "uncontrolled status message")
+		return misbehaving(p, msg.Body.Type, errResp(ErrExtraStatusMsg, "uncontrolled status message"))
 
 	// Block header query, collect the requested headers and reply
 	case msg.Body.Type == GetBlockHeadersMsg:
 		// Decode the complex header query
 		var query getBlockHeadersData
 		if err := msg.Decode(&query); err != nil {
-			return errResp(ErrDecode, "%v: %v", msg, err)
+			return misbehaving(p, msg.Body.Type, errResp(ErrDecode, "%v: %v", msg, err))
 		}
 		hashMode := query.Origin.Hash != (common.Hash{})
 		first := true
@@ -455,7 +500,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		// A batch of headers arrived to one of our previous requests
 		var headers []*types.Header
 		if err := msg.Decode(&headers); err != nil {
-			return errResp(ErrDecode, "msg %v: %v", msg, err)
+			return misbehaving(p, msg.Body.Type, errResp(ErrDecode, "msg %v: %v", msg, err))
 		}
 		// Filter out any explicitly requested headers, deliver the rest to the downloader
 		filter := len(headers) == 1
@@ -474,7 +519,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		// Decode the retrieval message
 		msgStream := rlp.NewStream(msg.Body.Payload, uint64(msg.Body.PayloadSize))
 		if _, err := msgStream.List(); err != nil {
-			return err
+			return misbehaving(p, msg.Body.Type, err)
 		}
 		// Gather blocks until the fetch or network limits is reached
 		var (
@@ -487,12 +532,14 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			if err := msgStream.Decode(&hash); err == rlp.EOL {
 				break
 			} else if err != nil {
-				return errResp(ErrDecode, "msg %v: %v", msg, err)
+				return misbehaving(p, msg.Body.Type, errResp(ErrDecode, "msg %v: %v", msg, err))
 			}
 			// Retrieve the requested block body, stopping if enough was found
 			if data := pm.blockchain.GetBodyRLP(hash); len(data) != 0 {
 				bodies = append(bodies, data)
 				bytes += len(data)
+			} else if pm.expired(hash) {
+				break
 			}
 		}
 		return p.SendBlockBodiesRLP(bodies)
@@ -501,7 +548,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		// A batch of block bodies arrived to one of our previous requests
 		var request blockBodiesData
 		if err := msg.Decode(&request); err != nil {
-			return errResp(ErrDecode, "msg %v: %v", msg, err)
+			return misbehaving(p, msg.Body.Type, errResp(ErrDecode, "msg %v: %v", msg, err))
 		}
 		// Deliver them all to the downloader for queuing
 		transactions := make([][]*types.Transaction, len(request))
@@ -523,7 +570,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		// Decode the retrieval message
 		msgStream := rlp.NewStream(msg.Body.Payload, uint64(msg.Body.PayloadSize))
 		if _, err := msgStream.List(); err != nil {
-			return err
+			return misbehaving(p, msg.Body.Type, err)
 		}
 		// Gather state data until the fetch or network limits is reached
 		var (
@@ -536,7 +583,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			if err := msgStream.Decode(&hash); err == rlp.EOL {
 				break
 			} else if err != nil {
-				return errResp(ErrDecode, "msg %v: %v", msg, err)
+				return misbehaving(p, msg.Body.Type, errResp(ErrDecode, "msg %v: %v", msg, err))
 			}
 			// Retrieve the requested state entry, stopping if enough was found
 			if entry, err := pm.blockchain.TrieNode(hash); err == nil {
@@ -550,7 +597,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		// A batch of node state data arrived to one of our previous requests
 		var data [][]byte
 		if err := msg.Decode(&data); err != nil {
-			return errResp(ErrDecode, "msg %v: %v", msg, err)
+			return misbehaving(p, msg.Body.Type, errResp(ErrDecode, "msg %v: %v", msg, err))
 		}
 		// Deliver all to the downloader
 		if err := pm.downloader.DeliverNodeData(p.id, data); err != nil {
@@ -561,7 +608,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		// Decode the retrieval message
 		msgStream := rlp.NewStream(msg.Body.Payload, uint64(msg.Body.PayloadSize))
 		if _, err := msgStream.List(); err != nil {
-			return err
+			return misbehaving(p, msg.Body.Type, err)
 		}
 		// Gather state data until the fetch or network limits is reached
 		var (
@@ -574,11 +621,14 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			if err := msgStream.Decode(&hash); err == rlp.EOL {
 				break
 			} else if err != nil {
-				return errResp(ErrDecode, "msg %v: %v", msg, err)
+				return misbehaving(p, msg.Body.Type, errResp(ErrDecode, "msg %v: %v", msg, err))
 			}
 			// Retrieve the requested block's receipts, skipping if unknown to us
 			results := pm.blockchain.GetReceiptsByHash(hash)
 			if results == nil {
+				if pm.expired(hash) {
+					break
+				}
 				if header := pm.blockchain.GetHeaderByHash(hash); header == nil || header.ReceiptHash != types.EmptyRootHash {
 					continue
 				}
@@ -597,7 +647,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		// A batch of receipts arrived to one of our previous requests
 		var receipts [][]*types.Receipt
 		if err := msg.Decode(&receipts); err != nil {
-			return errResp(ErrDecode, "msg %v: %v", msg, err)
+			return misbehaving(p, msg.Body.Type, errResp(ErrDecode, "msg %v: %v", msg, err))
 		}
 		// Deliver all to the downloader
 		if err := pm.downloader.DeliverReceipts(p.id, receipts); err != nil {
@@ -607,7 +657,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	case msg.Body.Type == NewBlockHashesMsg:
 		var announces newBlockHashesData
 		if err := msg.Decode(&announces); err != nil {
-			return errResp(ErrDecode, "%v: %v", msg, err)
+			return misbehaving(p, msg.Body.Type, errResp(ErrDecode, "%v: %v", msg, err))
 		}
 		// Mark the hashes as present at the remote node
 		for _, block := range announces {
@@ -637,6 +687,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	case msg.Body.Type == NewBlockMsg:
 		// This message is forbid. The peer is malicious and will be removed.
 		log.Info("Receive NewBlockMsg from", "peer", p.id)
+		p.Report(vntp2p.ProtocolViolation)
 		pm.removePeer(p.id)
 
 	case msg.Body.Type == TxMsg:
@@ -647,12 +698,12 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		// Transactions can be processed, parse all of them and deliver to the pool
 		var txs []*types.Transaction
 		if err := msg.Decode(&txs); err != nil {
-			return errResp(ErrDecode, "msg %v: %v", msg, err)
+			return misbehaving(p, msg.Body.Type, errResp(ErrDecode, "msg %v: %v", msg, err))
 		}
 		for i, tx := range txs {
 			// Validate and mark the remote transaction
 			if tx == nil {
-				return errResp(ErrDecode, "transaction %d is nil", i)
+				return misbehaving(p, msg.Body.Type, errResp(ErrDecode, "transaction %d is nil", i))
 			}
 			p.MarkTransaction(tx.Hash())
 		}
@@ -661,29 +712,61 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		bftMsg := types.PreprepareMsg{}
 		if err := msg.Decode(&bftMsg); err != nil {
 			log.Error("Decode bftMsg Error", "err", err)
-			return errResp(ErrDecode, "msg %v: %v", msg, err)
+			return misbehaving(p, msg.Body.Type, errResp(ErrDecode, "msg %v: %v", msg, err))
 		}
-		pm.postRecBftEvent(&bftMsg)
+		pm.handleBftMsg(p.id, p.Report, &bftMsg)
 	case msg.Body.Type == BftPrepareMsg:
 		bftMsg := types.PrepareMsg{}
 		if err := msg.Decode(&bftMsg); err != nil {
 			log.Error("Decode bftMsg Error", "err", err)
-			return errResp(ErrDecode, "msg %v: %v", msg, err)
+			return misbehaving(p, msg.Body.Type, errResp(ErrDecode, "msg %v: %v", msg, err))
 		}
-		pm.postRecBftEvent(&bftMsg)
+		pm.handleBftMsg(p.id, p.Report, &bftMsg)
 	case msg.Body.Type == BftCommitMsg:
 		bftMsg := types.CommitMsg{}
 		if err := msg.Decode(&bftMsg); err != nil {
 			log.Error("Decode bftMsg Error", "err", err)
-			return errResp(ErrDecode, "msg %v: %v", msg, err)
+			return misbehaving(p, msg.Body.Type, errResp(ErrDecode, "msg %v: %v", msg, err))
 		}
-		pm.postRecBftEvent(&bftMsg)
+		pm.handleBftMsg(p.id, p.Report, &bftMsg)
 	default:
-		return errResp(ErrInvalidMsgCode, "%v", msg.Body.Type)
+		return misbehaving(p, msg.Body.Type, errResp(ErrInvalidMsgCode, "%v", msg.Body.Type))
 	}
 	return nil
 }
 
+// expired reports whether the body and receipts of a block were pruned by the
+// history expiry. The responses stop at such blocks rather than skipping them:
+// the requester matches the items to the requested blocks in order, and a peer
+// holding a stale history tail asks for the expired ones first. Given nothing,
+// it retrieves them from other peers.
+func (pm *ProtocolManager) expired(hash common.Hash) bool {
+	header := pm.blockchain.GetHeaderByHash(hash)
+	if header == nil {
+		return false
+	}
+	number := header.Number.Uint64()
+	return number > 0 && number < pm.blockchain.HistoryTail()
+}
+
+// misbehaving reports a peer for a message of the given type which failed to
+// decode or validate, passing on the error tearing down the connection.
+func misbehaving(p *peer, code vntp2p.MessageType, err error) error {
+	p.Report(msgMisbehaviour(code))
+	return err
+}
+
+// msgMisbehaviour classifies the misbehaviour of a peer whose message of the
+// given type could not be handled.
+func msgMisbehaviour(code vntp2p.MessageType) vntp2p.Misbehaviour {
+	switch code {
+	case BftPreprepareMsg, BftPrepareMsg, BftCommitMsg:
+		return vntp2p.InvalidBftMsg
+	default:
+		return vntp2p.ProtocolViolation
+	}
+}
+
 // updatePeerHeadAndSync will update peer's head and start a sync if local fall behind of peer.
 func (pm *ProtocolManager) updatePeerHeadAndSync(p *peer, parentHash common.Hash, parentTd *big.Int) {
 	// Update the peers total difficulty if better than the previous
@@ -702,6 +785,24 @@ func (pm *ProtocolManager) updatePeerHeadAndSync(p *peer, parentHash common.Hash
 	}
 }
 
+// handleBftMsg hands a bft message received from a peer, directly or over the
+// witness overlay, to the consensus engine if it passes the checks of the
+// engine. The sender of a message failing them is reported, but stays
+// connected.
+func (pm *ProtocolManager) handleBftMsg(from libp2p.ID, report func(vntp2p.Misbehaviour), msg types.ConsensusMsg) {
+	if pm.bftValidator != nil {
+		// The unverifiable messages are handed to the engine all the same: the
+		// witnesses of the next block may have rotated, and the far future ones
+		// let it catch up with the network.
+		if err := pm.bftValidator.ValidateBftMsg(pm.blockchain, msg); err != nil && err != consensus.ErrUnverifiableMsg {
+			log.Debug("Invalid bft message", "peer", from, "type", msg.Type(), "h", msg.GetBlockNum(), "err", err)
+			report(vntp2p.InvalidBftMsg)
+			return
+		}
+	}
+	pm.postRecBftEvent(msg)
+}
+
 func (pm *ProtocolManager) postRecBftEvent(msg types.ConsensusMsg) {
 	log.Debug("Post RecBftEvent", "type", msg.Type(),
 		"h", msg.GetBlockNum(), "r", msg.GetRound(), "hash", msg.Hash())
@@ -735,10 +836,16 @@ func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
 func (pm *ProtocolManager) BroadcastTxs(txs types.Transactions) {
 	var txset = make(map[*peer]types.Transactions)
 
+	if pm.gossip != nil {
+		pm.publishTxs(txs)
+	}
 	// Broadcast transactions to a batch of peers not knowing about it
 	for _, tx := range txs {
 		peers := pm.peers.PeersWithoutTx(tx.Hash())
 		for _, peer := range peers {
+			if pm.gossip != nil && pm.gossip.Connected(peer.id) {
+				continue
+			}
 			txset[peer] = append(txset[peer], tx)
 		}
 		log.Trace("Broadcast transaction", "hash", tx.Hash(), "recipients", len(peers))
@@ -750,12 +857,22 @@ func (pm *ProtocolManager) BroadcastTxs(txs types.Transactions) {
 }
 
 func (pm *ProtocolManager) BroadcastBftMsg(bftMsg types.BftMsg) {
-	peers := pm.peers.PeersForBft()
+	ids, peers := pm.peers.BftPeers()
 	log.Trace("BroadcastBftMsg", "type", bftMsg.BftType, "hash", bftMsg.Msg.Hash(), "number of bft peer", len(peers))
 
-	for _, p := range peers {
+	if pm.gossip != nil {
+		pm.publishBftMsg(bftMsg)
+	}
+	for _, id := range ids {
 		// using goroutine for each peer for peer may connection
-		go func(p *peer) {
+		go func(id libp2p.ID, p *peer) {
+			// Witnesses connected over the witness overlay need no other delivery
+			if pm.sendWitnessBftMsg(id, bftMsg) {
+				return
+			}
+			if p == nil || pm.gossip != nil && pm.gossip.Connected(p.id) {
+				return
+			}
 			log.Trace("BroadcastBftMsg", "to peer", p.id.ToString())
 			err := p.SendBftMsg(bftMsg)
 			if err != nil {
@@ -763,7 +880,7 @@ func (pm *ProtocolManager) BroadcastBftMsg(bftMsg types.BftMsg) {
 			} else {
 				log.Trace("BroadcastBftMsg success", "to peer", p.id.ToString())
 			}
-		}(p)
+		}(id, peers[id])
 	}
 
 	log.Trace("BroadcastBftMsg exit")
@@ -50,6 +50,10 @@ var (
 	reqReceiptInTrafficMeter  = metrics.NewRegisteredMeter("vnt/req/receipts/in/traffic", nil)
 	reqReceiptOutPacketsMeter = metrics.NewRegisteredMeter("vnt/req/receipts/out/packets", nil)
 	reqReceiptOutTrafficMeter = metrics.NewRegisteredMeter("vnt/req/receipts/out/traffic", nil)
+	bftInPacketsMeter         = metrics.NewRegisteredMeter("vnt/bft/in/packets", nil)
+	bftInTrafficMeter         = metrics.NewRegisteredMeter("vnt/bft/in/traffic", nil)
+	bftOutPacketsMeter        = metrics.NewRegisteredMeter("vnt/bft/out/packets", nil)
+	bftOutTrafficMeter        = metrics.NewRegisteredMeter("vnt/bft/out/traffic", nil)
 	miscInPacketsMeter        = metrics.NewRegisteredMeter("vnt/misc/in/packets", nil)
 	miscInTrafficMeter        = metrics.NewRegisteredMeter("vnt/misc/in/traffic", nil)
 	miscOutPacketsMeter       = metrics.NewRegisteredMeter("vnt/misc/out/packets", nil)
@@ -103,6 +107,9 @@ func (rw *meteredMsgReadWriter) ReadMsg() (vntp2p.Msg, error) {
 		packets, traffic = propBlockInPacketsMeter, propBlockInTrafficMeter
 	case msg.Body.Type == TxMsg:
 		packets, traffic = propTxnInPacketsMeter, propTxnInTrafficMeter
+
+	case msg.Body.Type == BftPreprepareMsg || msg.Body.Type == BftPrepareMsg || msg.Body.Type == BftCommitMsg:
+		packets, traffic = bftInPacketsMeter, bftInTrafficMeter
 	}
 	packets.Mark(1)
 	traffic.Mark(int64(msg.GetBodySize()))
@@ -130,6 +137,9 @@ func (rw *meteredMsgReadWriter) WriteMsg(msg vntp2p.Msg) error {
 		packets, traffic = propBlockOutPacketsMeter, propBlockOutTrafficMeter
 	case msg.Body.Type == TxMsg:
 		packets, traffic = propTxnOutPacketsMeter, propTxnOutTrafficMeter
+
+	case msg.Body.Type == BftPreprepareMsg || msg.Body.Type == BftPrepareMsg || msg.Body.Type == BftCommitMsg:
+		packets, traffic = bftOutPacketsMeter, bftOutTrafficMeter
 	}
 	packets.Mark(1)
 	traffic.Mark(int64(msg.GetBodySize()))
@@ -0,0 +1,139 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package vnt
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/consensus/mock"
+	"github.com/vntchain/go-vnt/core"
+	"github.com/vntchain/go-vnt/core/types"
+	"github.com/vntchain/go-vnt/core/vm"
+	"github.com/vntchain/go-vnt/event"
+	"github.com/vntchain/go-vnt/params"
+	"github.com/vntchain/go-vnt/vnt/downloader"
+	"github.com/vntchain/go-vnt/vntdb"
+	"github.com/vntchain/go-vnt/vntp2p"
+	"github.com/vntchain/go-vnt/vntp2p/mocknet"
+)
+
+var testGenesis = core.Genesis{
+	Config: params.TestChainConfig,
+	Alloc:  core.GenesisAlloc{common.Address{1}: {Balance: big.NewInt(1000000000)}},
+}
+
+// testTxPool is a transaction pool discarding all transactions.
+type testTxPool struct {
+	feed event.Feed
+}
+
+func (p *testTxPool) AddRemotes(txs []*types.Transaction) []error { return make([]error, len(txs)) }
+
+func (p *testTxPool) Pending() (map[common.Address]types.Transactions, error) { return nil, nil }
+
+func (p *testTxPool) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
+	return p.feed.Subscribe(ch)
+}
+
+// testNode is a protocol manager running on a simulated network.
+type testNode struct {
+	pm     *ProtocolManager
+	server *vntp2p.Server
+}
+
+// newTestNode starts a protocol manager on the network, with the given blocks
+// on top of the test genesis.
+func newTestNode(t *testing.T, net *mocknet.Network, blocks []*types.Block) *testNode {
+	db := vntdb.NewMemDatabase()
+	testGenesis.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, testGenesis.Config, mock.NewMock(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import blocks: %v", err)
+	}
+	pm, err := NewProtocolManager(testGenesis.Config, downloader.FullSync, DefaultConfig.NetworkId, new(event.TypeMux), new(testTxPool), mock.NewMock(), chain, db, nil)
+	if err != nil {
+		t.Fatalf("failed to create protocol manager: %v", err)
+	}
+	server, err := net.StartServer(vntp2p.Config{MaxPeers: 10, Protocols: pm.SubProtocols})
+	if err != nil {
+		t.Fatalf("failed to start p2p server: %v", err)
+	}
+	pm.Start(10)
+	return &testNode{pm: pm, server: server}
+}
+
+func (n *testNode) stop() {
+	n.pm.Stop()
+	n.pm.blockchain.Stop()
+}
+
+func (n *testNode) head() uint64 {
+	return n.pm.blockchain.CurrentBlock().NumberU64()
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func makeTestBlocks(n int) []*types.Block {
+	db := vntdb.NewMemDatabase()
+	genesis := testGenesis.MustCommit(db)
+	blocks, _ := core.GenerateChain(testGenesis.Config, genesis, mock.NewMock(), db, n, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{byte(i)})
+	})
+	return blocks
+}
+
+// Tests that nodes connected over a lossless simulated network synchronise,
+// and propagate the blocks they import afterwards.
+func TestMocknetSyncAndPropagation(t *testing.T) {
+	net := mocknet.New(1)
+	defer net.Close()
+	net.SetDefaultLink(mocknet.LinkOptions{Latency: 5 * time.Millisecond})
+
+	blocks := makeTestBlocks(33)
+	source := newTestNode(t, net, blocks[:32])
+	defer source.stop()
+	sink := newTestNode(t, net, nil)
+	defer sink.stop()
+
+	mocknet.Connect(sink.server, source.server)
+	waitFor(t, "peer connection", func() bool { return sink.pm.peers.Len() == 1 && source.pm.peers.Len() == 1 })
+
+	go sink.pm.synchronise(sink.pm.peers.BestPeer())
+	waitFor(t, "chain sync", func() bool { return sink.head() == 32 })
+
+	// Blocks imported by the source after the sync reach the sink by propagation
+	if _, err := source.pm.blockchain.InsertChain(blocks[32:]); err != nil {
+		t.Fatalf("failed to import block: %v", err)
+	}
+	source.pm.BroadcastBlock(blocks[32], true)
+	waitFor(t, "block propagation", func() bool { return sink.head() == 33 })
+}
@@ -89,6 +89,7 @@ type peer struct {
 
 	head common.Hash
 	td   *big.Int
+	tail uint64 // First block whose body and receipts are served
 	lock sync.RWMutex
 
 	knownTxs    *set.Set                  // Set of transaction hashes known to be known by this peer
@@ -168,6 +169,13 @@ func (p *peer) Head() (hash common.Hash, td *big.Int) {
 	return hash, new(big.Int).Set(p.td)
 }
 
+// HistoryTail retrieves the first block whose body and receipts the peer
+// advertised to serve in the handshake, zero for the whole history. The peer
+// may expire more blocks since, it answers the requests for them with nothing.
+func (p *peer) HistoryTail() uint64 {
+	return p.tail
+}
+
 // SetHead updates the head hash and total difficulty of the peer.
 func (p *peer) SetHead(hash common.Hash, td *big.Int) {
 	p.lock.Lock()
@@ -270,16 +278,7 @@ func (p *peer) SendReceiptsRLP(receipts []rlp.RawValue) error {
 
 // SendBftMsg
 func (p *peer) SendBftMsg(bftMsg types.BftMsg) error {
-	var msgType vntp2p.MessageType
-	switch bftMsg.BftType {
-	case types.BftPreprepareMessage:
-		msgType = BftPreprepareMsg
-	case types.BftPrepareMessage:
-		msgType = BftPrepareMsg
-	case types.BftCommitMessage:
-		msgType = BftCommitMsg
-	}
-	return vntp2p.Send(p.rw, ProtocolName, msgType, bftMsg.Msg)
+	return vntp2p.Send(p.rw, ProtocolName, bftMsgCode(bftMsg.BftType), bftMsg.Msg)
 }
 
 // RequestOneHeader is a wrapper around the header query functions to fetch a
@@ -324,21 +323,25 @@ func (p *peer) RequestReceipts(hashes []common.Hash) error {
 }
 
 // Handshake executes the vnt protocol handshake, negotiating version number,
-// network IDs, difficulties, head and genesis blocks.
-func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash) error {
+// network IDs, difficulties, head and genesis blocks, and the retained history.
+func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash, tail uint64) error {
 	// Send out own handshake in a new thread
 	errc := make(chan error, 2)
 	var status statusData // safe to read after two values have been received from errc
 
 	go func() {
 		log.Info("vnt protocol handshake", "going to send handshake msg to", p.id, "ProtocolVersion", uint32(p.version))
-		errc <- vntp2p.Send(p.rw, ProtocolName, StatusMsg, &statusData{
+		status := &statusData{
 			ProtocolVersion: uint32(p.version),
 			NetworkId:       network,
 			TD:              td,
 			CurrentBlock:    head,
 			GenesisBlock:    genesis,
-		})
+		}
+		if tail > 0 {
+			status.HistoryTail = []uint64{tail}
+		}
+		errc <- vntp2p.Send(p.rw, ProtocolName, StatusMsg, status)
 	}()
 
 	go func() {
@@ -358,6 +361,9 @@ func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 		}
 	}
 	p.td, p.head = status.TD, status.CurrentBlock
+	if len(status.HistoryTail) > 0 {
+		p.tail = status.HistoryTail[0]
+	}
 	return nil
 }
 
@@ -437,6 +443,39 @@ func (ps *peerSet) Register(p *peer) error {
 	return nil
 }
 
+// IsBftPeer reports whether the peer belongs to the current bft peers, which
+// get connection slots reserved beyond the peer limit.
+func (ps *peerSet) IsBftPeer(id libp2p.ID) bool {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	_, ok := ps.bftPeers[id]
+	return ok
+}
+
+// HasBftPeers reports whether the bft peers are known, which only happens on
+// witness nodes.
+func (ps *peerSet) HasBftPeers() bool {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	return len(ps.bftPeers) > 0
+}
+
+// RegularLen returns the number of connected peers which are not bft peers.
+func (ps *peerSet) RegularLen() int {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	n := len(ps.peers)
+	for id := range ps.bftPeers {
+		if _, ok := ps.peers[id]; ok {
+			n--
+		}
+	}
+	return n
+}
+
 // Unregister removes a remote peer from the active set, disabling any further
 // actions to/from that particular entity.
 func (ps *peerSet) Unregister(id libp2p.ID) error {
@@ -499,18 +538,21 @@ func (ps *peerSet) PeersWithoutTx(hash common.Hash) []*peer {
 	return list
 }
 
-func (ps *peerSet) PeersForBft() []*peer {
+// BftPeers retrieves the ids of the bft peers, along with the ones connected
+// as regular peers.
+func (ps *peerSet) BftPeers() ([]libp2p.ID, map[libp2p.ID]*peer) {
 	ps.lock.RLock()
 	defer ps.lock.RUnlock()
 
-	list := make([]*peer, 0, len(ps.bftPeers))
-	for p, _ := range ps.bftPeers {
-		// 该处需要判断是不是bft节点
-		if peer, exists := ps.peers[p]; exists {
-			list = append(list, peer)
+	ids := make([]libp2p.ID, 0, len(ps.bftPeers))
+	peers := make(map[libp2p.ID]*peer, len(ps.bftPeers))
+	for id := range ps.bftPeers {
+		ids = append(ids, id)
+		if p, ok := ps.peers[id]; ok {
+			peers[id] = p
 		}
 	}
-	return list
+	return ids, peers
 }
 
 // BestPeer retrieves the known peer with the currently highest total difficulty.
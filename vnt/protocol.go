@@ -118,6 +118,11 @@ type statusData struct {
 	TD              *big.Int
 	CurrentBlock    common.Hash
 	GenesisBlock    common.Hash
+
+	// HistoryTail holds the first block whose body and receipts are served, if
+	// the history is expired. It's omitted otherwise, keeping the status of the
+	// nodes retaining the whole history readable by the older ones.
+	HistoryTail []uint64 `rlp:"tail"`
 }
 
 type newBlockHashData struct {
@@ -0,0 +1,339 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/core"
+	"github.com/vntchain/go-vnt/core/state/snapshot"
+	"github.com/vntchain/go-vnt/crypto"
+	"github.com/vntchain/go-vnt/log"
+	"github.com/vntchain/go-vnt/rlp"
+	"github.com/vntchain/go-vnt/trie"
+	"github.com/vntchain/go-vnt/vntp2p"
+)
+
+// maxResponseSize is the cap on the data served in a single response,
+// whatever the soft limit requested.
+const maxResponseSize = 2 * 1024 * 1024
+
+var (
+	errMsgTooLarge    = errors.New("message too long")
+	errExtraStatusMsg = errors.New("extra status message")
+)
+
+// maxHash is the largest possible hash, the end of the hash space.
+var maxHash = common.BytesToHash(bytes.Repeat([]byte{0xff}, common.HashLength))
+
+// Handler serves the state of the local chain over the snap protocol, and lets
+// its syncer retrieve states from the peers supporting it.
+type Handler struct {
+	chain  *core.BlockChain
+	syncer *Syncer
+}
+
+// NewHandler creates a snap protocol handler for the chain, syncing states into
+// its database.
+func NewHandler(chain *core.BlockChain, db snapshot.Database) *Handler {
+	return &Handler{
+		chain:  chain,
+		syncer: newSyncer(db, chain.Snapshots()),
+	}
+}
+
+// Syncer returns the state syncer retrieving states over the snap protocol.
+func (h *Handler) Syncer() *Syncer {
+	return h.syncer
+}
+
+// Protocol returns the snap protocol to run with the peers.
+func (h *Handler) Protocol() vntp2p.Protocol {
+	return vntp2p.Protocol{
+		Name:    ProtocolName,
+		Version: ProtocolVersion,
+		Length:  ProtocolLength,
+		Run:     h.run,
+	}
+}
+
+// run announces the support of the protocol to a peer, then serves its requests
+// and delivers its responses to the syncer until the connection is closed.
+func (h *Handler) run(p *vntp2p.Peer, rw vntp2p.MsgReadWriter) error {
+	peer := newPeer(p, rw)
+	if err := vntp2p.Send(rw, ProtocolName, StatusMsg, &statusData{ProtocolVersion: ProtocolVersion}); err != nil {
+		return err
+	}
+	defer h.syncer.unregister(peer.id)
+
+	for {
+		if err := h.handleMsg(peer); err != nil {
+			peer.Log().Debug("Snap message handling failed", "err", err)
+			return err
+		}
+	}
+}
+
+// handleMsg is invoked whenever an inbound message is received from a remote
+// peer. The remote connection is torn down upon returning any error.
+func (h *Handler) handleMsg(p *peer) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if size := msg.GetBodySize(); size > ProtocolMaxMsgSize {
+		return violation(p, fmt.Errorf("%v: %v > %v", errMsgTooLarge, size, ProtocolMaxMsgSize))
+	}
+	switch msg.Body.Type {
+	case StatusMsg:
+		var status statusData
+		if err := msg.Decode(&status); err != nil {
+			return violation(p, err)
+		}
+		if p.registered {
+			return violation(p, errExtraStatusMsg)
+		}
+		p.registered = true
+		h.syncer.register(p)
+
+	case GetAccountRangeMsg:
+		var req getAccountRangeData
+		if err := msg.Decode(&req); err != nil {
+			return violation(p, err)
+		}
+		return p.send(AccountRangeMsg, h.serveAccountRange(&req))
+
+	case AccountRangeMsg:
+		var res accountRangeData
+		if err := msg.Decode(&res); err != nil {
+			return violation(p, err)
+		}
+		h.syncer.deliver(p.id, res.ID, &res)
+
+	case GetStorageRangesMsg:
+		var req getStorageRangesData
+		if err := msg.Decode(&req); err != nil {
+			return violation(p, err)
+		}
+		return p.send(StorageRangesMsg, h.serveStorageRanges(&req))
+
+	case StorageRangesMsg:
+		var res storageRangesData
+		if err := msg.Decode(&res); err != nil {
+			return violation(p, err)
+		}
+		h.syncer.deliver(p.id, res.ID, &res)
+
+	case GetByteCodesMsg:
+		var req getByteCodesData
+		if err := msg.Decode(&req); err != nil {
+			return violation(p, err)
+		}
+		return p.send(ByteCodesMsg, h.serveByteCodes(&req))
+
+	case ByteCodesMsg:
+		var res byteCodesData
+		if err := msg.Decode(&res); err != nil {
+			return violation(p, err)
+		}
+		h.syncer.deliver(p.id, res.ID, &res)
+
+	default:
+		return violation(p, fmt.Errorf("invalid message code %d", msg.Body.Type))
+	}
+	return nil
+}
+
+// violation reports a peer for a message which failed to decode or was not
+// expected, passing on the error tearing down the connection.
+func violation(p *peer, err error) error {
+	p.Report(vntp2p.ProtocolViolation)
+	return err
+}
+
+// responseSize returns the size of data to serve for the requested soft limit.
+func responseSize(requested uint64) int {
+	if requested > maxResponseSize {
+		return maxResponseSize
+	}
+	return int(requested)
+}
+
+// serveAccountRange retrieves an account range from the snapshot if it holds
+// the requested state, or from the state trie otherwise.
+func (h *Handler) serveAccountRange(req *getAccountRangeData) *accountRangeData {
+	res := &accountRangeData{ID: req.ID}
+
+	tr, err := trie.New(req.Root, h.chain.StateCache().TrieDB())
+	if err != nil {
+		return res // State not available
+	}
+	var accounts []snapshot.Entry
+	if snaps := h.chain.Snapshots(); snaps != nil {
+		accounts, err = snaps.AccountRange(req.Root, req.Origin, req.Limit, responseSize(req.Bytes))
+	}
+	if accounts == nil || err != nil {
+		if accounts, err = trieRange(tr, req.Origin, req.Limit, responseSize(req.Bytes)); err != nil {
+			log.Debug("Failed to serve account range", "root", req.Root, "err", err)
+			return res
+		}
+	}
+	proof := newProofSet()
+	if err := tr.Prove(req.Origin[:], 0, proof); err != nil {
+		return res
+	}
+	if len(accounts) > 0 {
+		if err := tr.Prove(accounts[len(accounts)-1].Hash[:], 0, proof); err != nil {
+			return res
+		}
+	}
+	res.Accounts, res.Proof = accounts, proof.list
+	return res
+}
+
+// serveStorageRanges retrieves the storage slots of the requested accounts,
+// until the size limit is reached.
+func (h *Handler) serveStorageRanges(req *getStorageRangesData) *storageRangesData {
+	res := &storageRangesData{ID: req.ID}
+
+	triedb := h.chain.StateCache().TrieDB()
+	accTrie, err := trie.New(req.Root, triedb)
+	if err != nil {
+		return res // State not available
+	}
+	snaps := h.chain.Snapshots()
+	size := responseSize(req.Bytes)
+
+	for i, account := range req.Accounts {
+		if size <= 0 {
+			break
+		}
+		blob, err := accTrie.TryGet(account[:])
+		if err != nil || blob == nil {
+			break
+		}
+		var acc snapshot.Account
+		if err := rlp.DecodeBytes(blob, &acc); err != nil {
+			break
+		}
+		storeTrie, err := trie.New(acc.Root, triedb)
+		if err != nil {
+			break
+		}
+		origin, limit := common.Hash{}, maxHash
+		if i == 0 {
+			origin = req.Origin
+		}
+		if i == len(req.Accounts)-1 && req.Limit != (common.Hash{}) {
+			limit = req.Limit
+		}
+		var slots []snapshot.Entry
+		if snaps != nil {
+			slots, err = snaps.StorageRange(req.Root, account, origin, limit, size)
+		}
+		if slots == nil || err != nil {
+			if slots, err = trieRange(storeTrie, origin, limit, size); err != nil {
+				log.Debug("Failed to serve storage range", "root", req.Root, "account", account, "err", err)
+				break
+			}
+		}
+		for _, slot := range slots {
+			size -= common.HashLength + len(slot.Body)
+		}
+		res.Slots = append(res.Slots, slots)
+
+		// Slots of partial ranges are proven, and end the response
+		if origin != (common.Hash{}) || limit != maxHash || size <= 0 {
+			proof := newProofSet()
+			if err := storeTrie.Prove(origin[:], 0, proof); err != nil {
+				res.Slots = res.Slots[:len(res.Slots)-1]
+				break
+			}
+			if len(slots) > 0 {
+				if err := storeTrie.Prove(slots[len(slots)-1].Hash[:], 0, proof); err != nil {
+					res.Slots = res.Slots[:len(res.Slots)-1]
+					break
+				}
+			}
+			res.Proof = proof.list
+			break
+		}
+	}
+	return res
+}
+
+// serveByteCodes retrieves the requested contract codes, until the size limit
+// is reached.
+func (h *Handler) serveByteCodes(req *getByteCodesData) *byteCodesData {
+	res := &byteCodesData{ID: req.ID}
+
+	triedb := h.chain.StateCache().TrieDB()
+	size := responseSize(req.Bytes)
+	for _, hash := range req.Hashes {
+		if size <= 0 {
+			break
+		}
+		if code, err := triedb.Node(hash); err == nil {
+			res.Codes = append(res.Codes, code)
+			size -= len(code)
+		}
+	}
+	return res
+}
+
+// trieRange collects the leaves of a trie from origin on, ending with the first
+// one at or beyond limit, or once maxBytes are collected.
+func trieRange(tr *trie.Trie, origin, limit common.Hash, maxBytes int) ([]snapshot.Entry, error) {
+	var (
+		entries []snapshot.Entry
+		size    int
+	)
+	it := trie.NewIterator(tr.NodeIterator(origin[:]))
+	for it.Next() {
+		hash := common.BytesToHash(it.Key)
+		entries = append(entries, snapshot.Entry{Hash: hash, Body: common.CopyBytes(it.Value)})
+
+		size += common.HashLength + len(it.Value)
+		if bytes.Compare(hash[:], limit[:]) >= 0 || size >= maxBytes {
+			break
+		}
+	}
+	return entries, it.Err
+}
+
+// proofSet collects the distinct nodes of merkle proofs.
+type proofSet struct {
+	seen map[common.Hash]struct{}
+	list [][]byte
+}
+
+func newProofSet() *proofSet {
+	return &proofSet{seen: make(map[common.Hash]struct{})}
+}
+
+// Put implements vntdb.Putter, collecting the proof nodes.
+func (p *proofSet) Put(key []byte, value []byte) error {
+	hash := crypto.Keccak256Hash(value)
+	if _, ok := p.seen[hash]; !ok {
+		p.seen[hash] = struct{}{}
+		p.list = append(p.list, common.CopyBytes(value))
+	}
+	return nil
+}
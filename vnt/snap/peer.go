@@ -0,0 +1,64 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	libp2p "github.com/libp2p/go-libp2p-peer"
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/vntp2p"
+)
+
+// peer is a remote node running the snap protocol.
+type peer struct {
+	*vntp2p.Peer
+
+	id libp2p.ID
+	rw vntp2p.MsgReadWriter
+
+	registered bool // Whether the peer announced the support of the protocol
+}
+
+func newPeer(p *vntp2p.Peer, rw vntp2p.MsgReadWriter) *peer {
+	return &peer{
+		Peer: p,
+		id:   p.RemoteID(),
+		rw:   rw,
+	}
+}
+
+// send sends a snap protocol message to the peer.
+func (p *peer) send(code vntp2p.MessageType, data interface{}) error {
+	return vntp2p.Send(p.rw, ProtocolName, code, data)
+}
+
+// requestAccountRange fetches a range of accounts of a state.
+func (p *peer) requestAccountRange(id uint64, root, origin, limit common.Hash, bytes uint64) error {
+	p.Log().Trace("Fetching range of accounts", "reqid", id, "root", root, "origin", origin, "limit", limit)
+	return p.send(GetAccountRangeMsg, &getAccountRangeData{ID: id, Root: root, Origin: origin, Limit: limit, Bytes: bytes})
+}
+
+// requestStorageRanges fetches the storage slots of accounts of a state.
+func (p *peer) requestStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin, limit common.Hash, bytes uint64) error {
+	p.Log().Trace("Fetching ranges of storage slots", "reqid", id, "root", root, "accounts", len(accounts), "origin", origin)
+	return p.send(GetStorageRangesMsg, &getStorageRangesData{ID: id, Root: root, Accounts: accounts, Origin: origin, Limit: limit, Bytes: bytes})
+}
+
+// requestByteCodes fetches contract codes by hash.
+func (p *peer) requestByteCodes(id uint64, hashes []common.Hash, bytes uint64) error {
+	p.Log().Trace("Fetching contract codes", "reqid", id, "hashes", len(hashes))
+	return p.send(GetByteCodesMsg, &getByteCodesData{ID: id, Hashes: hashes, Bytes: bytes})
+}
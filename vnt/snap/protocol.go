@@ -0,0 +1,107 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snap implements the snap protocol, retrieving the state in contiguous
+// account and storage ranges proven against the state root by the merkle proofs
+// of their edges, instead of trie node by trie node.
+package snap
+
+import (
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/core/state/snapshot"
+)
+
+// ProtocolName is the official short name of the protocol used during capability negotiation.
+const ProtocolName = "snap"
+
+// ProtocolVersion is the supported version of the snap protocol.
+const ProtocolVersion = 1
+
+// ProtocolLength is the number of implemented messages.
+const ProtocolLength = 7
+
+// ProtocolMaxMsgSize is the maximum cap on the size of a protocol message.
+const ProtocolMaxMsgSize = 10 * 1024 * 1024
+
+// snap protocol message codes
+const (
+	StatusMsg           = 0x00
+	GetAccountRangeMsg  = 0x01
+	AccountRangeMsg     = 0x02
+	GetStorageRangesMsg = 0x03
+	StorageRangesMsg    = 0x04
+	GetByteCodesMsg     = 0x05
+	ByteCodesMsg        = 0x06
+)
+
+// statusData is the network packet announcing the support of the protocol.
+type statusData struct {
+	ProtocolVersion uint32
+}
+
+// getAccountRangeData is the network packet requesting the accounts of a state
+// from origin on, up to the first one at or beyond limit.
+type getAccountRangeData struct {
+	ID     uint64      // Request ID to match up the response with
+	Root   common.Hash // Root of the state to serve
+	Origin common.Hash // Hash of the first account to retrieve
+	Limit  common.Hash // Hash of the account after which to stop serving
+	Bytes  uint64      // Soft limit at which to stop returning data
+}
+
+// accountRangeData is the network packet of an account range, along with the
+// merkle proofs of its edges. Peers not serving the requested state reply with
+// neither accounts nor proofs.
+type accountRangeData struct {
+	ID       uint64
+	Accounts []snapshot.Entry
+	Proof    [][]byte
+}
+
+// getStorageRangesData is the network packet requesting the storage slots of
+// accounts of a state. The origin applies to the first account only, and the
+// limit to the last one.
+type getStorageRangesData struct {
+	ID       uint64
+	Root     common.Hash
+	Accounts []common.Hash
+	Origin   common.Hash
+	Limit    common.Hash
+	Bytes    uint64
+}
+
+// storageRangesData is the network packet of the storage slots of accounts.
+// All the slots of every account are included, except the last account when
+// a proof is attached: its slots are a range proven by it.
+type storageRangesData struct {
+	ID    uint64
+	Slots [][]snapshot.Entry
+	Proof [][]byte
+}
+
+// getByteCodesData is the network packet requesting contract codes.
+type getByteCodesData struct {
+	ID     uint64
+	Hashes []common.Hash
+	Bytes  uint64
+}
+
+// byteCodesData is the network packet of contract codes, in the order of the
+// request. Unavailable codes are skipped.
+type byteCodesData struct {
+	ID    uint64
+	Codes [][]byte
+}
@@ -0,0 +1,905 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	libp2p "github.com/libp2p/go-libp2p-peer"
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/core/rawdb"
+	"github.com/vntchain/go-vnt/core/state/snapshot"
+	"github.com/vntchain/go-vnt/crypto"
+	"github.com/vntchain/go-vnt/log"
+	"github.com/vntchain/go-vnt/rlp"
+	"github.com/vntchain/go-vnt/trie"
+	"github.com/vntchain/go-vnt/vntdb"
+	"github.com/vntchain/go-vnt/vntp2p"
+)
+
+var (
+	// softResponseLimit is the size of data requested from a peer at once.
+	softResponseLimit uint64 = 512 * 1024
+
+	// requestTimeout is the time allowed to a peer to answer a request.
+	requestTimeout = 10 * time.Second
+
+	// peerWaitTimeout is the time to wait for a peer able to serve the state
+	// before giving up on the snap sync.
+	peerWaitTimeout = 5 * time.Second
+
+	// statusInterval is the interval of the storage of the sync progress, kept
+	// to resume the sync if interrupted.
+	statusInterval = 10 * time.Second
+)
+
+const (
+	accountConcurrency = 16  // Number of account ranges retrieved concurrently
+	maxStorageAccounts = 128 // Maximum number of accounts in a storage request
+	maxCodeHashes      = 64  // Maximum number of hashes in a code request
+)
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCode is the known hash of the empty VM bytecode.
+	emptyCode = crypto.Keccak256Hash(nil)
+
+	errSyncRunning   = errors.New("snap sync already running")
+	errNoPeers       = errors.New("no peers to snap sync with")
+	errCancelled     = errors.New("snap sync cancelled")
+	errTooManySlots  = errors.New("storage slots of unrequested accounts")
+	errUnrequested   = errors.New("unrequested contract code")
+	errMissingProof  = errors.New("storage range continuation without proof")
+	errUnexpectedMsg = errors.New("response to another kind of request")
+	errStaleAccounts = errors.New("accounts retrieved for another state root")
+)
+
+// response is a response delivered by a peer.
+type response struct {
+	peer libp2p.ID
+	id   uint64
+	data interface{}
+}
+
+// Syncer retrieves a state from the peers supporting the snap protocol: the
+// accounts and storage slots are downloaded in proven ranges into the flat
+// snapshot, from which the tries are then rebuilt locally.
+type Syncer struct {
+	db    snapshot.Database
+	snaps *snapshot.Tree // Snapshot to fill, nil if not maintained
+
+	peers     map[libp2p.ID]*peer
+	peersLock sync.RWMutex
+
+	update    chan struct{}  // Notification channel for peer set changes
+	responses chan *response // Delivery channel for peer responses
+
+	reqID   uint64 // Last request ID, only used by the running sync
+	running int32  // Whether a sync is running, accessed atomically
+}
+
+func newSyncer(db snapshot.Database, snaps *snapshot.Tree) *Syncer {
+	return &Syncer{
+		db:        db,
+		snaps:     snaps,
+		peers:     make(map[libp2p.ID]*peer),
+		update:    make(chan struct{}, 1),
+		responses: make(chan *response, 64),
+	}
+}
+
+// register adds a peer supporting the protocol to the ones synced with.
+func (s *Syncer) register(p *peer) {
+	s.peersLock.Lock()
+	s.peers[p.id] = p
+	s.peersLock.Unlock()
+
+	s.notify()
+}
+
+// unregister removes a disconnected peer.
+func (s *Syncer) unregister(id libp2p.ID) {
+	s.peersLock.Lock()
+	delete(s.peers, id)
+	s.peersLock.Unlock()
+
+	s.notify()
+}
+
+// peer retrieves a registered peer, nil if it's not.
+func (s *Syncer) peer(id libp2p.ID) *peer {
+	s.peersLock.RLock()
+	defer s.peersLock.RUnlock()
+
+	return s.peers[id]
+}
+
+// notify wakes up the running sync on peer set changes.
+func (s *Syncer) notify() {
+	select {
+	case s.update <- struct{}{}:
+	default:
+	}
+}
+
+// deliver hands a peer response over to the running sync. Responses arriving
+// when no sync is running, or that can't be queued, are dropped, the requests
+// timing out instead.
+func (s *Syncer) deliver(peer libp2p.ID, id uint64, data interface{}) {
+	if atomic.LoadInt32(&s.running) == 0 {
+		return
+	}
+	select {
+	case s.responses <- &response{peer: peer, id: id, data: data}:
+	default:
+	}
+}
+
+// Sync retrieves the state of the given root into the database, until complete
+// or cancelled. An error is returned if it fails or no peer can serve it, the
+// state being left for the trie node sync to complete.
+//
+// The progress of an interrupted sync is resumed, even for another root as when
+// the pivot of the fast sync moves. The accounts retrieved for the previous
+// root are kept then, the trie node sync of the new root healing the ones
+// changed since, and the snapshot is regenerated once the chain is synced.
+func (s *Syncer) Sync(root common.Hash, cancel chan struct{}) error {
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return errSyncRunning
+	}
+	defer atomic.StoreInt32(&s.running, 0)
+
+	triedb := trie.NewDatabase(s.db)
+	if _, err := trie.New(root, triedb); err == nil {
+		return nil // State already present
+	}
+	if s.snaps != nil {
+		s.snaps.Suspend()
+	}
+	log.Info("Starting snap sync", "root", root)
+
+	r := newSyncRun(s, root, triedb)
+	if err := r.loop(cancel); err != nil {
+		r.saveStatus()
+		return err
+	}
+	rawdb.DeleteSnapSyncStatus(s.db)
+
+	if err := r.commitAccounts(); err == errStaleAccounts {
+		log.Info("Snap sync retrieved stale accounts, healing", "root", root, "accounts", r.accounts,
+			"elapsed", common.PrettyDuration(time.Since(r.start)))
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if s.snaps != nil {
+		s.snaps.Complete(root)
+	}
+	log.Info("Snap sync complete", "root", root, "accounts", r.accounts, "slots", r.slots, "codes", r.codes,
+		"elapsed", common.PrettyDuration(time.Since(r.start)))
+	return nil
+}
+
+// accountTask is a range of the account hash space left to retrieve.
+type accountTask struct {
+	next common.Hash // Next account to retrieve
+	last common.Hash // Last account of the range
+	busy bool        // Whether a request of the range is in flight
+	done bool        // Whether the range is completely retrieved
+}
+
+// storageTask is an account whose storage is left to retrieve.
+type storageTask struct {
+	account common.Hash
+	root    common.Hash
+	origin  common.Hash // Next slot to retrieve, non zero when continuing a large storage
+}
+
+// request is a request in flight, along with the tasks it retrieves.
+type request struct {
+	peer    libp2p.ID
+	sent    time.Time
+	account *accountTask
+	storage []*storageTask
+	codes   []common.Hash
+}
+
+// syncRun is the state of a running snap sync.
+type syncRun struct {
+	*Syncer
+
+	root   common.Hash
+	triedb *trie.Database
+
+	accountTasks []*accountTask
+	storageTasks []*storageTask
+	codeTasks    []common.Hash
+	codeQueued   map[common.Hash]struct{}
+
+	pending   map[uint64]*request
+	busy      map[libp2p.ID]struct{} // Peers with a request in flight
+	stateless map[libp2p.ID]struct{} // Peers not serving the state
+
+	stale bool // Whether accounts were retrieved for another root, left to heal
+
+	accounts, slots, codes int
+	start                  time.Time
+	saved                  time.Time // Time the progress was last stored
+	err                    error     // Database failure ending the sync
+}
+
+func newSyncRun(s *Syncer, root common.Hash, triedb *trie.Database) *syncRun {
+	r := &syncRun{
+		Syncer:     s,
+		root:       root,
+		triedb:     triedb,
+		codeQueued: make(map[common.Hash]struct{}),
+		pending:    make(map[uint64]*request),
+		busy:       make(map[libp2p.ID]struct{}),
+		stateless:  make(map[libp2p.ID]struct{}),
+		start:      time.Now(),
+		saved:      time.Now(),
+	}
+	// Split the account hash space in ranges retrieved concurrently
+	var (
+		one  = big.NewInt(1)
+		step = new(big.Int).Div(new(big.Int).Lsh(one, 256), big.NewInt(accountConcurrency))
+		next = new(big.Int)
+	)
+	for i := 0; i < accountConcurrency; i++ {
+		last := new(big.Int).Sub(new(big.Int).Add(next, step), one)
+		if i == accountConcurrency-1 {
+			last = maxHash.Big()
+		}
+		r.accountTasks = append(r.accountTasks, &accountTask{next: common.BigToHash(next), last: common.BigToHash(last)})
+		next = new(big.Int).Add(last, one)
+	}
+	r.loadStatus()
+	return r
+}
+
+// syncStatus is the progress of an interrupted snap sync, as stored.
+type syncStatus struct {
+	Root     common.Hash
+	Stale    bool // Whether accounts were retrieved for an earlier root
+	Accounts []accountStatus
+	Storage  []storageStatus
+	Codes    []common.Hash
+}
+
+// accountStatus is the progress of an account range.
+type accountStatus struct {
+	Next common.Hash
+	Last common.Hash
+	Done bool
+}
+
+// storageStatus is an account whose storage is left to retrieve.
+type storageStatus struct {
+	Account common.Hash
+	Root    common.Hash
+	Origin  common.Hash
+}
+
+// loadStatus resumes the progress of an interrupted sync. The accounts retrieved
+// for another root are kept, the accounts whose storage was left to retrieve
+// being retrieved again, as their storage may have changed.
+func (r *syncRun) loadStatus() {
+	data := rawdb.ReadSnapSyncStatus(r.db)
+	if data == nil {
+		return
+	}
+	var status syncStatus
+	if err := rlp.DecodeBytes(data, &status); err != nil || len(status.Accounts) == 0 {
+		log.Warn("Failed to decode snap sync status", "err", err)
+		return
+	}
+	r.accountTasks = r.accountTasks[:0]
+	for _, task := range status.Accounts {
+		r.accountTasks = append(r.accountTasks, &accountTask{next: task.Next, last: task.Last, done: task.Done})
+	}
+	for _, hash := range status.Codes {
+		r.codeQueued[hash] = struct{}{}
+		r.codeTasks = append(r.codeTasks, hash)
+	}
+	r.stale = status.Stale || status.Root != r.root
+	for _, task := range status.Storage {
+		if status.Root == r.root {
+			r.storageTasks = append(r.storageTasks, &storageTask{account: task.Account, root: task.Root, origin: task.Origin})
+		} else {
+			r.reopen(task.Account)
+		}
+	}
+	log.Info("Resuming snap sync", "root", r.root, "previous", status.Root)
+}
+
+// reopen retrieves an account again, along with the rest of its range.
+func (r *syncRun) reopen(account common.Hash) {
+	for _, task := range r.accountTasks {
+		if bytes.Compare(account[:], task.last[:]) > 0 {
+			continue
+		}
+		if task.done || bytes.Compare(account[:], task.next[:]) < 0 {
+			task.next, task.done = account, false
+		}
+		return
+	}
+}
+
+// saveStatus stores the progress of the sync, along with the tasks of the
+// requests in flight.
+func (r *syncRun) saveStatus() {
+	status := syncStatus{Root: r.root, Stale: r.stale}
+	for _, task := range r.accountTasks {
+		status.Accounts = append(status.Accounts, accountStatus{Next: task.next, Last: task.last, Done: task.done})
+	}
+	storage, codes := r.storageTasks, r.codeTasks
+	for _, req := range r.pending {
+		storage = append(storage, req.storage...)
+		codes = append(codes, req.codes...)
+	}
+	for _, task := range storage {
+		status.Storage = append(status.Storage, storageStatus{Account: task.account, Root: task.root, Origin: task.origin})
+	}
+	status.Codes = codes
+
+	data, err := rlp.EncodeToBytes(&status)
+	if err != nil {
+		log.Warn("Failed to encode snap sync status", "err", err)
+		return
+	}
+	rawdb.WriteSnapSyncStatus(r.db, data)
+	r.saved = time.Now()
+}
+
+// loop assigns the tasks to the peers and processes their responses until the
+// state is completely retrieved.
+func (r *syncRun) loop(cancel chan struct{}) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	active := time.Now()
+	for !r.done() {
+		r.assign()
+		if len(r.pending) > 0 {
+			active = time.Now()
+		} else if time.Since(active) > peerWaitTimeout {
+			return errNoPeers
+		}
+		select {
+		case <-cancel:
+			return errCancelled
+		case <-r.update:
+			r.expire()
+		case res := <-r.responses:
+			r.process(res)
+		case <-ticker.C:
+			r.expire()
+			if time.Since(r.saved) > statusInterval {
+				r.saveStatus()
+			}
+		}
+		if r.err != nil {
+			return r.err
+		}
+	}
+	return nil
+}
+
+// done reports whether the state is completely retrieved.
+func (r *syncRun) done() bool {
+	for _, task := range r.accountTasks {
+		if !task.done {
+			return false
+		}
+	}
+	return len(r.storageTasks) == 0 && len(r.codeTasks) == 0 && len(r.pending) == 0
+}
+
+// assign sends a request to every idle peer able to serve the state, codes and
+// storage first to keep their queues short.
+func (r *syncRun) assign() {
+	r.peersLock.RLock()
+	peers := make([]*peer, 0, len(r.peers))
+	for _, p := range r.peers {
+		peers = append(peers, p)
+	}
+	r.peersLock.RUnlock()
+
+	for _, p := range peers {
+		if _, ok := r.busy[p.id]; ok {
+			continue
+		}
+		if _, ok := r.stateless[p.id]; ok {
+			continue
+		}
+		r.reqID++
+		id, req := r.reqID, &request{peer: p.id, sent: time.Now()}
+
+		var err error
+		switch {
+		case len(r.codeTasks) > 0:
+			n := len(r.codeTasks)
+			if n > maxCodeHashes {
+				n = maxCodeHashes
+			}
+			req.codes = append([]common.Hash{}, r.codeTasks[:n]...)
+			r.codeTasks = r.codeTasks[n:]
+			err = p.requestByteCodes(id, req.codes, softResponseLimit)
+
+		case len(r.storageTasks) > 0:
+			// Large storages are continued alone, the others are batched
+			n := 1
+			if r.storageTasks[0].origin == (common.Hash{}) {
+				for n < len(r.storageTasks) && n < maxStorageAccounts && r.storageTasks[n].origin == (common.Hash{}) {
+					n++
+				}
+			}
+			req.storage = append([]*storageTask{}, r.storageTasks[:n]...)
+			r.storageTasks = r.storageTasks[n:]
+
+			accounts := make([]common.Hash, len(req.storage))
+			for i, task := range req.storage {
+				accounts[i] = task.account
+			}
+			err = p.requestStorageRanges(id, r.root, accounts, req.storage[0].origin, common.Hash{}, softResponseLimit)
+
+		default:
+			for _, task := range r.accountTasks {
+				if !task.busy && !task.done {
+					req.account = task
+					break
+				}
+			}
+			if req.account == nil {
+				return // Nothing left to assign
+			}
+			req.account.busy = true
+			err = p.requestAccountRange(id, r.root, req.account.next, req.account.last, softResponseLimit)
+		}
+		if err != nil {
+			r.revert(req)
+			continue
+		}
+		r.pending[id] = req
+		r.busy[p.id] = struct{}{}
+	}
+}
+
+// revert puts the tasks of a failed request back in the queues.
+func (r *syncRun) revert(req *request) {
+	if req.account != nil {
+		req.account.busy = false
+	}
+	r.storageTasks = append(req.storage, r.storageTasks...)
+	r.codeTasks = append(r.codeTasks, req.codes...)
+}
+
+// expire reverts the requests of the dropped peers and the timed out ones.
+func (r *syncRun) expire() {
+	for id, req := range r.pending {
+		p := r.peer(req.peer)
+		if p != nil && time.Since(req.sent) < requestTimeout {
+			continue
+		}
+		if p != nil {
+			p.Log().Debug("Snap request timed out", "reqid", id)
+			p.Report(vntp2p.RequestTimeout)
+		}
+		delete(r.pending, id)
+		delete(r.busy, req.peer)
+		r.revert(req)
+	}
+}
+
+// process handles a peer response, reverting its request if it's invalid or
+// the peer doesn't serve the state.
+func (r *syncRun) process(res *response) {
+	req := r.pending[res.id]
+	if req == nil || req.peer != res.peer {
+		return // Stale or unsolicited response
+	}
+	delete(r.pending, res.id)
+	delete(r.busy, res.peer)
+
+	var err error
+	switch data := res.data.(type) {
+	case *accountRangeData:
+		if req.account == nil {
+			err = errUnexpectedMsg
+		} else {
+			err = r.processAccounts(req, data)
+		}
+	case *storageRangesData:
+		if req.storage == nil {
+			err = errUnexpectedMsg
+		} else {
+			err = r.processStorage(req, data)
+		}
+	case *byteCodesData:
+		if req.codes == nil {
+			err = errUnexpectedMsg
+		} else {
+			err = r.processCodes(req, data)
+		}
+	}
+	if err != nil {
+		r.revert(req)
+		if p := r.peer(res.peer); p != nil {
+			p.Log().Debug("Invalid snap response", "reqid", res.id, "err", err)
+			p.Report(vntp2p.ProtocolViolation)
+		}
+	}
+}
+
+// markStateless stops requesting the state from a peer that doesn't serve it.
+func (r *syncRun) markStateless(req *request) {
+	log.Debug("Peer not serving the snap sync state", "peer", req.peer, "root", r.root)
+	r.stateless[req.peer] = struct{}{}
+	r.revert(req)
+}
+
+// processAccounts verifies a range of accounts against the state root, writes
+// them into the snapshot and queues their missing codes and storages.
+func (r *syncRun) processAccounts(req *request, res *accountRangeData) error {
+	task := req.account
+	if len(res.Accounts) == 0 && len(res.Proof) == 0 {
+		r.markStateless(req)
+		return nil
+	}
+	keys := make([][]byte, len(res.Accounts))
+	values := make([][]byte, len(res.Accounts))
+	for i, account := range res.Accounts {
+		keys[i], values[i] = common.CopyBytes(account.Hash[:]), account.Body
+	}
+	last := task.next
+	if len(keys) > 0 {
+		last = res.Accounts[len(keys)-1].Hash
+	}
+	more, err := trie.VerifyRangeProof(r.root, task.next[:], last[:], keys, values, proofDB(res.Proof))
+	if err != nil {
+		return err
+	}
+	// Drop the accounts beyond the range of the task
+	for len(keys) > 0 && bytes.Compare(keys[len(keys)-1], task.last[:]) > 0 {
+		keys, values, more = keys[:len(keys)-1], values[:len(values)-1], false
+	}
+	end := task.last
+	if more {
+		end = common.BytesToHash(keys[len(keys)-1])
+	}
+	accounts := make([]snapshot.Account, len(keys))
+	for i, value := range values {
+		if err := rlp.DecodeBytes(value, &accounts[i]); err != nil {
+			return err
+		}
+	}
+	batch := r.db.NewBatch()
+
+	// Delete the stale accounts of the range left by a previous state
+	retrieved := make(map[common.Hash]struct{}, len(keys))
+	for _, key := range keys {
+		retrieved[common.BytesToHash(key)] = struct{}{}
+	}
+	if err := r.deleteStaleAccounts(batch, task.next, end, retrieved); err != nil {
+		r.err = err
+		return nil
+	}
+	for i, key := range keys {
+		hash := common.BytesToHash(key)
+		rawdb.WriteAccountSnapshot(batch, hash, values[i])
+
+		account := accounts[i]
+		if code := common.BytesToHash(account.CodeHash); code != emptyCode {
+			if _, ok := r.codeQueued[code]; !ok {
+				if has, _ := r.db.Has(code[:]); !has {
+					r.codeQueued[code] = struct{}{}
+					r.codeTasks = append(r.codeTasks, code)
+				}
+			}
+		}
+		if err := deletePrefix(r.db, batch, rawdb.StorageSnapshotsKey(hash)); err != nil {
+			r.err = err
+			return nil
+		}
+		if account.Root == emptyRoot {
+			continue
+		}
+		// Storages already present locally only need their snapshot
+		if storeTrie, err := trie.New(account.Root, r.triedb); err == nil {
+			it := trie.NewIterator(storeTrie.NodeIterator(nil))
+			for it.Next() {
+				rawdb.WriteStorageSnapshot(batch, hash, common.BytesToHash(it.Key), common.CopyBytes(it.Value))
+				r.slots++
+			}
+			if it.Err == nil {
+				continue
+			}
+			if err := deletePrefix(r.db, batch, rawdb.StorageSnapshotsKey(hash)); err != nil {
+				r.err = err
+				return nil
+			}
+		}
+		r.storageTasks = append(r.storageTasks, &storageTask{account: hash, root: account.Root})
+	}
+	if err := batch.Write(); err != nil {
+		r.err = err
+		return nil
+	}
+	r.accounts += len(keys)
+
+	task.busy = false
+	if more {
+		task.next = incHash(end)
+	} else {
+		task.done = true
+	}
+	return nil
+}
+
+// deleteStaleAccounts deletes the snapshot accounts between origin and end not
+// retrieved, along with their storage.
+func (r *syncRun) deleteStaleAccounts(batch vntdb.Batch, origin, end common.Hash, retrieved map[common.Hash]struct{}) error {
+	prefix := rawdb.SnapshotAccountPrefix
+	it := r.db.NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	for ok := it.Seek(append(common.CopyBytes(prefix), origin[:]...)); ok; ok = it.Next() {
+		key := it.Key()
+		if len(key) != len(prefix)+common.HashLength {
+			continue
+		}
+		hash := common.BytesToHash(key[len(prefix):])
+		if bytes.Compare(hash[:], end[:]) > 0 {
+			break
+		}
+		if _, ok := retrieved[hash]; ok {
+			continue
+		}
+		rawdb.DeleteAccountSnapshot(batch, hash)
+		if err := deletePrefix(r.db, batch, rawdb.StorageSnapshotsKey(hash)); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// processStorage verifies the storage slots of accounts against their storage
+// roots, writes them into the snapshot and commits the complete storage tries.
+func (r *syncRun) processStorage(req *request, res *storageRangesData) error {
+	if len(res.Slots) == 0 && len(res.Proof) == 0 {
+		r.markStateless(req)
+		return nil
+	}
+	if len(res.Slots) > len(req.storage) {
+		return errTooManySlots
+	}
+	var (
+		tries  []*trie.Trie // Storage tries of the accounts retrieved whole
+		proven *storageTask // Account whose slots are a proven range, if any
+		resume common.Hash  // Slot to resume the proven account from, zero if done
+	)
+	for i, slots := range res.Slots {
+		task := req.storage[i]
+		keys := make([][]byte, len(slots))
+		values := make([][]byte, len(slots))
+		for j, slot := range slots {
+			keys[j], values[j] = common.CopyBytes(slot.Hash[:]), slot.Body
+		}
+		if i == len(res.Slots)-1 && len(res.Proof) > 0 {
+			last := task.origin
+			if len(keys) > 0 {
+				last = slots[len(slots)-1].Hash
+			}
+			more, err := trie.VerifyRangeProof(task.root, task.origin[:], last[:], keys, values, proofDB(res.Proof))
+			if err != nil {
+				return err
+			}
+			if proven = task; more {
+				resume = incHash(last)
+			}
+			continue
+		}
+		if task.origin != (common.Hash{}) {
+			return errMissingProof
+		}
+		tr, _ := trie.New(common.Hash{}, r.triedb)
+		for j, key := range keys {
+			if err := tr.TryUpdate(key, values[j]); err != nil {
+				return err
+			}
+		}
+		if root := tr.Hash(); root != task.root {
+			return fmt.Errorf("storage root mismatch: have %x, want %x", root, task.root)
+		}
+		tries = append(tries, tr)
+	}
+	// All the slots are valid, write them and commit the complete storages
+	batch := r.db.NewBatch()
+	for i, slots := range res.Slots {
+		for _, slot := range slots {
+			rawdb.WriteStorageSnapshot(batch, req.storage[i].account, slot.Hash, slot.Body)
+		}
+		r.slots += len(slots)
+	}
+	if err := batch.Write(); err != nil {
+		r.err = err
+		return nil
+	}
+	for _, tr := range tries {
+		if r.err = r.commitTrie(tr); r.err != nil {
+			return nil
+		}
+	}
+	if proven != nil {
+		if resume != (common.Hash{}) {
+			r.storageTasks = append([]*storageTask{{account: proven.account, root: proven.root, origin: resume}}, r.storageTasks...)
+		} else if err := r.commitStorage(proven); err != nil {
+			r.err = err
+			return nil
+		}
+	}
+	// Queue the accounts left out of the response again
+	r.storageTasks = append(r.storageTasks, req.storage[len(res.Slots):]...)
+	return nil
+}
+
+// commitStorage rebuilds the storage trie of an account retrieved in several
+// ranges from its snapshot, and commits it.
+func (r *syncRun) commitStorage(task *storageTask) error {
+	tr, err := r.rebuildTrie(rawdb.StorageSnapshotsKey(task.account))
+	if err != nil {
+		return err
+	}
+	if root := tr.Hash(); root != task.root {
+		// Every range was proven, only stale slots can cause this: start over
+		log.Warn("Rebuilt storage root mismatch", "account", task.account, "have", root, "want", task.root)
+		batch := r.db.NewBatch()
+		if err := deletePrefix(r.db, batch, rawdb.StorageSnapshotsKey(task.account)); err != nil {
+			return err
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		r.storageTasks = append(r.storageTasks, &storageTask{account: task.account, root: task.root})
+		return nil
+	}
+	return r.commitTrie(tr)
+}
+
+// processCodes verifies contract codes against their hashes and writes them.
+func (r *syncRun) processCodes(req *request, res *byteCodesData) error {
+	if len(res.Codes) == 0 {
+		r.markStateless(req)
+		return nil
+	}
+	requested := make(map[common.Hash]struct{}, len(req.codes))
+	for _, hash := range req.codes {
+		requested[hash] = struct{}{}
+	}
+	batch := r.db.NewBatch()
+	for _, code := range res.Codes {
+		hash := crypto.Keccak256Hash(code)
+		if _, ok := requested[hash]; !ok {
+			return errUnrequested
+		}
+		delete(requested, hash)
+		batch.Put(hash[:], code)
+	}
+	if err := batch.Write(); err != nil {
+		r.err = err
+		return nil
+	}
+	r.codes += len(res.Codes)
+
+	for _, hash := range req.codes {
+		if _, ok := requested[hash]; ok {
+			r.codeTasks = append(r.codeTasks, hash)
+		}
+	}
+	return nil
+}
+
+// commitAccounts rebuilds the account trie from the snapshot once the state is
+// completely retrieved, and commits it. If accounts were retrieved for another
+// root, the trie is committed all the same, so that healing it only retrieves
+// the nodes of the accounts changed since, and errStaleAccounts is returned.
+func (r *syncRun) commitAccounts() error {
+	tr, err := r.rebuildTrie(rawdb.SnapshotAccountPrefix)
+	if err != nil {
+		return err
+	}
+	if root := tr.Hash(); root != r.root {
+		if !r.stale {
+			return fmt.Errorf("state root mismatch: have %x, want %x", root, r.root)
+		}
+		if err := r.commitTrie(tr); err != nil {
+			return err
+		}
+		return errStaleAccounts
+	}
+	return r.commitTrie(tr)
+}
+
+// rebuildTrie builds the trie of the snapshot entries with the given prefix.
+func (r *syncRun) rebuildTrie(prefix []byte) (*trie.Trie, error) {
+	tr, _ := trie.New(common.Hash{}, r.triedb)
+
+	it := r.db.NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(prefix)+common.HashLength {
+			continue
+		}
+		if err := tr.TryUpdate(common.CopyBytes(key[len(prefix):]), common.CopyBytes(it.Value())); err != nil {
+			return nil, err
+		}
+	}
+	return tr, it.Error()
+}
+
+// commitTrie writes the nodes of a trie into the database.
+func (r *syncRun) commitTrie(tr *trie.Trie) error {
+	root, err := tr.Commit(nil)
+	if err != nil {
+		return err
+	}
+	return r.triedb.Commit(root, false)
+}
+
+// proofDB collects the nodes of a merkle proof, keyed by their hash.
+func proofDB(proof [][]byte) *vntdb.MemDatabase {
+	db := vntdb.NewMemDatabase()
+	for _, node := range proof {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return db
+}
+
+// deletePrefix deletes all the snapshot entries with the given prefix.
+func deletePrefix(db vntdb.Iteratee, batch vntdb.Batch, prefix []byte) error {
+	it := db.NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	for it.Next() {
+		if len(it.Key()) == len(prefix)+common.HashLength {
+			batch.Delete(common.CopyBytes(it.Key()))
+		}
+	}
+	return it.Error()
+}
+
+// incHash returns the hash following the given one.
+func incHash(h common.Hash) common.Hash {
+	for i := len(h) - 1; i >= 0; i-- {
+		h[i]++
+		if h[i] != 0 {
+			break
+		}
+	}
+	return h
+}
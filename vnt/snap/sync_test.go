@@ -0,0 +1,300 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/consensus/mock"
+	"github.com/vntchain/go-vnt/core"
+	"github.com/vntchain/go-vnt/core/rawdb"
+	"github.com/vntchain/go-vnt/core/state"
+	"github.com/vntchain/go-vnt/core/vm"
+	"github.com/vntchain/go-vnt/crypto"
+	"github.com/vntchain/go-vnt/params"
+	"github.com/vntchain/go-vnt/trie"
+	"github.com/vntchain/go-vnt/vntdb"
+	"github.com/vntchain/go-vnt/vntp2p"
+	"github.com/vntchain/go-vnt/vntp2p/mocknet"
+)
+
+// makeGenesis creates a genesis with plenty of accounts, some of them contracts
+// with storage, and one of them with a storage too large for a single response.
+func makeGenesis() *core.Genesis {
+	alloc := make(core.GenesisAlloc)
+	for i := 0; i < 300; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+		account := core.GenesisAccount{Balance: big.NewInt(int64(i + 1)), Nonce: uint64(i)}
+		if i%10 == 0 {
+			account.Code = []byte{byte(i), 0xaa}
+			account.Storage = make(map[common.Hash]common.Hash)
+			slots := 5
+			if i == 0 {
+				slots = 2000
+			}
+			for j := 0; j < slots; j++ {
+				account.Storage[common.BigToHash(big.NewInt(int64(j)))] = common.BigToHash(big.NewInt(int64(i*j + 1)))
+			}
+		}
+		alloc[addr] = account
+	}
+	return &core.Genesis{Config: params.TestChainConfig, Alloc: alloc}
+}
+
+// testNode is a chain serving and syncing states over the snap protocol on a
+// simulated network.
+type testNode struct {
+	db      *vntdb.MemDatabase
+	chain   *core.BlockChain
+	handler *Handler
+	server  *vntp2p.Server
+}
+
+func newTestNode(t *testing.T, net *mocknet.Network, genesis *core.Genesis, snapshot bool) *testNode {
+	db := vntdb.NewMemDatabase()
+	genesis.MustCommit(db)
+
+	cacheConfig := &core.CacheConfig{TrieNodeLimit: 256 * 1024 * 1024, TrieTimeLimit: 5 * time.Minute, Snapshot: snapshot}
+	chain, err := core.NewBlockChain(db, cacheConfig, genesis.Config, mock.NewMock(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	handler := NewHandler(chain, db)
+	server, err := net.StartServer(vntp2p.Config{MaxPeers: 10, Protocols: []vntp2p.Protocol{handler.Protocol()}})
+	if err != nil {
+		t.Fatalf("failed to start p2p server: %v", err)
+	}
+	return &testNode{db: db, chain: chain, handler: handler, server: server}
+}
+
+func (n *testNode) stop() {
+	n.server.Stop()
+	n.chain.Stop()
+}
+
+// Tests that a state is retrieved completely over the snap protocol, in many
+// ranges, whether the serving node has a snapshot of it or not.
+func TestSync(t *testing.T) {
+	t.Run("snapshot", func(t *testing.T) { testSync(t, true) })
+	t.Run("trie", func(t *testing.T) { testSync(t, false) })
+}
+
+func testSync(t *testing.T, snapshot bool) {
+	defer func(limit uint64) { softResponseLimit = limit }(softResponseLimit)
+	softResponseLimit = 4096
+
+	net := mocknet.New(1)
+	defer net.Close()
+
+	genesis := makeGenesis()
+	source := newTestNode(t, net, genesis, snapshot)
+	defer source.stop()
+	sink := newTestNode(t, net, &core.Genesis{Config: params.TestChainConfig}, true)
+	defer sink.stop()
+
+	root := source.chain.CurrentBlock().Root()
+	if snapshot {
+		for deadline := time.Now().Add(5 * time.Second); !source.chain.Snapshots().Generated(); {
+			if time.Now().After(deadline) {
+				t.Fatalf("source snapshot not generated in time")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	mocknet.Connect(sink.server, source.server)
+
+	if err := sink.handler.Syncer().Sync(root, make(chan struct{})); err != nil {
+		t.Fatalf("failed to sync state: %v", err)
+	}
+	// Every node of the state must be present
+	statedb, err := state.New(root, state.NewDatabase(sink.db))
+	if err != nil {
+		t.Fatalf("failed to open synced state: %v", err)
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+	}
+	if it.Error != nil {
+		t.Fatalf("synced state incomplete: %v", it.Error)
+	}
+	for addr, account := range genesis.Alloc {
+		if balance := statedb.GetBalance(addr); balance.Cmp(account.Balance) != 0 {
+			t.Fatalf("balance of %x mismatch: have %v, want %v", addr, balance, account.Balance)
+		}
+		if code := statedb.GetCode(addr); !bytes.Equal(code, account.Code) {
+			t.Fatalf("code of %x mismatch: have %x, want %x", addr, code, account.Code)
+		}
+		for key, value := range account.Storage {
+			if have := statedb.GetState(addr, key); have != value {
+				t.Fatalf("slot %x of %x mismatch: have %x, want %x", key, addr, have, value)
+			}
+		}
+	}
+	// The sink snapshot represents the synced state
+	if snaps := sink.chain.Snapshots(); snaps.Root() != root || !snaps.Generated() {
+		t.Fatalf("sink snapshot mismatch: have %x (generated %v), want %x", snaps.Root(), snaps.Generated(), root)
+	}
+}
+
+// Tests that the sync gives up when no peer serves the requested state, so that
+// the trie node sync takes over.
+func TestSyncUnavailableState(t *testing.T) {
+	defer func(timeout time.Duration) { peerWaitTimeout = timeout }(peerWaitTimeout)
+	peerWaitTimeout = 500 * time.Millisecond
+
+	net := mocknet.New(1)
+	defer net.Close()
+
+	source := newTestNode(t, net, makeGenesis(), true)
+	defer source.stop()
+	sink := newTestNode(t, net, &core.Genesis{Config: params.TestChainConfig}, true)
+	defer sink.stop()
+
+	mocknet.Connect(sink.server, source.server)
+
+	if err := sink.handler.Syncer().Sync(common.Hash{1}, make(chan struct{})); err != errNoPeers {
+		t.Fatalf("sync error mismatch: have %v, want %v", err, errNoPeers)
+	}
+}
+
+// Tests that a sync interrupted by a move of the pivot resumes for the new root,
+// keeping the accounts retrieved for the previous one, and that healing them
+// only retrieves the nodes changed since.
+func TestSyncPivotMove(t *testing.T) {
+	defer func(limit uint64) { softResponseLimit = limit }(softResponseLimit)
+	softResponseLimit = 1024
+
+	net := mocknet.New(1)
+	defer net.Close()
+	net.SetDefaultLink(mocknet.LinkOptions{Latency: 5 * time.Millisecond})
+
+	genesis := makeGenesis()
+	source := newTestNode(t, net, genesis, false)
+	defer source.stop()
+	sink := newTestNode(t, net, &core.Genesis{Config: params.TestChainConfig}, true)
+	defer sink.stop()
+
+	mocknet.Connect(sink.server, source.server)
+
+	// Interrupt the sync once some accounts are retrieved
+	root := source.chain.CurrentBlock().Root()
+	cancel := make(chan struct{})
+	go func() {
+		for len(snapshotAccounts(sink.db)) < 50 {
+			time.Sleep(time.Millisecond)
+		}
+		close(cancel)
+	}()
+	if err := sink.handler.Syncer().Sync(root, cancel); err != errCancelled {
+		t.Fatalf("sync error mismatch: have %v, want %v", err, errCancelled)
+	}
+	if rawdb.ReadSnapSyncStatus(sink.db) == nil {
+		t.Fatalf("progress of the interrupted sync not stored")
+	}
+	// Move the pivot, changing some of the accounts already retrieved
+	retrieved := snapshotAccounts(sink.db)
+	statedb, _ := state.New(root, source.chain.StateCache())
+	changed := 0
+	for addr, account := range genesis.Alloc {
+		if _, ok := retrieved[crypto.Keccak256Hash(addr[:])]; !ok {
+			continue
+		}
+		statedb.AddBalance(addr, big.NewInt(1))
+		if account.Storage != nil {
+			statedb.SetState(addr, common.Hash{}, common.Hash{0xff})
+		}
+		if changed++; changed == 10 {
+			break
+		}
+	}
+	statedb.AddBalance(common.Address{0xff}, big.NewInt(1))
+	pivot, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := source.chain.StateCache().TrieDB().Commit(pivot, false); err != nil {
+		t.Fatalf("failed to write state: %v", err)
+	}
+	if err := sink.handler.Syncer().Sync(pivot, make(chan struct{})); err != nil {
+		t.Fatalf("failed to sync state: %v", err)
+	}
+	if rawdb.ReadSnapSyncStatus(sink.db) != nil {
+		t.Fatalf("progress of the complete sync not removed")
+	}
+	// Heal the accounts changed since the pivot moved
+	healed := heal(t, sink.db, source.db, pivot)
+	if full := heal(t, vntdb.NewMemDatabase(), source.db, pivot); healed == 0 || healed >= full/10 {
+		t.Fatalf("healed nodes mismatch: have %d, want between 1 and %d", healed, full/10)
+	}
+	synced, err := state.New(pivot, state.NewDatabase(sink.db))
+	if err != nil {
+		t.Fatalf("failed to open synced state: %v", err)
+	}
+	want, _ := state.New(pivot, source.chain.StateCache())
+	for addr, account := range genesis.Alloc {
+		if have, want := synced.GetBalance(addr), want.GetBalance(addr); have.Cmp(want) != 0 {
+			t.Fatalf("balance of %x mismatch: have %v, want %v", addr, have, want)
+		}
+		for key := range account.Storage {
+			if have, want := synced.GetState(addr, key), want.GetState(addr, key); have != want {
+				t.Fatalf("slot %x of %x mismatch: have %x, want %x", key, addr, have, want)
+			}
+		}
+	}
+}
+
+// snapshotAccounts returns the hashes of the accounts in the snapshot.
+func snapshotAccounts(db *vntdb.MemDatabase) map[common.Hash]struct{} {
+	accounts := make(map[common.Hash]struct{})
+	it := db.NewIteratorWithPrefix(rawdb.SnapshotAccountPrefix)
+	defer it.Release()
+	for it.Next() {
+		if len(it.Key()) == len(rawdb.SnapshotAccountPrefix)+common.HashLength {
+			accounts[common.BytesToHash(it.Key()[len(rawdb.SnapshotAccountPrefix):])] = struct{}{}
+		}
+	}
+	return accounts
+}
+
+// heal retrieves the trie nodes and codes missing from a state, as the trie node
+// sync does after the snap sync, and returns their number.
+func heal(t *testing.T, db *vntdb.MemDatabase, source *vntdb.MemDatabase, root common.Hash) int {
+	sched := state.NewStateSync(root, db)
+	healed := 0
+	for missing := sched.Missing(0); len(missing) > 0; missing = sched.Missing(0) {
+		results := make([]trie.SyncResult, len(missing))
+		for i, hash := range missing {
+			data, err := source.Get(hash[:])
+			if err != nil {
+				t.Fatalf("node %x missing from the source", hash)
+			}
+			results[i] = trie.SyncResult{Hash: hash, Data: data}
+		}
+		if _, _, err := sched.Process(results); err != nil {
+			t.Fatalf("failed to process nodes: %v", err)
+		}
+		if _, err := sched.Commit(db); err != nil {
+			t.Fatalf("failed to commit nodes: %v", err)
+		}
+		healed += len(missing)
+	}
+	return healed
+}
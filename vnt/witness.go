@@ -0,0 +1,82 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package vnt
+
+import (
+	libp2p "github.com/libp2p/go-libp2p-peer"
+	"github.com/vntchain/go-vnt/core/types"
+	"github.com/vntchain/go-vnt/log"
+	"github.com/vntchain/go-vnt/vntp2p"
+)
+
+// bftMsgCode returns the protocol message code of a bft message type.
+func bftMsgCode(bftType types.BftMsgType) vntp2p.MessageType {
+	switch bftType {
+	case types.BftPreprepareMessage:
+		return BftPreprepareMsg
+	case types.BftPrepareMessage:
+		return BftPrepareMsg
+	default:
+		return BftCommitMsg
+	}
+}
+
+// isWitness reports whether the peer is one of the current witnesses.
+func (pm *ProtocolManager) isWitness(id libp2p.ID) bool {
+	if pm.peers.IsBftPeer(id) {
+		return true
+	}
+	return pm.node != nil && pm.node.Server() != nil && pm.node.Server().IsWitness(id)
+}
+
+// sendWitnessBftMsg sends a bft message to a witness connected over the
+// witness overlay, reporting whether it was written. Votes are sent ahead of
+// the proposals, whose blocks take much longer to transfer.
+func (pm *ProtocolManager) sendWitnessBftMsg(id libp2p.ID, bftMsg types.BftMsg) bool {
+	if pm.node == nil || pm.node.Server() == nil {
+		return false
+	}
+	urgent := bftMsg.BftType != types.BftPreprepareMessage
+	if err := pm.node.Server().SendToWitness(id, ProtocolName, bftMsgCode(bftMsg.BftType), bftMsg.Msg, urgent); err != nil {
+		log.Trace("Witness overlay unavailable", "peer", id, "err", err)
+		return false
+	}
+	return true
+}
+
+// handleWitnessMsg is invoked for the messages received over the witness
+// overlay, which only carries bft messages.
+func (pm *ProtocolManager) handleWitnessMsg(from libp2p.ID, msg vntp2p.Msg) error {
+	var bftMsg types.ConsensusMsg
+	switch msg.Body.Type {
+	case BftPreprepareMsg:
+		bftMsg = new(types.PreprepareMsg)
+	case BftPrepareMsg:
+		bftMsg = new(types.PrepareMsg)
+	case BftCommitMsg:
+		bftMsg = new(types.CommitMsg)
+	default:
+		pm.node.Server().ReportPeer(from, vntp2p.ProtocolViolation)
+		return errResp(ErrInvalidMsgCode, "%v", msg.Body.Type)
+	}
+	if err := msg.Decode(bftMsg); err != nil {
+		pm.node.Server().ReportPeer(from, vntp2p.InvalidBftMsg)
+		return errResp(ErrDecode, "msg %v: %v", msg, err)
+	}
+	pm.handleBftMsg(from, func(m vntp2p.Misbehaviour) { pm.node.Server().ReportPeer(from, m) }, bftMsg)
+	return nil
+}
@@ -0,0 +1,342 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package vntdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/dgraph-io/badger"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/log"
+)
+
+// badgerKeyPrefix is prepended to all the keys stored in Badger, which rejects
+// the empty key and reserves the keys starting with "!badger!" for itself.
+var badgerKeyPrefix = []byte("k")
+
+// errBadgerReverse is returned by the Badger iterators on backward moves.
+var errBadgerReverse = errors.New("badger iterator doesn't support backward iteration")
+
+// badgerKey returns the key under which a database key is stored in Badger.
+func badgerKey(key []byte) []byte {
+	return append(append(make([]byte, 0, len(badgerKeyPrefix)+len(key)), badgerKeyPrefix...), key...)
+}
+
+// BadgerDatabase is a Database stored in a Badger key-value store, an LSM tree
+// keeping the values apart from the keys, in a value log.
+type BadgerDatabase struct {
+	fn string     // filename for reporting
+	db *badger.DB // Badger instance
+
+	log log.Logger // Contextual logger tracking the database path
+}
+
+// NewBadgerDatabase returns a Badger wrapped object. Badger manages the file
+// handles by itself, the cache allowance sizes its memtables.
+func NewBadgerDatabase(file string, cache int, handles int) (*BadgerDatabase, error) {
+	logger := log.New("database", file)
+
+	// Ensure we have some minimal caching
+	if cache < 16 {
+		cache = 16
+	}
+	logger.Info("Allocated cache", "cache", cache)
+
+	opts := badger.DefaultOptions(file).
+		WithSyncWrites(false).
+		WithTruncate(true). // Recover from a partially written value log
+		WithLogger(badgerLogger{logger})
+	opts.MaxTableSize = int64(cache/4) * 1024 * 1024
+
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &BadgerDatabase{
+		fn:  file,
+		db:  db,
+		log: logger,
+	}, nil
+}
+
+// Path returns the path to the database directory.
+func (db *BadgerDatabase) Path() string {
+	return db.fn
+}
+
+// Put puts the given key / value to the database.
+func (db *BadgerDatabase) Put(key []byte, value []byte) error {
+	return db.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(badgerKey(key), common.CopyBytes(value))
+	})
+}
+
+func (db *BadgerDatabase) Has(key []byte) (bool, error) {
+	_, err := db.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Get returns the given key if it's present.
+func (db *BadgerDatabase) Get(key []byte) ([]byte, error) {
+	var dat []byte
+	err := db.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(badgerKey(key))
+		if err != nil {
+			return err
+		}
+		dat, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dat, nil
+}
+
+// Delete deletes the key from the database.
+func (db *BadgerDatabase) Delete(key []byte) error {
+	return db.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(badgerKey(key))
+	})
+}
+
+// NewIteratorWithPrefix returns an iterator over a snapshot of the database
+// content with a particular prefix. The iterator only moves forward.
+func (db *BadgerDatabase) NewIteratorWithPrefix(prefix []byte) iterator.Iterator {
+	txn := db.db.NewTransaction(false)
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = badgerKey(prefix)
+
+	return &badgerIterator{
+		txn:    txn,
+		it:     txn.NewIterator(opts),
+		prefix: opts.Prefix,
+	}
+}
+
+// Compact flattens the LSM tree of the database and garbage collects its value
+// log. Badger compacts the whole key space, regardless of the range.
+func (db *BadgerDatabase) Compact(start []byte, limit []byte) error {
+	if err := db.db.Flatten(runtime.NumCPU()); err != nil {
+		return err
+	}
+	for {
+		if err := db.db.RunValueLogGC(0.5); err == badger.ErrNoRewrite {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func (db *BadgerDatabase) Close() {
+	if err := db.db.Close(); err == nil {
+		db.log.Info("Database closed")
+	} else {
+		db.log.Error("Failed to close database", "err", err)
+	}
+}
+
+func (db *BadgerDatabase) NewBatch() Batch {
+	return &badgerBatch{db: db.db}
+}
+
+// badgerBatch buffers the writes in memory until they are committed in a single
+// transaction. A batch too large for a Badger transaction is split up over more
+// of them, and so is not written atomically.
+type badgerBatch struct {
+	db     *badger.DB
+	writes []kv
+	size   int
+}
+
+func (b *badgerBatch) Put(key, value []byte) error {
+	b.writes = append(b.writes, kv{badgerKey(key), common.CopyBytes(value), false})
+	b.size += len(value)
+	return nil
+}
+
+func (b *badgerBatch) Delete(key []byte) error {
+	b.writes = append(b.writes, kv{badgerKey(key), nil, true})
+	b.size++
+	return nil
+}
+
+func (b *badgerBatch) Write() error {
+	txn := b.db.NewTransaction(true)
+	for _, w := range b.writes {
+		err := b.apply(txn, w)
+		if err == badger.ErrTxnTooBig {
+			if err := txn.Commit(); err != nil {
+				return err
+			}
+			txn = b.db.NewTransaction(true)
+			err = b.apply(txn, w)
+		}
+		if err != nil {
+			txn.Discard()
+			return err
+		}
+	}
+	return txn.Commit()
+}
+
+// apply adds a buffered write to a transaction.
+func (b *badgerBatch) apply(txn *badger.Txn, w kv) error {
+	if w.del {
+		return txn.Delete(w.k)
+	}
+	return txn.Set(w.k, w.v)
+}
+
+func (b *badgerBatch) ValueSize() int {
+	return b.size
+}
+
+func (b *badgerBatch) Reset() {
+	b.writes = nil // The transactions may still reference the committed entries
+	b.size = 0
+}
+
+// badgerIterator adapts a forward Badger iterator over a read transaction to
+// the leveldb iterator interface.
+type badgerIterator struct {
+	txn      *badger.Txn
+	it       *badger.Iterator
+	prefix   []byte // Stored prefix of the iterated keys
+	started  bool   // Whether the iterator was positioned
+	key      []byte // Current key, nil if exhausted
+	value    []byte // Current value
+	err      error
+	releaser util.Releaser
+}
+
+// load loads the current entry of the Badger iterator.
+func (it *badgerIterator) load() bool {
+	it.key, it.value = nil, nil
+	if it.err != nil || !it.it.ValidForPrefix(it.prefix) {
+		return false
+	}
+	item := it.it.Item()
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.key, it.value = item.KeyCopy(nil)[len(badgerKeyPrefix):], value
+	return true
+}
+
+func (it *badgerIterator) First() bool {
+	if it.it == nil {
+		return false
+	}
+	it.started = true
+	it.it.Seek(it.prefix)
+	return it.load()
+}
+
+func (it *badgerIterator) Last() bool {
+	it.err = errBadgerReverse
+	return it.load()
+}
+
+func (it *badgerIterator) Seek(key []byte) bool {
+	if it.it == nil {
+		return false
+	}
+	it.started = true
+	if key := badgerKey(key); bytes.Compare(key, it.prefix) > 0 {
+		it.it.Seek(key)
+	} else {
+		it.it.Seek(it.prefix)
+	}
+	return it.load()
+}
+
+func (it *badgerIterator) Next() bool {
+	if it.it == nil {
+		return false
+	}
+	if !it.started {
+		return it.First()
+	}
+	if it.key == nil {
+		return false
+	}
+	it.it.Next()
+	return it.load()
+}
+
+func (it *badgerIterator) Prev() bool {
+	it.err = errBadgerReverse
+	return it.load()
+}
+
+func (it *badgerIterator) Valid() bool   { return it.key != nil }
+func (it *badgerIterator) Key() []byte   { return it.key }
+func (it *badgerIterator) Value() []byte { return it.value }
+func (it *badgerIterator) Error() error  { return it.err }
+
+func (it *badgerIterator) SetReleaser(releaser util.Releaser) {
+	it.releaser = releaser
+}
+
+func (it *badgerIterator) Release() {
+	if it.it == nil {
+		return
+	}
+	it.it.Close()
+	it.txn.Discard()
+	it.it, it.txn, it.key, it.value = nil, nil, nil, nil
+
+	if it.releaser != nil {
+		it.releaser.Release()
+		it.releaser = nil
+	}
+}
+
+// badgerLogger forwards the Badger logs to the database logger, one level down
+// as Badger reports its routine operations as informational.
+type badgerLogger struct {
+	log.Logger
+}
+
+func (l badgerLogger) Errorf(format string, args ...interface{}) {
+	l.Error(strings.TrimSpace(fmt.Sprintf(format, args...)))
+}
+
+func (l badgerLogger) Warningf(format string, args ...interface{}) {
+	l.Warn(strings.TrimSpace(fmt.Sprintf(format, args...)))
+}
+
+func (l badgerLogger) Infof(format string, args ...interface{}) {
+	l.Debug(strings.TrimSpace(fmt.Sprintf(format, args...)))
+}
+
+func (l badgerLogger) Debugf(format string, args ...interface{}) {
+	l.Trace(strings.TrimSpace(fmt.Sprintf(format, args...)))
+}
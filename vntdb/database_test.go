@@ -44,6 +44,22 @@ func newTestLDB() (*vntdb.LDBDatabase, func()) {
 	}
 }
 
+func newTestBadger() (*vntdb.BadgerDatabase, func()) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "vntdb_test_")
+	if err != nil {
+		panic("failed to create test file: " + err.Error())
+	}
+	db, err := vntdb.NewBadgerDatabase(dirname, 0, 0)
+	if err != nil {
+		panic("failed to create test database: " + err.Error())
+	}
+
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dirname)
+	}
+}
+
 var test_values = []string{"", "a", "1251", "\x00123\x00"}
 
 func TestLDB_PutGet(t *testing.T) {
@@ -52,6 +68,12 @@ func TestLDB_PutGet(t *testing.T) {
 	testPutGet(db, t)
 }
 
+func TestBadgerDB_PutGet(t *testing.T) {
+	db, remove := newTestBadger()
+	defer remove()
+	testPutGet(db, t)
+}
+
 func TestMemoryDB_PutGet(t *testing.T) {
 	testPutGet(vntdb.NewMemDatabase(), t)
 }
@@ -129,6 +151,12 @@ func TestLDB_ParallelPutGet(t *testing.T) {
 	testParallelPutGet(db, t)
 }
 
+func TestBadgerDB_ParallelPutGet(t *testing.T) {
+	db, remove := newTestBadger()
+	defer remove()
+	testParallelPutGet(db, t)
+}
+
 func TestMemoryDB_ParallelPutGet(t *testing.T) {
 	testParallelPutGet(vntdb.NewMemDatabase(), t)
 }
@@ -188,3 +216,114 @@ func testParallelPutGet(db vntdb.Database, t *testing.T) {
 	}
 	pending.Wait()
 }
+
+func TestLDB_BatchIterate(t *testing.T) {
+	db, remove := newTestLDB()
+	defer remove()
+	testBatchIterate(db, t)
+}
+
+func TestBadgerDB_BatchIterate(t *testing.T) {
+	db, remove := newTestBadger()
+	defer remove()
+	testBatchIterate(db, t)
+}
+
+func TestMemoryDB_BatchIterate(t *testing.T) {
+	testBatchIterate(vntdb.NewMemDatabase(), t)
+}
+
+// testBatchIterate checks that batched deletions apply, and that prefix
+// iteration walks the remaining keys in order.
+func testBatchIterate(db interface {
+	vntdb.Database
+	vntdb.Iteratee
+}, t *testing.T) {
+	t.Parallel()
+
+	batch := db.NewBatch()
+	for _, key := range []string{"p3", "p1", "q1", "p2", "p0"} {
+		batch.Put([]byte(key), []byte("v"+key))
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("batch write failed: %v", err)
+	}
+	batch.Reset()
+	batch.Delete([]byte("p2"))
+	if err := batch.Write(); err != nil {
+		t.Fatalf("batch write failed: %v", err)
+	}
+	it := db.NewIteratorWithPrefix([]byte("p"))
+	defer it.Release()
+
+	var keys []string
+	for it.Next() {
+		if !bytes.Equal(it.Value(), append([]byte("v"), it.Key()...)) {
+			t.Fatalf("value mismatch for %q: %q", it.Key(), it.Value())
+		}
+		keys = append(keys, string(it.Key()))
+	}
+	if have, want := fmt.Sprint(keys), "[p0 p1 p3]"; have != want {
+		t.Fatalf("iterated keys mismatch: have %s, want %s", have, want)
+	}
+	if !it.Seek([]byte("p2")) || string(it.Key()) != "p3" {
+		t.Fatalf("seek positioned at wrong key")
+	}
+}
+
+// Tests that the content of a database survives the copy into another engine,
+// and that the engine of a database is detected when reopening it.
+func TestCopyAcrossEngines(t *testing.T) {
+	src, remove := newTestLDB()
+	defer remove()
+
+	batch := src.NewBatch()
+	for i := 0; i < 1000; i++ {
+		batch.Put([]byte(fmt.Sprintf("key-%04d", i)), bytes.Repeat([]byte{byte(i)}, i))
+	}
+	batch.Put([]byte(""), []byte("empty"))
+	if err := batch.Write(); err != nil {
+		t.Fatalf("batch write failed: %v", err)
+	}
+	dirname, err := ioutil.TempDir(os.TempDir(), "vntdb_test_")
+	if err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+	defer os.RemoveAll(dirname)
+
+	dst, err := vntdb.Open(vntdb.EngineBadger, dirname, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to create badger database: %v", err)
+	}
+	if count, err := vntdb.Copy(dst, src); err != nil || count != 1001 {
+		t.Fatalf("copy mismatch: have %d (%v), want %d", count, err, 1001)
+	}
+	dst.Close()
+
+	if engine := vntdb.Engine(dirname); engine != vntdb.EngineBadger {
+		t.Fatalf("engine mismatch: have %q, want %q", engine, vntdb.EngineBadger)
+	}
+	if _, err := vntdb.Open(vntdb.EngineLevelDB, dirname, 0, 0); err == nil {
+		t.Fatalf("opened badger database as leveldb")
+	}
+	dst, err = vntdb.Open("", dirname, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to reopen badger database: %v", err)
+	}
+	defer dst.Close()
+
+	have, want := dst.(vntdb.Iteratee).NewIteratorWithPrefix(nil), src.NewIteratorWithPrefix(nil)
+	defer have.Release()
+	defer want.Release()
+	for want.Next() {
+		if !have.Next() {
+			t.Fatalf("missing key %q", want.Key())
+		}
+		if !bytes.Equal(have.Key(), want.Key()) || !bytes.Equal(have.Value(), want.Value()) {
+			t.Fatalf("entry mismatch: have %q, want %q", have.Key(), want.Key())
+		}
+	}
+	if have.Next() {
+		t.Fatalf("extra key %q", have.Key())
+	}
+}
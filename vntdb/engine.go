@@ -0,0 +1,112 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package vntdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/log"
+)
+
+// The storage engines of the persistent databases.
+const (
+	EngineLevelDB = "leveldb"
+	EngineBadger  = "badger"
+)
+
+// DefaultEngine is the engine of the newly created databases if none is chosen.
+const DefaultEngine = EngineLevelDB
+
+// Engines lists the supported storage engines.
+var Engines = []string{EngineLevelDB, EngineBadger}
+
+// Engine returns the storage engine of the database in the given directory, or
+// the empty string if there isn't any.
+func Engine(file string) string {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(file, name))
+		return err == nil
+	}
+	switch {
+	case exists("CURRENT"):
+		return EngineLevelDB
+	case exists("MANIFEST"):
+		return EngineBadger
+	}
+	return ""
+}
+
+// Open opens the database in the given directory with the given storage engine,
+// creating it if there is none. An empty engine selects the one of the existing
+// database, or the default one for a new database. Opening a database with an
+// engine other than the one it was created with fails.
+func Open(engine string, file string, cache int, handles int) (Database, error) {
+	existing := Engine(file)
+	if engine == "" {
+		engine = existing
+	}
+	if engine == "" {
+		engine = DefaultEngine
+	}
+	if existing != "" && existing != engine {
+		return nil, fmt.Errorf("database %s uses the %s engine, not %s (see 'gvnt db convert')", file, existing, engine)
+	}
+	switch engine {
+	case EngineLevelDB:
+		return NewLDBDatabase(file, cache, handles)
+	case EngineBadger:
+		return NewBadgerDatabase(file, cache, handles)
+	}
+	return nil, fmt.Errorf("unknown database engine %q", engine)
+}
+
+// Copy copies all the entries of an iterable database into another one, whatever
+// their engines, returning the number of entries copied.
+func Copy(dst Database, src Iteratee) (uint64, error) {
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		count  uint64
+	)
+	it := src.NewIteratorWithPrefix(nil)
+	defer it.Release()
+
+	batch := dst.NewBatch()
+	for it.Next() {
+		if err := batch.Put(it.Key(), it.Value()); err != nil {
+			return count, err
+		}
+		if batch.ValueSize() > IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return count, err
+			}
+			batch.Reset()
+		}
+		if count++; time.Since(logged) > 8*time.Second {
+			log.Info("Copying database", "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return count, err
+	}
+	return count, batch.Write()
+}
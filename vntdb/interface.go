@@ -16,6 +16,8 @@
 
 package vntdb
 
+import "github.com/syndtr/goleveldb/leveldb/iterator"
+
 // Code using batches should try to add this much data to the batch.
 // The value was determined empirically.
 const IdealBatchSize = 100 * 1024
@@ -35,10 +37,24 @@ type Database interface {
 	NewBatch() Batch
 }
 
+// Iteratee wraps the iteration over the content of the databases supporting it,
+// in ascending key order.
+type Iteratee interface {
+	NewIteratorWithPrefix(prefix []byte) iterator.Iterator
+}
+
+// Compacter wraps the compaction of the databases supporting it, reclaiming the
+// disk space of the deleted and overwritten entries. A nil start is treated as a
+// key before all keys and a nil limit as a key after all keys.
+type Compacter interface {
+	Compact(start []byte, limit []byte) error
+}
+
 // Batch is a write-only database that commits changes to its host database
 // when Write is called. Batch cannot be used concurrently.
 type Batch interface {
 	Putter
+	Delete(key []byte) error
 	ValueSize() int // amount of data in the batch
 	Write() error
 	// Reset resets the batch for reuse
@@ -17,9 +17,12 @@
 package vntdb
 
 import (
+	"bytes"
 	"errors"
+	"sort"
 	"sync"
 
+	"github.com/syndtr/goleveldb/leveldb/iterator"
 	"github.com/vntchain/go-vnt/common"
 )
 
@@ -88,6 +91,22 @@ func (db *MemDatabase) Delete(key []byte) error {
 	return nil
 }
 
+// NewIteratorWithPrefix returns an iterator over a snapshot of the database
+// content with a particular prefix.
+func (db *MemDatabase) NewIteratorWithPrefix(prefix []byte) iterator.Iterator {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var entries memEntries
+	for key, value := range db.db {
+		if bytes.HasPrefix([]byte(key), prefix) {
+			entries = append(entries, kv{k: []byte(key), v: common.CopyBytes(value)})
+		}
+	}
+	sort.Sort(entries)
+	return iterator.NewArrayIterator(entries)
+}
+
 func (db *MemDatabase) Close() {}
 
 func (db *MemDatabase) NewBatch() Batch {
@@ -96,7 +115,23 @@ func (db *MemDatabase) NewBatch() Batch {
 
 func (db *MemDatabase) Len() int { return len(db.db) }
 
-type kv struct{ k, v []byte }
+type kv struct {
+	k, v []byte
+	del  bool
+}
+
+// memEntries is a sorted set of entries, iterable as a leveldb array.
+type memEntries []kv
+
+func (e memEntries) Len() int           { return len(e) }
+func (e memEntries) Less(i, j int) bool { return bytes.Compare(e[i].k, e[j].k) < 0 }
+func (e memEntries) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+func (e memEntries) Search(key []byte) int {
+	return sort.Search(len(e), func(i int) bool { return bytes.Compare(e[i].k, key) >= 0 })
+}
+
+func (e memEntries) Index(i int) ([]byte, []byte) { return e[i].k, e[i].v }
 
 type memBatch struct {
 	db     *MemDatabase
@@ -105,16 +140,26 @@ type memBatch struct {
 }
 
 func (b *memBatch) Put(key, value []byte) error {
-	b.writes = append(b.writes, kv{common.CopyBytes(key), common.CopyBytes(value)})
+	b.writes = append(b.writes, kv{common.CopyBytes(key), common.CopyBytes(value), false})
 	b.size += len(value)
 	return nil
 }
 
+func (b *memBatch) Delete(key []byte) error {
+	b.writes = append(b.writes, kv{common.CopyBytes(key), nil, true})
+	b.size++
+	return nil
+}
+
 func (b *memBatch) Write() error {
 	b.db.lock.Lock()
 	defer b.db.lock.Unlock()
 
 	for _, kv := range b.writes {
+		if kv.del {
+			delete(b.db.db, string(kv.k))
+			continue
+		}
 		b.db.db[string(kv.k)] = kv.v
 	}
 	return nil
@@ -0,0 +1,182 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package vntp2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	peerstore "github.com/libp2p/go-libp2p-peerstore"
+	protocol "github.com/libp2p/go-libp2p-protocol"
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/log"
+	"github.com/vntchain/go-vnt/rlp"
+)
+
+const (
+	foreignChainKey   = "ForeignChain"  // Peerstore key of the chain of the peers following another one
+	foreignChainTTL   = time.Hour       // Time a peer of a foreign chain is rejected without checking again
+	chainCheckTimeout = 5 * time.Second // Maximum time of the chain exchange with a peer
+	maxChainIDSize    = 64              // Maximum encoded size of a chain id
+)
+
+var errForeignChain = errors.New("peer follows another chain")
+
+// ChainID identifies the chain followed by a node.
+type ChainID struct {
+	NetworkID uint64
+	Genesis   common.Hash
+}
+
+func (id ChainID) String() string {
+	return fmt.Sprintf("%d/%x", id.NetworkID, id.Genesis[:8])
+}
+
+// foreignMark is the peerstore record of a peer following another chain. The
+// mark expires, as the peer may switch to our chain.
+type foreignMark struct {
+	chain   ChainID
+	expires time.Time
+}
+
+// SetChainID sets the chain followed by the node. Once set, nodes exchange
+// their chain on connection, and the peers following another chain are
+// rejected before running any protocol and kept out of the routing table.
+// Peers not supporting the exchange are let through to the protocols.
+func (server *Server) SetChainID(id ChainID) {
+	server.chainID.Store(id)
+}
+
+func (server *Server) localChainID() (ChainID, bool) {
+	id, ok := server.chainID.Load().(ChainID)
+	return id, ok
+}
+
+// isForeign reports whether the peer is known to follow another chain.
+func (server *Server) isForeign(id peer.ID) bool {
+	if server.host == nil {
+		return false
+	}
+	return markedForeign(server.host.Peerstore(), id)
+}
+
+// markedForeign reports whether the peerstore holds an unexpired mark of the
+// peer following another chain.
+func markedForeign(ps peerstore.Peerstore, id peer.ID) bool {
+	mark, err := ps.Get(id, foreignChainKey)
+	if err != nil {
+		return false
+	}
+	foreign, ok := mark.(foreignMark)
+	return ok && time.Now().Before(foreign.expires)
+}
+
+// markForeign records that a peer follows another chain and forgets about it.
+func (server *Server) markForeign(id peer.ID, chain ChainID) {
+	log.Debug("Rejecting peer of foreign chain", "peer", id, "chain", chain)
+
+	server.host.Peerstore().Put(id, foreignChainKey, foreignMark{chain: chain, expires: time.Now().Add(foreignChainTTL)})
+	server.table.Remove(id)
+	server.disconnect(id)
+}
+
+// checkChain exchanges the chain ids with a peer before connecting to it.
+func (server *Server) checkChain(ctx context.Context, id peer.ID) error {
+	local, ok := server.localChainID()
+	if !ok {
+		return nil
+	}
+	if server.isForeign(id) {
+		return errForeignChain
+	}
+	ctx, cancel := context.WithTimeout(ctx, chainCheckTimeout)
+	defer cancel()
+
+	s, err := server.host.NewStream(ctx, id, protocol.ID(PIDChain))
+	if err != nil {
+		// Either unreachable or not supporting the exchange, the dial decides
+		return nil
+	}
+	defer s.Close()
+
+	s.SetDeadline(time.Now().Add(chainCheckTimeout))
+	if err := rlp.Encode(s, &local); err != nil {
+		return nil
+	}
+	remote, err := readChainID(s)
+	if err != nil {
+		log.Trace("Chain exchange failed", "peer", id, "err", err)
+		return nil
+	}
+	if remote != local {
+		server.markForeign(id, remote)
+		return errForeignChain
+	}
+	return nil
+}
+
+// handleChainStream answers the chain exchange of a peer connecting to us.
+func (server *Server) handleChainStream(s inet.Stream) {
+	defer s.Close()
+
+	local, ok := server.localChainID()
+	if !ok {
+		s.Reset()
+		return
+	}
+	s.SetDeadline(time.Now().Add(chainCheckTimeout))
+	remote, err := readChainID(s)
+	if err != nil {
+		s.Reset()
+		return
+	}
+	if err := rlp.Encode(s, &local); err != nil {
+		return
+	}
+	if remote != local {
+		server.markForeign(s.Conn().RemotePeer(), remote)
+	}
+}
+
+func readChainID(r io.Reader) (ChainID, error) {
+	var id ChainID
+	err := rlp.NewStream(io.LimitReader(r, maxChainIDSize), maxChainIDSize).Decode(&id)
+	return id, err
+}
+
+// filterForeignPeers removes the peers of foreign chains from the persisted
+// peer infos and buckets.
+func filterForeignPeers(ps peerstore.Peerstore, infos []peerstore.PeerInfo, buckets []peer.ID) ([]peerstore.PeerInfo, []peer.ID) {
+	keptInfos := infos[:0]
+	for _, info := range infos {
+		if !markedForeign(ps, info.ID) {
+			keptInfos = append(keptInfos, info)
+		}
+	}
+	keptBuckets := buckets[:0]
+	for _, id := range buckets {
+		if !markedForeign(ps, id) {
+			keptBuckets = append(keptBuckets, id)
+		}
+	}
+	return keptInfos, keptBuckets
+}
@@ -0,0 +1,50 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package vntp2p
+
+import (
+	"testing"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+	peerstore "github.com/libp2p/go-libp2p-peerstore"
+	"github.com/vntchain/go-vnt/common"
+)
+
+// Tests that the marks of the peers of foreign chains expire, so that they
+// are checked again and kept in the persisted peers.
+func TestForeignMarkExpiry(t *testing.T) {
+	var (
+		ps      = peerstore.NewPeerstore()
+		chain   = ChainID{NetworkID: 1, Genesis: common.Hash{2}}
+		marked  = peer.ID("marked")
+		expired = peer.ID("expired")
+	)
+	ps.Put(marked, foreignChainKey, foreignMark{chain: chain, expires: time.Now().Add(foreignChainTTL)})
+	ps.Put(expired, foreignChainKey, foreignMark{chain: chain, expires: time.Now().Add(-time.Second)})
+
+	if !markedForeign(ps, marked) {
+		t.Errorf("marked peer not foreign")
+	}
+	if markedForeign(ps, expired) {
+		t.Errorf("peer with expired mark still foreign")
+	}
+	infos, buckets := filterForeignPeers(ps, []peerstore.PeerInfo{{ID: marked}, {ID: expired}}, []peer.ID{marked, expired})
+	if len(infos) != 1 || infos[0].ID != expired || len(buckets) != 1 || buckets[0] != expired {
+		t.Errorf("filtered peers mismatch: have %v %v, want only the expired one", infos, buckets)
+	}
+}
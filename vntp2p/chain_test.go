@@ -0,0 +1,105 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package vntp2p_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/vntp2p"
+	"github.com/vntchain/go-vnt/vntp2p/mocknet"
+)
+
+func startChainServer(t *testing.T, net *mocknet.Network, chain *vntp2p.ChainID) *vntp2p.Server {
+	proto := vntp2p.Protocol{
+		Name:    "idle",
+		Version: 1,
+		Length:  1,
+		Run: func(p *vntp2p.Peer, rw vntp2p.MsgReadWriter) error {
+			_, err := rw.ReadMsg()
+			return err
+		},
+	}
+	server, err := net.StartServer(vntp2p.Config{MaxPeers: 10, Protocols: []vntp2p.Protocol{proto}})
+	if err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	if chain != nil {
+		server.SetChainID(*chain)
+	}
+	return server
+}
+
+// Tests that peers of foreign chains are rejected before connecting, while
+// the ones of the same chain and the ones not advertising it are accepted.
+func TestChainGating(t *testing.T) {
+	net := mocknet.New(1)
+	defer net.Close()
+
+	var (
+		mainnet = &vntp2p.ChainID{NetworkID: 1, Genesis: common.Hash{1}}
+		forked  = &vntp2p.ChainID{NetworkID: 1, Genesis: common.Hash{2}}
+
+		local   = startChainServer(t, net, mainnet)
+		sibling = startChainServer(t, net, mainnet)
+		foreign = startChainServer(t, net, forked)
+		legacy  = startChainServer(t, net, nil)
+	)
+	events := make(chan *vntp2p.PeerEvent, 16)
+	sub := local.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+
+	for _, remote := range []*vntp2p.Server{sibling, foreign, legacy} {
+		mocknet.Connect(local, remote)
+	}
+	// Wait for the peers of the same chain and without chain id to connect
+	pending := map[peer.ID]string{
+		sibling.Self().Id: "peer of the same chain",
+		legacy.Self().Id:  "peer without chain id",
+	}
+	timeout := time.After(5 * time.Second)
+	for len(pending) > 0 {
+		select {
+		case ev := <-events:
+			if ev.Type != vntp2p.PeerEventTypeAdd {
+				continue
+			}
+			if ev.Peer == foreign.Self().Id {
+				t.Fatalf("peer of a foreign chain connected")
+			}
+			delete(pending, ev.Peer)
+		case <-timeout:
+			t.Fatalf("peers not connected: %v", pending)
+		}
+	}
+	// Dialing the peer of the foreign chain is refused, whether checking its
+	// chain or relying on the mark of a previous check
+	if err := local.SetupStream(context.Background(), foreign.Self().Id, vntp2p.PID); err == nil {
+		t.Errorf("peer of a foreign chain dialed")
+	}
+	for _, info := range local.PeersInfo() {
+		if info.ID == foreign.Self().Id.String() {
+			t.Errorf("peer of a foreign chain connected")
+		}
+	}
+	if len(foreign.PeersInfo()) != 0 {
+		t.Errorf("foreign peer accepted the connection")
+	}
+}
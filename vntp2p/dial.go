@@ -131,7 +131,7 @@ func (s *taskstate) newTasks(peers map[peer.ID]*Peer) []task {
 
 	// update lookupNode, if need more, launch lookup task
 	s.lookupNode = s.lookupNode[i:]
-	if len(s.lookupNode) < needDial && !s.lookupRunning {
+	if len(s.lookupNode) < needDial && !s.lookupRunning && s.discovery() {
 		s.lookupRunning = true
 		newtasks = append(newtasks, &lookupTask{})
 	}
@@ -159,6 +159,11 @@ func (s *taskstate) checkDial(n peer.ID, peers map[peer.ID]*Peer) error {
 	return nil
 }
 
+// discovery reports whether lookups can be run to find more peers.
+func (s *taskstate) discovery() bool {
+	return s.table != nil && s.table.GetDhtTable() != nil
+}
+
 func (s *taskstate) removeStatic(n *Node) {
 	delete(s.static, n.Id)
 }
@@ -200,6 +205,10 @@ func (t *dialTask) Do(ctx context.Context, server *Server) {
 		return
 	}
 
+	if server.reputation.banned(t.target) {
+		log.Trace("Skip dial banned peer", "target", t.target)
+		return
+	}
 	log.Trace("Dial task", "target", t.target)
 	_ = t.dial(ctx, server, t.target, t.pid)
 }
@@ -38,6 +38,7 @@ type DhtTable interface {
 	Start(ctx context.Context) error
 	Lookup(ctx context.Context, targetID NodeID) []*NodeID
 	Update(ctx context.Context, id peer.ID) error
+	Remove(id peer.ID)
 	RandomPeer() []peer.ID
 	GetDhtTable() *dht.IpfsDHT
 }
@@ -83,6 +84,10 @@ func (vdht *VNTDht) Update(ctx context.Context, id peer.ID) error {
 	return nil
 }
 
+func (vdht *VNTDht) Remove(id peer.ID) {
+	vdht.table.Remove(id)
+}
+
 func randomID() peer.ID {
 	id := make([]byte, 16)
 	rand.Read(id)
@@ -162,3 +167,14 @@ func (vdht *VNTDht) RandomPeer() []peer.ID {
 func (vdht *VNTDht) GetDhtTable() *dht.IpfsDHT {
 	return vdht.table
 }
+
+// staticTable is the table of the servers without discovery, which only know
+// the peers they are explicitly given.
+type staticTable struct{}
+
+func (staticTable) Start(ctx context.Context) error                       { return nil }
+func (staticTable) Lookup(ctx context.Context, targetID NodeID) []*NodeID { return nil }
+func (staticTable) Update(ctx context.Context, id peer.ID) error          { return nil }
+func (staticTable) Remove(id peer.ID)                                     {}
+func (staticTable) RandomPeer() []peer.ID                                 { return nil }
+func (staticTable) GetDhtTable() *dht.IpfsDHT                             { return nil }
@@ -0,0 +1,370 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package gossip implements a publish/subscribe layer on top of the vntp2p
+// peer streams, flooding the messages of a topic to all the peers subscribed
+// to it in the manner of libp2p's floodsub.
+//
+// Every message is validated once before being relayed, and the peers
+// delivering invalid messages are reported to the reputation system.
+package gossip
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+	"github.com/vntchain/go-vnt/common"
+	"github.com/vntchain/go-vnt/crypto"
+	"github.com/vntchain/go-vnt/log"
+	"github.com/vntchain/go-vnt/metrics"
+	"github.com/vntchain/go-vnt/vntp2p"
+)
+
+const (
+	ProtocolName    = "gossip"
+	ProtocolVersion = 1
+	ProtocolLength  = 2 // Number of implemented message codes
+
+	maxQueuedMsgs = 256 // Maximum number of messages queued for a single peer
+)
+
+// Gossip protocol message codes
+const (
+	SubscriptionsMsg = 0x00
+	PublishMsg       = 0x01
+)
+
+var (
+	errClosed = errors.New("gossip router closed")
+
+	publishedMeter = metrics.NewRegisteredMeter("gossip/published", nil)
+	deliveredMeter = metrics.NewRegisteredMeter("gossip/delivered", nil)
+	duplicateMeter = metrics.NewRegisteredMeter("gossip/duplicate", nil)
+	rejectedMeter  = metrics.NewRegisteredMeter("gossip/rejected", nil)
+	droppedMeter   = metrics.NewRegisteredMeter("gossip/dropped", nil)
+)
+
+// ValidationResult is the verdict of a topic validator on a message.
+type ValidationResult int
+
+const (
+	Accept ValidationResult = iota // message is valid and relayed to the subscribers
+	Ignore                         // message is not relayed, the sender is not reported
+	Reject                         // message is invalid, the sender is reported
+)
+
+// Validator checks a message received on a topic and consumes it if it is
+// valid. It is invoked once per message, from the delivering peer's goroutine.
+type Validator func(from peer.ID, data []byte) ValidationResult
+
+// Config holds the parameters of a gossip router.
+type Config struct {
+	SeenTTL    time.Duration // Time message ids are remembered for deduplication
+	ExpiryTick time.Duration // Interval of the expiry of the remembered ids
+}
+
+// DefaultConfig contains the default router parameters.
+var DefaultConfig = Config{
+	SeenTTL:    2 * time.Minute,
+	ExpiryTick: time.Second,
+}
+
+// message is a published message, as transmitted on the wire.
+type message struct {
+	Topic string
+	Data  []byte
+}
+
+// id returns the identifier used to deduplicate the message.
+func (m *message) id() common.Hash {
+	return crypto.Keccak256Hash([]byte(m.Topic), m.Data)
+}
+
+// outMsg is a message queued for sending to a peer.
+type outMsg struct {
+	code vntp2p.MessageType
+	data interface{}
+}
+
+// gossipPeer is a remote peer speaking the gossip protocol.
+type gossipPeer struct {
+	id     peer.ID
+	report func(vntp2p.Misbehaviour)
+	topics map[string]struct{}
+	queue  chan outMsg
+}
+
+// Router relays the messages published on the subscribed topics.
+type Router struct {
+	config Config
+
+	lock       sync.Mutex
+	peers      map[peer.ID]*gossipPeer
+	validators map[string]Validator      // subscribed topics
+	seen       map[common.Hash]time.Time // ids of recently seen messages
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a gossip router and starts the expiry of the seen messages.
+func New(config Config) *Router {
+	r := &Router{
+		config:     config,
+		peers:      make(map[peer.ID]*gossipPeer),
+		validators: make(map[string]Validator),
+		seen:       make(map[common.Hash]time.Time),
+		quit:       make(chan struct{}),
+	}
+	r.wg.Add(1)
+	go r.loop()
+	return r
+}
+
+// Stop terminates the message relaying.
+func (r *Router) Stop() {
+	close(r.quit)
+	r.wg.Wait()
+}
+
+// Protocol returns the vntp2p protocol carrying the gossip messages.
+func (r *Router) Protocol() vntp2p.Protocol {
+	return vntp2p.Protocol{
+		Name:    ProtocolName,
+		Version: ProtocolVersion,
+		Length:  ProtocolLength,
+		Run: func(p *vntp2p.Peer, rw vntp2p.MsgReadWriter) error {
+			return r.run(p.RemoteID(), p.Report, rw)
+		},
+	}
+}
+
+// Subscribe joins the given topic, validating incoming messages with the given
+// validator before relaying them.
+func (r *Router) Subscribe(topic string, validate Validator) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.validators[topic] = validate
+	for _, p := range r.peers {
+		r.enqueue(p, SubscriptionsMsg, r.topics())
+	}
+}
+
+// Connected reports whether the given peer speaks the gossip protocol.
+func (r *Router) Connected(id peer.ID) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	_, ok := r.peers[id]
+	return ok
+}
+
+// Publish sends a message to the peers subscribed to the topic.
+func (r *Router) Publish(topic string, data []byte) error {
+	select {
+	case <-r.quit:
+		return errClosed
+	default:
+	}
+	msg := &message{Topic: topic, Data: data}
+	id := msg.id()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.seen[id]; ok {
+		return nil
+	}
+	r.seen[id] = time.Now()
+	r.relay(msg, "")
+
+	publishedMeter.Mark(1)
+	return nil
+}
+
+// run handles the gossip protocol with a remote peer until the connection is
+// torn down.
+func (r *Router) run(id peer.ID, report func(vntp2p.Misbehaviour), rw vntp2p.MsgReadWriter) error {
+	p := &gossipPeer{
+		id:     id,
+		report: report,
+		topics: make(map[string]struct{}),
+		queue:  make(chan outMsg, maxQueuedMsgs),
+	}
+	r.lock.Lock()
+	r.peers[id] = p
+	r.enqueue(p, SubscriptionsMsg, r.topics())
+	r.lock.Unlock()
+
+	defer func() {
+		r.lock.Lock()
+		delete(r.peers, id)
+		r.lock.Unlock()
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case out := <-p.queue:
+				if err := vntp2p.Send(rw, ProtocolName, out.code, out.data); err != nil {
+					return
+				}
+			case <-done:
+				return
+			case <-r.quit:
+				return
+			}
+		}
+	}()
+
+	for {
+		msg, err := rw.ReadMsg()
+		if err != nil {
+			return err
+		}
+		if err := r.handleMsg(p, msg); err != nil {
+			log.Debug("Gossip message handling failed", "peer", id, "err", err)
+			p.report(vntp2p.ProtocolViolation)
+			return err
+		}
+	}
+}
+
+// handleMsg processes a single message received from a peer.
+func (r *Router) handleMsg(p *gossipPeer, msg vntp2p.Msg) error {
+	switch msg.Body.Type {
+	case SubscriptionsMsg:
+		var topics []string
+		if err := msg.Decode(&topics); err != nil {
+			return err
+		}
+		r.lock.Lock()
+		p.topics = make(map[string]struct{}, len(topics))
+		for _, topic := range topics {
+			p.topics[topic] = struct{}{}
+		}
+		r.lock.Unlock()
+
+	case PublishMsg:
+		var msgs []*message
+		if err := msg.Decode(&msgs); err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			r.deliver(p, m)
+		}
+
+	default:
+		return errors.New("unknown gossip message")
+	}
+	return nil
+}
+
+// deliver validates a message received from a peer and relays it to the other
+// subscribers if it is new and valid.
+func (r *Router) deliver(p *gossipPeer, m *message) {
+	id := m.id()
+
+	r.lock.Lock()
+	validate, subscribed := r.validators[m.Topic]
+	if _, ok := r.seen[id]; ok {
+		r.lock.Unlock()
+		duplicateMeter.Mark(1)
+		return
+	}
+	if !subscribed {
+		r.lock.Unlock()
+		return
+	}
+	r.seen[id] = time.Now()
+	r.lock.Unlock()
+
+	// Validate outside of the lock, validators may take a while
+	switch validate(p.id, m.Data) {
+	case Accept:
+		deliveredMeter.Mark(1)
+
+		r.lock.Lock()
+		r.relay(m, p.id)
+		r.lock.Unlock()
+
+	case Reject:
+		rejectedMeter.Mark(1)
+
+		// Report outside of the lock, banning the peer disconnects it, which
+		// waits for its protocols to stop
+		p.report(vntp2p.UselessResponse)
+	}
+}
+
+// loop expires the ids of the messages seen long ago.
+func (r *Router) loop() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.config.ExpiryTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.lock.Lock()
+			now := time.Now()
+			for id, seen := range r.seen {
+				if now.Sub(seen) > r.config.SeenTTL {
+					delete(r.seen, id)
+				}
+			}
+			r.lock.Unlock()
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// relay queues a message for all the peers subscribed to its topic, except the
+// one it was received from. The lock must be held.
+func (r *Router) relay(m *message, from peer.ID) {
+	for id, p := range r.peers {
+		if _, ok := p.topics[m.Topic]; ok && id != from {
+			r.enqueue(p, PublishMsg, []*message{m})
+		}
+	}
+}
+
+// topics returns the subscribed topics. The lock must be held.
+func (r *Router) topics() []string {
+	topics := make([]string, 0, len(r.validators))
+	for topic := range r.validators {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// enqueue queues a message for sending to the peer, dropping it if the peer
+// does not keep up. The lock must be held.
+func (r *Router) enqueue(p *gossipPeer, code vntp2p.MessageType, data interface{}) {
+	select {
+	case p.queue <- outMsg{code: code, data: data}:
+	default:
+		droppedMeter.Mark(1)
+		log.Debug("Dropping gossip message to slow peer", "peer", p.id, "code", code)
+	}
+}
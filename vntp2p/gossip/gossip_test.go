@@ -0,0 +1,198 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package gossip
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+	"github.com/vntchain/go-vnt/vntp2p"
+)
+
+var errPipeClosed = errors.New("pipe closed")
+
+// pipeRW is one end of an in-memory message pipe.
+type pipeRW struct {
+	in, out chan vntp2p.Msg
+	closed  chan struct{}
+}
+
+func newPipe() (*pipeRW, *pipeRW) {
+	a, b := make(chan vntp2p.Msg, 64), make(chan vntp2p.Msg, 64)
+	closed := make(chan struct{})
+	return &pipeRW{in: a, out: b, closed: closed}, &pipeRW{in: b, out: a, closed: closed}
+}
+
+func (p *pipeRW) WriteMsg(msg vntp2p.Msg) error {
+	payload, err := ioutil.ReadAll(msg.Body.Payload)
+	if err != nil {
+		return err
+	}
+	msg.Body.Payload = bytes.NewReader(payload)
+	select {
+	case p.out <- msg:
+		return nil
+	case <-p.closed:
+		return errPipeClosed
+	}
+}
+
+func (p *pipeRW) ReadMsg() (vntp2p.Msg, error) {
+	select {
+	case msg := <-p.in:
+		return msg, nil
+	case <-p.closed:
+		return vntp2p.Msg{}, errPipeClosed
+	}
+}
+
+// testNode is a gossip router recording the messages it accepted.
+type testNode struct {
+	id     peer.ID
+	router *Router
+
+	lock     sync.Mutex
+	received [][]byte
+	reports  []vntp2p.Misbehaviour
+}
+
+func newTestNode(id string, topic string, verdict ValidationResult) *testNode {
+	n := &testNode{id: peer.ID(id), router: New(DefaultConfig)}
+	n.router.Subscribe(topic, func(from peer.ID, data []byte) ValidationResult {
+		n.lock.Lock()
+		defer n.lock.Unlock()
+		n.received = append(n.received, data)
+		return verdict
+	})
+	return n
+}
+
+func (n *testNode) count() int {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return len(n.received)
+}
+
+// subscribers returns the number of peers the node knows to be subscribed to
+// the topic.
+func (n *testNode) subscribers(topic string) int {
+	n.router.lock.Lock()
+	defer n.router.lock.Unlock()
+
+	count := 0
+	for _, p := range n.router.peers {
+		if _, ok := p.topics[topic]; ok {
+			count++
+		}
+	}
+	return count
+}
+
+// connect runs the gossip protocol between two nodes.
+func connect(a, b *testNode) {
+	rwa, rwb := newPipe()
+	go a.router.run(b.id, func(m vntp2p.Misbehaviour) {
+		// Reports may disconnect the peer, which takes the router lock
+		a.router.Connected(b.id)
+
+		a.lock.Lock()
+		a.reports = append(a.reports, m)
+		a.lock.Unlock()
+	}, rwa)
+	go b.router.run(a.id, func(vntp2p.Misbehaviour) {}, rwb)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Tests that messages are relayed to all the subscribers, and validated once
+// per node.
+func TestGossipRelay(t *testing.T) {
+	var nodes []*testNode
+	for _, id := range []string{"a", "b", "c", "d"} {
+		n := newTestNode(id, "txs", Accept)
+		defer n.router.Stop()
+		nodes = append(nodes, n)
+	}
+	// Connect the nodes in a ring, so that every message arrives twice
+	for i := range nodes {
+		connect(nodes[i], nodes[(i+1)%len(nodes)])
+	}
+	for _, n := range nodes {
+		waitFor(t, func() bool { return n.subscribers("txs") == 2 })
+	}
+	if err := nodes[0].router.Publish("txs", []byte("hello")); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	for _, n := range nodes[1:] {
+		waitFor(t, func() bool { return n.count() > 0 })
+	}
+	time.Sleep(100 * time.Millisecond)
+	for i, n := range nodes {
+		want := 1
+		if i == 0 {
+			want = 0 // own messages are not validated
+		}
+		if have := n.count(); have != want {
+			t.Errorf("node %d: message validated %d times, want %d", i, have, want)
+		}
+	}
+}
+
+// Tests that rejected messages are not relayed and get their sender reported.
+func TestGossipReject(t *testing.T) {
+	var (
+		src   = newTestNode("src", "bft", Accept)
+		judge = newTestNode("judge", "bft", Reject)
+		dst   = newTestNode("dst", "bft", Accept)
+	)
+	defer src.router.Stop()
+	defer judge.router.Stop()
+	defer dst.router.Stop()
+
+	connect(judge, src)
+	connect(judge, dst)
+	waitFor(t, func() bool { return judge.router.Connected(src.id) && judge.router.Connected(dst.id) })
+	waitFor(t, func() bool { return src.subscribers("bft") == 1 })
+	if err := src.router.Publish("bft", []byte("forged")); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	waitFor(t, func() bool { return judge.count() == 1 })
+	time.Sleep(100 * time.Millisecond)
+
+	if dst.count() != 0 {
+		t.Errorf("rejected message relayed")
+	}
+	judge.lock.Lock()
+	reports := len(judge.reports)
+	judge.lock.Unlock()
+	if reports != 1 {
+		t.Errorf("sender reported %d times, want 1", reports)
+	}
+}
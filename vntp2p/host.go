@@ -56,7 +56,17 @@ import (
 
 const (
 	// PID vnt protocol basic id
-	PID                 = "/p2p/1.0.0"
+	PID = "/p2p/1.0.0"
+	// PIDBinary vnt protocol id of streams using binary message framing
+	PIDBinary = "/p2p/2.0.0"
+	// PIDSnappy vnt protocol id of streams using binary message framing
+	// with snappy compressed payloads
+	PIDSnappy = "/p2p/2.0.0/snappy"
+	// PIDWitness vnt protocol id of the streams between witnesses
+	PIDWitness = "/p2p/witness/1.0.0"
+	// PIDChain vnt protocol id of the streams exchanging the chain of nodes
+	PIDChain = "/p2p/chain/1.0.0"
+
 	persistDataInterval = 10 * time.Second
 )
 
@@ -150,24 +160,26 @@ func ConstructDHT(ctx context.Context, listenstring string, nodekey *ecdsa.Priva
 	}
 
 	if vntp2pDB != nil {
-		go loop(vdht)
+		go loop(vdht, host.Peerstore())
 	}
 
 	return vdht, host, err
 }
 
 // some loop handler for p2p itself can be put here
-func loop(vdht *dht.IpfsDHT) {
+func loop(vdht *dht.IpfsDHT, ps pstore.Peerstore) {
 	var persistData = time.NewTicker(persistDataInterval)
 	for {
 		<-persistData.C
-		go persistDataPeriodly(vdht)
+		go persistDataPeriodly(vdht, ps)
 	}
 }
 
 // persist data unified entrance, both for bootnode and membernode
-func persistDataPeriodly(vdht *dht.IpfsDHT) {
+// Peers of foreign chains are left out, so that they are not reloaded.
+func persistDataPeriodly(vdht *dht.IpfsDHT, ps pstore.Peerstore) {
 	pd := vdht.GetPersistentData()
+	pd.PeerInfos, pd.KBuckets = filterForeignPeers(ps, pd.PeerInfos, pd.KBuckets)
 	/* fmt.Printf("host privKey is: %v \n", string(pd.PrivKey))
 	fmt.Printf("peerInfos is: \n")
 	for i := range pd.PeerInfos {
@@ -20,12 +20,15 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"sync/atomic"
 	"time"
 
+	"github.com/golang/snappy"
 	inet "github.com/libp2p/go-libp2p-net"
 	"github.com/vntchain/go-vnt/log"
 	"github.com/vntchain/go-vnt/rlp"
@@ -47,6 +50,24 @@ type MsgWriter interface {
 // MessageHeaderLength define message header length
 const MessageHeaderLength = 5
 
+// The last byte of the message header selects the framing of the message body.
+// Peers speaking the legacy protocol always leave it zero.
+const (
+	frameJSON        byte = 0    // JSON encoded body, the only framing of /p2p/1.0.0 peers
+	frameBinary      byte = 1    // binary encoded body (protocol, type, payload)
+	frameSnappy      byte = 0x80 // flag marking a snappy compressed payload
+	frameVersionMask byte = 0x7f
+
+	minCompressSize = 256              // payloads below this size are sent uncompressed
+	maxFrameSize    = 32 * 1024 * 1024 // maximum (uncompressed) size of a binary message
+)
+
+var (
+	errFrameTruncated = errors.New("truncated message frame")
+	errFrameTooLarge  = errors.New("message frame too large")
+	errProtocolName   = errors.New("protocol name too long")
+)
+
 // MessageType define vnt p2p protocol message type
 type MessageType uint64
 
@@ -105,17 +126,10 @@ func Send(w MsgWriter, protocolID string, msgType MessageType, data interface{})
 		PayloadSize: uint32(size),
 		Payload:     r,
 	}
-	msgBodyByte, err := json.Marshal(msgBody)
-	if err != nil {
-		log.Error("Send()", "marshal msgBody error", err)
-		return err
-	}
-	msgBodySize := len(msgBodyByte)
-	msgHeaderByte := make([]byte, MessageHeaderLength)
-	binary.LittleEndian.PutUint32(msgHeaderByte, uint32(msgBodySize))
-
+	// The header is only advisory until the framing of the writer is known,
+	// carry the payload size for the benefit of metering writers.
 	var msgHeader MsgHeader
-	copy(msgHeader[:], msgHeaderByte)
+	binary.LittleEndian.PutUint32(msgHeader[:], uint32(size))
 
 	msg := Msg{
 		Header: msgHeader,
@@ -159,13 +173,16 @@ type VNTMsger struct {
 
 // WriteMsg implement MsgReadWriter interface
 func (rw *VNTMsger) WriteMsg(msg Msg) (err error) {
-	msgHeaderByte := msg.Header[:]
-	msgBodyByte, err := json.Marshal(msg.Body)
+	var m []byte
+	if rw.peer.frame == frameJSON {
+		m, err = encodeJSONFrame(msg.Body)
+	} else {
+		m, err = encodeBinaryFrame(msg.Body, rw.peer.frame&frameSnappy != 0)
+	}
 	if err != nil {
-		rw.peer.log.Error("Write message", "marshal msgbody error", err)
+		rw.peer.log.Error("Write message", "encode msg error", err)
 		return err
 	}
-	m := append(msgHeaderByte, msgBodyByte...)
 
 	_, err = rw.w.Write(m)
 	if err != nil {
@@ -177,6 +194,7 @@ func (rw *VNTMsger) WriteMsg(msg Msg) (err error) {
 		rw.peer.log.Trace("Write message exit", "peer", rw.peer.RemoteID())
 		return err
 	}
+	rw.peer.traffic.add(msg.Body.ProtocolID, msg.Body.Type, len(m), false)
 	return nil
 }
 
@@ -224,3 +242,78 @@ func ExpectMsg(r MsgReader, code MessageType, content interface{}) error {
 	}
 	return nil
 }
+
+// encodeJSONFrame assembles the legacy frame of a message: the header followed
+// by the JSON encoded message body.
+func encodeJSONFrame(body MsgBody) ([]byte, error) {
+	msgBodyByte, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	frame := make([]byte, MessageHeaderLength, MessageHeaderLength+len(msgBodyByte))
+	binary.LittleEndian.PutUint32(frame, uint32(len(msgBodyByte)))
+	return append(frame, msgBodyByte...), nil
+}
+
+// encodeBinaryFrame assembles the binary frame of a message: the header
+// followed by the length prefixed protocol name, the uvarint message type and
+// the payload, snappy compressed if requested and worthwhile.
+func encodeBinaryFrame(body MsgBody, compress bool) ([]byte, error) {
+	if len(body.ProtocolID) > math.MaxUint8 {
+		return nil, errProtocolName
+	}
+	payload, err := ioutil.ReadAll(body.Payload)
+	if err != nil {
+		return nil, err
+	}
+	flags := frameBinary
+	if compress && len(payload) >= minCompressSize {
+		payload = snappy.Encode(nil, payload)
+		flags |= frameSnappy
+	}
+	frame := make([]byte, MessageHeaderLength, MessageHeaderLength+1+len(body.ProtocolID)+binary.MaxVarintLen64+len(payload))
+	frame = append(frame, byte(len(body.ProtocolID)))
+	frame = append(frame, body.ProtocolID...)
+
+	var msgType [binary.MaxVarintLen64]byte
+	frame = append(frame, msgType[:binary.PutUvarint(msgType[:], uint64(body.Type))]...)
+	frame = append(frame, payload...)
+
+	binary.LittleEndian.PutUint32(frame, uint32(len(frame)-MessageHeaderLength))
+	frame[MessageHeaderLength-1] = flags
+	return frame, nil
+}
+
+// decodeBinaryFrame parses the body of a binary frame with the given header
+// flags, decompressing the payload if needed.
+func decodeBinaryFrame(flags byte, data []byte) (*MsgBody, error) {
+	if len(data) < 1 || len(data) < 1+int(data[0]) {
+		return nil, errFrameTruncated
+	}
+	nameEnd := 1 + int(data[0])
+	protocolID, data := string(data[1:nameEnd]), data[nameEnd:]
+
+	msgType, n := binary.Uvarint(data)
+	if n <= 0 {
+		return nil, errFrameTruncated
+	}
+	payload := data[n:]
+	if flags&frameSnappy != 0 {
+		size, err := snappy.DecodedLen(payload)
+		if err != nil {
+			return nil, err
+		}
+		if size > maxFrameSize {
+			return nil, errFrameTooLarge
+		}
+		if payload, err = snappy.Decode(nil, payload); err != nil {
+			return nil, err
+		}
+	}
+	return &MsgBody{
+		ProtocolID:  protocolID,
+		Type:        MessageType(msgType),
+		PayloadSize: uint32(len(payload)),
+		Payload:     bytes.NewReader(payload),
+	}, nil
+}
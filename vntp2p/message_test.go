@@ -0,0 +1,112 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package vntp2p
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/vntchain/go-vnt/rlp"
+)
+
+// testBody creates a message body carrying the RLP encoding of data.
+func testBody(t *testing.T, data interface{}) MsgBody {
+	size, r, err := rlp.EncodeToReader(data)
+	if err != nil {
+		t.Fatalf("failed to encode payload: %v", err)
+	}
+	return MsgBody{ProtocolID: "vnt", Type: 7, PayloadSize: uint32(size), Payload: r}
+}
+
+// Tests that messages survive the binary framing, with and without compression.
+func TestBinaryFrameRoundtrip(t *testing.T) {
+	tests := []struct {
+		data       []byte
+		compress   bool
+		compressed bool
+	}{
+		{[]byte("short"), false, false},
+		{[]byte("short"), true, false},
+		{bytes.Repeat([]byte{0x42}, 4096), false, false},
+		{bytes.Repeat([]byte{0x42}, 4096), true, true},
+	}
+	for i, tt := range tests {
+		frame, err := encodeBinaryFrame(testBody(t, tt.data), tt.compress)
+		if err != nil {
+			t.Fatalf("test %d: failed to encode frame: %v", i, err)
+		}
+		flags := frame[MessageHeaderLength-1]
+		if flags&frameVersionMask != frameBinary || (flags&frameSnappy != 0) != tt.compressed {
+			t.Fatalf("test %d: frame flags mismatch: %#x", i, flags)
+		}
+		if size := binary.LittleEndian.Uint32(frame); int(size) != len(frame)-MessageHeaderLength {
+			t.Fatalf("test %d: body size mismatch: have %d, want %d", i, size, len(frame)-MessageHeaderLength)
+		}
+		body, err := decodeBinaryFrame(flags, frame[MessageHeaderLength:])
+		if err != nil {
+			t.Fatalf("test %d: failed to decode frame: %v", i, err)
+		}
+		if body.ProtocolID != "vnt" || body.Type != 7 {
+			t.Fatalf("test %d: message mismatch: %+v", i, body)
+		}
+		var data []byte
+		if err := (Msg{Body: *body}).Decode(&data); err != nil {
+			t.Fatalf("test %d: failed to decode payload: %v", i, err)
+		}
+		if !bytes.Equal(data, tt.data) {
+			t.Fatalf("test %d: payload mismatch: have %x, want %x", i, data, tt.data)
+		}
+	}
+}
+
+// Tests that corrupt binary frames are rejected.
+func TestBinaryFrameCorrupt(t *testing.T) {
+	frame, err := encodeBinaryFrame(testBody(t, bytes.Repeat([]byte{0x42}, 4096)), true)
+	if err != nil {
+		t.Fatalf("failed to encode frame: %v", err)
+	}
+	body := frame[MessageHeaderLength:]
+	for _, size := range []int{0, 1, 4} {
+		if _, err := decodeBinaryFrame(frameBinary, body[:size]); err != errFrameTruncated {
+			t.Errorf("truncated frame of %d bytes: error mismatch: have %v, want %v", size, err, errFrameTruncated)
+		}
+	}
+	if _, err := decodeBinaryFrame(frameBinary|frameSnappy, body[:len(body)-4]); err == nil {
+		t.Errorf("corrupt compressed payload accepted")
+	}
+}
+
+// Tests that the legacy framing is still understood by old peers.
+func TestJSONFrameCompatibility(t *testing.T) {
+	frame, err := encodeJSONFrame(testBody(t, []byte("legacy")))
+	if err != nil {
+		t.Fatalf("failed to encode frame: %v", err)
+	}
+	if frame[MessageHeaderLength-1] != frameJSON {
+		t.Fatalf("legacy frame flags set: %#x", frame[MessageHeaderLength-1])
+	}
+	body := &MsgBody{Payload: &rlp.EncReader{}}
+	if err := json.Unmarshal(frame[MessageHeaderLength:], body); err != nil {
+		t.Fatalf("failed to decode legacy frame: %v", err)
+	}
+	var data []byte
+	if err := (Msg{Body: *body}).Decode(&data); err != nil || string(data) != "legacy" {
+		t.Fatalf("legacy payload mismatch: have %q, %v", data, err)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package vntp2p
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/vntchain/go-vnt/metrics"
+)
+
+var (
+	ingressPacketsMeter = metrics.NewRegisteredMeter("p2p/ingress/packets", nil)
+	ingressTrafficMeter = metrics.NewRegisteredMeter("p2p/ingress/traffic", nil)
+	egressPacketsMeter  = metrics.NewRegisteredMeter("p2p/egress/packets", nil)
+	egressTrafficMeter  = metrics.NewRegisteredMeter("p2p/egress/traffic", nil)
+)
+
+// otherTraffic is the protocol the messages of unknown protocols or codes are
+// accounted under, with code 0.
+const otherTraffic = "other"
+
+// Traffic counts the messages and bytes exchanged in each direction. Sizes are
+// the ones of the frames on the wire, after compression.
+type Traffic struct {
+	IngressPackets uint64 `json:"ingressPackets"`
+	IngressBytes   uint64 `json:"ingressBytes"`
+	EgressPackets  uint64 `json:"egressPackets"`
+	EgressBytes    uint64 `json:"egressBytes"`
+}
+
+func (t *Traffic) add(size int, ingress bool) {
+	if ingress {
+		t.IngressPackets++
+		t.IngressBytes += uint64(size)
+	} else {
+		t.EgressPackets++
+		t.EgressBytes += uint64(size)
+	}
+}
+
+// PeerTraffic is the traffic exchanged with a peer, in total and broken down
+// by protocol and message code. The received messages of unknown protocols or
+// codes are gathered under the "other" protocol.
+type PeerTraffic struct {
+	Total     Traffic                             `json:"total"`
+	Protocols map[string]map[MessageType]*Traffic `json:"protocols"`
+}
+
+// trafficCounter accumulates the traffic of a peer.
+type trafficCounter struct {
+	lock    sync.Mutex
+	traffic PeerTraffic
+}
+
+func newTrafficCounter() *trafficCounter {
+	return &trafficCounter{traffic: PeerTraffic{Protocols: make(map[string]map[MessageType]*Traffic)}}
+}
+
+// add accounts a message of the given protocol and code, marking the traffic
+// meters as well.
+func (c *trafficCounter) add(protocol string, code MessageType, size int, ingress bool) {
+	meterTraffic(protocol, code, size, ingress)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.traffic.Total.add(size, ingress)
+	codes, ok := c.traffic.Protocols[protocol]
+	if !ok {
+		codes = make(map[MessageType]*Traffic)
+		c.traffic.Protocols[protocol] = codes
+	}
+	t, ok := codes[code]
+	if !ok {
+		t = new(Traffic)
+		codes[code] = t
+	}
+	t.add(size, ingress)
+}
+
+// snapshot returns a copy of the accumulated traffic.
+func (c *trafficCounter) snapshot() *PeerTraffic {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	cpy := &PeerTraffic{Total: c.traffic.Total, Protocols: make(map[string]map[MessageType]*Traffic, len(c.traffic.Protocols))}
+	for protocol, codes := range c.traffic.Protocols {
+		cpy.Protocols[protocol] = make(map[MessageType]*Traffic, len(codes))
+		for code, t := range codes {
+			traffic := *t
+			cpy.Protocols[protocol][code] = &traffic
+		}
+	}
+	return cpy
+}
+
+// ingressTraffic returns the protocol and code a received message is accounted
+// under. They are chosen by the remote peer, so only the known ones get their
+// own counters and meters, the others are gathered under otherTraffic.
+func ingressTraffic(msgers map[string]*VNTMsger, body *MsgBody) (string, MessageType) {
+	if msger, ok := msgers[body.ProtocolID]; ok && uint64(body.Type) < msger.protocol.Length {
+		return body.ProtocolID, body.Type
+	}
+	return otherTraffic, 0
+}
+
+// meterTraffic marks the total traffic meters and the ones of the protocol
+// message, named p2p/{ingress,egress}/<protocol>/<code>/{packets,traffic}.
+func meterTraffic(protocol string, code MessageType, size int, ingress bool) {
+	if !metrics.Enabled {
+		return
+	}
+	direction := "egress"
+	if ingress {
+		direction = "ingress"
+		ingressPacketsMeter.Mark(1)
+		ingressTrafficMeter.Mark(int64(size))
+	} else {
+		egressPacketsMeter.Mark(1)
+		egressTrafficMeter.Mark(int64(size))
+	}
+	prefix := fmt.Sprintf("p2p/%s/%s/%d", direction, protocol, code)
+	metrics.GetOrRegisterMeter(prefix+"/packets", nil).Mark(1)
+	metrics.GetOrRegisterMeter(prefix+"/traffic", nil).Mark(int64(size))
+}
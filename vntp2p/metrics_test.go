@@ -0,0 +1,67 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package vntp2p
+
+import "testing"
+
+// Tests that peer traffic is accounted per protocol and message code.
+func TestTrafficCounter(t *testing.T) {
+	c := newTrafficCounter()
+	c.add("vnt", 0x02, 100, true)
+	c.add("vnt", 0x02, 50, true)
+	c.add("vnt", 0x11, 1000, false)
+	c.add("les", 0x02, 10, false)
+
+	snap := c.snapshot()
+	if want := (Traffic{IngressPackets: 2, IngressBytes: 150, EgressPackets: 2, EgressBytes: 1010}); snap.Total != want {
+		t.Errorf("total traffic mismatch: have %+v, want %+v", snap.Total, want)
+	}
+	if want := (Traffic{IngressPackets: 2, IngressBytes: 150}); *snap.Protocols["vnt"][0x02] != want {
+		t.Errorf("vnt txs traffic mismatch: have %+v, want %+v", *snap.Protocols["vnt"][0x02], want)
+	}
+	if want := (Traffic{EgressPackets: 1, EgressBytes: 10}); *snap.Protocols["les"][0x02] != want {
+		t.Errorf("les traffic mismatch: have %+v, want %+v", *snap.Protocols["les"][0x02], want)
+	}
+	// Snapshots must not change with later traffic
+	c.add("vnt", 0x02, 1, true)
+	if snap.Protocols["vnt"][0x02].IngressPackets != 2 || snap.Total.IngressPackets != 2 {
+		t.Errorf("snapshot modified by later traffic")
+	}
+}
+
+// Tests that received messages of unknown protocols or codes are accounted
+// under a single bucket.
+func TestIngressTrafficBuckets(t *testing.T) {
+	msgers := map[string]*VNTMsger{"vnt": {protocol: Protocol{Name: "vnt", Length: 20}}}
+
+	tests := []struct {
+		body     MsgBody
+		protocol string
+		code     MessageType
+	}{
+		{MsgBody{ProtocolID: "vnt", Type: 0x02}, "vnt", 0x02},
+		{MsgBody{ProtocolID: "vnt", Type: 19}, "vnt", 19},
+		{MsgBody{ProtocolID: "vnt", Type: 20}, otherTraffic, 0},
+		{MsgBody{ProtocolID: "vnt", Type: 1 << 60}, otherTraffic, 0},
+		{MsgBody{ProtocolID: "spam", Type: 0x02}, otherTraffic, 0},
+	}
+	for i, tt := range tests {
+		if protocol, code := ingressTraffic(msgers, &tt.body); protocol != tt.protocol || code != tt.code {
+			t.Errorf("test %d: bucket mismatch: have %s/%d, want %s/%d", i, protocol, code, tt.protocol, tt.code)
+		}
+	}
+}
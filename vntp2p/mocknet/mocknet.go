@@ -0,0 +1,600 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package mocknet implements an in-memory libp2p network, connecting vntp2p
+// servers without sockets so that protocols can be tested end-to-end.
+//
+// Hosts get deterministic identities derived from the seed of the network,
+// and the links between them can delay and drop the written data. Drops affect
+// whole writes, which vntp2p uses to send single message frames.
+package mocknet
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	p2phost "github.com/libp2p/go-libp2p-host"
+	ifconnmgr "github.com/libp2p/go-libp2p-interface-connmgr"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	protocol "github.com/libp2p/go-libp2p-protocol"
+	ma "github.com/multiformats/go-multiaddr"
+	msmux "github.com/multiformats/go-multistream"
+	"github.com/vntchain/go-vnt/crypto"
+	"github.com/vntchain/go-vnt/vntp2p"
+)
+
+var (
+	errUnknownPeer      = errors.New("peer not in the network")
+	errHostClosed       = errors.New("host closed")
+	errNotSupported     = errors.New("protocol not supported")
+	errStreamClosed     = errors.New("stream closed")
+	errStreamReset      = errors.New("stream reset")
+	errStreamsForbidden = errors.New("simulated connections carry a single stream")
+)
+
+var _ p2phost.Host = (*Host)(nil)
+
+// LinkOptions configures the links between two simulated hosts.
+type LinkOptions struct {
+	Latency time.Duration // Delay of every write before it reaches the remote end
+	Loss    float64       // Probability of a write to be dropped, between 0 and 1
+}
+
+// Network is a set of simulated hosts reachable from each other.
+type Network struct {
+	seed int64
+
+	lock     sync.Mutex
+	hosts    map[peer.ID]*Host
+	order    []*Host
+	links    map[[2]peer.ID]LinkOptions
+	defaults LinkOptions
+	streams  uint64
+}
+
+// New creates an empty network. Networks created with the same seed assign
+// the same identities to their hosts and drop the same writes.
+func New(seed int64) *Network {
+	return &Network{
+		seed:  seed,
+		hosts: make(map[peer.ID]*Host),
+		links: make(map[[2]peer.ID]LinkOptions),
+	}
+}
+
+// SetDefaultLink sets the options of the links without specific ones. It only
+// applies to the streams opened afterwards.
+func (n *Network) SetDefaultLink(opts LinkOptions) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.defaults = opts
+}
+
+// SetLink sets the options of the links between two hosts, in both directions.
+// It only applies to the streams opened afterwards.
+func (n *Network) SetLink(a, b peer.ID, opts LinkOptions) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.links[linkKey(a, b)] = opts
+}
+
+func linkKey(a, b peer.ID) [2]peer.ID {
+	if b < a {
+		a, b = b, a
+	}
+	return [2]peer.ID{a, b}
+}
+
+// AddHost creates a new host in the network.
+func (n *Network) AddHost() (*Host, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	index := len(n.order)
+	var seed [16]byte
+	binary.BigEndian.PutUint64(seed[:], uint64(n.seed))
+	binary.BigEndian.PutUint64(seed[8:], uint64(index))
+	key, err := crypto.ToECDSA(crypto.Keccak256(seed[:]))
+	if err != nil {
+		return nil, err
+	}
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := ma.NewMultiaddr(fmt.Sprintf("/ip4/10.%d.%d.%d/tcp/3001", index>>16&0xff, index>>8&0xff, index&0xff))
+	if err != nil {
+		return nil, err
+	}
+	h := &Host{
+		net:       n,
+		id:        id,
+		key:       key,
+		addr:      addr,
+		peerstore: pstore.NewPeerstore(),
+		handlers:  make(map[protocol.ID]inet.StreamHandler),
+		streams:   make(map[*stream]struct{}),
+	}
+	h.peerstore.AddAddr(id, addr, pstore.PermanentAddrTTL)
+	n.hosts[id] = h
+	n.order = append(n.order, h)
+	return h, nil
+}
+
+// Hosts returns the hosts of the network, in order of creation.
+func (n *Network) Hosts() []*Host {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return append([]*Host{}, n.order...)
+}
+
+// Disconnect resets all the streams between two hosts.
+func (n *Network) Disconnect(a, b peer.ID) {
+	n.lock.Lock()
+	h := n.hosts[a]
+	n.lock.Unlock()
+
+	if h != nil {
+		for _, s := range h.openStreams() {
+			if s.conn.remote.id == b {
+				s.Reset()
+			}
+		}
+	}
+}
+
+// Close closes all the hosts of the network.
+func (n *Network) Close() {
+	for _, h := range n.Hosts() {
+		h.Close()
+	}
+}
+
+// StartServer starts a vntp2p server on a new host of the network.
+func (n *Network) StartServer(config vntp2p.Config) (*vntp2p.Server, error) {
+	h, err := n.AddHost()
+	if err != nil {
+		return nil, err
+	}
+	config.Host = h
+	server := &vntp2p.Server{Config: config}
+	if err := server.Start(); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// Connect makes a server connect to the host of another one, reconnecting as
+// long as it is running.
+func Connect(server, remote *vntp2p.Server) {
+	server.AddPeer(context.Background(), remote.Self())
+}
+
+// dial opens a stream from a host to another.
+func (n *Network) dial(from *Host, to peer.ID, pids []protocol.ID) (*stream, error) {
+	n.lock.Lock()
+	remote := n.hosts[to]
+	opts, ok := n.links[linkKey(from.id, to)]
+	if !ok {
+		opts = n.defaults
+	}
+	n.streams++
+	nonce := n.streams
+	n.lock.Unlock()
+
+	if remote == nil {
+		return nil, errUnknownPeer
+	}
+	var (
+		pid     protocol.ID
+		handler inet.StreamHandler
+	)
+	for _, id := range pids {
+		if handler = remote.handler(id); handler != nil {
+			pid = id
+			break
+		}
+	}
+	if handler == nil {
+		return nil, errNotSupported
+	}
+	// Seed the drops of every direction independently of the others, so that
+	// they do not depend on the scheduling of the streams
+	newDropper := func(direction int64) *dropper {
+		return &dropper{loss: opts.Loss, random: rand.New(rand.NewSource(n.seed ^ int64(nonce)<<1 ^ direction))}
+	}
+	local, other := newBuffer(), newBuffer()
+	ls := &stream{protocol: pid, in: local, out: newLink(opts.Latency, newDropper(0), other)}
+	rs := &stream{protocol: pid, in: other, out: newLink(opts.Latency, newDropper(1), local)}
+	ls.conn = &conn{local: from, remote: remote, stream: ls}
+	rs.conn = &conn{local: remote, remote: from, stream: rs}
+	ls.peer, rs.peer = rs, ls
+
+	if err := from.track(ls); err != nil {
+		ls.Reset()
+		return nil, err
+	}
+	if err := remote.track(rs); err != nil {
+		ls.Reset()
+		return nil, err
+	}
+	go handler(rs)
+	return ls, nil
+}
+
+// Host is a simulated libp2p host. It does not implement the network and
+// multistream accessors, which vntp2p does not use.
+type Host struct {
+	net       *Network
+	id        peer.ID
+	key       *ecdsa.PrivateKey
+	addr      ma.Multiaddr
+	peerstore pstore.Peerstore
+
+	lock     sync.Mutex
+	handlers map[protocol.ID]inet.StreamHandler
+	streams  map[*stream]struct{}
+	closed   bool
+}
+
+// ID returns the peer id of the host.
+func (h *Host) ID() peer.ID { return h.id }
+
+// Peerstore returns the peer repository of the host.
+func (h *Host) Peerstore() pstore.Peerstore { return h.peerstore }
+
+// Addrs returns the simulated listening address of the host.
+func (h *Host) Addrs() []ma.Multiaddr { return []ma.Multiaddr{h.addr} }
+
+// Network is not implemented by simulated hosts.
+func (h *Host) Network() inet.Network { return nil }
+
+// Mux is not implemented by simulated hosts.
+func (h *Host) Mux() *msmux.MultistreamMuxer { return nil }
+
+// ConnManager returns a connection manager doing nothing.
+func (h *Host) ConnManager() ifconnmgr.ConnManager { return ifconnmgr.NullConnMgr{} }
+
+// Connect checks that the peer is part of the network, simulated hosts open
+// their connections along with the streams.
+func (h *Host) Connect(ctx context.Context, pi pstore.PeerInfo) error {
+	h.net.lock.Lock()
+	defer h.net.lock.Unlock()
+
+	if _, ok := h.net.hosts[pi.ID]; !ok {
+		return errUnknownPeer
+	}
+	return nil
+}
+
+// SetStreamHandler sets the handler of the inbound streams of a protocol.
+func (h *Host) SetStreamHandler(pid protocol.ID, handler inet.StreamHandler) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.handlers[pid] = handler
+}
+
+// SetStreamHandlerMatch sets the handler of a protocol, ignoring the matcher.
+func (h *Host) SetStreamHandlerMatch(pid protocol.ID, match func(string) bool, handler inet.StreamHandler) {
+	h.SetStreamHandler(pid, handler)
+}
+
+// RemoveStreamHandler removes the handler of a protocol.
+func (h *Host) RemoveStreamHandler(pid protocol.ID) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	delete(h.handlers, pid)
+}
+
+func (h *Host) handler(pid protocol.ID) inet.StreamHandler {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.closed {
+		return nil
+	}
+	return h.handlers[pid]
+}
+
+// NewStream opens a stream to a peer, using the first of the protocols it
+// handles.
+func (h *Host) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (inet.Stream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s, err := h.net.dial(h, p, pids)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close resets all the streams of the host and stops accepting new ones.
+func (h *Host) Close() error {
+	h.lock.Lock()
+	h.closed = true
+	h.lock.Unlock()
+
+	for _, s := range h.openStreams() {
+		s.Reset()
+	}
+	return nil
+}
+
+func (h *Host) track(s *stream) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.closed {
+		return errHostClosed
+	}
+	h.streams[s] = struct{}{}
+	return nil
+}
+
+func (h *Host) untrack(s *stream) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	delete(h.streams, s)
+}
+
+func (h *Host) openStreams() []*stream {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	streams := make([]*stream, 0, len(h.streams))
+	for s := range h.streams {
+		streams = append(streams, s)
+	}
+	return streams
+}
+
+// conn is the simulated connection carrying a stream.
+type conn struct {
+	local, remote *Host
+	stream        *stream
+}
+
+func (c *conn) Close() error                       { return c.stream.Reset() }
+func (c *conn) LocalPeer() peer.ID                 { return c.local.id }
+func (c *conn) LocalPrivateKey() *ecdsa.PrivateKey { return c.local.key }
+func (c *conn) RemotePeer() peer.ID                { return c.remote.id }
+func (c *conn) RemotePublicKey() *ecdsa.PublicKey  { return &c.remote.key.PublicKey }
+func (c *conn) LocalMultiaddr() ma.Multiaddr       { return c.local.addr }
+func (c *conn) RemoteMultiaddr() ma.Multiaddr      { return c.remote.addr }
+func (c *conn) NewStream() (inet.Stream, error)    { return nil, errStreamsForbidden }
+func (c *conn) GetStreams() []inet.Stream          { return []inet.Stream{c.stream} }
+
+// stream is one end of a simulated stream.
+type stream struct {
+	conn *conn
+	peer *stream // Other end of the stream
+	in   *buffer // Data written by the other end
+	out  *link   // Data written to the other end
+
+	lock     sync.Mutex
+	protocol protocol.ID
+}
+
+func (s *stream) Read(b []byte) (int, error)  { return s.in.read(b) }
+func (s *stream) Write(b []byte) (int, error) { return s.out.write(b) }
+
+// Close closes the stream for writing, the other end reads the data written
+// until then before getting EOF.
+func (s *stream) Close() error {
+	s.out.close()
+	return nil
+}
+
+// Reset closes both ends of the stream, discarding the data in flight.
+func (s *stream) Reset() error {
+	for _, end := range []*stream{s, s.peer} {
+		end.out.reset()
+		end.in.fail(errStreamReset)
+		end.conn.local.untrack(end)
+	}
+	return nil
+}
+
+// Deadlines are not supported by simulated streams.
+func (s *stream) SetDeadline(time.Time) error      { return nil }
+func (s *stream) SetReadDeadline(time.Time) error  { return nil }
+func (s *stream) SetWriteDeadline(time.Time) error { return nil }
+
+func (s *stream) Protocol() protocol.ID {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.protocol
+}
+
+func (s *stream) SetProtocol(pid protocol.ID) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.protocol = pid
+}
+
+func (s *stream) Conn() inet.Conn { return s.conn }
+
+// buffer holds the data delivered to a stream until it is read.
+type buffer struct {
+	lock sync.Mutex
+	cond *sync.Cond
+	data []byte
+	eof  bool  // Whether the other end closed the stream
+	err  error // Error returned once the stream is reset
+}
+
+func newBuffer() *buffer {
+	b := new(buffer)
+	b.cond = sync.NewCond(&b.lock)
+	return b
+}
+
+func (b *buffer) read(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for len(b.data) == 0 && !b.eof && b.err == nil {
+		b.cond.Wait()
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	if len(b.data) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, b.data)
+	b.data = b.data[n:]
+	return n, nil
+}
+
+func (b *buffer) push(data []byte) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.err == nil {
+		b.data = append(b.data, data...)
+		b.cond.Broadcast()
+	}
+}
+
+func (b *buffer) closeWrite() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.eof = true
+	b.cond.Broadcast()
+}
+
+func (b *buffer) fail(err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.err == nil {
+		b.err = err
+		b.data = nil
+	}
+	b.cond.Broadcast()
+}
+
+// segment is a write in flight on a link.
+type segment struct {
+	data []byte
+	at   time.Time // Time of the delivery
+}
+
+// dropper decides which writes of a link are lost.
+type dropper struct {
+	loss   float64
+	random *rand.Rand
+}
+
+func (d *dropper) drop() bool {
+	return d.loss > 0 && d.random.Float64() < d.loss
+}
+
+// link delivers the writes of a stream to the buffer of the other end, in
+// order, after the latency of the link unless they are dropped.
+type link struct {
+	latency time.Duration
+	drops   *dropper // Guarded by the lock of the link
+	dst     *buffer
+
+	lock    sync.Mutex
+	cond    *sync.Cond
+	pending []segment
+	closed  bool
+}
+
+func newLink(latency time.Duration, drops *dropper, dst *buffer) *link {
+	l := &link{latency: latency, drops: drops, dst: dst}
+	l.cond = sync.NewCond(&l.lock)
+	go l.loop()
+	return l
+}
+
+func (l *link) write(b []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.closed {
+		return 0, errStreamClosed
+	}
+	if l.drops.drop() {
+		return len(b), nil
+	}
+	l.pending = append(l.pending, segment{data: append([]byte{}, b...), at: time.Now().Add(l.latency)})
+	l.cond.Signal()
+	return len(b), nil
+}
+
+// close stops accepting writes, delivering the pending ones before the EOF.
+func (l *link) close() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.closed = true
+	l.cond.Signal()
+}
+
+// reset stops accepting writes and discards the pending ones.
+func (l *link) reset() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.closed = true
+	l.pending = nil
+	l.cond.Signal()
+}
+
+func (l *link) loop() {
+	for {
+		l.lock.Lock()
+		for len(l.pending) == 0 && !l.closed {
+			l.cond.Wait()
+		}
+		if len(l.pending) == 0 {
+			l.lock.Unlock()
+			l.dst.closeWrite()
+			return
+		}
+		seg := l.pending[0]
+		l.pending = l.pending[1:]
+		l.lock.Unlock()
+
+		if wait := time.Until(seg.at); wait > 0 {
+			time.Sleep(wait)
+		}
+		l.dst.push(seg.data)
+	}
+}
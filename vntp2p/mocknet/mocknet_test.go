@@ -0,0 +1,141 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package mocknet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vntchain/go-vnt/vntp2p"
+)
+
+const pingCount = 200
+
+// pingProtocol sends numbered messages to every peer, reporting the ones it
+// receives on the given channel.
+func pingProtocol(received chan<- uint64) vntp2p.Protocol {
+	return vntp2p.Protocol{
+		Name:    "ping",
+		Version: 1,
+		Length:  1,
+		Run: func(p *vntp2p.Peer, rw vntp2p.MsgReadWriter) error {
+			go func() {
+				for i := uint64(0); i < pingCount; i++ {
+					if err := vntp2p.Send(rw, "ping", 0, i); err != nil {
+						return
+					}
+				}
+			}()
+			for {
+				msg, err := rw.ReadMsg()
+				if err != nil {
+					return err
+				}
+				var n uint64
+				if err := msg.Decode(&n); err != nil {
+					return err
+				}
+				received <- n
+			}
+		},
+	}
+}
+
+func startPair(t *testing.T, net *Network) (chan uint64, chan uint64) {
+	a, b := make(chan uint64, pingCount), make(chan uint64, pingCount)
+	sa, err := net.StartServer(vntp2p.Config{MaxPeers: 10, Protocols: []vntp2p.Protocol{pingProtocol(a)}})
+	if err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	sb, err := net.StartServer(vntp2p.Config{MaxPeers: 10, Protocols: []vntp2p.Protocol{pingProtocol(b)}})
+	if err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	Connect(sa, sb)
+	return a, b
+}
+
+// collect reads the messages arriving until the channel stays idle.
+func collect(ch chan uint64, idle time.Duration) []uint64 {
+	var all []uint64
+	for {
+		select {
+		case n := <-ch:
+			all = append(all, n)
+		case <-time.After(idle):
+			return all
+		}
+	}
+}
+
+// Tests that servers exchange messages in order over a simulated link, and
+// that the link latency is honoured.
+func TestLatency(t *testing.T) {
+	net := New(1)
+	defer net.Close()
+	net.SetDefaultLink(LinkOptions{Latency: 50 * time.Millisecond})
+
+	start := time.Now()
+	a, b := startPair(t, net)
+
+	select {
+	case <-b:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no message received")
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("message delivered after %v, faster than the link latency", elapsed)
+	}
+	for _, ch := range []chan uint64{a, b} {
+		all := collect(ch, 500*time.Millisecond)
+		if ch == b {
+			all = append([]uint64{0}, all...)
+		}
+		if len(all) != pingCount {
+			t.Fatalf("received %d messages, want %d", len(all), pingCount)
+		}
+		for i, n := range all {
+			if n != uint64(i) {
+				t.Fatalf("message %d out of order: %d", i, n)
+			}
+		}
+	}
+}
+
+// Tests that lossy links drop whole messages, the same ones for equal seeds.
+func TestLoss(t *testing.T) {
+	run := func() []uint64 {
+		net := New(7)
+		defer net.Close()
+		net.SetDefaultLink(LinkOptions{Loss: 0.25})
+
+		_, b := startPair(t, net)
+		return collect(b, 500*time.Millisecond)
+	}
+	first, second := run(), run()
+	if len(first) == 0 || len(first) >= pingCount {
+		t.Fatalf("received %d of %d messages over lossy link", len(first), pingCount)
+	}
+	if len(first) != len(second) {
+		t.Fatalf("drops differ between runs: %d vs %d messages", len(first), len(second))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("drops differ between runs at %d: %d vs %d", i, first[i], second[i])
+		}
+	}
+}
@@ -77,6 +77,7 @@ type PeerInfo struct {
 		Static        bool   `json:"static"`
 	} `json:"network"`
 	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
+	Traffic   *PeerTraffic           `json:"traffic"`   // Messages and bytes exchanged with the peer
 }
 
 type Peer struct {
@@ -86,7 +87,9 @@ type Peer struct {
 	events  *event.Feed
 	err     chan error
 	msgers  map[string]*VNTMsger // protocolName - vntMessenger
+	frame   byte                 // framing of the messages written to the stream
 	server  *Server
+	traffic *trafficCounter
 	wg      sync.WaitGroup
 }
 
@@ -109,7 +112,9 @@ func newPeer(s *Stream, server *Server) *Peer {
 		err:     make(chan error),
 		reseted: 0,
 		msgers:  m,
+		frame:   streamFrame(string(s.stream.Protocol())),
 		server:  server,
+		traffic: newTrafficCounter(),
 	}
 	for _, msger := range p.msgers {
 		msger.peer = p
@@ -118,6 +123,19 @@ func newPeer(s *Stream, server *Server) *Peer {
 	return p
 }
 
+// streamFrame returns the message framing negotiated by the given stream
+// protocol id.
+func streamFrame(pid string) byte {
+	switch pid {
+	case PIDSnappy:
+		return frameBinary | frameSnappy
+	case PIDBinary:
+		return frameBinary
+	default:
+		return frameJSON
+	}
+}
+
 // Drop this peer forever because of protocol mismatch
 func (p *Peer) Drop() {
 	log.Trace("Drop peer forever", "pid", p.RemoteID())
@@ -125,6 +143,13 @@ func (p *Peer) Drop() {
 	blacklist.write(p.RemoteID())
 }
 
+// Report penalises the remote peer for misbehaving, see Server.ReportPeer.
+func (p *Peer) Report(m Misbehaviour) {
+	if p.server != nil {
+		p.server.ReportPeer(p.RemoteID(), m)
+	}
+}
+
 // LocalID return local PeerID for upper application
 func (p *Peer) LocalID() libp2p.ID {
 	return p.rw.Conn().LocalPeer()
@@ -208,7 +233,8 @@ func (p *Peer) Reset() {
 
 func (p *Peer) Info() *PeerInfo {
 	info := &PeerInfo{
-		ID: p.RemoteID().String(),
+		ID:      p.RemoteID().String(),
+		Traffic: p.traffic.snapshot(),
 	}
 	info.Network.LocalAddress = p.rw.Conn().LocalMultiaddr().String()
 	info.Network.RemoteAddress = p.rw.Conn().RemoteMultiaddr().String()
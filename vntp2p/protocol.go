@@ -19,6 +19,7 @@ package vntp2p
 import (
 	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"io"
 	"time"
 
@@ -42,7 +43,7 @@ type Protocol struct {
 // 主、被动连接都走的流程
 func (server *Server) HandleStream(s inet.Stream) {
 	// if peer is blacklisted, ignore it
-	if blacklist.exists(s.Conn().RemotePeer()) {
+	if blacklist.exists(s.Conn().RemotePeer()) || server.reputation.banned(s.Conn().RemotePeer()) || server.isForeign(s.Conn().RemotePeer()) {
 		log.Trace("HandleStream: related peer is blacklisted", "pid", s.Conn().RemotePeer())
 		s.Conn().Close()
 		return
@@ -74,7 +75,13 @@ func (server *Server) HandleStream(s inet.Stream) {
 			return
 		}
 		bodySize := binary.LittleEndian.Uint32(msgHeaderByte)
+		frame := msgHeaderByte[MessageHeaderLength-1]
 
+		if frame&frameVersionMask != frameJSON && bodySize > maxFrameSize {
+			peer.log.Error("HandleStream", "read msg Body error", errFrameTooLarge)
+			notifyError(peer.msgers, errFrameTooLarge)
+			return
+		}
 		msgBodyByte := make([]byte, bodySize)
 		_, err = io.ReadFull(s, msgBodyByte)
 		if err != nil {
@@ -82,8 +89,20 @@ func (server *Server) HandleStream(s inet.Stream) {
 			notifyError(peer.msgers, err)
 			return
 		}
-		msgBody := &MsgBody{Payload: &rlp.EncReader{}}
-		err = json.Unmarshal(msgBodyByte, msgBody)
+		var msgBody *MsgBody
+		switch frame & frameVersionMask {
+		case frameJSON:
+			msgBody = &MsgBody{Payload: &rlp.EncReader{}}
+			err = json.Unmarshal(msgBodyByte, msgBody)
+		case frameBinary:
+			// Report the decoded payload size to the protocols, as it is the one
+			// subject to their message size limits
+			if msgBody, err = decodeBinaryFrame(frame, msgBodyByte); err == nil {
+				binary.LittleEndian.PutUint32(msgHeaderByte, msgBody.PayloadSize)
+			}
+		default:
+			err = fmt.Errorf("unknown message framing %d", frame&frameVersionMask)
+		}
 		if err != nil {
 			peer.log.Error("HandleStream", "unmarshal msg Body error", err)
 			notifyError(peer.msgers, err)
@@ -99,6 +118,9 @@ func (server *Server) HandleStream(s inet.Stream) {
 			Header: msgHeader,
 			Body:   *msgBody,
 		}
+		protocol, code := ingressTraffic(peer.msgers, msgBody)
+		peer.traffic.add(protocol, code, MessageHeaderLength+int(bodySize), true)
+
 		if msger, ok := peer.msgers[msgBody.ProtocolID]; ok { // this node support protocolID
 			// 非阻塞向上层协议传递消息，如果2s还未被读取，认为上层协议有故障
 			select {
@@ -0,0 +1,308 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package vntp2p
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+	"github.com/vntchain/go-vnt/log"
+)
+
+const (
+	defaultBanThreshold = 100
+	defaultBanDuration  = time.Hour
+
+	// scoreDecay is the number of penalty points forgiven per minute, so that
+	// occasional misbehaviour of honest peers never adds up to a ban.
+	scoreDecay = 1.0
+
+	// pruneInterval is the minimum interval between the evictions of the
+	// fully decayed scores and the expired bans.
+	pruneInterval = time.Minute
+
+	banListFile = "banned-nodes.json" // file within the node database directory
+)
+
+// Misbehaviour is a kind of misconduct of a remote peer reported by the
+// protocols running on top of the server.
+type Misbehaviour int
+
+const (
+	InvalidBlock      Misbehaviour = iota // peer propagated or delivered an invalid block
+	InvalidBftMsg                         // peer sent a malformed or invalid BFT message
+	RequestTimeout                        // peer failed to answer a request in time
+	UselessResponse                       // peer answered a request with unrequested data
+	ProtocolViolation                     // peer broke the rules of the protocol
+)
+
+// penalties is the score added for each kind of misbehaviour.
+var penalties = map[Misbehaviour]float64{
+	InvalidBlock:      50,
+	InvalidBftMsg:     25,
+	RequestTimeout:    10,
+	UselessResponse:   5,
+	ProtocolViolation: 50,
+}
+
+func (m Misbehaviour) String() string {
+	switch m {
+	case InvalidBlock:
+		return "invalid block"
+	case InvalidBftMsg:
+		return "invalid bft message"
+	case RequestTimeout:
+		return "request timeout"
+	case UselessResponse:
+		return "useless response"
+	case ProtocolViolation:
+		return "protocol violation"
+	default:
+		return "unknown misbehaviour"
+	}
+}
+
+// Ban is an entry of the ban list.
+type Ban struct {
+	ID     peer.ID   `json:"id"`
+	Reason string    `json:"reason"`
+	Until  time.Time `json:"until"`
+}
+
+// peerScore is the penalty score accumulated by a peer.
+type peerScore struct {
+	value   float64
+	updated time.Time
+}
+
+// decayed returns the score left at the given time.
+func (s *peerScore) decayed(now time.Time) float64 {
+	value := s.value - now.Sub(s.updated).Minutes()*scoreDecay
+	if value < 0 {
+		return 0
+	}
+	return value
+}
+
+// reputation tracks the score of remote peers and bans the ones whose score
+// exceeds the configured threshold. Bans outlive restarts of the node if a
+// database directory is configured.
+type reputation struct {
+	threshold float64
+	duration  time.Duration
+	path      string // ban list file, empty for an in-memory list
+
+	lock   sync.Mutex
+	scores map[peer.ID]*peerScore
+	bans   map[peer.ID]*Ban
+	pruned time.Time // last eviction of the idle scores and expired bans
+}
+
+// newReputation creates a reputation tracker, loading the persisted ban list
+// from the given directory if it is not empty.
+func newReputation(threshold int, duration time.Duration, dir string) *reputation {
+	if threshold <= 0 {
+		threshold = defaultBanThreshold
+	}
+	if duration <= 0 {
+		duration = defaultBanDuration
+	}
+	r := &reputation{
+		threshold: float64(threshold),
+		duration:  duration,
+		scores:    make(map[peer.ID]*peerScore),
+		bans:      make(map[peer.ID]*Ban),
+	}
+	if dir != "" {
+		r.path = filepath.Join(dir, banListFile)
+		r.load()
+	}
+	return r
+}
+
+// report adds the penalty of the given misbehaviour to the score of the peer,
+// returning the resulting ban if the peer crossed the ban threshold.
+func (r *reputation) report(id peer.ID, m Misbehaviour) *Ban {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+	r.prune(now)
+
+	score := r.scores[id]
+	if score == nil {
+		score = &peerScore{updated: now}
+		r.scores[id] = score
+	}
+	score.value = score.decayed(now) + penalties[m]
+	score.updated = now
+
+	if score.value < r.threshold {
+		return nil
+	}
+	delete(r.scores, id)
+	return r.addBan(id, r.duration, m.String())
+}
+
+// prune evicts the scores which fully decayed and the expired bans, so that
+// the peers seen once are not tracked forever. It runs at most once per prune
+// interval. The lock must be held by the caller.
+func (r *reputation) prune(now time.Time) {
+	if now.Sub(r.pruned) < pruneInterval {
+		return
+	}
+	r.pruned = now
+
+	for id, score := range r.scores {
+		if score.decayed(now) == 0 {
+			delete(r.scores, id)
+		}
+	}
+	expired := false
+	for id, ban := range r.bans {
+		if now.After(ban.Until) {
+			delete(r.bans, id)
+			expired = true
+		}
+	}
+	if expired {
+		r.save()
+	}
+}
+
+// ban adds the peer to the ban list for the given duration, or for the default
+// duration if it is zero.
+func (r *reputation) ban(id peer.ID, duration time.Duration, reason string) *Ban {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if duration <= 0 {
+		duration = r.duration
+	}
+	delete(r.scores, id)
+	return r.addBan(id, duration, reason)
+}
+
+// addBan adds the peer to the ban list. The lock must be held by the caller.
+func (r *reputation) addBan(id peer.ID, duration time.Duration, reason string) *Ban {
+	ban := &Ban{ID: id, Reason: reason, Until: time.Now().Add(duration)}
+	r.bans[id] = ban
+	r.save()
+	return ban
+}
+
+// unban removes the peer from the ban list and resets its score, reporting
+// whether it was banned.
+func (r *reputation) unban(id peer.ID) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.scores, id)
+	if _, ok := r.bans[id]; !ok {
+		return false
+	}
+	delete(r.bans, id)
+	r.save()
+	return true
+}
+
+// banned reports whether the peer is currently banned.
+func (r *reputation) banned(id peer.ID) bool {
+	if r == nil {
+		return false
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	ban, ok := r.bans[id]
+	if !ok {
+		return false
+	}
+	if time.Now().After(ban.Until) {
+		delete(r.bans, id)
+		r.save()
+		return false
+	}
+	return true
+}
+
+// list returns the active bans, ordered by expiry.
+func (r *reputation) list() []Ban {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+	bans := make([]Ban, 0, len(r.bans))
+	for _, ban := range r.bans {
+		if now.Before(ban.Until) {
+			bans = append(bans, *ban)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Until.Before(bans[j].Until) })
+	return bans
+}
+
+// load reads the persisted ban list, skipping expired entries.
+func (r *reputation) load() {
+	blob, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn("Failed to read ban list", "path", r.path, "err", err)
+		}
+		return
+	}
+	var bans []*Ban
+	if err := json.Unmarshal(blob, &bans); err != nil {
+		log.Warn("Failed to parse ban list", "path", r.path, "err", err)
+		return
+	}
+	now := time.Now()
+	for _, ban := range bans {
+		if now.Before(ban.Until) {
+			r.bans[ban.ID] = ban
+		}
+	}
+}
+
+// save persists the ban list. The lock must be held by the caller.
+func (r *reputation) save() {
+	if r.path == "" {
+		return
+	}
+	bans := make([]*Ban, 0, len(r.bans))
+	for _, ban := range r.bans {
+		bans = append(bans, ban)
+	}
+	blob, err := json.MarshalIndent(bans, "", "  ")
+	if err != nil {
+		log.Warn("Failed to encode ban list", "err", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		log.Warn("Failed to create ban list directory", "err", err)
+		return
+	}
+	if err := ioutil.WriteFile(r.path, blob, 0600); err != nil {
+		log.Warn("Failed to write ban list", "path", r.path, "err", err)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package vntp2p
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+	"github.com/vntchain/go-vnt/crypto"
+)
+
+func newTestPeerID(t *testing.T) peer.ID {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to derive peer id: %v", err)
+	}
+	return id
+}
+
+// Tests that peers get banned once their misbehaviour crosses the threshold.
+func TestReputationBanThreshold(t *testing.T) {
+	r := newReputation(55, time.Minute, "")
+	id, other := newTestPeerID(t), newTestPeerID(t)
+
+	for i := 0; i < 5; i++ {
+		if ban := r.report(id, RequestTimeout); ban != nil {
+			t.Fatalf("peer banned after %d timeouts", i+1)
+		}
+	}
+	if ban := r.report(id, RequestTimeout); ban == nil || ban.Reason != RequestTimeout.String() {
+		t.Fatalf("peer not banned after crossing threshold: %v", ban)
+	}
+	if !r.banned(id) || r.banned(other) {
+		t.Fatalf("ban status mismatch")
+	}
+	if bans := r.list(); len(bans) != 1 || bans[0].ID != id {
+		t.Fatalf("ban list mismatch: %v", bans)
+	}
+	if !r.unban(id) || r.banned(id) || r.unban(id) {
+		t.Fatalf("failed to lift ban")
+	}
+	// Unbanning resets the score of the peer
+	if ban := r.report(id, RequestTimeout); ban != nil {
+		t.Fatalf("unbanned peer retained its score")
+	}
+}
+
+// Tests that bans expire and survive restarts.
+func TestReputationPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vntp2p-bans-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	id, expired := newTestPeerID(t), newTestPeerID(t)
+
+	r := newReputation(0, 0, dir)
+	r.ban(id, 0, "manual")
+	r.ban(expired, time.Millisecond, "short")
+	time.Sleep(10 * time.Millisecond)
+
+	if r.banned(expired) {
+		t.Fatalf("expired ban still active")
+	}
+	r = newReputation(0, 0, dir)
+	if !r.banned(id) {
+		t.Fatalf("ban lost across restart")
+	}
+	bans := r.list()
+	if len(bans) != 1 || bans[0].Reason != "manual" || time.Until(bans[0].Until) < 59*time.Minute {
+		t.Fatalf("persisted ban list mismatch: %v", bans)
+	}
+}
+
+// Tests that the scores of idle peers and the expired bans are evicted.
+func TestReputationPrune(t *testing.T) {
+	r := newReputation(0, 0, "")
+	idle, active, expired := newTestPeerID(t), newTestPeerID(t), newTestPeerID(t)
+
+	r.report(idle, RequestTimeout)
+	r.report(active, InvalidBlock)
+	r.ban(expired, time.Millisecond, "short")
+
+	// Let the score of the idle peer fully decay, not the other one's
+	past := time.Now().Add(-20 * time.Minute)
+	r.scores[idle].updated, r.scores[active].updated, r.pruned = past, past, past
+	time.Sleep(10 * time.Millisecond)
+
+	r.report(active, UselessResponse)
+	if _, ok := r.scores[idle]; ok {
+		t.Errorf("decayed score not evicted")
+	}
+	if score, ok := r.scores[active]; !ok || score.value < 34 || score.value > 35 {
+		t.Errorf("active score mismatch: have %v", score)
+	}
+	if _, ok := r.bans[expired]; ok {
+		t.Errorf("expired ban not evicted")
+	}
+}
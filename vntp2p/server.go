@@ -23,6 +23,8 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	libp2p "github.com/libp2p/go-libp2p"
 	p2phost "github.com/libp2p/go-libp2p-host"
@@ -74,6 +76,22 @@ type Config struct {
 
 	EnableMsgEvents bool
 	Logger          log.Logger `toml:",omitempty"`
+
+	// NoCompression disables the negotiation of snappy compressed messages,
+	// binary framing is still used with peers supporting it.
+	NoCompression bool `toml:",omitempty"`
+
+	// BanThreshold is the misbehaviour score at which a peer gets banned, zero
+	// selects the default of 100.
+	BanThreshold int `toml:",omitempty"`
+
+	// BanDuration is the time a misbehaving peer stays banned, zero selects the
+	// default of one hour.
+	BanDuration time.Duration `toml:",omitempty"`
+
+	// Host replaces the libp2p host listening on ListenAddr, disabling the DHT
+	// discovery. It is meant for in-memory simulated networks.
+	Host p2phost.Host `toml:"-"`
 }
 
 type Server struct {
@@ -99,6 +117,10 @@ type Server struct {
 	peerOpDone chan struct{}
 
 	protomap map[string][]Protocol
+
+	reputation *reputation
+	witnesses  *witnessOverlay
+	chainID    atomic.Value // ChainID of the node, unset until known
 }
 
 type peerOpFunc func(map[peer.ID]*Peer)
@@ -139,34 +161,49 @@ func (server *Server) Start() error {
 	server.quit = make(chan struct{})
 	server.peerOp = make(chan peerOpFunc)
 	server.peerOpDone = make(chan struct{})
+	server.reputation = newReputation(server.BanThreshold, server.BanDuration, server.NodeDatabase)
+	server.witnesses = newWitnessOverlay(server)
 
 	// 协议映射初始化
 	server.protomap = make(map[string][]Protocol)
 
-	server.protomap[PID] = server.Protocols
+	for _, pid := range server.streamProtocols() {
+		server.protomap[pid] = server.Protocols
+	}
 
 	// Listen
 	// run
-	if server.ListenAddr == "" {
+	if server.ListenAddr == "" && server.Host == nil {
 		return fmt.Errorf("P2P Server can't start for no listening")
 	}
 
-	listenPort := server.Config.ListenAddr[1:]
 	ctx, cancel := context.WithCancel(context.Background())
 	server.cancel = cancel
 
-	d := server.NodeDatabase
-	vdht, host, err := ConstructDHT(ctx, MakePort(listenPort), nil, d, server.Config.NetRestrict, server.Config.NAT)
-	if err != nil {
-		log.Error("ConstructDHT failed", "error", err)
-		return err
+	host := server.Host
+	if host != nil {
+		// Simulated hosts have no discovery, peers are added explicitly
+		server.table = staticTable{}
+	} else {
+		listenPort := server.Config.ListenAddr[1:]
+		d := server.NodeDatabase
+		vdht, h, err := ConstructDHT(ctx, MakePort(listenPort), nil, d, server.Config.NetRestrict, server.Config.NAT)
+		if err != nil {
+			log.Error("ConstructDHT failed", "error", err)
+			return err
+		}
+		host = h
+		server.table = NewDHTTable(vdht, host.ID())
 	}
 
 	// setStreamHandler can only handle request message
 	// it can not hear response
-	host.SetStreamHandler(PID, server.HandleStream)
+	for _, pid := range server.streamProtocols() {
+		host.SetStreamHandler(protocol.ID(pid), server.HandleStream)
+	}
+	host.SetStreamHandler(protocol.ID(PIDWitness), server.handleWitnessStream)
+	host.SetStreamHandler(protocol.ID(PIDChain), server.handleChainStream)
 
-	server.table = NewDHTTable(vdht, host.ID())
 	server.host = host
 
 	bootnodes := server.LoadConfig(ctx)
@@ -178,6 +215,8 @@ func (server *Server) Start() error {
 	server.loopWG.Add(1)
 	go server.run(ctx, taskState)
 
+	go server.witnesses.loop(ctx)
+
 	server.running = true
 	return nil
 }
@@ -293,7 +332,7 @@ func (server *Server) Stop() {
 }
 
 func (server *Server) AddPeer(ctx context.Context, node *Node) {
-	if blacklist.exists(node.Id) {
+	if blacklist.exists(node.Id) || server.reputation.banned(node.Id) {
 		log.Trace("node is blacklisted", "pid", node.Id)
 		return
 	}
@@ -313,6 +352,62 @@ func (server *Server) RemovePeer(node *Node) {
 	}
 }
 
+// ReportPeer penalises a remote peer for the given misbehaviour, banning and
+// disconnecting it once its score crosses the ban threshold.
+func (server *Server) ReportPeer(id peer.ID, m Misbehaviour) {
+	if server.reputation == nil {
+		return
+	}
+	log.Debug("Peer misbehaved", "peer", id, "reason", m)
+	if ban := server.reputation.report(id, m); ban != nil {
+		log.Info("Banned misbehaving peer", "peer", id, "reason", ban.Reason, "until", ban.Until)
+		server.disconnect(id)
+	}
+}
+
+// BanPeer bans a remote peer for the given duration, disconnecting it if it is
+// connected. A zero duration bans the peer for the configured ban duration.
+func (server *Server) BanPeer(id peer.ID, duration time.Duration, reason string) error {
+	if server.reputation == nil {
+		return errServerStopped
+	}
+	ban := server.reputation.ban(id, duration, reason)
+	log.Info("Banned peer", "peer", id, "reason", reason, "until", ban.Until)
+
+	server.disconnect(id)
+	return nil
+}
+
+// UnbanPeer lifts the ban of a remote peer, reporting whether it was banned.
+func (server *Server) UnbanPeer(id peer.ID) bool {
+	if server.reputation == nil {
+		return false
+	}
+	return server.reputation.unban(id)
+}
+
+// Bans returns the currently banned peers.
+func (server *Server) Bans() []Ban {
+	if server.reputation == nil {
+		return nil
+	}
+	return server.reputation.list()
+}
+
+// disconnect closes the connection to the given peer, if any.
+func (server *Server) disconnect(id peer.ID) {
+	select {
+	case <-server.quit:
+	case server.peerOp <- func(peers map[peer.ID]*Peer) {
+		if p, ok := peers[id]; ok {
+			p.Disconnect(DiscUselessPeer)
+			p.rw.Conn().Close()
+		}
+	}:
+		<-server.peerOpDone
+	}
+}
+
 func (server *Server) SubscribeEvents(ch chan *PeerEvent) event.Subscription {
 	return server.peerFeed.Subscribe(ch)
 }
@@ -358,7 +453,7 @@ func (server *Server) GetPeerByRemoteID(s inet.Stream) *Peer {
 	var p *Peer
 
 	// always try to new this peer
-	err := server.dispatch(&Stream{stream: s, Protocols: server.protomap[PID]}, server.addpeer)
+	err := server.dispatch(&Stream{stream: s, Protocols: server.protomap[string(s.Protocol())]}, server.addpeer)
 	if err != nil {
 		log.Error("GetPeerByRemoteID()", "new peer error", err)
 		return nil
@@ -413,9 +508,30 @@ func (server *Server) maxDialedConns() int {
 	return server.MaxPeers / r
 }
 
+// streamProtocols returns the ids of the stream protocols spoken by the server,
+// in order of preference.
+func (server *Server) streamProtocols() []string {
+	if server.NoCompression {
+		return []string{PIDBinary, PID}
+	}
+	return []string{PIDSnappy, PIDBinary, PID}
+}
+
 // SetupStream 主动发起连接
+// Dialing the basic protocol negotiates the best message framing supported by
+// the remote peer.
 func (server *Server) SetupStream(ctx context.Context, target peer.ID, pid string) error {
-	s, err := server.host.NewStream(ctx, target, protocol.ID(pid))
+	pids := []protocol.ID{protocol.ID(pid)}
+	if pid == PID {
+		pids = pids[:0]
+		for _, id := range server.streamProtocols() {
+			pids = append(pids, protocol.ID(id))
+		}
+	}
+	if err := server.checkChain(ctx, target); err != nil {
+		return err
+	}
+	s, err := server.host.NewStream(ctx, target, pids...)
 	if err != nil {
 		// fmt.Println("SetupStream NewStream Error: ", err)
 		return err
@@ -436,7 +552,7 @@ func (server *Server) SetupStream(ctx context.Context, target peer.ID, pid strin
 		return err
 	} */
 
-	err = server.dispatch(&Stream{stream: s, Protocols: server.protomap[pid]}, server.addpeer)
+	err = server.dispatch(&Stream{stream: s, Protocols: server.protomap[string(s.Protocol())]}, server.addpeer)
 	if err != nil {
 		fmt.Println("SetupStream dispatch Error: ", err)
 		return err
@@ -0,0 +1,352 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package vntp2p
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	peerstore "github.com/libp2p/go-libp2p-peerstore"
+	protocol "github.com/libp2p/go-libp2p-protocol"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/vntchain/go-vnt/log"
+	"github.com/vntchain/go-vnt/rlp"
+)
+
+const (
+	witnessDialInterval = 10 * time.Second // Interval of the reconnection to lost witnesses
+	witnessQueueSize    = 64               // Maximum number of messages queued per priority and witness
+)
+
+var (
+	errNotWitness          = errors.New("peer is not a witness")
+	errWitnessDisconnected = errors.New("witness not connected")
+	errWitnessQueueFull    = errors.New("witness send queue full")
+)
+
+// WitnessHandler is invoked for every message received from a witness over
+// the witness overlay. Returning an error closes the connection.
+type WitnessHandler func(from peer.ID, msg Msg) error
+
+// witnessFrame is a message frame queued to a witness, along with the channel
+// receiving the result of its write.
+type witnessFrame struct {
+	data []byte
+	sent chan error
+}
+
+// witnessConn is a connection of the witness overlay, carried by a dedicated
+// stream so that its messages never queue behind the regular peer traffic.
+type witnessConn struct {
+	id       peer.ID
+	stream   inet.Stream
+	outbound bool
+
+	urgent chan *witnessFrame // Frames sent before any bulk one
+	bulk   chan *witnessFrame
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (c *witnessConn) close() {
+	c.once.Do(func() {
+		close(c.closed)
+		c.stream.Reset()
+	})
+}
+
+// witnessOverlay maintains direct connections between the current witnesses.
+// Only peers of the witness set authenticated by the transport may join it,
+// and its connections do not count against the peer limit.
+type witnessOverlay struct {
+	server *Server
+
+	lock      sync.RWMutex
+	witnesses map[peer.ID]*Node
+	conns     map[peer.ID]*witnessConn
+	handler   WitnessHandler
+}
+
+func newWitnessOverlay(server *Server) *witnessOverlay {
+	return &witnessOverlay{
+		server:    server,
+		witnesses: make(map[peer.ID]*Node),
+		conns:     make(map[peer.ID]*witnessConn),
+	}
+}
+
+// SetWitnesses replaces the set of witnesses allowed on the witness overlay,
+// disconnecting the former witnesses and connecting to the new ones.
+func (server *Server) SetWitnesses(nodes []*Node) {
+	o := server.witnesses
+	if o == nil {
+		return
+	}
+	o.lock.Lock()
+	o.witnesses = make(map[peer.ID]*Node, len(nodes))
+	for _, node := range nodes {
+		if node.Id != server.host.ID() {
+			o.witnesses[node.Id] = node
+			server.host.Peerstore().AddAddrs(node.Id, []ma.Multiaddr{node.Addr}, peerstore.PermanentAddrTTL)
+		}
+	}
+	for id, conn := range o.conns {
+		if _, ok := o.witnesses[id]; !ok {
+			delete(o.conns, id)
+			conn.close()
+		}
+	}
+	o.lock.Unlock()
+
+	go o.dialMissing(context.Background())
+}
+
+// SetWitnessHandler sets the handler of the messages received over the witness
+// overlay.
+func (server *Server) SetWitnessHandler(handler WitnessHandler) {
+	if o := server.witnesses; o != nil {
+		o.lock.Lock()
+		o.handler = handler
+		o.lock.Unlock()
+	}
+}
+
+// IsWitness reports whether the given peer belongs to the current witness set.
+func (server *Server) IsWitness(id peer.ID) bool {
+	o := server.witnesses
+	if o == nil {
+		return false
+	}
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	_, ok := o.witnesses[id]
+	return ok
+}
+
+// SendToWitness sends a message to a witness connected over the witness
+// overlay, returning once it was written to the connection. Urgent messages
+// are sent ahead of the other queued ones. A message dropped by a full queue
+// or a lost connection returns an error.
+func (server *Server) SendToWitness(id peer.ID, protocolID string, code MessageType, data interface{}, urgent bool) error {
+	o := server.witnesses
+	if o == nil {
+		return errWitnessDisconnected
+	}
+	o.lock.RLock()
+	conn := o.conns[id]
+	o.lock.RUnlock()
+	if conn == nil {
+		return errWitnessDisconnected
+	}
+	size, r, err := rlp.EncodeToReader(data)
+	if err != nil {
+		return err
+	}
+	encoded, err := encodeBinaryFrame(MsgBody{ProtocolID: protocolID, Type: code, PayloadSize: uint32(size), Payload: r}, !server.NoCompression)
+	if err != nil {
+		return err
+	}
+	queue := conn.bulk
+	if urgent {
+		queue = conn.urgent
+	}
+	frame := &witnessFrame{data: encoded, sent: make(chan error, 1)}
+	select {
+	case queue <- frame:
+	case <-conn.closed:
+		return errWitnessDisconnected
+	default:
+		return errWitnessQueueFull
+	}
+	select {
+	case err := <-frame.sent:
+		if err != nil {
+			return err
+		}
+		meterTraffic(protocolID, code, len(encoded), false)
+		return nil
+	case <-conn.closed:
+		return errWitnessDisconnected
+	}
+}
+
+// handleWitnessStream accepts an inbound witness overlay stream.
+func (server *Server) handleWitnessStream(s inet.Stream) {
+	remote := s.Conn().RemotePeer()
+	if server.reputation.banned(remote) || !server.IsWitness(remote) {
+		log.Debug("Rejected witness stream", "peer", remote, "err", errNotWitness)
+		s.Reset()
+		return
+	}
+	server.witnesses.run(s, false)
+}
+
+// dialMissing connects to the witnesses without an overlay connection.
+func (o *witnessOverlay) dialMissing(ctx context.Context) {
+	o.lock.RLock()
+	var missing []peer.ID
+	for id := range o.witnesses {
+		if _, ok := o.conns[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	o.lock.RUnlock()
+
+	for _, id := range missing {
+		if o.server.reputation.banned(id) {
+			continue
+		}
+		s, err := o.server.host.NewStream(ctx, id, protocol.ID(PIDWitness))
+		if err != nil {
+			log.Trace("Failed to dial witness", "peer", id, "err", err)
+			continue
+		}
+		go o.run(s, true)
+	}
+}
+
+// loop periodically reconnects to the witnesses whose connection was lost.
+func (o *witnessOverlay) loop(ctx context.Context) {
+	ticker := time.NewTicker(witnessDialInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			o.dialMissing(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// run registers an overlay connection and serves it until it fails.
+func (o *witnessOverlay) run(s inet.Stream, outbound bool) {
+	conn := &witnessConn{
+		id:       s.Conn().RemotePeer(),
+		stream:   s,
+		outbound: outbound,
+		urgent:   make(chan *witnessFrame, witnessQueueSize),
+		bulk:     make(chan *witnessFrame, witnessQueueSize),
+		closed:   make(chan struct{}),
+	}
+	if !o.register(conn) {
+		conn.close()
+		return
+	}
+	log.Debug("Witness connected", "peer", conn.id, "outbound", outbound)
+
+	go o.writeLoop(conn)
+	err := o.readLoop(conn)
+
+	o.lock.Lock()
+	if o.conns[conn.id] == conn {
+		delete(o.conns, conn.id)
+	}
+	o.lock.Unlock()
+	conn.close()
+
+	log.Debug("Witness disconnected", "peer", conn.id, "err", err)
+}
+
+// register adds a connection to the overlay. If both witnesses dialed each
+// other, the stream opened by the lower peer id is kept on both ends.
+func (o *witnessOverlay) register(conn *witnessConn) bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if _, ok := o.witnesses[conn.id]; !ok {
+		return false
+	}
+	if old, ok := o.conns[conn.id]; ok {
+		local := o.server.host.ID()
+		if old.outbound == (local < conn.id) {
+			return false
+		}
+		old.close()
+	}
+	o.conns[conn.id] = conn
+	return true
+}
+
+// writeLoop sends the queued frames of a connection, urgent ones first. The
+// senders of the frames left in the queues when the connection is closed are
+// released by the closure.
+func (o *witnessOverlay) writeLoop(conn *witnessConn) {
+	for {
+		var frame *witnessFrame
+		select {
+		case frame = <-conn.urgent:
+		default:
+			select {
+			case frame = <-conn.urgent:
+			case frame = <-conn.bulk:
+			case <-conn.closed:
+				return
+			}
+		}
+		if _, err := conn.stream.Write(frame.data); err != nil {
+			log.Debug("Failed to write to witness", "peer", conn.id, "err", err)
+			frame.sent <- err
+			conn.close()
+			return
+		}
+		frame.sent <- nil
+	}
+}
+
+// readLoop hands the messages received from a witness to the handler.
+func (o *witnessOverlay) readLoop(conn *witnessConn) error {
+	for {
+		var header MsgHeader
+		if _, err := io.ReadFull(conn.stream, header[:]); err != nil {
+			return err
+		}
+		size := binary.LittleEndian.Uint32(header[:])
+		if size > maxFrameSize {
+			return errFrameTooLarge
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(conn.stream, data); err != nil {
+			return err
+		}
+		body, err := decodeBinaryFrame(header[MessageHeaderLength-1], data)
+		if err != nil {
+			return err
+		}
+		body.ReceivedAt = time.Now()
+		meterTraffic(body.ProtocolID, body.Type, MessageHeaderLength+int(size), true)
+		binary.LittleEndian.PutUint32(header[:], body.PayloadSize)
+
+		o.lock.RLock()
+		handler := o.handler
+		o.lock.RUnlock()
+		if handler == nil {
+			continue
+		}
+		if err := handler(conn.id, Msg{Header: header, Body: *body}); err != nil {
+			return err
+		}
+	}
+}
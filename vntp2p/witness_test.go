@@ -0,0 +1,148 @@
+// Copyright 2019 The go-vnt Authors
+// This file is part of the go-vnt library.
+//
+// The go-vnt library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-vnt library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-vnt library. If not, see <http://www.gnu.org/licenses/>.
+
+package vntp2p
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+// recordStream is a stream recording the frames written to it.
+type recordStream struct {
+	inet.Stream
+
+	lock   sync.Mutex
+	frames []string
+}
+
+func (s *recordStream) Write(b []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.frames = append(s.frames, string(b))
+	return len(b), nil
+}
+
+func (s *recordStream) Reset() error { return nil }
+
+func (s *recordStream) written() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string{}, s.frames...)
+}
+
+// Tests that urgent witness messages overtake the queued bulk ones.
+func TestWitnessQueuePriority(t *testing.T) {
+	stream := new(recordStream)
+	conn := &witnessConn{
+		stream: stream,
+		urgent: make(chan *witnessFrame, witnessQueueSize),
+		bulk:   make(chan *witnessFrame, witnessQueueSize),
+		closed: make(chan struct{}),
+	}
+	newFrame := func(data string) *witnessFrame {
+		return &witnessFrame{data: []byte(data), sent: make(chan error, 1)}
+	}
+	conn.bulk <- newFrame("block-1")
+	conn.bulk <- newFrame("block-2")
+	conn.urgent <- newFrame("vote-1")
+	conn.urgent <- newFrame("vote-2")
+
+	o := newWitnessOverlay(new(Server))
+	go o.writeLoop(conn)
+	defer conn.close()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for len(stream.written()) < 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for frames")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	want := []string{"vote-1", "vote-2", "block-1", "block-2"}
+	for i, frame := range stream.written() {
+		if frame != want[i] {
+			t.Errorf("frame %d: have %q, want %q", i, frame, want[i])
+		}
+	}
+}
+
+// Tests that only members of the witness set join the overlay.
+func TestWitnessAuthorisation(t *testing.T) {
+	witness, stranger := newTestPeerID(t), newTestPeerID(t)
+
+	o := newWitnessOverlay(new(Server))
+	o.witnesses[witness] = &Node{Id: witness}
+
+	newConn := func(id peer.ID) *witnessConn {
+		return &witnessConn{id: id, stream: new(recordStream), closed: make(chan struct{})}
+	}
+	if o.register(newConn(stranger)) {
+		t.Fatalf("stranger joined the witness overlay")
+	}
+	if !o.register(newConn(witness)) {
+		t.Fatalf("witness rejected from the witness overlay")
+	}
+	if _, ok := o.conns[witness]; !ok || len(o.conns) != 1 {
+		t.Fatalf("witness connections mismatch: %v", o.conns)
+	}
+}
+
+// failingStream is a stream failing all the writes.
+type failingStream struct {
+	inet.Stream
+}
+
+func (s *failingStream) Write(b []byte) (int, error) { return 0, errors.New("stream reset") }
+func (s *failingStream) Reset() error                { return nil }
+
+// Tests that witness messages are only reported sent once written, so that
+// the ones lost with a connection can be delivered otherwise.
+func TestWitnessSendResult(t *testing.T) {
+	server := &Server{Config: Config{NoCompression: true}}
+	server.witnesses = newWitnessOverlay(server)
+
+	newConn := func(id peer.ID, stream inet.Stream) *witnessConn {
+		conn := &witnessConn{
+			id:     id,
+			stream: stream,
+			urgent: make(chan *witnessFrame, witnessQueueSize),
+			bulk:   make(chan *witnessFrame, witnessQueueSize),
+			closed: make(chan struct{}),
+		}
+		server.witnesses.conns[id] = conn
+		go server.witnesses.writeLoop(conn)
+		return conn
+	}
+	healthy, broken := newTestPeerID(t), newTestPeerID(t)
+	defer newConn(healthy, new(recordStream)).close()
+	defer newConn(broken, new(failingStream)).close()
+
+	if err := server.SendToWitness(healthy, "test", 1, "vote", true); err != nil {
+		t.Errorf("failed to send to healthy witness: %v", err)
+	}
+	if err := server.SendToWitness(broken, "test", 1, "vote", true); err == nil {
+		t.Errorf("message reported sent over a failing stream")
+	}
+	if err := server.SendToWitness(newTestPeerID(t), "test", 1, "vote", true); err != errWitnessDisconnected {
+		t.Errorf("error mismatch for unconnected witness: have %v, want %v", err, errWitnessDisconnected)
+	}
+}